@@ -0,0 +1,327 @@
+// Package alloc allocates blocks of space in a segment store so that space
+// used by deleted records can be reused. Block sizes are rounded up to a power
+// of two (size class) and freed blocks are kept in a free list for each class.
+// Blocks are aligned to their size so freed offsets can be validated.
+//
+// When Sync is called, free lists are written to a block allocated in the
+// store and its location is persisted in a metadata file together with the
+// end of the allocated space. Changes after the last Sync are lost on a crash
+// so sync the allocator together with the data which references the blocks.
+package alloc
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"github.com/kadirahq/go-tools/segments"
+	"github.com/kadirahq/go-tools/segments/segfile"
+)
+
+const (
+	// metadata key used to store the end of allocated space
+	endKey = "alloc.end"
+
+	// metadata keys used to store the offset and the size
+	// of the block which has free lists written by Sync
+	listKey  = "alloc.list"
+	lsizeKey = "alloc.list.size"
+
+	// smallest block size is 1 << minShift bytes
+	minShift = 4
+
+	// largest block size is 1 << maxShift bytes
+	maxShift = 30
+
+	// number of size classes
+	nclasses = maxShift - minShift + 1
+
+	// free list blocks start with the number of offsets in each class
+	lheadSize = 4 * nclasses
+)
+
+var (
+	// ErrSize is returned when the block size is zero or too large.
+	ErrSize = errors.New("invalid block size")
+
+	// ErrFree is returned when freeing a block which was not allocated.
+	ErrFree = errors.New("invalid block offset")
+
+	// ErrList is returned when free lists in the store are not valid.
+	ErrList = errors.New("invalid free list block")
+)
+
+// Allocator allocates blocks in a segment store. All methods are safe to use
+// concurrently. Blocks should only be freed once with the size used to
+// allocate them.
+type Allocator struct {
+	store segments.Store
+	meta  *segfile.Metadata
+	end   int64
+	free  [nclasses][]int64
+	freed map[int64]bool
+	list  int64
+	lsize int64
+	dirty bool
+	mutex sync.Mutex
+	syncm sync.Mutex
+}
+
+// New creates an allocator for the store. Free lists are loaded from the
+// block recorded in the metadata and written to a new block by Sync.
+func New(s segments.Store, m *segfile.Metadata) (a *Allocator, err error) {
+	a = &Allocator{store: s, meta: m, list: -1, freed: map[int64]bool{}}
+	a.end, _ = m.Int(endKey)
+
+	list, ok := m.Int(listKey)
+	if !ok {
+		return a, nil
+	}
+
+	lsize, _ := m.Int(lsizeKey)
+	if bsize, err := BlockSize(lsize); err != nil || bsize != lsize || lsize < lheadSize {
+		return nil, ErrList
+	} else if list < 0 || list%lsize != 0 || list+lsize > a.end {
+		return nil, ErrList
+	}
+
+	data := make([]byte, lsize)
+	if _, err := s.ReadAt(data, list); err != nil {
+		return nil, err
+	}
+
+	if err := a.decode(data); err != nil {
+		return nil, err
+	}
+
+	a.list, a.lsize = list, lsize
+	return a, nil
+}
+
+// Alloc allocates a block which can hold given number of bytes and returns
+// its offset. Freed blocks are reused before allocating more space.
+func (a *Allocator) Alloc(size int64) (off int64, err error) {
+	c, bsize, err := class(size)
+	if err != nil {
+		return 0, err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.alloc(c, bsize)
+}
+
+// Free releases a block so that it can be allocated again. The size should
+// be the same size used to allocate the block. ErrFree is returned if the
+// offset is not an allocated block of that size or if it's already free.
+func (a *Allocator) Free(off, size int64) (err error) {
+	c, bsize, err := class(size)
+	if err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if off < 0 || off%bsize != 0 || off+bsize > a.end || a.freed[off] {
+		return ErrFree
+	}
+
+	// the block with persisted free lists is not allocated by users
+	if a.list >= 0 && off < a.list+a.lsize && off+bsize > a.list {
+		return ErrFree
+	}
+
+	a.push(c, off)
+	a.dirty = true
+	return nil
+}
+
+// Size returns the size of the space allocated from the store.
+// This includes blocks which are in free lists.
+func (a *Allocator) Size() (sz int64) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.end
+}
+
+// Sync writes free lists to a new block in the store and syncs the store
+// before recording the block in the metadata. The previous free list block
+// is reused after the metadata is synced.
+func (a *Allocator) Sync() (err error) {
+	a.syncm.Lock()
+	defer a.syncm.Unlock()
+
+	a.mutex.Lock()
+	prev, psize, err := a.write()
+	end, list, lsize := a.end, a.list, a.lsize
+	a.mutex.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	if err := a.store.Sync(); err != nil {
+		return err
+	}
+
+	a.meta.SetInt(endKey, end)
+	if list >= 0 {
+		a.meta.SetInt(listKey, list)
+		a.meta.SetInt(lsizeKey, lsize)
+	}
+
+	if err := a.meta.Sync(); err != nil {
+		return err
+	}
+
+	if prev >= 0 {
+		// the block is not marked dirty, it leaks on a crash
+		// until free lists are written again for other changes
+		c, _, _ := class(psize)
+		a.mutex.Lock()
+		a.push(c, prev)
+		a.mutex.Unlock()
+	}
+
+	return nil
+}
+
+// BlockSize returns the size of the block allocated for given size.
+func BlockSize(size int64) (bsize int64, err error) {
+	_, bsize, err = class(size)
+	return bsize, err
+}
+
+// alloc takes a block from the free list or from the end of allocated space.
+// The end is aligned to the block size and skipped space is added to smaller
+// free lists. The mutex should be locked when calling this.
+func (a *Allocator) alloc(c int, bsize int64) (off int64, err error) {
+	if n := len(a.free[c]); n > 0 {
+		off = a.free[c][n-1]
+		a.free[c] = a.free[c][:n-1]
+		delete(a.freed, off)
+		a.dirty = true
+		return off, nil
+	}
+
+	off = (a.end + bsize - 1) / bsize * bsize
+	if err := a.store.Ensure(off + bsize); err != nil {
+		return 0, err
+	}
+
+	for start := a.end; start < off; {
+		sc, ssize := 0, int64(1<<minShift)
+		for start%(ssize<<1) == 0 && start+(ssize<<1) <= off {
+			sc, ssize = sc+1, ssize<<1
+		}
+
+		a.push(sc, start)
+		a.dirty = true
+		start += ssize
+	}
+
+	a.end = off + bsize
+	return off, nil
+}
+
+// push adds a block to a free list
+func (a *Allocator) push(c int, off int64) {
+	a.free[c] = append(a.free[c], off)
+	a.freed[off] = true
+}
+
+// write writes free lists to a new block if they have changed and returns
+// the previous block. The mutex should be locked when calling this.
+func (a *Allocator) write() (prev, psize int64, err error) {
+	if !a.dirty {
+		return -1, 0, nil
+	}
+
+	// allocating the block can add at most one block
+	// to each free list when aligning the end offset
+	n := int64(nclasses)
+	for c := range a.free {
+		n += int64(len(a.free[c]))
+	}
+
+	c, bsize, err := class(lheadSize + 8*n)
+	if err != nil {
+		return -1, 0, err
+	}
+
+	off, err := a.alloc(c, bsize)
+	if err != nil {
+		return -1, 0, err
+	}
+
+	if _, err := a.store.WriteAt(a.encode(), off); err != nil {
+		a.push(c, off)
+		return -1, 0, err
+	}
+
+	prev, psize = a.list, a.lsize
+	a.list, a.lsize = off, bsize
+	a.dirty = false
+	return prev, psize, nil
+}
+
+// class returns the size class and the block size for given size
+func class(size int64) (c int, bsize int64, err error) {
+	if size <= 0 || size > 1<<maxShift {
+		return 0, 0, ErrSize
+	}
+
+	for c, bsize = 0, 1<<minShift; bsize < size; c, bsize = c+1, bsize<<1 {
+	}
+
+	return c, bsize, nil
+}
+
+// encode encodes the number of blocks in each free list followed by
+// block offsets of all free lists as little endian integers
+func (a *Allocator) encode() (data []byte) {
+	enc := binary.LittleEndian
+	data = make([]byte, lheadSize, lheadSize+8*len(a.freed))
+
+	for c := range a.free {
+		enc.PutUint32(data[4*c:], uint32(len(a.free[c])))
+	}
+
+	for c := range a.free {
+		for _, off := range a.free[c] {
+			data = enc.AppendUint64(data, uint64(off))
+		}
+	}
+
+	return data
+}
+
+// decode decodes free lists encoded by the encode function.
+// Offsets are checked the same way as freed blocks. Bytes after
+// the encoded free lists are not used.
+func (a *Allocator) decode(data []byte) (err error) {
+	enc := binary.LittleEndian
+	rest := data[lheadSize:]
+
+	for c := range a.free {
+		n := int(enc.Uint32(data[4*c:]))
+		if 8*n > len(rest) {
+			return ErrList
+		}
+
+		bsize := int64(1) << uint(c+minShift)
+		for i := 0; i < n; i++ {
+			off := int64(enc.Uint64(rest[8*i:]))
+			if off < 0 || off%bsize != 0 || off+bsize > a.end || a.freed[off] {
+				return ErrList
+			}
+
+			a.push(c, off)
+		}
+
+		rest = rest[8*n:]
+	}
+
+	return nil
+}
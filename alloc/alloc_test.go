@@ -0,0 +1,200 @@
+package alloc
+
+import (
+	"os"
+	"testing"
+
+	"github.com/kadirahq/go-tools/segments/segfile"
+	"github.com/kadirahq/go-tools/segments/segmmap"
+)
+
+var (
+	tmpdir = "/tmp/test-alloc/"
+)
+
+func setup(t *testing.T) func() {
+	if err := os.RemoveAll(tmpdir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(tmpdir, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if err := os.RemoveAll(tmpdir); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func open(t *testing.T) (a *Allocator, close func()) {
+	s, err := segmmap.New(tmpdir+"seg_", 64, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := segfile.NewMetadata(tmpdir+"meta", 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err = New(s, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a, func() {
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := m.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBlockSize(t *testing.T) {
+	cases := map[int64]int64{1: 16, 16: 16, 17: 32, 100: 128, 1 << 30: 1 << 30}
+	for sz, exp := range cases {
+		if bsz, err := BlockSize(sz); err != nil || bsz != exp {
+			t.Fatal("wrong block size", sz, bsz, err)
+		}
+	}
+
+	for _, sz := range []int64{0, -1, 1<<30 + 1} {
+		if _, err := BlockSize(sz); err != ErrSize {
+			t.Fatal("expected ErrSize", sz)
+		}
+	}
+}
+
+func TestAllocFree(t *testing.T) {
+	defer setup(t)()
+	a, close := open(t)
+
+	offs := []int64{}
+	for i := 0; i < 4; i++ {
+		off, err := a.Alloc(20)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		offs = append(offs, off)
+	}
+
+	if offs[3] != 96 || a.Size() != 128 {
+		t.Fatal("wrong offsets", offs, a.Size())
+	}
+
+	if err := a.Free(offs[1], 20); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Free(128, 20); err != ErrFree {
+		t.Fatal("expected ErrFree")
+	}
+
+	// freed twice
+	if err := a.Free(offs[1], 20); err != ErrFree {
+		t.Fatal("expected ErrFree")
+	}
+
+	// not on a block boundary
+	if err := a.Free(16, 20); err != ErrFree {
+		t.Fatal("expected ErrFree")
+	}
+
+	// free lists are written to a 512 byte block
+	// space before it is added to free lists
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	close()
+	a, close = open(t)
+	defer close()
+
+	if a.Size() != 1024 {
+		t.Fatal("wrong size", a.Size())
+	}
+
+	// a different size class uses new space
+	if off, err := a.Alloc(8); err != nil || off != 1024 {
+		t.Fatal("wrong offset", off, err)
+	}
+
+	// the freed block is reused
+	if off, err := a.Alloc(32); err != nil || off != offs[1] {
+		t.Fatal("wrong offset", off, err)
+	}
+
+	// new blocks are aligned to their size
+	if off, err := a.Alloc(32); err != nil || off != 1056 {
+		t.Fatal("wrong offset", off, err)
+	}
+
+	// space skipped while aligning blocks is reused
+	if off, err := a.Alloc(100); err != nil || off != 128 {
+		t.Fatal("wrong offset", off, err)
+	}
+
+	if off, err := a.Alloc(16); err != nil || off != 1040 {
+		t.Fatal("wrong offset", off, err)
+	}
+}
+
+func TestManyFree(t *testing.T) {
+	defer setup(t)()
+	a, close := open(t)
+
+	offs := map[int64]bool{}
+	for i := 0; i < 1000; i++ {
+		off, err := a.Alloc(16)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		offs[off] = true
+	}
+
+	for off := range offs {
+		if err := a.Free(off, 16); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// free lists do not fit in the metadata file
+	for i := 0; i < 3; i++ {
+		if err := a.Sync(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	close()
+	a, close = open(t)
+	defer close()
+
+	size := a.Size()
+	for i := 0; i < 1000; i++ {
+		off, err := a.Alloc(16)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !offs[off] {
+			t.Fatal("should reuse freed blocks", off)
+		}
+
+		delete(offs, off)
+	}
+
+	if a.Size() != size {
+		t.Fatal("should not allocate more space")
+	}
+
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,174 @@
+// Package arena allocates short-lived buffers from large anonymous memory
+// maps. Memory allocated from an arena is not managed by the garbage collector
+// and all buffers are freed together with Reset or Release. Buffers must not be
+// used after the arena is reset or released and should not hold pointers to
+// memory managed by the garbage collector.
+package arena
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+var (
+	// ErrLimit is returned when an allocation would exceed the memory limit.
+	ErrLimit = errors.New("arena memory limit exceeded")
+
+	// ErrAlign is returned when the alignment is not a power of two.
+	ErrAlign = errors.New("alignment should be a power of two")
+
+	// ErrSize is returned when the allocation size is negative.
+	ErrSize = errors.New("allocation size should not be negative")
+)
+
+// chunk is an anonymous memory map
+type chunk struct {
+	data []byte
+	used int
+}
+
+// Arena hands out aligned slices of anonymous memory maps.
+// All methods are safe to use concurrently.
+type Arena struct {
+	csize  int
+	limit  int64
+	mapped int64
+	chunks []*chunk
+	curr   int
+	mutex  sync.Mutex
+}
+
+// New creates an arena which maps memory in chunks of given size. The total
+// size of memory maps will not exceed the limit. Use a limit of 0 for no limit.
+func New(chunkSize, limit int64) (a *Arena) {
+	return &Arena{
+		csize: roundPage(int(chunkSize)),
+		limit: limit,
+	}
+}
+
+// Alloc returns a zeroed buffer of given size with given alignment.
+// An alignment of 0 or 1 means the buffer does not need to be aligned.
+func (a *Arena) Alloc(size, align int) (p []byte, err error) {
+	if size < 0 {
+		return nil, ErrSize
+	}
+
+	if align <= 0 {
+		align = 1
+	}
+
+	if align&(align-1) != 0 || align > os.Getpagesize() {
+		return nil, ErrAlign
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for ; a.curr < len(a.chunks); a.curr++ {
+		if p := a.chunks[a.curr].alloc(size, align); p != nil {
+			return p, nil
+		}
+	}
+
+	// large buffers get a chunk of their own
+	csize := a.csize
+	if size > csize {
+		csize = roundPage(size)
+	}
+
+	if a.limit > 0 && a.mapped+int64(csize) > a.limit {
+		return nil, ErrLimit
+	}
+
+	data, err := mapAnon(csize)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &chunk{data: data}
+	a.chunks = append(a.chunks, c)
+	a.mapped += int64(csize)
+
+	return c.alloc(size, align), nil
+}
+
+// Reset frees all buffers but keeps memory maps to reuse them.
+// Reused memory is cleared before it's handed out again.
+func (a *Arena) Reset() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for _, c := range a.chunks {
+		for i := range c.data[:c.used] {
+			c.data[i] = 0
+		}
+
+		c.used = 0
+	}
+
+	a.curr = 0
+}
+
+// Release frees all buffers and unmaps all memory maps.
+// The arena can be used again after it's released.
+func (a *Arena) Release() (err error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for i, c := range a.chunks {
+		if err := unmapAnon(c.data); err != nil {
+			a.chunks = a.chunks[i:]
+			a.curr = 0
+			return err
+		}
+
+		a.mapped -= int64(len(c.data))
+	}
+
+	a.chunks = nil
+	a.curr = 0
+	return nil
+}
+
+// Used returns the number of bytes handed out (including alignment padding).
+func (a *Arena) Used() (n int64) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for _, c := range a.chunks {
+		n += int64(c.used)
+	}
+
+	return n
+}
+
+// Mapped returns the total size of memory maps in bytes.
+func (a *Arena) Mapped() (n int64) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.mapped
+}
+
+// alloc returns an aligned slice or nil if the chunk doesn't have space
+func (c *chunk) alloc(size, align int) (p []byte) {
+	start := (c.used + align - 1) &^ (align - 1)
+	end := start + size
+	if end > len(c.data) {
+		return nil
+	}
+
+	c.used = end
+	return c.data[start:end:end]
+}
+
+// roundPage rounds the size up to a multiple of the page size
+func roundPage(n int) int {
+	ps := os.Getpagesize()
+	if n <= 0 {
+		return ps
+	}
+
+	return (n + ps - 1) / ps * ps
+}
@@ -0,0 +1,94 @@
+package arena
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestAlloc(t *testing.T) {
+	a := New(4096, 0)
+	defer a.Release()
+
+	p, err := a.Alloc(3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := a.Alloc(100, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p) != 3 || len(q) != 100 || cap(q) != 100 {
+		t.Fatal("wrong size")
+	}
+
+	if uintptr(unsafe.Pointer(&q[0]))%64 != 0 {
+		t.Fatal("wrong alignment")
+	}
+
+	for i := range q {
+		if q[i] != 0 {
+			t.Fatal("expected zeroed memory")
+		}
+	}
+
+	if _, err := a.Alloc(10, 3); err != ErrAlign {
+		t.Fatal("expected ErrAlign")
+	}
+
+	if _, err := a.Alloc(-1, 0); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	// larger than a chunk
+	if _, err := a.Alloc(10000, 8); err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Mapped() != 4096+12288 {
+		t.Fatal("wrong mapped size")
+	}
+
+	if a.Used() != 64+100+10000 {
+		t.Fatal("wrong used size")
+	}
+}
+
+func TestReset(t *testing.T) {
+	a := New(4096, 4096)
+	defer a.Release()
+
+	p, err := a.Alloc(4096, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p[0] = 1
+
+	if _, err := a.Alloc(1, 0); err != ErrLimit {
+		t.Fatal("expected ErrLimit")
+	}
+
+	a.Reset()
+	if a.Used() != 0 || a.Mapped() != 4096 {
+		t.Fatal("wrong size after reset")
+	}
+
+	p, err = a.Alloc(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p[0] != 0 {
+		t.Fatal("expected zeroed memory")
+	}
+
+	if err := a.Release(); err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Mapped() != 0 {
+		t.Fatal("wrong size after release")
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package arena
+
+import "syscall"
+
+const (
+	mflag = syscall.MAP_ANON | syscall.MAP_PRIVATE
+	mprot = syscall.PROT_READ | syscall.PROT_WRITE
+)
+
+// mapAnon creates an anonymous memory map
+func mapAnon(size int) (data []byte, err error) {
+	return syscall.Mmap(-1, 0, size, mprot, mflag)
+}
+
+// unmapAnon removes an anonymous memory map
+func unmapAnon(data []byte) (err error) {
+	return syscall.Munmap(data)
+}
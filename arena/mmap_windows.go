@@ -0,0 +1,45 @@
+package arena
+
+import (
+	"os"
+	"reflect"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32     = syscall.NewLazyDLL("kernel32.dll")
+	virtualAlloc = kernel32.NewProc("VirtualAlloc")
+	virtualFree  = kernel32.NewProc("VirtualFree")
+)
+
+const (
+	memCommit  = 0x1000
+	memReserve = 0x2000
+	memRelease = 0x8000
+)
+
+// mapAnon allocates committed memory with VirtualAlloc
+func mapAnon(size int) (data []byte, err error) {
+	addr, _, e := virtualAlloc.Call(0, uintptr(size), memCommit|memReserve, syscall.PAGE_READWRITE)
+	if addr == 0 {
+		return nil, os.NewSyscallError("VirtualAlloc", e)
+	}
+
+	head := (*reflect.SliceHeader)(unsafe.Pointer(&data))
+	head.Data = addr
+	head.Len = size
+	head.Cap = size
+
+	return data, nil
+}
+
+// unmapAnon releases memory allocated with VirtualAlloc
+func unmapAnon(data []byte) (err error) {
+	addr := uintptr(unsafe.Pointer(&data[0]))
+	if r, _, e := virtualFree.Call(addr, 0, memRelease); r == 0 {
+		return os.NewSyscallError("VirtualFree", e)
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+// Package bench measures latencies and throughput of operations in running
+// applications. Latencies are recorded in histograms and throughput with
+// meters. A Suite groups named histograms and meters and creates reports
+// which can be printed as text or encoded as JSON.
+//
+//	s := bench.New()
+//	h := s.Latency("store.write")
+//	m := s.Meter("store.write")
+//
+//	start := time.Now()
+//	n, _ := store.Write(p)
+//	h.Since(start)
+//	m.Mark(int64(n))
+//
+//	s.Report().WriteText(os.Stdout)
+package bench
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// Meter counts operations and bytes to calculate throughput.
+type Meter struct {
+	ops   int64
+	bytes int64
+	start int64
+}
+
+// NewMeter creates a meter which starts measuring now.
+func NewMeter() (m *Meter) {
+	return &Meter{start: time.Now().UnixNano()}
+}
+
+// Mark records an operation which processed n bytes.
+func (m *Meter) Mark(n int64) {
+	atomic.AddInt64(&m.ops, 1)
+	atomic.AddInt64(&m.bytes, n)
+}
+
+// Rates has throughput values calculated by a meter.
+type Rates struct {
+	Ops         int64         `json:"ops"`
+	Bytes       int64         `json:"bytes"`
+	Elapsed     time.Duration `json:"elapsed"`
+	OpsPerSec   float64       `json:"opsPerSec"`
+	BytesPerSec float64       `json:"bytesPerSec"`
+}
+
+// Rates calculates throughput since the meter was created or reset.
+func (m *Meter) Rates() (r Rates) {
+	r.Ops = atomic.LoadInt64(&m.ops)
+	r.Bytes = atomic.LoadInt64(&m.bytes)
+	r.Elapsed = time.Duration(time.Now().UnixNano() - atomic.LoadInt64(&m.start))
+
+	if secs := r.Elapsed.Seconds(); secs > 0 {
+		r.OpsPerSec = float64(r.Ops) / secs
+		r.BytesPerSec = float64(r.Bytes) / secs
+	}
+
+	return r
+}
+
+// Reset clears counters and starts measuring again.
+func (m *Meter) Reset() {
+	atomic.StoreInt64(&m.ops, 0)
+	atomic.StoreInt64(&m.bytes, 0)
+	atomic.StoreInt64(&m.start, time.Now().UnixNano())
+}
+
+// Suite is a collection of named histograms and meters.
+type Suite struct {
+	hists  map[string]*Histogram
+	meters map[string]*Meter
+	mutex  sync.Mutex
+}
+
+// New creates an empty suite.
+func New() (s *Suite) {
+	return &Suite{
+		hists:  map[string]*Histogram{},
+		meters: map[string]*Meter{},
+	}
+}
+
+// Latency returns the histogram with given name creating it if necessary.
+func (s *Suite) Latency(name string) (h *Histogram) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if h, ok := s.hists[name]; ok {
+		return h
+	}
+
+	h = NewHistogram()
+	s.hists[name] = h
+	return h
+}
+
+// Meter returns the meter with given name creating it if necessary.
+func (s *Suite) Meter(name string) (m *Meter) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if m, ok := s.meters[name]; ok {
+		return m
+	}
+
+	m = NewMeter()
+	s.meters[name] = m
+	return m
+}
+
+// Reset resets all histograms and meters.
+func (s *Suite) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, h := range s.hists {
+		h.Reset()
+	}
+
+	for _, m := range s.meters {
+		m.Reset()
+	}
+}
+
+// Report has stats of all histograms and meters in a suite.
+// Use encoding/json to encode it as JSON.
+type Report struct {
+	Latency    map[string]Stats `json:"latency"`
+	Throughput map[string]Rates `json:"throughput"`
+}
+
+// Report creates a report with current values.
+func (s *Suite) Report() (r *Report) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	r = &Report{
+		Latency:    make(map[string]Stats, len(s.hists)),
+		Throughput: make(map[string]Rates, len(s.meters)),
+	}
+
+	for name, h := range s.hists {
+		r.Latency[name] = h.Stats()
+	}
+
+	for name, m := range s.meters {
+		r.Throughput[name] = m.Rates()
+	}
+
+	return r
+}
+
+// WriteText writes the report as aligned text tables sorted by name.
+func (r *Report) WriteText(w io.Writer) (err error) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	if len(r.Latency) > 0 {
+		fmt.Fprintln(tw, "latency\tcount\tmin\tmean\tp50\tp90\tp99\tp99.9\tmax")
+		names := make([]string, 0, len(r.Latency))
+		for name := range r.Latency {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+		for _, name := range names {
+			s := r.Latency[name]
+			fmt.Fprintf(tw, "%s\t%d\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", name, s.Count,
+				s.Min, s.Mean, s.P50, s.P90, s.P99, s.P999, s.Max)
+		}
+	}
+
+	if len(r.Throughput) > 0 {
+		if len(r.Latency) > 0 {
+			fmt.Fprintln(tw)
+		}
+
+		fmt.Fprintln(tw, "throughput\tops\tbytes\tops/s\tbytes/s")
+		names := make([]string, 0, len(r.Throughput))
+		for name := range r.Throughput {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+		for _, name := range names {
+			t := r.Throughput[name]
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%.1f\n", name, t.Ops, t.Bytes,
+				t.OpsPerSec, t.BytesPerSec)
+		}
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,71 @@
+package bench
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMeter(t *testing.T) {
+	m := NewMeter()
+	m.Mark(10)
+	m.Mark(20)
+
+	r := m.Rates()
+	if r.Ops != 2 || r.Bytes != 30 || r.OpsPerSec <= 0 {
+		t.Fatal("wrong rates")
+	}
+
+	m.Reset()
+	if r := m.Rates(); r.Ops != 0 || r.Bytes != 0 {
+		t.Fatal("wrong rates after reset")
+	}
+}
+
+func TestSuite(t *testing.T) {
+	s := New()
+	if s.Latency("a") != s.Latency("a") || s.Meter("a") != s.Meter("a") {
+		t.Fatal("expected same values")
+	}
+
+	s.Latency("a").Record(time.Millisecond)
+	s.Latency("b").Time(func() {})
+	s.Meter("a").Mark(100)
+
+	r := s.Report()
+	if r.Latency["a"].Count != 1 || r.Latency["b"].Count != 1 || r.Throughput["a"].Bytes != 100 {
+		t.Fatal("wrong report")
+	}
+
+	buf := &bytes.Buffer{}
+	if err := r.WriteText(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "latency") || !strings.Contains(out, "throughput") ||
+		strings.Index(out, "\na ") > strings.Index(out, "\nb ") {
+		t.Fatal("wrong text report")
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := &Report{}
+	if err := json.Unmarshal(data, res); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Latency["a"] != r.Latency["a"] {
+		t.Fatal("wrong json report")
+	}
+
+	s.Reset()
+	if r := s.Report(); r.Latency["a"].Count != 0 {
+		t.Fatal("expected empty report")
+	}
+}
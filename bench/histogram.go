@@ -0,0 +1,189 @@
+package bench
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// values are stored with subBits bits of precision (error < 1/64)
+	subBits  = 7
+	subCount = 1 << subBits
+	subHalf  = subCount / 2
+
+	// number of buckets required for all positive int64 values
+	nbuckets = (64-subBits)*subHalf + subCount
+)
+
+// Histogram records durations in logarithmic buckets with linear sub-buckets
+// (similar to HDR histograms). Recorded values have a relative error of less
+// than 2%. Recording is lock free and does not allocate.
+type Histogram struct {
+	counts [nbuckets]uint64
+	count  uint64
+	sum    uint64
+	min    int64
+	max    int64
+}
+
+// NewHistogram creates an empty histogram.
+func NewHistogram() (h *Histogram) {
+	return &Histogram{min: math.MaxInt64}
+}
+
+// Record adds a duration to the histogram. Negative values are recorded as 0.
+func (h *Histogram) Record(d time.Duration) {
+	v := int64(d)
+	if v < 0 {
+		v = 0
+	}
+
+	atomic.AddUint64(&h.counts[index(v)], 1)
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddUint64(&h.sum, uint64(v))
+
+	for min := atomic.LoadInt64(&h.min); v < min; min = atomic.LoadInt64(&h.min) {
+		if atomic.CompareAndSwapInt64(&h.min, min, v) {
+			break
+		}
+	}
+
+	for max := atomic.LoadInt64(&h.max); v > max; max = atomic.LoadInt64(&h.max) {
+		if atomic.CompareAndSwapInt64(&h.max, max, v) {
+			break
+		}
+	}
+}
+
+// Since records the time elapsed since given time.
+func (h *Histogram) Since(start time.Time) {
+	h.Record(time.Since(start))
+}
+
+// Time runs the function and records how long it took.
+func (h *Histogram) Time(fn func()) {
+	start := time.Now()
+	fn()
+	h.Since(start)
+}
+
+// Reset removes all recorded values. Values recorded while resetting
+// may be partially removed.
+func (h *Histogram) Reset() {
+	for i := range h.counts {
+		atomic.StoreUint64(&h.counts[i], 0)
+	}
+
+	atomic.StoreUint64(&h.count, 0)
+	atomic.StoreUint64(&h.sum, 0)
+	atomic.StoreInt64(&h.min, math.MaxInt64)
+	atomic.StoreInt64(&h.max, 0)
+}
+
+// Stats has a summary of values in a histogram.
+type Stats struct {
+	Count int64         `json:"count"`
+	Min   time.Duration `json:"min"`
+	Max   time.Duration `json:"max"`
+	Mean  time.Duration `json:"mean"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	P999  time.Duration `json:"p999"`
+}
+
+// Stats calculates a summary of recorded values.
+func (h *Histogram) Stats() (s Stats) {
+	count := atomic.LoadUint64(&h.count)
+	if count == 0 {
+		return s
+	}
+
+	s.Count = int64(count)
+	s.Min = time.Duration(atomic.LoadInt64(&h.min))
+	s.Max = time.Duration(atomic.LoadInt64(&h.max))
+	s.Mean = time.Duration(atomic.LoadUint64(&h.sum) / count)
+	s.P50 = h.Quantile(0.5)
+	s.P90 = h.Quantile(0.9)
+	s.P99 = h.Quantile(0.99)
+	s.P999 = h.Quantile(0.999)
+
+	return s
+}
+
+// Quantile returns the value below which given fraction of values are.
+// The quantile should be between 0 and 1 (ex. 0.99 for the 99th percentile).
+func (h *Histogram) Quantile(q float64) (d time.Duration) {
+	total := uint64(0)
+	for i := range h.counts {
+		total += atomic.LoadUint64(&h.counts[i])
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(q * float64(total)))
+	if rank == 0 {
+		rank = 1
+	}
+
+	seen := uint64(0)
+	for i := range h.counts {
+		seen += atomic.LoadUint64(&h.counts[i])
+		if seen >= rank {
+			v := upper(i)
+			if max := atomic.LoadInt64(&h.max); v > max {
+				v = max
+			}
+
+			return time.Duration(v)
+		}
+	}
+
+	return time.Duration(atomic.LoadInt64(&h.max))
+}
+
+// Merge adds all values recorded in another histogram to this histogram.
+func (h *Histogram) Merge(o *Histogram) {
+	for i := range o.counts {
+		if n := atomic.LoadUint64(&o.counts[i]); n > 0 {
+			atomic.AddUint64(&h.counts[i], n)
+		}
+	}
+
+	atomic.AddUint64(&h.count, atomic.LoadUint64(&o.count))
+	atomic.AddUint64(&h.sum, atomic.LoadUint64(&o.sum))
+
+	if min := atomic.LoadInt64(&o.min); min < atomic.LoadInt64(&h.min) {
+		atomic.StoreInt64(&h.min, min)
+	}
+
+	if max := atomic.LoadInt64(&o.max); max > atomic.LoadInt64(&h.max) {
+		atomic.StoreInt64(&h.max, max)
+	}
+}
+
+// index returns the bucket index for a value
+func index(v int64) int {
+	msb := bits.Len64(uint64(v))
+	if msb <= subBits {
+		return int(v)
+	}
+
+	shift := msb - subBits
+	return shift*subHalf + int(v>>uint(shift))
+}
+
+// upper returns the largest value which is stored in the bucket
+func upper(i int) int64 {
+	if i < subCount {
+		return int64(i)
+	}
+
+	shift := i/subHalf - 1
+	sub := int64(i - shift*subHalf)
+	return (sub+1)<<uint(shift) - 1
+}
@@ -0,0 +1,65 @@
+package bench
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIndex(t *testing.T) {
+	vals := []int64{0, 1, 127, 128, 129, 255, 256, 1000, 1e6, 1e9, 1 << 62, 1<<63 - 1}
+	for _, v := range vals {
+		i := index(v)
+		if i < 0 || i >= nbuckets {
+			t.Fatal("wrong index")
+		}
+
+		if u := upper(i); u < v || float64(u-v) > float64(v)/64+1 {
+			t.Fatal("wrong bucket")
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Microsecond)
+	}
+
+	s := h.Stats()
+	if s.Count != 1000 || s.Min != time.Microsecond || s.Max != time.Millisecond {
+		t.Fatal("wrong stats")
+	}
+
+	check := func(got, exp time.Duration) {
+		if got < exp || got > exp+exp/50 {
+			t.Fatal("wrong quantile", got, exp)
+		}
+	}
+
+	check(s.P50, 500*time.Microsecond)
+	check(s.P90, 900*time.Microsecond)
+	check(s.P99, 990*time.Microsecond)
+	check(s.Mean, 500*time.Microsecond)
+
+	o := NewHistogram()
+	o.Record(time.Second)
+	h.Merge(o)
+
+	if s := h.Stats(); s.Count != 1001 || s.Max != time.Second {
+		t.Fatal("wrong stats after merge")
+	}
+
+	h.Reset()
+	if s := h.Stats(); s.Count != 0 || h.Quantile(0.5) != 0 {
+		t.Fatal("expected empty histogram")
+	}
+}
+
+func BenchmarkRecord(b *testing.B) {
+	h := NewHistogram()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			h.Record(time.Millisecond)
+		}
+	})
+}
@@ -0,0 +1,156 @@
+// Package bitset implements a fixed size set of bits stored in a memory
+// mapped file. It can be used to persist existence indexes (ex. which
+// records are deleted) without loading them into memory.
+package bitset
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"sync"
+
+	"github.com/kadirahq/go-tools/memmap"
+)
+
+const (
+	// header has the magic number and the number of bits
+	headSize = 16
+
+	// magic number used to check whether the file is initialized
+	magic = 0x3154455354494b47
+)
+
+var (
+	// ErrIndex is returned when the bit index is out of range.
+	ErrIndex = errors.New("bit index out of range")
+
+	// ErrSize is returned when the number of bits is zero.
+	ErrSize = errors.New("invalid number of bits")
+
+	// ErrLayout is returned when the file was created with a different size.
+	ErrLayout = errors.New("bitset file has a different layout")
+)
+
+// Bitset is a set of bits in a memory map. All methods are safe to use
+// concurrently. All bits are cleared when the file is created.
+type Bitset struct {
+	mmap  *memmap.Map
+	bits  []byte
+	nbits uint64
+	mutex sync.RWMutex
+}
+
+// Open opens or creates a bitset file on given path with n bits.
+// Opening an existing file with a different size returns ErrLayout.
+func Open(path string, n uint64) (b *Bitset, err error) {
+	if n == 0 {
+		return nil, ErrSize
+	}
+
+	// bits are stored in 64 bit words
+	nbytes := (n + 63) / 64 * 8
+
+	mmap, err := memmap.New(path, headSize+int64(nbytes))
+	if err == memmap.ErrBadSz {
+		return nil, ErrLayout
+	} else if err != nil {
+		return nil, err
+	}
+
+	enc := binary.LittleEndian
+	data := mmap.Data
+
+	if enc.Uint64(data[0:]) != magic {
+		enc.PutUint64(data[8:], n)
+		enc.PutUint64(data[0:], magic)
+	} else if enc.Uint64(data[8:]) != n {
+		mmap.Close()
+		return nil, ErrLayout
+	}
+
+	b = &Bitset{
+		mmap:  mmap,
+		bits:  data[headSize:],
+		nbits: n,
+	}
+
+	return b, nil
+}
+
+// Len returns the number of bits in the set.
+func (b *Bitset) Len() (n uint64) {
+	return b.nbits
+}
+
+// Set sets the bit at given index.
+func (b *Bitset) Set(i uint64) (err error) {
+	if i >= b.nbits {
+		return ErrIndex
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.bits[i/8] |= 1 << (i % 8)
+	return nil
+}
+
+// Clear clears the bit at given index.
+func (b *Bitset) Clear(i uint64) (err error) {
+	if i >= b.nbits {
+		return ErrIndex
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.bits[i/8] &^= 1 << (i % 8)
+	return nil
+}
+
+// Test returns whether the bit at given index is set.
+func (b *Bitset) Test(i uint64) (ok bool, err error) {
+	if i >= b.nbits {
+		return false, ErrIndex
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return b.bits[i/8]&(1<<(i%8)) != 0, nil
+}
+
+// Count returns the number of bits which are set.
+func (b *Bitset) Count() (n uint64) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return Count(b.bits)
+}
+
+// Sync writes changes to the disk.
+func (b *Bitset) Sync() (err error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.mmap.Sync()
+}
+
+// Close unmaps the bitset file.
+func (b *Bitset) Close() (err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.mmap.Close()
+}
+
+// Count returns the number of bits which are set in the byte slice.
+func Count(p []byte) (n uint64) {
+	for len(p) >= 8 {
+		n += uint64(bits.OnesCount64(binary.LittleEndian.Uint64(p)))
+		p = p[8:]
+	}
+
+	for _, c := range p {
+		n += uint64(bits.OnesCount8(c))
+	}
+
+	return n
+}
@@ -0,0 +1,91 @@
+package bitset
+
+import (
+	"os"
+	"testing"
+)
+
+var (
+	tmpfile = "/tmp/test-bitset"
+)
+
+func setup(t *testing.T) func() {
+	if err := os.RemoveAll(tmpfile); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if err := os.RemoveAll(tmpfile); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBitset(t *testing.T) {
+	defer setup(t)()
+
+	if _, err := Open(tmpfile, 0); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	b, err := Open(tmpfile, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, i := range []uint64{0, 7, 8, 63, 64, 99} {
+		if err := b.Set(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := b.Set(100); err != ErrIndex {
+		t.Fatal("expected ErrIndex")
+	}
+
+	if err := b.Clear(8); err != nil {
+		t.Fatal(err)
+	}
+
+	if b.Count() != 5 {
+		t.Fatal("wrong count")
+	}
+
+	if err := b.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(tmpfile, 200); err != ErrLayout {
+		t.Fatal("expected ErrLayout")
+	}
+
+	b, err = Open(tmpfile, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer b.Close()
+
+	for i := uint64(0); i < 100; i++ {
+		ok, err := b.Test(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		set := i == 0 || i == 7 || i == 63 || i == 64 || i == 99
+		if ok != set {
+			t.Fatal("wrong bit", i)
+		}
+	}
+}
+
+func TestCount(t *testing.T) {
+	p := []byte{0xff, 1, 0, 0, 0, 0, 0, 0x80, 3}
+	if Count(p) != 12 {
+		t.Fatal("wrong count")
+	}
+}
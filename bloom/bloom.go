@@ -0,0 +1,211 @@
+// Package bloom implements a bloom filter stored in a memory mapped file.
+// The filter size and the number of hash functions are calculated from the
+// expected number of keys and the false positive rate when it's created.
+// Use it in front of disk based indexes to avoid reads for absent keys.
+package bloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+	"sync"
+
+	"github.com/kadirahq/go-tools/bitset"
+	"github.com/kadirahq/go-tools/memmap"
+)
+
+const (
+	// header has the magic number, the number of bits and hash functions
+	headSize = 24
+
+	// magic number used to check whether the file is initialized
+	magic = 0x314d4f4f4c424b47
+)
+
+var (
+	// ErrParams is returned when the key count or false positive rate is wrong.
+	ErrParams = errors.New("invalid bloom filter parameters")
+
+	// ErrLayout is returned when the filter file has a different layout.
+	ErrLayout = errors.New("filter file has a different layout")
+)
+
+// Filter is a bloom filter in a memory map. All methods are safe to use
+// concurrently. Keys cannot be removed once they are added to the filter.
+type Filter struct {
+	mmap  *memmap.Map
+	bits  []byte
+	nbits uint64
+	nhash uint64
+	mutex sync.RWMutex
+}
+
+// Open opens or creates a bloom filter file on given path. The filter is
+// sized to hold n keys with given false positive rate (ex. 0.01).
+// Opening an existing file with different parameters returns ErrLayout.
+func Open(path string, n int64, rate float64) (f *Filter, err error) {
+	nbits, nhash, err := Params(n, rate)
+	if err != nil {
+		return nil, err
+	}
+
+	mmap, err := memmap.New(path, headSize+int64(nbits/8))
+	if err == memmap.ErrBadSz {
+		return nil, ErrLayout
+	} else if err != nil {
+		return nil, err
+	}
+
+	enc := binary.LittleEndian
+	data := mmap.Data
+
+	if enc.Uint64(data[0:]) != magic {
+		enc.PutUint64(data[8:], nbits)
+		enc.PutUint64(data[16:], nhash)
+		enc.PutUint64(data[0:], magic)
+	} else if enc.Uint64(data[8:]) != nbits || enc.Uint64(data[16:]) != nhash {
+		mmap.Close()
+		return nil, ErrLayout
+	}
+
+	f = &Filter{
+		mmap:  mmap,
+		bits:  data[headSize:],
+		nbits: nbits,
+		nhash: nhash,
+	}
+
+	return f, nil
+}
+
+// Params calculates the number of bits (rounded up to a multiple of 64) and
+// the number of hash functions for n keys with given false positive rate.
+func Params(n int64, rate float64) (nbits, nhash uint64, err error) {
+	if n <= 0 || rate <= 0 || rate >= 1 {
+		return 0, 0, ErrParams
+	}
+
+	m := math.Ceil(-float64(n) * math.Log(rate) / (math.Ln2 * math.Ln2))
+	nbits = (uint64(m) + 63) / 64 * 64
+
+	k := math.Round(float64(nbits) / float64(n) * math.Ln2)
+	if nhash = uint64(k); nhash < 1 {
+		nhash = 1
+	}
+
+	return nbits, nhash, nil
+}
+
+// Add adds a key to the filter.
+func (f *Filter) Add(key []byte) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.add(key)
+}
+
+// AddBatch adds multiple keys to the filter while holding the lock once.
+func (f *Filter) AddBatch(keys [][]byte) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, key := range keys {
+		f.add(key)
+	}
+}
+
+// Test returns false if the key is definitely not in the filter.
+// If it returns true, the key may be in the filter.
+func (f *Filter) Test(key []byte) (ok bool) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.test(key)
+}
+
+// TestBatch tests multiple keys while holding the lock once.
+// Results are in the same order as the keys.
+func (f *Filter) TestBatch(keys [][]byte) (res []bool) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	res = make([]bool, len(keys))
+	for i, key := range keys {
+		res[i] = f.test(key)
+	}
+
+	return res
+}
+
+// Rate estimates the current false positive rate using the fraction of
+// bits which are set. It increases as keys are added to the filter.
+func (f *Filter) Rate() (rate float64) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	set := float64(bitset.Count(f.bits)) / float64(f.nbits)
+	return math.Pow(set, float64(f.nhash))
+}
+
+// Count estimates the number of keys added to the filter using the number
+// of bits which are set. Adding the same key again is not counted.
+func (f *Filter) Count() (n int64) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	m := float64(f.nbits)
+	x := float64(bitset.Count(f.bits))
+	if x >= m {
+		return math.MaxInt64
+	}
+
+	est := -m / float64(f.nhash) * math.Log(1-x/m)
+	return int64(math.Round(est))
+}
+
+// Sync writes filter changes to the disk.
+func (f *Filter) Sync() (err error) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.mmap.Sync()
+}
+
+// Close unmaps the filter file.
+func (f *Filter) Close() (err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.mmap.Close()
+}
+
+// add sets all bits for the key
+func (f *Filter) add(key []byte) {
+	h1, h2 := hash(key)
+	for i := uint64(0); i < f.nhash; i++ {
+		bit := (h1 + i*h2) % f.nbits
+		f.bits[bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// test checks whether all bits for the key are set
+func (f *Filter) test(key []byte) (ok bool) {
+	h1, h2 := hash(key)
+	for i := uint64(0); i < f.nhash; i++ {
+		bit := (h1 + i*h2) % f.nbits
+		if f.bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hash calculates two hashes of a key used for double hashing
+func hash(key []byte) (h1, h2 uint64) {
+	a := fnv.New64a()
+	a.Write(key)
+
+	b := fnv.New64()
+	b.Write(key)
+
+	// make sure the second hash is odd so that it does not repeat
+	return a.Sum64(), b.Sum64() | 1
+}
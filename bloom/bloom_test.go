@@ -0,0 +1,108 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+)
+
+var (
+	tmpfile = "/tmp/test-bloom"
+)
+
+func setup(t *testing.T) func() {
+	if err := os.RemoveAll(tmpfile); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if err := os.RemoveAll(tmpfile); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func key(i int) []byte {
+	k := make([]byte, 8)
+	binary.LittleEndian.PutUint64(k, uint64(i))
+	return k
+}
+
+func TestParams(t *testing.T) {
+	nbits, nhash, err := Params(1000, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if nbits != 9600 || nhash != 7 {
+		t.Fatal("wrong params", nbits, nhash)
+	}
+
+	if _, _, err := Params(1000, 1); err != ErrParams {
+		t.Fatal("expected ErrParams")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	defer setup(t)()
+
+	f, err := Open(tmpfile, 1000, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = key(i)
+	}
+
+	f.AddBatch(keys[:500])
+	for _, k := range keys[500:] {
+		f.Add(k)
+	}
+
+	if err := f.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(tmpfile, 2000, 0.01); err != ErrLayout {
+		t.Fatal("expected ErrLayout")
+	}
+
+	f, err = Open(tmpfile, 1000, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	for i, ok := range f.TestBatch(keys) {
+		if !ok {
+			t.Fatal("missing key", i)
+		}
+	}
+
+	fp := 0
+	for i := 1000; i < 11000; i++ {
+		if f.Test(key(i)) {
+			fp++
+		}
+	}
+
+	// expected rate is 1% (100 keys)
+	if fp > 200 {
+		t.Fatal("too many false positives", fp)
+	}
+
+	if rate := f.Rate(); rate < 0.005 || rate > 0.02 {
+		t.Fatal("wrong rate estimate", rate)
+	}
+
+	if n := f.Count(); n < 950 || n > 1050 {
+		t.Fatal("wrong count estimate", n)
+	}
+}
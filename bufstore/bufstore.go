@@ -0,0 +1,194 @@
+// Package bufstore buffers small appends to a segment store. Data is written
+// to the store in chunks which are aligned to the chunk size so that a chunk
+// never spans two segments when the segment size is a multiple of the chunk
+// size. Buffered data is written when a chunk is full, periodically and when
+// data is committed. Concurrent Commit calls are grouped into a single sync.
+package bufstore
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kadirahq/go-tools/function"
+	"github.com/kadirahq/go-tools/segments"
+)
+
+var (
+	// ErrClosed is returned when writing to a closed writer.
+	ErrClosed = errors.New("writer is closed")
+
+	// ErrChunkSize is returned when the chunk size is not positive.
+	ErrChunkSize = errors.New("chunk size should be positive")
+)
+
+// Options for the buffered writer
+type Options struct {
+	// Size of chunks written to the store in bytes.
+	ChunkSize int64
+
+	// Buffered data is written to the store at this interval
+	// (without syncing it). If zero, it's only written when a chunk
+	// is full or when data is committed.
+	FlushInterval time.Duration
+
+	// Pending Commit calls are synced together at this interval.
+	CommitInterval time.Duration
+}
+
+// DefaultOptions is used when options are not given to New.
+var DefaultOptions = &Options{
+	ChunkSize:      64 * 1024,
+	FlushInterval:  100 * time.Millisecond,
+	CommitInterval: 10 * time.Millisecond,
+}
+
+// Writer appends data to a store through a buffer.
+// All methods are safe to use concurrently.
+type Writer struct {
+	store  segments.Store
+	csize  int64
+	buf    []byte
+	boff   int64
+	group  *function.AutoGroup
+	tick   *function.Ticker
+	closed bool
+	mutex  sync.Mutex
+}
+
+// New creates a writer which appends data to the store starting at offset.
+func New(s segments.Store, off int64, opts *Options) (w *Writer, err error) {
+	if opts == nil {
+		opts = DefaultOptions
+	}
+
+	if opts.ChunkSize <= 0 {
+		return nil, ErrChunkSize
+	}
+
+	w = &Writer{
+		store: s,
+		csize: opts.ChunkSize,
+		buf:   make([]byte, 0, opts.ChunkSize),
+		boff:  off,
+	}
+
+	w.group = function.NewAutoGroup(w.commit, opts.CommitInterval, 0)
+
+	if opts.FlushInterval > 0 {
+		w.tick = function.NewTicker(func() { w.Flush() }, opts.FlushInterval)
+		w.tick.Start()
+	}
+
+	return w, nil
+}
+
+// Write implements the io.Writer interface. Data is appended to the buffer
+// and full chunks are written to the store.
+func (w *Writer) Write(p []byte) (n int, err error) {
+	_, err = w.Append(p)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Append appends data to the buffer and returns its offset in the store.
+// The data is not on the disk until Commit returns.
+func (w *Writer) Append(p []byte) (off int64, err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return 0, ErrClosed
+	}
+
+	off = w.boff + int64(len(w.buf))
+
+	for len(p) > 0 {
+		// writes never cross a chunk boundary
+		limit := w.csize - w.boff%w.csize
+		n := int(limit) - len(w.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+
+		if int64(len(w.buf)) == limit {
+			if err := w.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return off, nil
+}
+
+// Offset returns the offset where the next append will be written.
+func (w *Writer) Offset() (off int64) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.boff + int64(len(w.buf))
+}
+
+// Flush writes buffered data to the store without syncing it.
+func (w *Writer) Flush() (err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.flush()
+}
+
+// Commit blocks until all data appended before calling it is on the disk.
+// Commit calls made by multiple goroutines are grouped into a single sync.
+func (w *Writer) Commit() (err error) {
+	return w.group.Run()
+}
+
+// Close writes and syncs buffered data. It does not close the store.
+func (w *Writer) Close() (err error) {
+	if w.tick != nil {
+		w.tick.Stop()
+	}
+
+	if err := w.group.Stop(); err != nil {
+		return err
+	}
+
+	if err := w.commit(); err != nil {
+		return err
+	}
+
+	w.mutex.Lock()
+	w.closed = true
+	w.mutex.Unlock()
+
+	return nil
+}
+
+// commit writes buffered data and syncs the store
+func (w *Writer) commit() (err error) {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	return w.store.Sync()
+}
+
+// flush writes buffered data to the store.
+// The buffer is kept if the write fails.
+func (w *Writer) flush() (err error) {
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	if _, err := w.store.WriteAt(w.buf, w.boff); err != nil {
+		return err
+	}
+
+	w.boff += int64(len(w.buf))
+	w.buf = w.buf[:0]
+	return nil
+}
@@ -0,0 +1,165 @@
+package bufstore
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kadirahq/go-tools/segments/segfile"
+)
+
+var (
+	tmpdir  = "/tmp/test-bufstore/"
+	tmpbase = tmpdir + "seg_"
+)
+
+func setup(t *testing.T) func() {
+	if err := os.RemoveAll(tmpdir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(tmpdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if err := os.RemoveAll(tmpdir); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// store wraps a segment store and records write sizes
+type store struct {
+	*segfile.Store
+	writes []int
+	mutex  sync.Mutex
+}
+
+func (s *store) WriteAt(p []byte, off int64) (n int, err error) {
+	s.mutex.Lock()
+	if off/8 != (off+int64(len(p))-1)/8 {
+		s.writes = append(s.writes, -1)
+	} else {
+		s.writes = append(s.writes, len(p))
+	}
+	s.mutex.Unlock()
+
+	return s.Store.WriteAt(p, off)
+}
+
+func TestAppend(t *testing.T) {
+	defer setup(t)()
+
+	fs, err := segfile.New(tmpbase, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer fs.Close()
+
+	s := &store{Store: fs}
+	w, err := New(s, 3, &Options{ChunkSize: 8, CommitInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if off, err := w.Append([]byte("abc")); err != nil || off != 3 {
+		t.Fatal("wrong offset")
+	}
+
+	if len(s.writes) != 0 {
+		t.Fatal("should buffer small writes")
+	}
+
+	if off, err := w.Append([]byte("0123456789")); err != nil || off != 6 {
+		t.Fatal("wrong offset")
+	}
+
+	if err := w.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Offset() != 16 {
+		t.Fatal("wrong offset")
+	}
+
+	for _, n := range s.writes {
+		if n < 0 {
+			t.Fatal("write crosses a chunk boundary")
+		}
+	}
+
+	p := make([]byte, 13)
+	if _, err := fs.ReadAt(p, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(p, []byte("abc0123456789")) {
+		t.Fatal("wrong data")
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Append([]byte("x")); err != ErrClosed {
+		t.Fatal("expected ErrClosed")
+	}
+}
+
+func TestCommit(t *testing.T) {
+	defer setup(t)()
+
+	fs, err := segfile.New(tmpbase, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer fs.Close()
+
+	w, err := New(fs, 0, &Options{ChunkSize: 64, FlushInterval: time.Millisecond, CommitInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, err := w.Write([]byte("0123456789")); err != nil {
+				t.Error(err)
+			}
+
+			if err := w.Commit(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+	if w.Offset() != 100 {
+		t.Fatal("wrong offset")
+	}
+
+	p := make([]byte, 100)
+	if _, err := fs.ReadAt(p, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(p, bytes.Repeat([]byte("0123456789"), 10)) {
+		t.Fatal("wrong data")
+	}
+}
+
+func TestOptions(t *testing.T) {
+	if _, err := New(nil, 0, &Options{}); err != ErrChunkSize {
+		t.Fatal("expected ErrChunkSize")
+	}
+}
@@ -0,0 +1,295 @@
+// Package cache is a disk backed key value cache. Values are stored in a
+// segment store and keys are kept in memory with the location of the value.
+// When the total size of values reaches the limit, least recently used values
+// are evicted. Values can have a time to live. The index is not persisted so
+// the cache starts empty every time it's opened.
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kadirahq/go-tools/alloc"
+	"github.com/kadirahq/go-tools/segments/segfile"
+	"github.com/kadirahq/go-tools/segments/segmmap"
+)
+
+var (
+	// ErrTooLarge is returned when the value is larger than the cache.
+	ErrTooLarge = errors.New("value is too large for the cache")
+
+	// ErrNotCache is returned when the directory has files which do not
+	// belong to a cache.
+	ErrNotCache = errors.New("directory is not a cache")
+)
+
+// Options for the cache
+type Options struct {
+	// Maximum size of all values in bytes. Values use space rounded up
+	// to the next power of two (minimum 16 bytes).
+	MaxSize int64
+
+	// Size of each segment file in bytes.
+	SegmentSize int64
+
+	// Default time to live for values. Zero means values do not expire.
+	TTL time.Duration
+}
+
+// DefaultOptions is used when options are not given to Open.
+var DefaultOptions = &Options{
+	MaxSize:     256 * 1024 * 1024,
+	SegmentSize: 16 * 1024 * 1024,
+}
+
+// entry is the location of a value in the store
+type entry struct {
+	key     string
+	off     int64
+	size    int64
+	bsize   int64
+	expires time.Time
+}
+
+// Cache is a disk backed LRU cache. All methods are safe to use concurrently.
+type Cache struct {
+	opts  *Options
+	store *segmmap.Store
+	meta  *segfile.Metadata
+	alloc *alloc.Allocator
+	items map[string]*list.Element
+	lru   *list.List
+	size  int64
+	mutex sync.Mutex
+}
+
+// Open creates a cache in given directory. Existing cache files in the
+// directory are removed because the index is only kept in memory. It returns
+// ErrNotCache if the directory has other files to avoid removing them.
+func Open(dir string, opts *Options) (c *Cache, err error) {
+	if opts == nil {
+		opts = DefaultOptions
+	}
+
+	if err := clean(dir); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	meta, err := segfile.NewMetadata(path.Join(dir, "meta"), 4096)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := segmmap.New(path.Join(dir, "seg_"), opts.SegmentSize, false)
+	if err != nil {
+		meta.Close()
+		return nil, err
+	}
+
+	a, err := alloc.New(store, meta)
+	if err != nil {
+		store.Close()
+		meta.Close()
+		return nil, err
+	}
+
+	c = &Cache{
+		opts:  opts,
+		store: store,
+		meta:  meta,
+		alloc: a,
+		items: map[string]*list.Element{},
+		lru:   list.New(),
+	}
+
+	return c, nil
+}
+
+// Get returns a copy of the value stored with given key.
+// The boolean result will be false if the key does not exist.
+func (c *Cache) Get(key string) (val []byte, ok bool, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	e := el.Value.(*entry)
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		return nil, false, c.remove(el)
+	}
+
+	val = make([]byte, e.size)
+	if _, err := c.store.ReadAt(val, e.off); err != nil {
+		return nil, false, err
+	}
+
+	c.lru.MoveToFront(el)
+	return val, true, nil
+}
+
+// Put stores the value with given key using the default time to live.
+func (c *Cache) Put(key string, val []byte) (err error) {
+	return c.PutTTL(key, val, c.opts.TTL)
+}
+
+// PutTTL stores the value with given key. The value expires after given
+// duration. If the duration is zero, the value does not expire.
+func (c *Cache) PutTTL(key string, val []byte, ttl time.Duration) (err error) {
+	size := int64(len(val))
+	if size == 0 {
+		// allocate space even for empty values to keep the code simple
+		size = 1
+	}
+
+	bsize, err := alloc.BlockSize(size)
+	if err != nil || bsize > c.opts.MaxSize {
+		return ErrTooLarge
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		if err := c.remove(el); err != nil {
+			return err
+		}
+	}
+
+	for c.size+bsize > c.opts.MaxSize {
+		if err := c.remove(c.lru.Back()); err != nil {
+			return err
+		}
+	}
+
+	off, err := c.alloc.Alloc(size)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.store.WriteAt(val, off); err != nil {
+		c.alloc.Free(off, size)
+		return err
+	}
+
+	e := &entry{key: key, off: off, size: int64(len(val)), bsize: bsize}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+
+	c.items[key] = c.lru.PushFront(e)
+	c.size += bsize
+
+	return nil
+}
+
+// Delete removes the value stored with given key.
+func (c *Cache) Delete(key string) (err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		return c.remove(el)
+	}
+
+	return nil
+}
+
+// Len returns the number of values in the cache (including expired values
+// which are not removed yet).
+func (c *Cache) Len() (n int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.items)
+}
+
+// Size returns the space used by values in bytes.
+func (c *Cache) Size() (sz int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.size
+}
+
+// Close closes the cache. Data in the directory is removed when it's opened.
+func (c *Cache) Close() (err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.store.Close(); err != nil {
+		return err
+	}
+
+	return c.meta.Close()
+}
+
+// remove removes an entry and frees its space
+func (c *Cache) remove(el *list.Element) (err error) {
+	e := el.Value.(*entry)
+
+	size := e.size
+	if size == 0 {
+		size = 1
+	}
+
+	if err := c.alloc.Free(e.off, size); err != nil {
+		return err
+	}
+
+	c.lru.Remove(el)
+	delete(c.items, e.key)
+	c.size -= e.bsize
+
+	return nil
+}
+
+// clean removes cache files from the directory if it exists
+func clean(dir string) (err error) {
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, info := range infos {
+		if !isCacheFile(info) {
+			return ErrNotCache
+		}
+	}
+
+	for _, info := range infos {
+		if err := os.Remove(path.Join(dir, info.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isCacheFile checks whether the file is created by the cache
+func isCacheFile(info os.FileInfo) bool {
+	if info.IsDir() {
+		return false
+	}
+
+	switch name := info.Name(); name {
+	case "meta", "seg_header", "seg_lock", "seg_sums":
+		return true
+	default:
+		_, err := strconv.ParseUint(strings.TrimPrefix(name, "seg_"), 10, 64)
+		return strings.HasPrefix(name, "seg_") && err == nil
+	}
+}
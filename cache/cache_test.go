@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+var (
+	tmpdir = "/tmp/test-cache"
+)
+
+func open(t *testing.T, ttl time.Duration) (c *Cache, close func()) {
+	c, err := Open(tmpdir, &Options{MaxSize: 64, SegmentSize: 32, TTL: ttl})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c, func() {
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.RemoveAll(tmpdir); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func get(t *testing.T, c *Cache, key string) []byte {
+	val, ok, err := c.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ok {
+		return nil
+	}
+
+	return val
+}
+
+func TestGetPut(t *testing.T) {
+	c, close := open(t, 0)
+	defer close()
+
+	if err := c.Put("a", []byte("value-a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Put("b", []byte("value-b")); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(get(t, c, "a"), []byte("value-a")) {
+		t.Fatal("wrong value")
+	}
+
+	// replacing a value reuses space
+	if err := c.Put("b", []byte("new-b")); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(get(t, c, "b"), []byte("new-b")) || c.Size() != 32 {
+		t.Fatal("wrong value", c.Size())
+	}
+
+	if err := c.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if get(t, c, "a") != nil || c.Len() != 1 {
+		t.Fatal("value was not deleted")
+	}
+
+	if err := c.Put("big", make([]byte, 65)); err != ErrTooLarge {
+		t.Fatal("expected ErrTooLarge")
+	}
+}
+
+func TestEvict(t *testing.T) {
+	c, close := open(t, 0)
+	defer close()
+
+	for _, k := range []string{"a", "b", "c", "d"} {
+		if err := c.Put(k, []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// "a" becomes the most recently used value
+	get(t, c, "a")
+
+	if err := c.Put("e", make([]byte, 20)); err != nil {
+		t.Fatal(err)
+	}
+
+	for k, exp := range map[string]bool{"a": true, "b": false, "c": false, "d": true, "e": true} {
+		if (get(t, c, k) != nil) != exp {
+			t.Fatal("wrong eviction", k)
+		}
+	}
+
+	if c.Size() > 64 {
+		t.Fatal("cache is too large", c.Size())
+	}
+}
+
+func TestTTL(t *testing.T) {
+	c, close := open(t, 10*time.Millisecond)
+	defer close()
+
+	c.Put("a", []byte("a"))
+	c.PutTTL("b", []byte("b"), 0)
+
+	time.Sleep(20 * time.Millisecond)
+
+	if get(t, c, "a") != nil {
+		t.Fatal("value did not expire")
+	}
+
+	if get(t, c, "b") == nil {
+		t.Fatal("value without ttl expired")
+	}
+}
+
+func TestOpenNotCache(t *testing.T) {
+	c, close := open(t, 0)
+	if err := c.Put("foo", []byte("bar")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// cache files are removed when opening again
+	c, close = open(t, 0)
+	defer close()
+
+	if get(t, c, "foo") != nil {
+		t.Fatal("cache should be empty")
+	}
+
+	other := path.Join(tmpdir, "other")
+	if err := ioutil.WriteFile(other, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(tmpdir, &Options{MaxSize: 64, SegmentSize: 32}); err != ErrNotCache {
+		t.Fatal("expected ErrNotCache", err)
+	}
+
+	if _, err := os.Stat(other); err != nil {
+		t.Fatal("other files should not be removed", err)
+	}
+}
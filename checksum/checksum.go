@@ -0,0 +1,76 @@
+// Package checksum has the checksum functions used by storage packages.
+// CRC32-C uses the crc32 package from the standard library which uses SSE4.2
+// instructions on amd64 and CRC32 instructions on arm64 when available.
+// XXH64 is a fast non-cryptographic hash implemented in pure Go.
+package checksum
+
+import (
+	"errors"
+	"hash"
+	"hash/crc32"
+)
+
+var (
+	// ErrSelfTest is returned when a checksum function gives wrong results.
+	ErrSelfTest = errors.New("checksum self test failed")
+)
+
+// castagnoli is the crc32 table for the Castagnoli polynomial
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// CRC32C calculates the CRC32-C (Castagnoli) checksum of the data.
+func CRC32C(p []byte) uint32 {
+	return crc32.Checksum(p, castagnoli)
+}
+
+// UpdateCRC32C returns the checksum after adding the data to it.
+func UpdateCRC32C(crc uint32, p []byte) uint32 {
+	return crc32.Update(crc, castagnoli, p)
+}
+
+// NewCRC32C creates a streaming CRC32-C hash.
+func NewCRC32C() hash.Hash32 {
+	return crc32.New(castagnoli)
+}
+
+// test vectors with known checksums
+var vectors = []struct {
+	data  string
+	crc   uint32
+	xxh64 uint64
+}{
+	{"", 0x00000000, 0xef46db3751d8e999},
+	{"a", 0xc1d04330, 0xd24ec4f1a98c6e5b},
+	{"abc", 0x364b3fb7, 0x44bc2cf5ad770999},
+	{"123456789", 0xe3069283, 0x8cb841db40e6ae83},
+}
+
+// SelfTest checks checksum functions with known values. Storage packages
+// can call this when they start to make sure checksums can be trusted.
+func SelfTest() (err error) {
+	for _, v := range vectors {
+		p := []byte(v.data)
+
+		if CRC32C(p) != v.crc {
+			return ErrSelfTest
+		}
+
+		h := NewCRC32C()
+		h.Write(p)
+		if h.Sum32() != v.crc {
+			return ErrSelfTest
+		}
+
+		if XXH64(p, 0) != v.xxh64 {
+			return ErrSelfTest
+		}
+
+		x := NewXXH64(0)
+		x.Write(p)
+		if x.Sum64() != v.xxh64 {
+			return ErrSelfTest
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package checksum
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	if err := SelfTest(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for n := 0; n < 200; n++ {
+		p := make([]byte, n)
+		r.Read(p)
+
+		x := NewXXH64(42)
+		c := NewCRC32C()
+		crc := uint32(0)
+
+		// write in random sized pieces
+		for q := p; len(q) > 0; {
+			k := r.Intn(40) + 1
+			if k > len(q) {
+				k = len(q)
+			}
+
+			x.Write(q[:k])
+			c.Write(q[:k])
+			crc = UpdateCRC32C(crc, q[:k])
+			q = q[k:]
+		}
+
+		if x.Sum64() != XXH64(p, 42) {
+			t.Fatal("wrong xxh64", n)
+		}
+
+		if c.Sum32() != CRC32C(p) || crc != CRC32C(p) {
+			t.Fatal("wrong crc32c", n)
+		}
+	}
+}
+
+func BenchmarkCRC32C(b *testing.B) {
+	p := make([]byte, 64*1024)
+	b.SetBytes(int64(len(p)))
+
+	for i := 0; i < b.N; i++ {
+		CRC32C(p)
+	}
+}
+
+func BenchmarkXXH64(b *testing.B) {
+	p := make([]byte, 64*1024)
+	b.SetBytes(int64(len(p)))
+
+	for i := 0; i < b.N; i++ {
+		XXH64(p, 0)
+	}
+}
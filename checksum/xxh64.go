@@ -0,0 +1,190 @@
+package checksum
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+const (
+	prime1 uint64 = 11400714785074694791
+	prime2 uint64 = 14029467366897019727
+	prime3 uint64 = 1609587929392839161
+	prime4 uint64 = 9650029242287828579
+	prime5 uint64 = 2870177450012600261
+)
+
+// XXH64 calculates the 64 bit xxHash of the data with given seed.
+func XXH64(p []byte, seed uint64) uint64 {
+	n := len(p)
+
+	var h uint64
+	if n >= 32 {
+		v1 := seed + prime1 + prime2
+		v2 := seed + prime2
+		v3 := seed
+		v4 := seed - prime1
+
+		for ; len(p) >= 32; p = p[32:] {
+			v1 = round(v1, binary.LittleEndian.Uint64(p[0:]))
+			v2 = round(v2, binary.LittleEndian.Uint64(p[8:]))
+			v3 = round(v3, binary.LittleEndian.Uint64(p[16:]))
+			v4 = round(v4, binary.LittleEndian.Uint64(p[24:]))
+		}
+
+		h = merge(v1, v2, v3, v4)
+	} else {
+		h = seed + prime5
+	}
+
+	h += uint64(n)
+	return finish(h, p)
+}
+
+// round mixes 8 bytes of input into an accumulator
+func round(acc, input uint64) uint64 {
+	acc += input * prime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * prime1
+}
+
+// mergeRound merges an accumulator into the hash
+func mergeRound(h, v uint64) uint64 {
+	h ^= round(0, v)
+	return h*prime1 + prime4
+}
+
+// merge combines all four accumulators
+func merge(v1, v2, v3, v4 uint64) (h uint64) {
+	h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) +
+		bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+
+	h = mergeRound(h, v1)
+	h = mergeRound(h, v2)
+	h = mergeRound(h, v3)
+	h = mergeRound(h, v4)
+
+	return h
+}
+
+// finish mixes the remaining bytes (less than 32) and avalanches the hash
+func finish(h uint64, p []byte) uint64 {
+	for ; len(p) >= 8; p = p[8:] {
+		h ^= round(0, binary.LittleEndian.Uint64(p))
+		h = bits.RotateLeft64(h, 27)*prime1 + prime4
+	}
+
+	if len(p) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(p)) * prime1
+		h = bits.RotateLeft64(h, 23)*prime2 + prime3
+		p = p[4:]
+	}
+
+	for _, b := range p {
+		h ^= uint64(b) * prime5
+		h = bits.RotateLeft64(h, 11) * prime1
+	}
+
+	h ^= h >> 33
+	h *= prime2
+	h ^= h >> 29
+	h *= prime3
+	h ^= h >> 32
+
+	return h
+}
+
+// Digest is a streaming 64 bit xxHash. It implements hash.Hash64.
+type Digest struct {
+	seed  uint64
+	v1    uint64
+	v2    uint64
+	v3    uint64
+	v4    uint64
+	total uint64
+	buf   [32]byte
+	nbuf  int
+}
+
+var _ hash.Hash64 = (*Digest)(nil)
+
+// NewXXH64 creates a streaming 64 bit xxHash with given seed.
+func NewXXH64(seed uint64) (d *Digest) {
+	d = &Digest{seed: seed}
+	d.Reset()
+	return d
+}
+
+// Reset implements the hash.Hash interface
+func (d *Digest) Reset() {
+	d.v1 = d.seed + prime1 + prime2
+	d.v2 = d.seed + prime2
+	d.v3 = d.seed
+	d.v4 = d.seed - prime1
+	d.total = 0
+	d.nbuf = 0
+}
+
+// Size implements the hash.Hash interface
+func (d *Digest) Size() int { return 8 }
+
+// BlockSize implements the hash.Hash interface
+func (d *Digest) BlockSize() int { return 32 }
+
+// Write implements the io.Writer interface. It never returns an error.
+func (d *Digest) Write(p []byte) (n int, err error) {
+	n = len(p)
+	d.total += uint64(n)
+
+	if d.nbuf > 0 {
+		c := copy(d.buf[d.nbuf:], p)
+		d.nbuf += c
+		p = p[c:]
+
+		if d.nbuf < 32 {
+			return n, nil
+		}
+
+		d.blocks(d.buf[:])
+		d.nbuf = 0
+	}
+
+	if len(p) >= 32 {
+		full := len(p) / 32 * 32
+		d.blocks(p[:full])
+		p = p[full:]
+	}
+
+	d.nbuf = copy(d.buf[:], p)
+	return n, nil
+}
+
+// Sum64 implements the hash.Hash64 interface
+func (d *Digest) Sum64() uint64 {
+	var h uint64
+	if d.total >= 32 {
+		h = merge(d.v1, d.v2, d.v3, d.v4)
+	} else {
+		h = d.seed + prime5
+	}
+
+	h += d.total
+	return finish(h, d.buf[:d.nbuf])
+}
+
+// Sum implements the hash.Hash interface
+func (d *Digest) Sum(b []byte) []byte {
+	var s [8]byte
+	binary.BigEndian.PutUint64(s[:], d.Sum64())
+	return append(b, s[:]...)
+}
+
+// blocks mixes 32 byte blocks into the accumulators
+func (d *Digest) blocks(p []byte) {
+	for ; len(p) >= 32; p = p[32:] {
+		d.v1 = round(d.v1, binary.LittleEndian.Uint64(p[0:]))
+		d.v2 = round(d.v2, binary.LittleEndian.Uint64(p[8:]))
+		d.v3 = round(d.v3, binary.LittleEndian.Uint64(p[16:]))
+		d.v4 = round(d.v4, binary.LittleEndian.Uint64(p[24:]))
+	}
+}
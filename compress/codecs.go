@@ -0,0 +1,118 @@
+package compress
+
+import (
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	// None stores blocks without compressing them.
+	None Codec = noneCodec{}
+
+	// Snappy compresses blocks with the snappy block format.
+	Snappy Codec = snappyCodec{}
+
+	// LZ4 compresses blocks with the lz4 block format.
+	LZ4 Codec = lz4Codec{}
+
+	// Zstd compresses blocks with zstandard at the default level.
+	Zstd Codec = &zstdCodec{}
+)
+
+type noneCodec struct{}
+
+func (noneCodec) ID() byte     { return 0 }
+func (noneCodec) Name() string { return "none" }
+
+func (noneCodec) Encode(dst, src []byte) (res []byte, err error) {
+	return append(dst, src...), nil
+}
+
+func (noneCodec) Decode(dst, src []byte) (res []byte, err error) {
+	return append(dst, src...), nil
+}
+
+type snappyCodec struct{}
+
+func (snappyCodec) ID() byte     { return 1 }
+func (snappyCodec) Name() string { return "snappy" }
+
+func (snappyCodec) Encode(dst, src []byte) (res []byte, err error) {
+	enc := snappy.Encode(nil, src)
+	return append(dst, enc...), nil
+}
+
+func (snappyCodec) Decode(dst, src []byte) (res []byte, err error) {
+	// a snappy element produces at most 64 bytes from 3 bytes so
+	// larger sizes can only come from corrupted blocks
+	if sz, err := snappy.DecodedLen(src); err != nil || sz > len(src)*22 {
+		return nil, ErrCorrupt
+	}
+
+	dec, err := snappy.Decode(nil, src)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+
+	return append(dst, dec...), nil
+}
+
+type lz4Codec struct{}
+
+func (lz4Codec) ID() byte     { return 2 }
+func (lz4Codec) Name() string { return "lz4" }
+
+func (lz4Codec) Encode(dst, src []byte) (res []byte, err error) {
+	return lz4Encode(dst, src), nil
+}
+
+func (lz4Codec) Decode(dst, src []byte) (res []byte, err error) {
+	return lz4Decode(dst, src)
+}
+
+// zstdCodec creates the encoder and decoder when they're used first
+// because creating them allocates a lot of memory.
+type zstdCodec struct {
+	enc  *zstd.Encoder
+	dec  *zstd.Decoder
+	err  error
+	once sync.Once
+}
+
+func (*zstdCodec) ID() byte     { return 3 }
+func (*zstdCodec) Name() string { return "zstd" }
+
+func (c *zstdCodec) Encode(dst, src []byte) (res []byte, err error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+
+	return c.enc.EncodeAll(src, dst), nil
+}
+
+func (c *zstdCodec) Decode(dst, src []byte) (res []byte, err error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+
+	res, err = c.dec.DecodeAll(src, dst)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+
+	return res, nil
+}
+
+func (c *zstdCodec) init() (err error) {
+	c.once.Do(func() {
+		if c.enc, c.err = zstd.NewWriter(nil); c.err != nil {
+			return
+		}
+
+		c.dec, c.err = zstd.NewReader(nil)
+	})
+
+	return c.err
+}
@@ -0,0 +1,68 @@
+// Package compress has block compression codecs and framed readers/writers
+// which compress data in fixed-size blocks. Use a block size which divides
+// the segment size to keep compressed blocks aligned with segment boundaries.
+// Each block has a checksum of the uncompressed data which is verified when
+// reading it back.
+package compress
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrCodec is returned when a block uses an unknown codec.
+	ErrCodec = errors.New("unknown compression codec")
+
+	// ErrCorrupt is returned when compressed data is not valid.
+	ErrCorrupt = errors.New("corrupted compressed data")
+)
+
+// Codec compresses and decompresses blocks of data. Both methods append
+// the result to dst (which can be nil) and return the extended slice.
+type Codec interface {
+	// ID is stored with each block to find the codec when reading it.
+	ID() byte
+
+	// Name returns a human readable codec name (ex. "snappy").
+	Name() string
+
+	// Encode compresses src and appends it to dst.
+	Encode(dst, src []byte) (res []byte, err error)
+
+	// Decode decompresses src and appends it to dst.
+	Decode(dst, src []byte) (res []byte, err error)
+}
+
+var (
+	codecs   = map[byte]Codec{}
+	codecsmx sync.RWMutex
+)
+
+// Register makes a codec available to readers. Codecs in this package are
+// registered automatically. Registering a codec with the same ID replaces it.
+func Register(c Codec) {
+	codecsmx.Lock()
+	defer codecsmx.Unlock()
+	codecs[c.ID()] = c
+}
+
+// Lookup finds a registered codec by its ID.
+func Lookup(id byte) (c Codec, err error) {
+	codecsmx.RLock()
+	defer codecsmx.RUnlock()
+
+	c, ok := codecs[id]
+	if !ok {
+		return nil, ErrCodec
+	}
+
+	return c, nil
+}
+
+func init() {
+	Register(None)
+	Register(Snappy)
+	Register(LZ4)
+	Register(Zstd)
+}
@@ -0,0 +1,163 @@
+package compress
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+)
+
+func samples() [][]byte {
+	rnd := make([]byte, 5000)
+	rand.New(rand.NewSource(1)).Read(rnd)
+
+	return [][]byte{
+		{},
+		[]byte("a"),
+		[]byte("hello world"),
+		bytes.Repeat([]byte("abcd"), 1000),
+		bytes.Repeat([]byte{0}, 70000),
+		append(bytes.Repeat([]byte("xyz123"), 300), rnd...),
+		rnd,
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	for _, c := range []Codec{None, Snappy, LZ4, Zstd} {
+		if r, err := Lookup(c.ID()); err != nil || r != c {
+			t.Fatal("codec not registered", c.Name())
+		}
+
+		for i, p := range samples() {
+			enc, err := c.Encode([]byte("prefix"), p)
+			if err != nil {
+				t.Fatal(c.Name(), i, err)
+			}
+
+			if string(enc[:6]) != "prefix" {
+				t.Fatal(c.Name(), i, "dst was not extended")
+			}
+
+			dec, err := c.Decode(nil, enc[6:])
+			if err != nil {
+				t.Fatal(c.Name(), i, err)
+			}
+
+			if !bytes.Equal(dec, p) {
+				t.Fatal(c.Name(), i, "wrong data")
+			}
+
+			// repeated data should compress well
+			if c != None && i == 3 && len(enc)-6 > len(p)/10 {
+				t.Fatal(c.Name(), "data was not compressed", len(enc))
+			}
+		}
+	}
+
+	if _, err := Lookup(200); err != ErrCodec {
+		t.Fatal("expected ErrCodec")
+	}
+}
+
+func TestLZ4Corrupt(t *testing.T) {
+	p := bytes.Repeat([]byte("abcdefgh"), 100)
+	enc, _ := LZ4.Encode(nil, p)
+
+	for i := 1; i < len(enc); i++ {
+		// truncated blocks should never panic
+		LZ4.Decode(nil, enc[:i])
+	}
+
+	if _, err := LZ4.Decode(nil, enc[:len(enc)-1]); err != ErrCorrupt {
+		t.Fatal("expected ErrCorrupt")
+	}
+
+	// a huge size in the header should not be allocated
+	huge := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f, 0x10, 'a'}
+	if _, err := LZ4.Decode(nil, huge); err != ErrCorrupt {
+		t.Fatal("expected ErrCorrupt")
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+
+	for _, c := range []Codec{None, Snappy, LZ4, Zstd} {
+		for i, p := range samples() {
+			enc, err := c.Encode(nil, p)
+			if err != nil {
+				t.Fatal(c.Name(), i, err)
+			}
+
+			// truncated and modified blocks should never panic
+			for j := 0; j < len(enc) && j < 200; j++ {
+				c.Decode(nil, enc[:j])
+
+				bad := append([]byte{}, enc...)
+				bad[j] ^= byte(rnd.Intn(255) + 1)
+				if _, err := c.Decode(nil, bad); err != nil && err != ErrCorrupt {
+					t.Fatal(c.Name(), i, "expected ErrCorrupt", err)
+				}
+			}
+		}
+
+		// huge sizes in headers should be rejected
+		huge := []byte{0xff, 0xff, 0xff, 0xff, 0x0f, 0x00}
+		if c != None {
+			if _, err := c.Decode(nil, huge); err != ErrCorrupt {
+				t.Fatal(c.Name(), "expected ErrCorrupt", err)
+			}
+		}
+	}
+}
+
+func TestFrames(t *testing.T) {
+	data := bytes.Join(samples(), nil)
+
+	for _, c := range []Codec{None, Snappy, LZ4, Zstd} {
+		buf := &bytes.Buffer{}
+		w := NewWriter(buf, c, 4096)
+
+		// write in uneven pieces
+		for p := data; len(p) > 0; {
+			n := 1000
+			if n > len(p) {
+				n = len(p)
+			}
+
+			if _, err := w.Write(p[:n]); err != nil {
+				t.Fatal(err)
+			}
+
+			p = p[n:]
+		}
+
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := ioutil.ReadAll(NewReader(bytes.NewReader(buf.Bytes()), 4096))
+		if err != nil {
+			t.Fatal(c.Name(), err)
+		}
+
+		if !bytes.Equal(out, data) {
+			t.Fatal(c.Name(), "wrong data")
+		}
+
+		if _, err := ioutil.ReadAll(NewReader(bytes.NewReader(buf.Bytes()), 1024)); err != ErrBlockSize {
+			t.Fatal(c.Name(), "expected ErrBlockSize", err)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf, None, 0)
+	w.Write([]byte("some data"))
+	w.Close()
+
+	frame := buf.Bytes()
+	frame[len(frame)-1] ^= 1
+	if _, err := ioutil.ReadAll(NewReader(bytes.NewReader(frame), 0)); err != ErrChecksum {
+		t.Fatal("expected ErrChecksum", err)
+	}
+}
@@ -0,0 +1,186 @@
+package compress
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/kadirahq/go-tools/checksum"
+)
+
+const (
+	// frame header has the codec ID, uncompressed size, compressed size
+	// and the checksum of uncompressed data
+	frameHead = 13
+
+	// DefaultBlockSize is used when the block size is not positive.
+	DefaultBlockSize = 1024 * 1024
+)
+
+var (
+	// ErrChecksum is returned when a block has a bad checksum.
+	ErrChecksum = errors.New("compressed block checksum mismatch")
+
+	// ErrBlockSize is returned when a frame has a block larger than the limit.
+	ErrBlockSize = errors.New("compressed block is too large")
+)
+
+// Writer compresses data written to it in blocks and writes them as frames.
+// Call Close (or Flush) to write the last partial block.
+type Writer struct {
+	w     io.Writer
+	codec Codec
+	block []byte
+	frame []byte
+	size  int
+}
+
+// NewWriter creates a writer which compresses data with the codec in blocks
+// of given size. If the size is not positive, DefaultBlockSize is used.
+func NewWriter(w io.Writer, c Codec, size int) (cw *Writer) {
+	if size <= 0 {
+		size = DefaultBlockSize
+	}
+
+	return &Writer{
+		w:     w,
+		codec: c,
+		block: make([]byte, 0, size),
+		size:  size,
+	}
+}
+
+// Write implements the io.Writer interface
+func (cw *Writer) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		c := copy(cw.block[len(cw.block):cw.size], p)
+		cw.block = cw.block[:len(cw.block)+c]
+		p = p[c:]
+		n += c
+
+		if len(cw.block) == cw.size {
+			if err := cw.Flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// Flush compresses and writes buffered data as a block.
+func (cw *Writer) Flush() (err error) {
+	if len(cw.block) == 0 {
+		return nil
+	}
+
+	frame := append(cw.frame[:0], make([]byte, frameHead)...)
+	frame, err = cw.codec.Encode(frame, cw.block)
+	if err != nil {
+		return err
+	}
+
+	frame[0] = cw.codec.ID()
+	binary.LittleEndian.PutUint32(frame[1:], uint32(len(cw.block)))
+	binary.LittleEndian.PutUint32(frame[5:], uint32(len(frame)-frameHead))
+	binary.LittleEndian.PutUint32(frame[9:], checksum.CRC32C(cw.block))
+
+	cw.frame = frame
+	cw.block = cw.block[:0]
+
+	_, err = cw.w.Write(frame)
+	return err
+}
+
+// Close flushes buffered data. It does not close the underlying writer.
+func (cw *Writer) Close() (err error) {
+	return cw.Flush()
+}
+
+// Reader reads frames written by a Writer and decompresses them.
+type Reader struct {
+	r     io.Reader
+	max   int
+	head  []byte
+	frame []byte
+	block []byte
+	pos   int
+}
+
+// NewReader creates a reader which reads frames from r. Blocks larger than
+// max bytes are rejected. If max is not positive, DefaultBlockSize is used.
+func NewReader(r io.Reader, max int) (cr *Reader) {
+	if max <= 0 {
+		max = DefaultBlockSize
+	}
+
+	return &Reader{
+		r:    r,
+		max:  max,
+		head: make([]byte, frameHead),
+	}
+}
+
+// Read implements the io.Reader interface
+func (cr *Reader) Read(p []byte) (n int, err error) {
+	for cr.pos == len(cr.block) {
+		if err := cr.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n = copy(p, cr.block[cr.pos:])
+	cr.pos += n
+	return n, nil
+}
+
+// next reads the next frame and decompresses it
+func (cr *Reader) next() (err error) {
+	if _, err := io.ReadFull(cr.r, cr.head); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return ErrCorrupt
+		}
+
+		return err
+	}
+
+	codec, err := Lookup(cr.head[0])
+	if err != nil {
+		return err
+	}
+
+	rsz := int(binary.LittleEndian.Uint32(cr.head[1:]))
+	csz := int(binary.LittleEndian.Uint32(cr.head[5:]))
+	if rsz > cr.max || csz > cr.max+cr.max/8+1024 {
+		return ErrBlockSize
+	}
+
+	if cap(cr.frame) < csz {
+		cr.frame = make([]byte, csz)
+	}
+
+	cr.frame = cr.frame[:csz]
+	if _, err := io.ReadFull(cr.r, cr.frame); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrCorrupt
+		}
+
+		return err
+	}
+
+	cr.block, err = codec.Decode(cr.block[:0], cr.frame)
+	if err != nil {
+		return err
+	}
+
+	if len(cr.block) != rsz {
+		return ErrCorrupt
+	}
+
+	if checksum.CRC32C(cr.block) != binary.LittleEndian.Uint32(cr.head[9:]) {
+		return ErrChecksum
+	}
+
+	cr.pos = 0
+	return nil
+}
@@ -0,0 +1,205 @@
+package compress
+
+import (
+	"encoding/binary"
+)
+
+const (
+	// minimum length of a match
+	lz4MinMatch = 4
+
+	// last 5 bytes are always literals
+	lz4LastLits = 5
+
+	// last match should start at least 12 bytes before the end
+	lz4MFLimit = 12
+
+	// matches should be within this distance
+	lz4MaxOffset = 65535
+
+	// size of the hash table used to find matches
+	lz4HashLog = 14
+)
+
+// lz4Encode compresses src with the lz4 block format and appends it to dst.
+// The size of the uncompressed data is stored before the block as a varint.
+func lz4Encode(dst, src []byte) []byte {
+	var hdr [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(hdr[:], uint64(len(src)))
+	dst = append(dst, hdr[:n]...)
+
+	if len(src) < lz4MFLimit+1 {
+		return lz4Emit(dst, src, 0, 0)
+	}
+
+	var table [1 << lz4HashLog]int32
+	anchor := 0
+	limit := len(src) - lz4MFLimit
+
+	for i := 0; i < limit; {
+		seq := binary.LittleEndian.Uint32(src[i:])
+		h := (seq * 2654435761) >> (32 - lz4HashLog)
+		ref := int(table[h]) - 1
+		table[h] = int32(i + 1)
+
+		if ref < 0 || i-ref > lz4MaxOffset || binary.LittleEndian.Uint32(src[ref:]) != seq {
+			i++
+			continue
+		}
+
+		m := lz4MinMatch
+		for i+m < len(src)-lz4LastLits && src[ref+m] == src[i+m] {
+			m++
+		}
+
+		dst = lz4Emit(dst, src[anchor:i], i-ref, m)
+		i += m
+		anchor = i
+	}
+
+	return lz4Emit(dst, src[anchor:], 0, 0)
+}
+
+// lz4Emit appends a sequence with literals and a match to dst.
+// If mlen is zero, only the literals are appended (last sequence).
+func lz4Emit(dst, lits []byte, off, mlen int) []byte {
+	llen := len(lits)
+	token := byte(0)
+
+	if llen >= 15 {
+		token = 15 << 4
+	} else {
+		token = byte(llen) << 4
+	}
+
+	ml := mlen - lz4MinMatch
+	if mlen > 0 {
+		if ml >= 15 {
+			token |= 15
+		} else {
+			token |= byte(ml)
+		}
+	}
+
+	dst = append(dst, token)
+	if llen >= 15 {
+		dst = lz4Length(dst, llen-15)
+	}
+
+	dst = append(dst, lits...)
+	if mlen == 0 {
+		return dst
+	}
+
+	dst = append(dst, byte(off), byte(off>>8))
+	if ml >= 15 {
+		dst = lz4Length(dst, ml-15)
+	}
+
+	return dst
+}
+
+// lz4Length appends an extended length value
+func lz4Length(dst []byte, n int) []byte {
+	for ; n >= 255; n -= 255 {
+		dst = append(dst, 255)
+	}
+
+	return append(dst, byte(n))
+}
+
+// lz4Decode decompresses a block encoded by lz4Encode and appends it to dst.
+func lz4Decode(dst, src []byte) (res []byte, err error) {
+	size, n := binary.Uvarint(src)
+	if n <= 0 {
+		return nil, ErrCorrupt
+	}
+
+	// each byte of a block can produce at most 255 bytes (extended
+	// lengths) so larger sizes can only come from corrupted blocks
+	src = src[n:]
+	if size > uint64(len(src))*255 {
+		return nil, ErrCorrupt
+	}
+
+	base := len(dst)
+	end := base + int(size)
+
+	if cap(dst) < end {
+		tmp := make([]byte, base, end)
+		copy(tmp, dst)
+		dst = tmp
+	}
+
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+
+		llen := int(token >> 4)
+		if llen == 15 {
+			if llen, i, err = lz4ReadLength(src, i, llen); err != nil {
+				return nil, err
+			}
+		}
+
+		if i+llen > len(src) || len(dst)+llen > end {
+			return nil, ErrCorrupt
+		}
+
+		dst = append(dst, src[i:i+llen]...)
+		i += llen
+
+		// the last sequence only has literals
+		if i == len(src) {
+			break
+		}
+
+		if i+2 > len(src) {
+			return nil, ErrCorrupt
+		}
+
+		off := int(src[i]) | int(src[i+1])<<8
+		i += 2
+
+		mlen := int(token & 15)
+		if mlen == 15 {
+			if mlen, i, err = lz4ReadLength(src, i, mlen); err != nil {
+				return nil, err
+			}
+		}
+
+		mlen += lz4MinMatch
+		pos := len(dst) - off
+		if off == 0 || pos < base || len(dst)+mlen > end {
+			return nil, ErrCorrupt
+		}
+
+		// matches can overlap with the data being written
+		for j := 0; j < mlen; j++ {
+			dst = append(dst, dst[pos+j])
+		}
+	}
+
+	if len(dst) != end {
+		return nil, ErrCorrupt
+	}
+
+	return dst, nil
+}
+
+// lz4ReadLength reads an extended length value
+func lz4ReadLength(src []byte, i, n int) (res, next int, err error) {
+	for {
+		if i >= len(src) {
+			return 0, 0, ErrCorrupt
+		}
+
+		b := src[i]
+		i++
+		n += int(b)
+
+		if b != 255 {
+			return n, i, nil
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Package crashtest checks whether data written by a process survives when
+// the process is killed. A workload runs in a child process and writes data
+// to a directory. The child is killed with SIGKILL at a random time and the
+// data directory is verified before starting the next run.
+//
+// Workloads report durable progress with the ack function after data is
+// synced. The verify function receives the last acknowledged value and
+// should check that all acknowledged data is still there.
+//
+// The child process is the same executable, usually the test binary. Call
+// Main at the start of TestMain to run workloads in child processes.
+//
+//	func init() {
+//		crashtest.Register("append", appendWorkload)
+//	}
+//
+//	func TestMain(m *testing.M) {
+//		crashtest.Main()
+//		os.Exit(m.Run())
+//	}
+package crashtest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+const (
+	// environment variables used to start workloads in child processes
+	envName = "CRASHTEST_WORKLOAD"
+	envDir  = "CRASHTEST_DIR"
+
+	// file descriptor of the pipe used to send acks to the parent
+	ackFD = 3
+)
+
+var (
+	// ErrUnknown is returned when the workload is not registered.
+	ErrUnknown = errors.New("unknown workload")
+
+	// ErrFailed is returned when the child exits with an error before it's
+	// killed. The output of the child is written to the stderr.
+	ErrFailed = errors.New("workload failed")
+
+	workloads = map[string]Workload{}
+	mutex     sync.Mutex
+)
+
+// Workload writes data to the directory until the process is killed.
+// Call ack with a value which describes the progress (ex. number of records)
+// after the data is synced to the disk.
+type Workload func(dir string, ack func(n int64)) (err error)
+
+// Verify checks the data directory after the child is killed. The value is
+// the last value acknowledged by the workload (0 if there were no acks).
+type Verify func(dir string, acked int64) (err error)
+
+// Options for crash tests
+type Options struct {
+	// Number of times the child is started and killed.
+	Runs int
+
+	// The child is killed after a random delay within this range.
+	MinDelay time.Duration
+	MaxDelay time.Duration
+
+	// Seed for the random delays. Use the same seed to reproduce a test.
+	Seed int64
+}
+
+// DefaultOptions is used when options are not given to Run.
+var DefaultOptions = &Options{
+	Runs:     10,
+	MinDelay: 10 * time.Millisecond,
+	MaxDelay: 200 * time.Millisecond,
+	Seed:     1,
+}
+
+// Register adds a workload which can be started with Run.
+// Workloads should be registered in both parent and child processes
+// (ex. in an init function).
+func Register(name string, fn Workload) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	workloads[name] = fn
+}
+
+// Main runs the workload and exits if the process was started by Run.
+// Otherwise it returns immediately.
+func Main() {
+	name := os.Getenv(envName)
+	if name == "" {
+		return
+	}
+
+	mutex.Lock()
+	fn, ok := workloads[name]
+	mutex.Unlock()
+
+	if !ok {
+		fmt.Fprintln(os.Stderr, ErrUnknown, name)
+		os.Exit(2)
+	}
+
+	pipe := os.NewFile(ackFD, "ack")
+	buf := make([]byte, 8)
+	ack := func(n int64) {
+		binary.LittleEndian.PutUint64(buf, uint64(n))
+		if _, err := pipe.Write(buf); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	if err := fn(os.Getenv(envDir), ack); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+// Run starts the workload in a child process, kills it and verifies the
+// data directory. This is repeated opts.Runs times with the same directory.
+func Run(dir, name string, opts *Options, verify Verify) (err error) {
+	if opts == nil {
+		opts = DefaultOptions
+	}
+
+	mutex.Lock()
+	_, ok := workloads[name]
+	mutex.Unlock()
+
+	if !ok {
+		return ErrUnknown
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	for i := 0; i < opts.Runs; i++ {
+		delay := opts.MinDelay
+		if d := opts.MaxDelay - opts.MinDelay; d > 0 {
+			delay += time.Duration(rnd.Int63n(int64(d)))
+		}
+
+		acked, err := run(dir, name, delay)
+		if err != nil {
+			return fmt.Errorf("crashtest: run %d: %v", i, err)
+		}
+
+		if err := verify(dir, acked); err != nil {
+			return fmt.Errorf("crashtest: run %d (acked %d): %v", i, acked, err)
+		}
+	}
+
+	return nil
+}
+
+// run starts the child, kills it after the delay and returns the last ack
+func run(dir, name string, delay time.Duration) (acked int64, err error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+
+	defer r.Close()
+
+	cmd := exec.Command(exe)
+	cmd.Env = append(os.Environ(), envName+"="+name, envDir+"="+dir)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{w}
+
+	if err := cmd.Start(); err != nil {
+		w.Close()
+		return 0, err
+	}
+
+	// only the child should have the write end open
+	w.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		buf := make([]byte, 8)
+		for {
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+
+			acked = int64(binary.LittleEndian.Uint64(buf))
+		}
+	}()
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	select {
+	case err := <-exited:
+		<-done
+		if err != nil {
+			return acked, ErrFailed
+		}
+
+		return acked, nil
+	case <-time.After(delay):
+	}
+
+	if err := cmd.Process.Kill(); err != nil {
+		return 0, err
+	}
+
+	<-exited
+	<-done
+
+	return acked, nil
+}
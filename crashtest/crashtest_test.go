@@ -0,0 +1,128 @@
+package crashtest
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kadirahq/go-tools/wal"
+)
+
+var (
+	tmpdir = "/tmp/test-crashtest"
+	opts   = &wal.Options{
+		SegmentSize:   1024,
+		SyncInterval:  time.Millisecond,
+		MaxRecordSize: 1024,
+	}
+)
+
+func init() {
+	Register("wal", func(dir string, ack func(n int64)) error {
+		l, err := wal.Open(dir, opts)
+		if err != nil {
+			return err
+		}
+
+		n, err := count(l)
+		if err != nil {
+			return err
+		}
+
+		for {
+			if _, err := l.Append(record(n)); err != nil {
+				return err
+			}
+
+			if err := l.Sync(); err != nil {
+				return err
+			}
+
+			n++
+			ack(n)
+		}
+	})
+}
+
+func TestMain(m *testing.M) {
+	Main()
+	os.Exit(m.Run())
+}
+
+func setup(t *testing.T) func() {
+	if err := os.RemoveAll(tmpdir); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if err := os.RemoveAll(tmpdir); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func record(i int64) []byte {
+	return []byte("record-" + strconv.FormatInt(i, 10))
+}
+
+// count checks records in the log and returns the number of records
+func count(l *wal.Log) (n int64, err error) {
+	it := l.Iter(l.Head())
+	for ; ; n++ {
+		_, p, err := it.Next()
+		if err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return 0, err
+		}
+
+		if string(p) != string(record(n)) {
+			return 0, fmt.Errorf("wrong record %d", n)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	defer setup(t)()
+
+	o := &Options{Runs: 5, MinDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond, Seed: 1}
+	total := int64(0)
+
+	err := Run(tmpdir, "wal", o, func(dir string, acked int64) error {
+		l, err := wal.Open(dir, opts)
+		if err != nil {
+			return err
+		}
+
+		defer l.Close()
+
+		n, err := count(l)
+		if err != nil {
+			return err
+		}
+
+		if n < acked {
+			return fmt.Errorf("lost records: %d < %d", n, acked)
+		}
+
+		total = n
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if total == 0 {
+		t.Fatal("expected records")
+	}
+}
+
+func TestUnknown(t *testing.T) {
+	if err := Run(tmpdir, "unknown", nil, nil); err != ErrUnknown {
+		t.Fatal("expected ErrUnknown")
+	}
+}
@@ -0,0 +1,256 @@
+// Package dict interns byte strings in memory mapped segment files and gives
+// each of them a stable uint32 id. Strings can be looked up by their ids
+// without copying them. This is useful for storing repeated values such as
+// label names and values once and referring to them with small ids.
+//
+// Ids are assigned in the order strings are added and they do not change
+// when the dictionary is reopened. The id of a string is found with an
+// in-memory index which is rebuilt when the dictionary is opened.
+package dict
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"sync"
+
+	"github.com/kadirahq/go-tools/segments/segmmap"
+)
+
+const (
+	// entry header has the string size (+1) and a checksum
+	headSize = 8
+)
+
+var (
+	// ErrID is returned when there's no string with given id.
+	ErrID = errors.New("unknown string id")
+
+	// ErrTooLarge is returned when the string does not fit in a segment.
+	ErrTooLarge = errors.New("string is too large")
+
+	// ErrFull is returned when all uint32 ids are used.
+	ErrFull = errors.New("dictionary is full")
+)
+
+// Options for dictionaries
+type Options struct {
+	// Size of each segment file in bytes. Strings cannot be larger than
+	// the segment size (minus a small header).
+	SegmentSize int64
+}
+
+// DefaultOptions is used when options are not given to Open.
+var DefaultOptions = &Options{
+	SegmentSize: 16 * 1024 * 1024,
+}
+
+// Dict is a dictionary of interned strings. Strings are stored in segment
+// files and they never span segments so they can be sliced without copying.
+// All methods are safe to use concurrently.
+type Dict struct {
+	store *segmmap.Store
+	ssize int64
+	offs  []int64
+	ids   map[string]uint32
+	tail  int64
+	mutex sync.RWMutex
+}
+
+// Open opens or creates a dictionary with segment files on given path.
+// Existing strings are read to rebuild the index. Strings which were not
+// completely written before a crash are discarded.
+func Open(base string, opts *Options) (d *Dict, err error) {
+	if opts == nil {
+		opts = DefaultOptions
+	}
+
+	store, err := segmmap.New(base, opts.SegmentSize, false)
+	if err != nil {
+		return nil, err
+	}
+
+	d = &Dict{
+		store: store,
+		ssize: opts.SegmentSize,
+		ids:   map[string]uint32{},
+	}
+
+	if err := d.load(); err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// Len returns the number of strings in the dictionary.
+func (d *Dict) Len() (n int) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return len(d.offs)
+}
+
+// Intern adds the string to the dictionary if it's not already there and
+// returns its id. The string is copied so it can be reused by the caller.
+func (d *Dict) Intern(p []byte) (id uint32, err error) {
+	if int64(len(p)) > d.ssize-headSize {
+		return 0, ErrTooLarge
+	}
+
+	d.mutex.RLock()
+	id, ok := d.ids[string(p)]
+	d.mutex.RUnlock()
+
+	if ok {
+		return id, nil
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	// it may be added while waiting for the lock
+	if id, ok := d.ids[string(p)]; ok {
+		return id, nil
+	}
+
+	if uint64(len(d.offs)) > uint64(^uint32(0)) {
+		return 0, ErrFull
+	}
+
+	// strings which do not fit in the rest of the
+	// segment are written to the next segment
+	off := d.tail
+	sz := int64(headSize + len(p))
+	if rem := d.ssize - off%d.ssize; sz > rem {
+		off += rem
+	}
+
+	entry := make([]byte, sz)
+	binary.LittleEndian.PutUint32(entry[0:], uint32(len(p)+1))
+	binary.LittleEndian.PutUint32(entry[4:], crc32.ChecksumIEEE(p))
+	copy(entry[headSize:], p)
+
+	if _, err := d.store.WriteAt(entry, off); err != nil {
+		return 0, err
+	}
+
+	id = uint32(len(d.offs))
+	d.offs = append(d.offs, off)
+	d.ids[string(p)] = id
+	d.tail = off + sz
+
+	return id, nil
+}
+
+// ID returns the id of the string if it's in the dictionary.
+func (d *Dict) ID(p []byte) (id uint32, ok bool) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	id, ok = d.ids[string(p)]
+	return id, ok
+}
+
+// Lookup returns the string with given id without copying it. The slice
+// must not be modified and it must not be used after closing the dict.
+func (d *Dict) Lookup(id uint32) (p []byte, err error) {
+	d.mutex.RLock()
+	if int64(id) >= int64(len(d.offs)) {
+		d.mutex.RUnlock()
+		return nil, ErrID
+	}
+
+	off := d.offs[id]
+	d.mutex.RUnlock()
+
+	head, err := d.store.SliceAt(headSize, off)
+	if err != nil {
+		return nil, err
+	}
+
+	sz := int64(binary.LittleEndian.Uint32(head)) - 1
+	if sz == 0 {
+		return []byte{}, nil
+	}
+
+	return d.store.SliceAt(sz, off+headSize)
+}
+
+// Sync writes strings to the disk. Strings added after the last
+// Sync call may be lost if the process crashes.
+func (d *Dict) Sync() (err error) {
+	return d.store.Sync()
+}
+
+// Close syncs strings to the disk and closes segment files.
+func (d *Dict) Close() (err error) {
+	if err := d.store.Sync(); err != nil {
+		return err
+	}
+
+	return d.store.Close()
+}
+
+// load reads all strings to rebuild the index and find the end of data
+func (d *Dict) load() (err error) {
+	head := make([]byte, headSize)
+
+	for off := int64(0); ; {
+		rem := d.ssize - off%d.ssize
+		if rem < headSize {
+			off += rem
+			continue
+		}
+
+		_, err := d.store.ReadAt(head, off)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		sz := int64(binary.LittleEndian.Uint32(head[0:]))
+		if sz == 0 {
+			// the rest of the segment was skipped if the next string
+			// did not fit in it, check the start of the next segment
+			if off%d.ssize == 0 || !d.exists(off+rem) {
+				return nil
+			}
+
+			off += rem
+			continue
+		}
+
+		// strings cannot span segments
+		sz--
+		if headSize+sz > rem {
+			return nil
+		}
+
+		p := make([]byte, sz)
+		if _, err := d.store.ReadAt(p, off+headSize); err != nil {
+			return err
+		}
+
+		if binary.LittleEndian.Uint32(head[4:]) != crc32.ChecksumIEEE(p) {
+			return nil
+		}
+
+		d.ids[string(p)] = uint32(len(d.offs))
+		d.offs = append(d.offs, off)
+
+		off += headSize + sz
+		d.tail = off
+	}
+}
+
+// exists checks whether there's a string at given offset
+func (d *Dict) exists(off int64) (ok bool) {
+	head := make([]byte, headSize)
+	if _, err := d.store.ReadAt(head, off); err != nil {
+		return false
+	}
+
+	return binary.LittleEndian.Uint32(head) != 0
+}
@@ -0,0 +1,200 @@
+package dict
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+const (
+	tmpdir = "/tmp/test-dict/"
+)
+
+var (
+	opts = &Options{SegmentSize: 32}
+)
+
+func setup(t *testing.T) {
+	if err := os.RemoveAll(tmpdir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(tmpdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIntern(t *testing.T) {
+	setup(t)
+	defer os.RemoveAll(tmpdir)
+
+	d, err := Open(tmpdir+"seg_", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer d.Close()
+
+	strs := []string{"host", "", "region", "host", "a-long-label-value"}
+	ids := []uint32{0, 1, 2, 0, 3}
+
+	for i, s := range strs {
+		if id, err := d.Intern([]byte(s)); err != nil {
+			t.Fatal(err)
+		} else if id != ids[i] {
+			t.Fatal("wrong id", s, id)
+		}
+	}
+
+	if n := d.Len(); n != 4 {
+		t.Fatal("wrong length", n)
+	}
+
+	for i, s := range strs {
+		if p, err := d.Lookup(ids[i]); err != nil {
+			t.Fatal(err)
+		} else if string(p) != s {
+			t.Fatal("wrong string", string(p))
+		}
+	}
+
+	if id, ok := d.ID([]byte("region")); !ok || id != 2 {
+		t.Fatal("wrong id")
+	}
+
+	if _, ok := d.ID([]byte("missing")); ok {
+		t.Fatal("should not find string")
+	}
+
+	if _, err := d.Lookup(4); err != ErrID {
+		t.Fatal("expected ErrID")
+	}
+
+	if _, err := d.Intern(make([]byte, 25)); err != ErrTooLarge {
+		t.Fatal("expected ErrTooLarge")
+	}
+}
+
+func TestReopen(t *testing.T) {
+	setup(t)
+	defer os.RemoveAll(tmpdir)
+
+	d, err := Open(tmpdir+"seg_", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// strings skip the rest of segments when they don't fit
+	for i := 0; i < 20; i++ {
+		if _, err := d.Intern([]byte("value-" + strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = Open(tmpdir+"seg_", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer d.Close()
+
+	if n := d.Len(); n != 20 {
+		t.Fatal("wrong length", n)
+	}
+
+	for i := 0; i < 20; i++ {
+		s := "value-" + strconv.Itoa(i)
+		if id, ok := d.ID([]byte(s)); !ok || id != uint32(i) {
+			t.Fatal("wrong id", s)
+		}
+
+		if p, err := d.Lookup(uint32(i)); err != nil {
+			t.Fatal(err)
+		} else if string(p) != s {
+			t.Fatal("wrong string", string(p))
+		}
+	}
+
+	if id, err := d.Intern([]byte("new")); err != nil {
+		t.Fatal(err)
+	} else if id != 20 {
+		t.Fatal("wrong id", id)
+	}
+}
+
+func TestTorn(t *testing.T) {
+	setup(t)
+	defer os.RemoveAll(tmpdir)
+
+	d, err := Open(tmpdir+"seg_", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"aaaa", "bbbb"} {
+		if _, err := d.Intern([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// corrupt the payload of the last string
+	if _, err := d.store.WriteAt([]byte("x"), 12+headSize); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = Open(tmpdir+"seg_", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer d.Close()
+
+	if n := d.Len(); n != 1 {
+		t.Fatal("torn string should be discarded", n)
+	}
+
+	if id, err := d.Intern([]byte("cccc")); err != nil {
+		t.Fatal(err)
+	} else if id != 1 {
+		t.Fatal("wrong id", id)
+	}
+
+	if p, err := d.Lookup(1); err != nil {
+		t.Fatal(err)
+	} else if string(p) != "cccc" {
+		t.Fatal("wrong string", string(p))
+	}
+}
+
+func BenchmarkIntern(b *testing.B) {
+	os.RemoveAll(tmpdir)
+	os.MkdirAll(tmpdir, 0755)
+	defer os.RemoveAll(tmpdir)
+
+	d, err := Open(tmpdir+"seg_", nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defer d.Close()
+
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = []byte("value-" + strconv.Itoa(i))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := d.Intern(keys[i%len(keys)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
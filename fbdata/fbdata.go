@@ -0,0 +1,380 @@
+// Package fbdata persists a flatbuffer in a memory mapped file using the same
+// approach as segfile.Metadata: the file is split into two slots and each
+// flush writes to the slot not in use with a higher generation number and a
+// checksum, so a crash while writing never loses the previous value. Sync
+// calls are grouped together and written with a single msync call.
+//
+// The package does not depend on a flatbuffers library. Users provide a
+// function which returns a table accessor for a finished buffer, usually a
+// wrapper around the generated GetRootAs function:
+//
+//	d, err := fbdata.Open(path, 4096, func(b []byte) *schema.Meta {
+//		return schema.GetRootAsMeta(b, 0)
+//	}, nil)
+//
+// Values are updated by building a new buffer with flatbuffers.Builder and
+// calling Set with builder.FinishedBytes().
+package fbdata
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/kadirahq/go-tools/function"
+	"github.com/kadirahq/go-tools/memmap"
+	"github.com/kadirahq/go-tools/secure"
+	"github.com/kadirahq/go-tools/segments/segfile"
+)
+
+const (
+	// size of the slot header (generation, checksum, length)
+	slotHead = 16
+)
+
+var (
+	// ErrFull is returned when the buffer does not fit in a slot.
+	// Use a larger file or store less data in the buffer.
+	ErrFull = errors.New("buffer does not fit in file")
+
+	// ErrBad is returned when the file has invalid content.
+	ErrBad = errors.New("invalid file content")
+
+	// ErrReadOnly is returned when the user attempts to modify
+	// or sync data opened with the ReadOnly option.
+	ErrReadOnly = errors.New("data is read only")
+
+	// ErrClosed is returned when the user attempts to sync data after
+	// closing it. Sync calls waiting while closing also get it.
+	ErrClosed = errors.New("data is closed")
+)
+
+// Options for flatbuffer data files
+type Options struct {
+	// Pending Sync calls are synced together at this interval.
+	// segfile.DefaultSyncInterval is used if it's not positive.
+	SyncInterval time.Duration
+
+	// Read the file without memory mapping it. Data cannot be changed.
+	ReadOnly bool
+
+	// Reload a read only file at this interval if it's changed by another
+	// process. Zero disables reloading. Only used with ReadOnly.
+	ReloadInterval time.Duration
+
+	// Called after reloading a changed file. It's called from a background
+	// goroutine and the next reload waits until it returns.
+	OnChange func()
+}
+
+// DefaultOptions is used when options are not given to Open.
+var DefaultOptions = &Options{
+	SyncInterval: segfile.DefaultSyncInterval,
+}
+
+// Data is a flatbuffer persisted in a memory mapped file.
+// All methods are safe to use concurrently.
+type Data[T any] struct {
+	root  func(buf []byte) T
+	path  string
+	onchg func()
+	gen   uint64
+	buf   []byte
+	dirty bool
+	mutex sync.RWMutex
+	mmap  *memmap.Map
+	group *function.Group
+	tick  *function.Ticker
+	watch *function.Ticker
+	ctx   context.Context
+	stop  context.CancelFunc
+	shut  secure.Bool
+}
+
+// Open creates or loads a data file on given path. The file will be created
+// with given size if it does not exist (half of it is usable for the buffer).
+// The root function is used to create table accessors for the buffer.
+func Open[T any](path string, sz int64, root func(buf []byte) T, opts *Options) (d *Data[T], err error) {
+	if opts == nil {
+		opts = DefaultOptions
+	}
+
+	d = &Data[T]{root: root, path: path, onchg: opts.OnChange}
+
+	if opts.ReadOnly {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := d.load(data); err != nil {
+			return nil, err
+		}
+
+		if opts.ReloadInterval > 0 {
+			d.watch = function.NewTicker(d.reload, opts.ReloadInterval)
+			d.watch.Start()
+		}
+
+		return d, nil
+	}
+
+	mmap, err := memmap.New(path, sz)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.load(mmap.Data); err != nil {
+		mmap.Close()
+		return nil, err
+	}
+
+	interval := opts.SyncInterval
+	if interval <= 0 {
+		interval = segfile.DefaultSyncInterval
+	}
+
+	d.mmap = mmap
+	d.group = function.NewGroupE(d.flush)
+	d.tick = function.NewTicker(func() { d.group.Flush() }, interval)
+	d.ctx, d.stop = context.WithCancel(context.Background())
+	d.tick.Start()
+
+	return d, nil
+}
+
+// Get returns a table accessor for the current buffer. The boolean result
+// will be false if a buffer is not set yet. The accessor can be used after
+// setting a new buffer and it will continue to use the old buffer.
+func (d *Data[T]) Get() (val T, ok bool) {
+	d.mutex.RLock()
+	buf := d.buf
+	d.mutex.RUnlock()
+
+	if buf == nil {
+		return val, false
+	}
+
+	return d.root(buf), true
+}
+
+// Bytes returns the current buffer. It must not be modified.
+func (d *Data[T]) Bytes() (buf []byte) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.buf
+}
+
+// Set replaces the buffer with a copy of given finished flatbuffer.
+// Use Sync to make sure that the new buffer is written to the disk.
+func (d *Data[T]) Set(buf []byte) (err error) {
+	if d.mmap == nil {
+		return ErrReadOnly
+	}
+
+	if len(buf) > len(slot(d.mmap.Data, 0))-slotHead {
+		return ErrFull
+	}
+
+	buf = append([]byte{}, buf...)
+
+	d.mutex.Lock()
+	d.buf = buf
+	d.dirty = true
+	d.mutex.Unlock()
+
+	return nil
+}
+
+// Sync blocks until the buffer is written to the disk.
+// Sync calls made by multiple goroutines are grouped together.
+func (d *Data[T]) Sync() (err error) {
+	if d.mmap == nil {
+		return ErrReadOnly
+	}
+
+	if d.shut.Get() {
+		return ErrClosed
+	}
+
+	err = d.group.RunContext(d.ctx)
+	if err == context.Canceled {
+		return ErrClosed
+	}
+
+	return err
+}
+
+// Close stops background goroutines, writes changes to the disk and closes
+// the file. Calling Close more than once has no effect.
+func (d *Data[T]) Close() (err error) {
+	if !d.shut.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	if d.watch != nil {
+		d.watch.Stop()
+	}
+
+	if d.mmap == nil {
+		return nil
+	}
+
+	d.tick.Stop()
+	ferr := d.group.Flush()
+	d.stop()
+
+	if err := d.flush(); err != nil {
+		return err
+	}
+
+	if err := d.mmap.Close(); err != nil {
+		return err
+	}
+
+	return ferr
+}
+
+// reload reads the file again and updates the buffer if it's changed
+// If the file cannot be loaded, the previous buffer is kept.
+func (d *Data[T]) reload() {
+	data, err := ioutil.ReadFile(d.path)
+	if err != nil {
+		return
+	}
+
+	tmp := &Data[T]{}
+	if err := tmp.load(data); err != nil {
+		return
+	}
+
+	d.mutex.Lock()
+	changed := tmp.gen != d.gen
+	if changed {
+		d.gen = tmp.gen
+		d.buf = tmp.buf
+	}
+	d.mutex.Unlock()
+
+	if changed && d.onchg != nil {
+		d.onchg()
+	}
+}
+
+// flush writes the buffer to the memory map and syncs it to the disk
+func (d *Data[T]) flush() (err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if !d.dirty {
+		return nil
+	}
+
+	gen := d.gen + 1
+	s := slot(d.mmap.Data, gen)
+	if len(d.buf) > len(s)-slotHead {
+		return ErrFull
+	}
+
+	// a partially written slot will fail the checksum test
+	// and the previous generation will be loaded instead
+	copy(s[slotHead:], d.buf)
+	enc := binary.LittleEndian
+	enc.PutUint32(s[12:], uint32(len(d.buf)))
+	enc.PutUint64(s[0:], gen)
+	enc.PutUint32(s[8:], sum(s, len(d.buf)))
+
+	if err := d.mmap.Sync(); err != nil {
+		return err
+	}
+
+	d.gen = gen
+	d.dirty = false
+	return nil
+}
+
+// load loads the buffer from the valid slot with the highest generation
+// If the file is empty, the buffer will be nil.
+func (d *Data[T]) load(data []byte) (err error) {
+	var best []byte
+	var gen uint64
+	var legacy bool
+
+	for i := uint64(0); i < 2; i++ {
+		s := slot(data, i)
+		if len(s) < slotHead {
+			return ErrBad
+		}
+
+		g := binary.LittleEndian.Uint64(s[0:])
+		if g == 0 {
+			continue
+		}
+
+		// slots written before the generation was covered by the
+		// checksum are only used when no other slot is valid
+		p, old := check(s)
+		if p != nil && (best == nil || legacy && !old || legacy == old && g > gen) {
+			best, gen, legacy = p, g, old
+		}
+	}
+
+	if best == nil {
+		// the first flush writes to the second slot so the first
+		// slot is still empty if the process crashed while writing it
+		for _, b := range slot(data, 0) {
+			if b != 0 {
+				return ErrBad
+			}
+		}
+
+		return nil
+	}
+
+	d.gen = gen
+	d.buf = append([]byte{}, best...)
+	return nil
+}
+
+// slot returns the slot used to store given generation
+func slot(data []byte, gen uint64) (s []byte) {
+	half := len(data) / 2
+	if gen%2 == 0 {
+		return data[:half]
+	}
+
+	return data[half : 2*half]
+}
+
+// check returns the buffer if the slot has a valid checksum. Older files
+// have a checksum which does not cover the generation (legacy slots).
+func check(s []byte) (p []byte, legacy bool) {
+	enc := binary.LittleEndian
+	want := enc.Uint32(s[8:])
+	sz := int(enc.Uint32(s[12:]))
+
+	if sz > len(s)-slotHead {
+		return nil, false
+	}
+
+	p = s[slotHead : slotHead+sz]
+	if sum(s, sz) == want {
+		return p, false
+	}
+
+	if crc32.ChecksumIEEE(s[12:slotHead+sz]) == want {
+		return p, true
+	}
+
+	return nil, false
+}
+
+// sum calculates the checksum of the generation, the length and the buffer
+func sum(s []byte, sz int) uint32 {
+	c := crc32.ChecksumIEEE(s[0:8])
+	return crc32.Update(c, crc32.IEEETable, s[12:slotHead+sz])
+}
@@ -0,0 +1,291 @@
+package fbdata
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+const (
+	tmpfile = "/tmp/test-fbdata"
+)
+
+// root is used instead of a generated flatbuffers accessor
+func root(buf []byte) string {
+	return string(buf)
+}
+
+func setup(t *testing.T) {
+	if err := os.RemoveAll(tmpfile); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetSync(t *testing.T) {
+	setup(t)
+	defer os.RemoveAll(tmpfile)
+
+	d, err := Open(tmpfile, 128, root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := d.Get(); ok {
+		t.Fatal("should not have a buffer")
+	}
+
+	for _, v := range []string{"first", "second"} {
+		if err := d.Set([]byte(v)); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := d.Sync(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if v, ok := d.Get(); !ok || v != "second" {
+		t.Fatal("wrong value", v)
+	}
+
+	if err := d.Set(make([]byte, 64-slotHead+1)); err != ErrFull {
+		t.Fatal("expected ErrFull")
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Sync(); err != ErrClosed {
+		t.Fatal("expected ErrClosed")
+	}
+
+	d, err = Open(tmpfile, 128, root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer d.Close()
+
+	if v, ok := d.Get(); !ok || v != "second" {
+		t.Fatal("wrong value", v)
+	}
+}
+
+func TestZeroOptions(t *testing.T) {
+	setup(t)
+	defer os.RemoveAll(tmpfile)
+
+	d, err := Open(tmpfile, 128, root, &Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer d.Close()
+
+	if err := d.Set([]byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Sync(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTorn(t *testing.T) {
+	setup(t)
+	defer os.RemoveAll(tmpfile)
+
+	d, err := Open(tmpfile, 128, root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{"first", "second"} {
+		d.Set([]byte(v))
+		if err := d.Sync(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// corrupt the slot with the latest generation (2)
+	d.tick.Stop()
+	d.mmap.Data[slotHead] = 'x'
+	d.mmap.Close()
+
+	d, err = Open(tmpfile, 128, root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer d.Close()
+
+	if v, ok := d.Get(); !ok || v != "first" {
+		t.Fatal("previous value should be loaded", v)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	setup(t)
+	defer os.RemoveAll(tmpfile)
+
+	w, err := Open(tmpfile, 128, root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+
+	w.Set([]byte("first"))
+	if err := w.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(chan struct{}, 10)
+	onchg := func() { changes <- struct{}{} }
+	opts := &Options{ReadOnly: true, ReloadInterval: 5 * time.Millisecond, OnChange: onchg}
+	r, err := Open(tmpfile, 0, root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer r.Close()
+
+	if v, ok := r.Get(); !ok || v != "first" {
+		t.Fatal("wrong value", v)
+	}
+
+	if err := r.Set([]byte("x")); err != ErrReadOnly {
+		t.Fatal("expected ErrReadOnly")
+	}
+
+	if err := r.Sync(); err != ErrReadOnly {
+		t.Fatal("expected ErrReadOnly")
+	}
+
+	w.Set([]byte("second"))
+	if err := w.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("changes should be reloaded")
+	}
+
+	if v, _ := r.Get(); v != "second" {
+		t.Fatal("wrong value", v)
+	}
+}
+
+func TestTornFirst(t *testing.T) {
+	setup(t)
+	defer os.RemoveAll(tmpfile)
+
+	d, err := Open(tmpfile, 128, root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.Set([]byte("first"))
+	if err := d.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// corrupt the slot written by the first flush (1)
+	d.tick.Stop()
+	d.mmap.Data[64+slotHead] = 'x'
+	d.mmap.Close()
+
+	d, err = Open(tmpfile, 128, root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer d.Close()
+
+	if _, ok := d.Get(); ok {
+		t.Fatal("should not have a buffer")
+	}
+}
+
+func TestTornGen(t *testing.T) {
+	setup(t)
+	defer os.RemoveAll(tmpfile)
+
+	d, err := Open(tmpfile, 128, root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{"first", "second", "third"} {
+		d.Set([]byte(v))
+		if err := d.Sync(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a torn write changed the generation of the older slot (2)
+	d.tick.Stop()
+	binary.LittleEndian.PutUint64(d.mmap.Data[0:], 4)
+	d.mmap.Close()
+
+	d, err = Open(tmpfile, 128, root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer d.Close()
+
+	if v, ok := d.Get(); !ok || v != "third" {
+		t.Fatal("latest value should be loaded", v)
+	}
+}
+
+func TestLegacy(t *testing.T) {
+	setup(t)
+	defer os.RemoveAll(tmpfile)
+
+	// slot 1 written with a checksum which does not cover the generation
+	data := make([]byte, 128)
+	copy(data[64+slotHead:], "old")
+	binary.LittleEndian.PutUint64(data[64:], 1)
+	binary.LittleEndian.PutUint32(data[64+12:], 3)
+	binary.LittleEndian.PutUint32(data[64+8:], crc32.ChecksumIEEE(data[64+12:64+slotHead+3]))
+
+	if err := ioutil.WriteFile(tmpfile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := Open(tmpfile, 128, root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer d.Close()
+
+	if v, ok := d.Get(); !ok || v != "old" {
+		t.Fatal("legacy value should be loaded", v)
+	}
+}
+
+func TestBad(t *testing.T) {
+	setup(t)
+	defer os.RemoveAll(tmpfile)
+
+	f, err := os.Create(tmpfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.Write([]byte("not a data file"))
+	f.Close()
+
+	if _, err := Open(tmpfile, 128, root, &Options{ReadOnly: true}); err != ErrBad {
+		t.Fatal("expected ErrBad", err)
+	}
+}
@@ -0,0 +1,88 @@
+// Package filestore presents a single file through the segments.Store
+// interface. This can be used to run code written for segmented stores
+// with ordinary files without using a segmented directory layout.
+package filestore
+
+import (
+	"os"
+)
+
+// Store wraps an os.File to implement the segments.Store interface.
+// Slices are copies of file data; changing them will not change the file.
+type Store struct {
+	*os.File
+}
+
+// New creates a store using given file. Closing the store closes the file.
+func New(file *os.File) (s *Store) {
+	return &Store{file}
+}
+
+// Open opens or creates a file on given path and creates a store with it.
+func Open(path string) (s *Store, err error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(file), nil
+}
+
+// Slice implements the fs.Slicer interface
+func (s *Store) Slice(sz int64) (p []byte, err error) {
+	p = make([]byte, sz)
+	n, err := s.Read(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return p[:n], nil
+}
+
+// SliceAt implements the fs.SlicerAt interface
+func (s *Store) SliceAt(sz, off int64) (p []byte, err error) {
+	p = make([]byte, sz)
+	n, err := s.ReadAt(p, off)
+	if n == 0 && err != nil {
+		return nil, err
+	}
+
+	return p[:n], nil
+}
+
+// Ensure implements the fs.Ensurer interface
+// The file is extended with zeroes if it's smaller than off.
+func (s *Store) Ensure(off int64) (err error) {
+	sz, err := s.Size()
+	if err != nil {
+		return err
+	}
+
+	if sz >= off {
+		return nil
+	}
+
+	return s.File.Truncate(off)
+}
+
+// SyncRange implements the fs.SyncRanger interface
+// Files are always synced completely.
+func (s *Store) SyncRange(sz, off int64) (err error) {
+	return s.File.Sync()
+}
+
+// Flush implements the fs.Flusher interface
+// Data is not buffered by the store so there's nothing to flush.
+func (s *Store) Flush() (err error) {
+	return nil
+}
+
+// Size implements the fs.Sizer interface
+func (s *Store) Size() (sz int64, err error) {
+	info, err := s.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Size(), nil
+}
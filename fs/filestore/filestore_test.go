@@ -0,0 +1,69 @@
+package filestore
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/kadirahq/go-tools/segments"
+)
+
+var (
+	tmpfile = "/tmp/test-filestore"
+)
+
+func TestStore(t *testing.T) {
+	if err := os.RemoveAll(tmpfile); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpfile)
+
+	s, err := Open(tmpfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Ensure(10); err != nil {
+		t.Fatal(err)
+	}
+
+	if sz, err := s.Size(); err != nil || sz != 10 {
+		t.Fatal("wrong size")
+	}
+
+	e := []byte{1, 2, 3}
+	if _, err := s.WriteAt(e, 8); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := s.SliceAt(5, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(p, e) {
+		t.Fatal("wrong values")
+	}
+
+	if err := s.SyncRange(3, 8); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Truncate(9); err != nil {
+		t.Fatal(err)
+	}
+
+	if sz, err := s.Size(); err != nil || sz != 9 {
+		t.Fatal("wrong size")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImpl(t *testing.T) {
+	// throws error if it doesn't
+	var _ segments.Store = &Store{}
+}
@@ -23,3 +23,34 @@ type Slicer interface {
 type Ensurer interface {
 	Ensure(off int64) (err error)
 }
+
+// Truncator interface provides a Truncate method which will
+// change the size of the data storage to the given size.
+type Truncator interface {
+	Truncate(sz int64) (err error)
+}
+
+// Sizer interface provides a Size method which will
+// return the current size of the data storage.
+type Sizer interface {
+	Size() (sz int64, err error)
+}
+
+// Flusher interface provides a Flush method which will write
+// buffered data to the underlying storage without syncing it.
+type Flusher interface {
+	Flush() (err error)
+}
+
+// SyncRanger interface provides a SyncRange method which will permenantly
+// store data in the given range to a device (eg. hard-disk)
+type SyncRanger interface {
+	SyncRange(sz, off int64) (err error)
+}
+
+// SlicerV interface provides a SliceVAt method which will fetch a set of
+// byte slices from an in-memory data storage. Unlike SliceAt, it does not
+// stop at internal boundaries (eg. segments) and returns all requested data.
+type SlicerV interface {
+	SliceVAt(sz, off int64) (ps [][]byte, err error)
+}
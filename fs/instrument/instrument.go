@@ -0,0 +1,108 @@
+// Package instrument wraps a segments.Store to record metrics for each
+// operation. Number of calls, number of bytes, errors and time spent on
+// each operation type are tracked in a monitor metric store.
+package instrument
+
+import (
+	"time"
+
+	"github.com/kadirahq/go-tools/monitor"
+	"github.com/kadirahq/go-tools/segments"
+)
+
+// operation types
+const (
+	opRead  = "read"
+	opWrite = "write"
+	opSlice = "slice"
+	opSync  = "sync"
+)
+
+// Store is a segments.Store which records metrics for each operation.
+// For each operation type (read, write, slice, sync), these metrics
+// are tracked as counters: "<op>.count", "<op>.bytes", "<op>.errors"
+// and "<op>.time" (total time spent in nanoseconds).
+type Store struct {
+	segments.Store
+	mon *monitor.Store
+}
+
+// New wraps the store to track metrics in given monitor metric store.
+func New(s segments.Store, m *monitor.Store) *Store {
+	for _, op := range []string{opRead, opWrite, opSlice, opSync} {
+		m.Register(op+".count", monitor.Counter)
+		m.Register(op+".bytes", monitor.Counter)
+		m.Register(op+".errors", monitor.Counter)
+		m.Register(op+".time", monitor.Counter)
+	}
+
+	return &Store{Store: s, mon: m}
+}
+
+// Read implements the io.Reader interface
+func (s *Store) Read(p []byte) (n int, err error) {
+	defer s.track(opRead, time.Now(), &n, &err)
+	return s.Store.Read(p)
+}
+
+// Write implements the io.Writer interface
+func (s *Store) Write(p []byte) (n int, err error) {
+	defer s.track(opWrite, time.Now(), &n, &err)
+	return s.Store.Write(p)
+}
+
+// Slice implements the fs.Slicer interface
+func (s *Store) Slice(sz int64) (p []byte, err error) {
+	var n int
+	defer s.track(opSlice, time.Now(), &n, &err)
+	p, err = s.Store.Slice(sz)
+	n = len(p)
+	return p, err
+}
+
+// ReadAt implements the io.ReaderAt interface
+func (s *Store) ReadAt(p []byte, off int64) (n int, err error) {
+	defer s.track(opRead, time.Now(), &n, &err)
+	return s.Store.ReadAt(p, off)
+}
+
+// WriteAt implements the io.WriterAt interface
+func (s *Store) WriteAt(p []byte, off int64) (n int, err error) {
+	defer s.track(opWrite, time.Now(), &n, &err)
+	return s.Store.WriteAt(p, off)
+}
+
+// SliceAt implements the fs.SlicerAt interface
+func (s *Store) SliceAt(sz, off int64) (p []byte, err error) {
+	var n int
+	defer s.track(opSlice, time.Now(), &n, &err)
+	p, err = s.Store.SliceAt(sz, off)
+	n = len(p)
+	return p, err
+}
+
+// Sync implements the fs.Syncer interface
+func (s *Store) Sync() (err error) {
+	var n int
+	defer s.track(opSync, time.Now(), &n, &err)
+	return s.Store.Sync()
+}
+
+// SyncRange implements the fs.SyncRanger interface
+func (s *Store) SyncRange(sz, off int64) (err error) {
+	n := int(sz)
+	defer s.track(opSync, time.Now(), &n, &err)
+	return s.Store.SyncRange(sz, off)
+}
+
+// track records metrics for an operation. This is called with defer
+// so pointers are used to get values after the operation completes.
+func (s *Store) track(op string, beg time.Time, n *int, err *error) {
+	s.mon.Track(op+".count", 1)
+	s.mon.Track(op+".bytes", int64(*n))
+	s.mon.Track(op+".time", int64(time.Since(beg)))
+
+	if *err != nil {
+		s.mon.Track(op+".errors", 1)
+	}
+}
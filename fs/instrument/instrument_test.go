@@ -0,0 +1,50 @@
+package instrument
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kadirahq/go-tools/fs/memstore"
+	"github.com/kadirahq/go-tools/monitor"
+	"github.com/kadirahq/go-tools/segments"
+)
+
+func TestStore(t *testing.T) {
+	m := monitor.New("test-instrument")
+	ms := memstore.New()
+	s := New(ms, m)
+
+	if _, err := s.WriteAt([]byte{1, 2, 3}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	ms.FailNextWrite(errors.New("test error"))
+	if _, err := s.Write([]byte{1}); err == nil {
+		t.Fatal("should fail")
+	}
+
+	if _, err := s.ReadAt(make([]byte, 2), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	vals := m.Values()
+	exp := map[string]int64{
+		"write.count":  2,
+		"write.bytes":  3,
+		"write.errors": 1,
+		"read.count":   1,
+		"read.bytes":   2,
+		"read.errors":  0,
+	}
+
+	for k, v := range exp {
+		if vals["app.test-instrument:"+k] != v {
+			t.Fatal("wrong value for", k)
+		}
+	}
+}
+
+func TestImpl(t *testing.T) {
+	// throws error if it doesn't
+	var _ segments.Store = &Store{}
+}
@@ -0,0 +1,317 @@
+// Package memstore provides an in-memory implementation of segments.Store.
+// It can be used to test code built on top of stores without using files.
+// Faults can be injected to test how the code handles failing stores.
+package memstore
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrShortRead is returned by ReadAt when the read limit set with
+	// SetReadLimit is less than the number of bytes requested.
+	ErrShortRead = errors.New("short read")
+
+	// ErrClosed is returned when the store is used after closing it.
+	ErrClosed = errors.New("store is closed")
+)
+
+// Store is an in-memory data store which implements segments.Store
+// Unlike segmented stores, slices do not stop at segment boundaries.
+type Store struct {
+	data   []byte
+	offs   int64
+	closed bool
+	mutex  sync.RWMutex
+
+	// fault injection
+	werr   error
+	serr   error
+	rlimit int
+	sdelay time.Duration
+}
+
+// New creates a new empty in-memory store
+func New() (s *Store) {
+	return &Store{}
+}
+
+// NewBytes creates a new in-memory store using given byte slice as its data.
+// The slice is not copied so changes to the store may change the slice.
+// This can be used to create stores with test fixtures (ex. buf.Bytes()).
+func NewBytes(p []byte) (s *Store) {
+	return &Store{data: p}
+}
+
+// FailNextWrite makes the next Write or WriteAt call fail with given error
+// without writing any data. Use a nil error to cancel a pending failure.
+func (s *Store) FailNextWrite(err error) {
+	s.mutex.Lock()
+	s.werr = err
+	s.mutex.Unlock()
+}
+
+// FailNextSync makes the next Sync or SyncRange call fail with given error.
+// Use a nil error to cancel a pending failure.
+func (s *Store) FailNextSync(err error) {
+	s.mutex.Lock()
+	s.serr = err
+	s.mutex.Unlock()
+}
+
+// SetReadLimit limits the number of bytes returned by a single read call.
+// Read calls return fewer bytes and ReadAt calls also return ErrShortRead.
+// Use zero to remove the limit.
+func (s *Store) SetReadLimit(n int) {
+	s.mutex.Lock()
+	s.rlimit = n
+	s.mutex.Unlock()
+}
+
+// SetSyncDelay makes Sync and SyncRange calls take at least given duration.
+func (s *Store) SetSyncDelay(d time.Duration) {
+	s.mutex.Lock()
+	s.sdelay = d
+	s.mutex.Unlock()
+}
+
+// Read implements the io.Reader interface
+func (s *Store) Read(p []byte) (n int, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, err = s.readAt(p, s.offs)
+	if err == ErrShortRead {
+		err = nil
+	}
+
+	s.offs += int64(n)
+	return n, err
+}
+
+// Write implements the io.Writer interface
+func (s *Store) Write(p []byte) (n int, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, err = s.writeAt(p, s.offs)
+	s.offs += int64(n)
+	return n, err
+}
+
+// Slice implements the fs.Slicer interface
+func (s *Store) Slice(sz int64) (p []byte, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	p, err = s.sliceAt(sz, s.offs)
+	s.offs += int64(len(p))
+	return p, err
+}
+
+// Seek implements the io.Seeker interface
+func (s *Store) Seek(offset int64, whence int) (off int64, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch whence {
+	case 0:
+		// from start
+		s.offs = offset
+	case 1:
+		// from current
+		s.offs += offset
+	case 2:
+		// from end
+		s.offs = int64(len(s.data)) + offset
+	}
+
+	return s.offs, nil
+}
+
+// ReadAt implements the io.ReaderAt interface
+func (s *Store) ReadAt(p []byte, off int64) (n int, err error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.readAt(p, off)
+}
+
+// WriteAt implements the io.WriterAt interface
+func (s *Store) WriteAt(p []byte, off int64) (n int, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.writeAt(p, off)
+}
+
+// SliceAt implements the fs.SlicerAt interface
+// Sliced data may not be used after growing the store.
+func (s *Store) SliceAt(sz, off int64) (p []byte, err error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.sliceAt(sz, off)
+}
+
+// Ensure implements the fs.Ensurer interface
+func (s *Store) Ensure(off int64) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	s.grow(off)
+	return nil
+}
+
+// Sync implements the fs.Syncer interface
+func (s *Store) Sync() (err error) {
+	return s.sync()
+}
+
+// SyncRange implements the fs.SyncRanger interface
+func (s *Store) SyncRange(sz, off int64) (err error) {
+	return s.sync()
+}
+
+// Flush implements the fs.Flusher interface
+func (s *Store) Flush() (err error) {
+	return nil
+}
+
+// Size implements the fs.Sizer interface
+func (s *Store) Size() (sz int64, err error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return int64(len(s.data)), nil
+}
+
+// Truncate implements the fs.Truncator interface
+func (s *Store) Truncate(sz int64) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	if sz < int64(len(s.data)) {
+		s.data = s.data[:sz]
+	} else {
+		s.grow(sz)
+	}
+
+	return nil
+}
+
+// Close implements the io.Closer interface
+func (s *Store) Close() (err error) {
+	s.mutex.Lock()
+	s.closed = true
+	s.mutex.Unlock()
+	return nil
+}
+
+// Bytes returns a copy of all data in the store
+func (s *Store) Bytes() (p []byte) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return append([]byte{}, s.data...)
+}
+
+func (s *Store) readAt(p []byte, off int64) (n int, err error) {
+	if s.closed {
+		return 0, ErrClosed
+	}
+
+	if off >= int64(len(s.data)) {
+		return 0, io.EOF
+	}
+
+	if s.rlimit > 0 && len(p) > s.rlimit {
+		n = copy(p[:s.rlimit], s.data[off:])
+		return n, ErrShortRead
+	}
+
+	n = copy(p, s.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (s *Store) writeAt(p []byte, off int64) (n int, err error) {
+	if s.closed {
+		return 0, ErrClosed
+	}
+
+	if err := s.werr; err != nil {
+		s.werr = nil
+		return 0, err
+	}
+
+	s.grow(off + int64(len(p)))
+	n = copy(s.data[off:], p)
+	return n, nil
+}
+
+func (s *Store) sliceAt(sz, off int64) (p []byte, err error) {
+	if s.closed {
+		return nil, ErrClosed
+	}
+
+	if off >= int64(len(s.data)) {
+		return nil, io.EOF
+	}
+
+	end := off + sz
+	if end > int64(len(s.data)) {
+		end = int64(len(s.data))
+	}
+
+	return s.data[off:end], nil
+}
+
+func (s *Store) sync() (err error) {
+	s.mutex.Lock()
+	delay := s.sdelay
+	err = s.serr
+	s.serr = nil
+	closed := s.closed
+	s.mutex.Unlock()
+
+	if closed {
+		return ErrClosed
+	}
+
+	time.Sleep(delay)
+	return err
+}
+
+// grow makes sure that the data slice has at least sz bytes
+// This should be called while holding the write lock.
+func (s *Store) grow(sz int64) {
+	if sz <= int64(len(s.data)) {
+		return
+	}
+
+	if sz <= int64(cap(s.data)) {
+		n := len(s.data)
+		s.data = s.data[:sz]
+
+		// may have data from before truncating
+		for i := n; i < len(s.data); i++ {
+			s.data[i] = 0
+		}
+
+		return
+	}
+
+	data := make([]byte, sz, 2*sz)
+	copy(data, s.data)
+	s.data = data
+}
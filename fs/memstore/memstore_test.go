@@ -0,0 +1,149 @@
+package memstore
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/kadirahq/go-tools/segments"
+)
+
+func TestReadWrite(t *testing.T) {
+	s := New()
+
+	e := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	p := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+
+	if n, err := s.Write(e); err != nil {
+		t.Fatal(err)
+	} else if n != 10 {
+		t.Fatal("short write")
+	}
+
+	if _, err := s.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := s.Read(p); err != nil {
+		t.Fatal(err)
+	} else if n != 10 {
+		t.Fatal("short read")
+	}
+
+	if !bytes.Equal(p, e) {
+		t.Fatal("wrong values")
+	}
+
+	if _, err := s.Read(p); err != io.EOF {
+		t.Fatal("wrong error")
+	}
+
+	if n, err := s.ReadAt(p, 5); err != io.EOF || n != 5 {
+		t.Fatal("wrong result")
+	}
+
+	q, err := s.SliceAt(20, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(q, e[5:]) {
+		t.Fatal("wrong values")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.ReadAt(p, 0); err != ErrClosed {
+		t.Fatal("wrong error")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	s := New()
+
+	if err := s.Ensure(10); err != nil {
+		t.Fatal(err)
+	}
+
+	if sz, err := s.Size(); err != nil || sz != 10 {
+		t.Fatal("wrong size")
+	}
+
+	if _, err := s.WriteAt([]byte{1, 2, 3}, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Truncate(6); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Truncate(8); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(s.Bytes(), []byte{0, 0, 0, 0, 0, 1, 0, 0}) {
+		t.Fatal("wrong values")
+	}
+}
+
+func TestNewBytes(t *testing.T) {
+	buf := bytes.NewBuffer([]byte{1, 2, 3})
+	s := NewBytes(buf.Bytes())
+
+	p := make([]byte, 3)
+	if n, err := s.ReadAt(p, 0); err != nil || n != 3 {
+		t.Fatal("wrong result")
+	}
+
+	if !bytes.Equal(p, buf.Bytes()) {
+		t.Fatal("wrong values")
+	}
+}
+
+func TestFaults(t *testing.T) {
+	s := New()
+	e := errors.New("test error")
+
+	s.FailNextWrite(e)
+	if _, err := s.WriteAt([]byte{1}, 0); err != e {
+		t.Fatal("wrong error")
+	}
+
+	if _, err := s.WriteAt([]byte{1, 2, 3}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	s.SetReadLimit(2)
+	p := make([]byte, 3)
+	if n, err := s.ReadAt(p, 0); err != ErrShortRead || n != 2 {
+		t.Fatal("wrong result")
+	}
+
+	if n, err := s.Read(p); err != nil || n != 2 {
+		t.Fatal("wrong result")
+	}
+
+	s.FailNextSync(e)
+	if err := s.Sync(); err != e {
+		t.Fatal("wrong error")
+	}
+
+	s.SetSyncDelay(10 * time.Millisecond)
+	start := time.Now()
+	if err := s.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if time.Since(start) < 10*time.Millisecond {
+		t.Fatal("should be delayed")
+	}
+}
+
+func TestImpl(t *testing.T) {
+	// throws error if it doesn't
+	var _ segments.Store = &Store{}
+}
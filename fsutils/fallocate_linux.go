@@ -0,0 +1,65 @@
+package fsutils
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	fallocKeepSize  = 0x01
+	fallocPunchHole = 0x02
+
+	seekData = 3
+	seekHole = 4
+)
+
+// fallocate allocates disk space for a file (mode 0 extends the file)
+func fallocate(file *os.File, size int64) (err error) {
+	if size == 0 {
+		return nil
+	}
+
+	err = syscall.Fallocate(int(file.Fd()), 0, 0, size)
+	return unsupported(err)
+}
+
+// punchHole deallocates a range of a file with fallocate
+func punchHole(file *os.File, off, sz int64) (err error) {
+	err = syscall.Fallocate(int(file.Fd()), fallocKeepSize|fallocPunchHole, off, sz)
+	return unsupported(err)
+}
+
+// dataExtents finds data ranges with lseek (SEEK_DATA and SEEK_HOLE)
+func dataExtents(file *os.File, size int64) (extents []Extent, err error) {
+	fd := int(file.Fd())
+
+	for off := int64(0); off < size; {
+		start, err := syscall.Seek(fd, off, seekData)
+		if err == syscall.ENXIO {
+			// no data after the offset
+			break
+		} else if err != nil {
+			return nil, unsupported(err)
+		}
+
+		end, err := syscall.Seek(fd, start, seekHole)
+		if err != nil {
+			return nil, unsupported(err)
+		}
+
+		extents = append(extents, Extent{Offset: start, Length: end - start})
+		off = end
+	}
+
+	return extents, nil
+}
+
+// unsupported converts errors used when the kernel or the file system does
+// not support an operation to ErrUnsupported
+func unsupported(err error) error {
+	if err == syscall.EOPNOTSUPP || err == syscall.ENOSYS || err == syscall.EINVAL {
+		return ErrUnsupported
+	}
+
+	return err
+}
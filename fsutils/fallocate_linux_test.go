@@ -0,0 +1,37 @@
+package fsutils
+
+import (
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestFallocateBlocks(t *testing.T) {
+	if err := os.RemoveAll(tmpfile); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpfile)
+
+	file, err := os.Create(tmpfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	size := int64(zeroChunk)
+	if err := FallocateFile(file, size); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// st_blocks is in 512 byte units
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && st.Blocks*512 < size {
+		t.Fatal("disk space should be allocated", st.Blocks)
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+// +build !linux
+
+package fsutils
+
+import (
+	"os"
+)
+
+// fallocate is not supported on this platform
+func fallocate(file *os.File, size int64) (err error) {
+	return ErrUnsupported
+}
+
+// punchHole is not supported on this platform
+func punchHole(file *os.File, off, sz int64) (err error) {
+	return ErrUnsupported
+}
+
+// dataExtents is not supported on this platform
+func dataExtents(file *os.File, size int64) (extents []Extent, err error) {
+	return nil, ErrUnsupported
+}
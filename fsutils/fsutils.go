@@ -0,0 +1,99 @@
+// Package fsutils has helpers for allocating and freeing disk space used by
+// files and for locking files. They use fallocate where it's available and
+// fall back to portable (but slower) implementations on other platforms and
+// file systems. Files are locked with flock on unix platforms.
+package fsutils
+
+import (
+	"errors"
+	"os"
+)
+
+// zeroChunk is the size of chunks written when space cannot be allocated
+const zeroChunk = 1024 * 1024
+
+var (
+	// ErrUnsupported is returned when the platform or the file system does
+	// not support an operation (ex. punching holes in files).
+	ErrUnsupported = errors.New("operation is not supported")
+)
+
+// Extent is a range of a file
+type Extent struct {
+	Offset int64
+	Length int64
+}
+
+// FallocateFile makes sure that disk space is allocated for the first size
+// bytes of the file. The file grows if it's smaller but existing data is not
+// changed. Allocating space when creating files avoids running out of disk
+// space later while writing (which crashes processes using memory maps).
+// Zeroes are written after the end of the file if the platform or the file
+// system does not support fallocate.
+func FallocateFile(file *os.File, size int64) (err error) {
+	err = fallocate(file, size)
+	if err != ErrUnsupported {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	off := info.Size()
+	if off >= size {
+		return nil
+	}
+
+	buf := make([]byte, zeroChunk)
+	for off < size {
+		p := buf
+		if rem := size - off; rem < int64(len(p)) {
+			p = p[:rem]
+		}
+
+		n, err := file.WriteAt(p, off)
+		if err != nil {
+			return err
+		}
+
+		off += int64(n)
+	}
+
+	return nil
+}
+
+// PunchHole frees disk space used by a range of a file. The file size does
+// not change and the range is read as zeroes. ErrUnsupported is returned if
+// the platform or the file system does not support it.
+func PunchHole(file *os.File, off, sz int64) (err error) {
+	if sz <= 0 {
+		return nil
+	}
+
+	return punchHole(file, off, sz)
+}
+
+// DataExtents returns ranges of the file which have data (ranges which are
+// not holes) sorted by their offsets. If the platform or the file system
+// cannot find holes, the whole file is returned as one extent. This uses the
+// file descriptor offset so it should not be used with Read/Write/Seek.
+func DataExtents(file *os.File) (extents []Extent, err error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := info.Size()
+	if size == 0 {
+		return nil, nil
+	}
+
+	extents, err = dataExtents(file, size)
+	if err == ErrUnsupported {
+		return []Extent{{Offset: 0, Length: size}}, nil
+	}
+
+	return extents, err
+}
@@ -0,0 +1,130 @@
+package fsutils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+var (
+	tmpfile = "/tmp/test-fsutils"
+)
+
+func TestFallocateFile(t *testing.T) {
+	if err := os.RemoveAll(tmpfile); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpfile)
+
+	if err := ioutil.WriteFile(tmpfile, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.OpenFile(tmpfile, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	size := int64(zeroChunk + 10)
+	if err := FallocateFile(file, size); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(tmpfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if int64(len(data)) != size {
+		t.Fatal("wrong size")
+	}
+
+	if !bytes.HasPrefix(data, []byte("hello")) {
+		t.Fatal("data should not change")
+	}
+
+	for _, b := range data[5:] {
+		if b != 0 {
+			t.Fatal("new space should have zeroes")
+		}
+	}
+
+	// smaller sizes should not truncate the file
+	if err := FallocateFile(file, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err := file.Stat(); err != nil {
+		t.Fatal(err)
+	} else if info.Size() != size {
+		t.Fatal("file should not shrink")
+	}
+}
+
+func TestPunchHole(t *testing.T) {
+	if err := os.RemoveAll(tmpfile); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpfile)
+
+	data := make([]byte, 3*zeroChunk)
+	for i := range data {
+		data[i] = 1
+	}
+
+	if err := ioutil.WriteFile(tmpfile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.OpenFile(tmpfile, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	if extents, err := DataExtents(file); err != nil {
+		t.Fatal(err)
+	} else if len(extents) != 1 || extents[0] != (Extent{0, 3 * zeroChunk}) {
+		t.Fatal("wrong extents", extents)
+	}
+
+	err = PunchHole(file, zeroChunk, zeroChunk)
+	if err == ErrUnsupported {
+		t.Skip("punching holes is not supported")
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := ioutil.ReadFile(tmpfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(after) != len(data) {
+		t.Fatal("file size should not change")
+	}
+
+	for i, b := range after {
+		hole := i >= zeroChunk && i < 2*zeroChunk
+		if (hole && b != 0) || (!hole && b != 1) {
+			t.Fatal("wrong data after punching a hole")
+		}
+	}
+
+	extents, err := DataExtents(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range extents {
+		if e.Offset < 2*zeroChunk && e.Offset+e.Length > zeroChunk {
+			t.Fatal("hole should not be in data extents", extents)
+		}
+	}
+}
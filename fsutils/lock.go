@@ -0,0 +1,45 @@
+package fsutils
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// lockPoll is the interval used to retry locking files in WaitLock
+const lockPoll = 10 * time.Millisecond
+
+var (
+	// ErrLocked is returned when a file is locked by another process
+	// (or by another open file in the same process).
+	ErrLocked = errors.New("file is locked")
+)
+
+// LockFile takes an advisory lock on the file without waiting. Exclusive
+// locks are used by writers and shared locks by readers. ErrLocked is
+// returned if a conflicting lock is held. Locks are released when the file
+// is closed. ErrUnsupported is returned if the platform cannot lock files.
+func LockFile(file *os.File, exclusive bool) (err error) {
+	return lockFile(file, exclusive)
+}
+
+// UnlockFile releases a lock taken with LockFile or WaitLock.
+func UnlockFile(file *os.File) (err error) {
+	return unlockFile(file)
+}
+
+// WaitLock is similar to LockFile but it retries until the lock is taken or
+// the timeout expires. ErrLocked is returned if the lock was not taken.
+// Use 0 as the timeout to try only once.
+func WaitLock(file *os.File, exclusive bool, timeout time.Duration) (err error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		err = lockFile(file, exclusive)
+		if err != ErrLocked || !time.Now().Before(deadline) {
+			return err
+		}
+
+		time.Sleep(lockPoll)
+	}
+}
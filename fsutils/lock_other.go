@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package fsutils
+
+import (
+	"os"
+)
+
+// lockFile is not supported on this platform
+func lockFile(file *os.File, exclusive bool) (err error) {
+	return ErrUnsupported
+}
+
+// unlockFile is not supported on this platform
+func unlockFile(file *os.File) (err error) {
+	return ErrUnsupported
+}
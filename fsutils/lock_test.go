@@ -0,0 +1,82 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package fsutils
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLockFile(t *testing.T) {
+	if err := os.RemoveAll(tmpfile); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpfile)
+
+	// flock locks conflict between open files in the same process
+	open := func() *os.File {
+		file, err := os.OpenFile(tmpfile, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return file
+	}
+
+	f1, f2 := open(), open()
+	defer f1.Close()
+	defer f2.Close()
+
+	// shared locks do not conflict
+	if err := LockFile(f1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LockFile(f2, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := UnlockFile(f2); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LockFile(f2, true); err != ErrLocked {
+		t.Fatal("expected ErrLocked")
+	}
+
+	start := time.Now()
+	if err := WaitLock(f2, true, 50*time.Millisecond); err != ErrLocked {
+		t.Fatal("expected ErrLocked")
+	} else if time.Since(start) < 50*time.Millisecond {
+		t.Fatal("should wait until the timeout")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		UnlockFile(f1)
+		close(done)
+	}()
+
+	if err := WaitLock(f2, true, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	<-done
+
+	if err := LockFile(f1, false); err != ErrLocked {
+		t.Fatal("expected ErrLocked")
+	}
+
+	// locks are released when files are closed
+	if err := f2.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LockFile(f1, true); err != nil {
+		t.Fatal(err)
+	}
+}
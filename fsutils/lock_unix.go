@@ -0,0 +1,39 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package fsutils
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile locks the file with flock (LOCK_NB makes it non-blocking)
+func lockFile(file *os.File, exclusive bool) (err error) {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+
+	for {
+		err = syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+
+	switch err {
+	case syscall.EWOULDBLOCK:
+		return ErrLocked
+	case syscall.EOPNOTSUPP, syscall.ENOLCK:
+		// ex. network file systems without lock support
+		return ErrUnsupported
+	}
+
+	return err
+}
+
+// unlockFile releases the flock lock
+func unlockFile(file *os.File) (err error) {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
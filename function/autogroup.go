@@ -0,0 +1,67 @@
+package function
+
+import (
+	"sync"
+	"time"
+)
+
+// AutoGroup is a Group which flushes itself periodically and also when
+// a given number of run calls are waiting. This removes the need to run
+// a separate goroutine with a ticker to flush the group.
+type AutoGroup struct {
+	*Group
+	interval time.Duration
+	stopper  chan struct{}
+	stopped  chan struct{}
+	stoponce sync.Once
+}
+
+// NewAutoGroup creates a new self flushing group using a function.
+// The group is flushed every interval and when maxPending run calls
+// are collected. Use zero to disable either of these conditions.
+func NewAutoGroup(fn func() error, interval time.Duration, maxPending int) (g *AutoGroup) {
+	g = &AutoGroup{
+		Group:    NewGroupE(fn),
+		interval: interval,
+		stopper:  make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	g.SetBatchSize(maxPending)
+	go g.loop()
+	return g
+}
+
+// Stop stops flushing the group automatically. The group is flushed once
+// more to release all waiting goroutines. Run calls made after stopping
+// the group will block until the Flush method is called manually.
+func (g *AutoGroup) Stop() (err error) {
+	g.stoponce.Do(func() {
+		close(g.stopper)
+		<-g.stopped
+		err = g.Flush()
+	})
+
+	return err
+}
+
+// loop flushes the group until the group is stopped
+func (g *AutoGroup) loop() {
+	defer close(g.stopped)
+
+	var tick <-chan time.Time
+	if g.interval > 0 {
+		ticker := time.NewTicker(g.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-tick:
+			g.Flush()
+		case <-g.stopper:
+			return
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package function
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAutoGroupInterval(t *testing.T) {
+	var n int64
+
+	g := NewAutoGroup(func() error {
+		atomic.AddInt64(&n, 1)
+		return nil
+	}, 10*time.Millisecond, 0)
+
+	defer g.Stop()
+
+	for i := 0; i < 3; i++ {
+		if err := g.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if atomic.LoadInt64(&n) != 3 {
+		t.Fatal("n != 3")
+	}
+}
+
+func TestAutoGroupMaxPending(t *testing.T) {
+	var n int64
+
+	g := NewAutoGroup(func() error {
+		atomic.AddInt64(&n, 1)
+		return nil
+	}, 0, 10)
+
+	defer g.Stop()
+
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			errs <- g.Run()
+		}()
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if atomic.LoadInt64(&n) != 1 {
+		t.Fatal("n != 1")
+	}
+}
+
+func TestAutoGroupStop(t *testing.T) {
+	g := NewAutoGroup(func() error {
+		return nil
+	}, 0, 0)
+
+	done := make(chan error)
+	go func() {
+		done <- g.Run()
+	}()
+
+	// wait for the goroutine to join the batch
+	time.Sleep(10 * time.Millisecond)
+
+	if err := g.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// stopping again should not fail
+	if err := g.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
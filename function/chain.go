@@ -0,0 +1,43 @@
+package function
+
+// Step is a single step in a chain. The Undo function is used to rollback
+// the step when a later step fails. Undo can be nil if it's not required.
+type Step struct {
+	Do   func() error
+	Undo func() error
+}
+
+// Chain creates a function which runs given steps in order. If a step fails,
+// completed steps are rolled back in reverse order and the error is returned.
+// Errors returned by Undo functions are ignored when rolling back steps.
+func Chain(steps ...Step) func() error {
+	return func() (err error) {
+		for i, step := range steps {
+			if err := step.Do(); err != nil {
+				for j := i - 1; j >= 0; j-- {
+					if undo := steps[j].Undo; undo != nil {
+						undo()
+					}
+				}
+
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// Compose creates a function which runs given functions in order.
+// It stops at the first error and returns it without rolling back.
+func Compose(fns ...func() error) func() error {
+	return func() (err error) {
+		for _, fn := range fns {
+			if err := fn(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
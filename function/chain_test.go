@@ -0,0 +1,61 @@
+package function
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	e := errors.New("test error")
+	res := []string{}
+
+	step := func(name string, fail bool) Step {
+		return Step{
+			Do: func() error {
+				if fail {
+					return e
+				}
+
+				res = append(res, "do:"+name)
+				return nil
+			},
+			Undo: func() error {
+				res = append(res, "undo:"+name)
+				return nil
+			},
+		}
+	}
+
+	fn := Chain(step("a", false), step("b", false), step("c", true))
+	if err := fn(); err != e {
+		t.Fatal("wrong error")
+	}
+
+	exp := []string{"do:a", "do:b", "undo:b", "undo:a"}
+	if !reflect.DeepEqual(res, exp) {
+		t.Fatal("wrong order", res)
+	}
+}
+
+func TestCompose(t *testing.T) {
+	e := errors.New("test error")
+	var n int
+
+	inc := func() error {
+		n++
+		return nil
+	}
+
+	fail := func() error {
+		return e
+	}
+
+	if err := Compose(inc, inc)(); err != nil || n != 2 {
+		t.Fatal("wrong result")
+	}
+
+	if err := Compose(inc, fail, inc)(); err != e || n != 3 {
+		t.Fatal("wrong result")
+	}
+}
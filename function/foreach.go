@@ -0,0 +1,112 @@
+package function
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// Errors is a collection of errors returned by ForEachAll.
+type Errors []error
+
+// Error implements the error interface
+func (e Errors) Error() string {
+	strs := make([]string, len(e))
+	for i, err := range e {
+		strs[i] = err.Error()
+	}
+
+	return strings.Join(strs, "; ")
+}
+
+// ForEach runs the function for each item index from 0 to items-1 using
+// upto n goroutines. It stops at the first error and cancels the context
+// given to the function. The first error is returned after all goroutines
+// complete. Use n <= 0 to run the function for all items in parallel.
+func ForEach(ctx context.Context, n, items int, fn func(ctx context.Context, i int) error) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var once sync.Once
+	run(ctx, n, items, func(i int) {
+		if e := fn(ctx, i); e != nil {
+			once.Do(func() {
+				err = e
+				cancel()
+			})
+		}
+	})
+
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	return err
+}
+
+// ForEachAll runs the function for each item index from 0 to items-1 using
+// upto n goroutines. Unlike ForEach, it runs the function for all items even
+// when some of them fail. All errors are returned in item order as Errors.
+// If the context is done before all items are started, the context error is
+// added after errors of items which were run.
+func ForEachAll(ctx context.Context, n, items int, fn func(ctx context.Context, i int) error) (err error) {
+	errs := make([]error, items)
+	done := make([]bool, items)
+	run(ctx, n, items, func(i int) {
+		errs[i] = fn(ctx, i)
+		done[i] = true
+	})
+
+	var res Errors
+	var skipped bool
+	for i, e := range errs {
+		if e != nil {
+			res = append(res, e)
+		}
+
+		skipped = skipped || !done[i]
+	}
+
+	if skipped {
+		res = append(res, ctx.Err())
+	}
+
+	if res != nil {
+		return res
+	}
+
+	return nil
+}
+
+// run runs the function for item indexes using upto n goroutines.
+// It stops starting new items when the context is done.
+func run(ctx context.Context, n, items int, fn func(i int)) {
+	if n <= 0 || n > items {
+		n = items
+	}
+
+	next := make(chan int)
+	wg := sync.WaitGroup{}
+	wg.Add(n)
+
+	for j := 0; j < n; j++ {
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+
+loop:
+	for i := 0; i < items; i++ {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+
+	close(next)
+	wg.Wait()
+}
@@ -0,0 +1,107 @@
+package function
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestForEach(t *testing.T) {
+	var curr, max, sum int64
+
+	err := ForEach(context.Background(), 3, 100, func(ctx context.Context, i int) error {
+		n := atomic.AddInt64(&curr, 1)
+		defer atomic.AddInt64(&curr, -1)
+
+		for {
+			m := atomic.LoadInt64(&max)
+			if n <= m || atomic.CompareAndSwapInt64(&max, m, n) {
+				break
+			}
+		}
+
+		atomic.AddInt64(&sum, int64(i))
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if max > 3 {
+		t.Fatal("max > 3")
+	}
+
+	if sum != 4950 {
+		t.Fatal("wrong sum")
+	}
+}
+
+func TestForEachError(t *testing.T) {
+	e := errors.New("test error")
+	var n int64
+
+	err := ForEach(context.Background(), 2, 1000, func(ctx context.Context, i int) error {
+		atomic.AddInt64(&n, 1)
+		if i == 1 {
+			return e
+		}
+
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if err != e {
+		t.Fatal("wrong error")
+	}
+
+	if atomic.LoadInt64(&n) == 1000 {
+		t.Fatal("should stop after an error")
+	}
+}
+
+func TestForEachAll(t *testing.T) {
+	e := errors.New("test error")
+
+	err := ForEachAll(context.Background(), 4, 10, func(ctx context.Context, i int) error {
+		if i%5 == 0 {
+			return e
+		}
+
+		return nil
+	})
+
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 2 {
+		t.Fatal("wrong error")
+	}
+
+	if err := ForEachAll(context.Background(), 4, 10, func(ctx context.Context, i int) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestForEachAllCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var count int64
+
+	err := ForEachAll(ctx, 1, 10, func(ctx context.Context, i int) error {
+		if atomic.AddInt64(&count, 1) == 3 {
+			cancel()
+		}
+
+		return nil
+	})
+
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 1 || errs[0] != context.Canceled {
+		t.Fatal("expected context.Canceled", err)
+	}
+
+	if n := atomic.LoadInt64(&count); n >= 10 {
+		t.Fatal("items should not start after cancelling")
+	}
+}
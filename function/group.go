@@ -1,8 +1,8 @@
 package function
 
 import (
+	"context"
 	"sync"
-	"sync/atomic"
 )
 
 // Group wraps a function to run only once when called multiple times.
@@ -10,53 +10,128 @@ import (
 // Calls made before running the payload function will be released.
 // This can be useful for synchronizing data (ex. running file.Sync).
 type Group struct {
-	running   uint32
-	payload   func()
-	primary   sync.RWMutex
-	secondary sync.RWMutex
+	payload func() error
+	batch   *batch
+	batchmx sync.Mutex
+	flushmx sync.Mutex
+	limit   int
+}
+
+// batch collects run calls made between two flush calls.
+// The done channel is closed when the batch gets flushed.
+type batch struct {
+	done    chan struct{}
+	pending bool
+	count   int
+	err     error
+}
+
+func newBatch() *batch {
+	return &batch{done: make(chan struct{})}
 }
 
 // NewGroup creates a new batch instance using a function.
 func NewGroup(fn func()) (g *Group) {
-	g = &Group{payload: fn}
-	g.primary.Lock()
-	return g
+	return NewGroupE(func() error {
+		fn()
+		return nil
+	})
+}
+
+// NewGroupE creates a new batch instance using a function which can fail.
+// The error returned by the function is returned to all released goroutines.
+func NewGroupE(fn func() error) (g *Group) {
+	return &Group{payload: fn, batch: newBatch()}
 }
 
 // Run blocks calling goroutine until the next flush
-func (g *Group) Run() {
-	// This blocks run calls while a flush is in progress
-	// These will have to wait until the next flush call
-	g.secondary.RLock()
-	g.secondary.RUnlock()
+// and returns the error returned by the payload function.
+func (g *Group) Run() (err error) {
+	b := g.join()
+	<-b.done
+	return b.err
+}
+
+// RunContext blocks calling goroutine until the next flush or until the
+// context is done. If the context is done before the flush completes,
+// the context error is returned. The payload may still run with the batch.
+func (g *Group) RunContext(ctx context.Context) (err error) {
+	b := g.join()
 
-	// mark that the task needs to run
-	atomic.StoreUint32(&g.running, 1)
+	select {
+	case <-b.done:
+		return b.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
-	// This will collect run calls until a flush occurs
-	// This will be released immediately after the flush
-	g.primary.RLock()
-	g.primary.RUnlock()
+// SetBatchSize sets the number of run calls to collect before flushing the
+// group automatically. The run call which fills the batch runs the flush.
+// Use zero to disable it and flush only when Flush is called explicitly.
+func (g *Group) SetBatchSize(n int) {
+	g.batchmx.Lock()
+	g.limit = n
+	g.batchmx.Unlock()
 }
 
 // Flush releases currently waiting goroutines. It ensures that only goroutines
 // which were waiting before running the beforeFlush function gets released.
-func (g *Group) Flush() {
+// The error returned by the payload function is also returned by Flush.
+// If the payload panics, waiting goroutines receive ErrPanic.
+func (g *Group) Flush() (err error) {
+	return g.flush(nil)
+}
+
+// flush flushes the batch if it's still collecting run calls or the current
+// batch if it's nil. It returns immediately if the batch is already flushed.
+func (g *Group) flush(want *batch) (err error) {
+	// Only one flush can run at a time
+	// This also prevents running payload in parallel
+	g.flushmx.Lock()
+	defer g.flushmx.Unlock()
+
 	// Stop collecting run calls for this flush call
 	// Others will have to wait until the next flush call
-	g.secondary.Lock()
+	g.batchmx.Lock()
+	b := g.batch
+	if want != nil && want != b {
+		g.batchmx.Unlock()
+		return nil
+	}
+
+	g.batch = newBatch()
+	g.batchmx.Unlock()
+
+	// Release goroutines waiting on this batch
+	// even if the payload function panics
+	defer close(b.done)
 
 	// Run the payload function only if run is called
-	if atomic.CompareAndSwapUint32(&g.running, 1, 0) {
-		g.payload()
+	if b.pending {
+		// kept if the payload function panics
+		b.err = ErrPanic
+		b.err = g.payload()
 	}
 
-	// Release goroutines currently waiting with run
-	// and immediately lock again to block future runs
-	g.primary.Unlock()
-	g.primary.Lock()
+	return b.err
+}
+
+// join marks that the task needs to run and returns the current batch.
+// The group is flushed if enough run calls are collected for the batch.
+func (g *Group) join() (b *batch) {
+	g.batchmx.Lock()
+	b = g.batch
+	b.pending = true
+	b.count++
+	full := g.limit > 0 && b.count == g.limit
+	g.batchmx.Unlock()
+
+	// the error is returned to every goroutine in the batch
+	// with b.err after the done channel is closed
+	if full {
+		g.flush(b)
+	}
 
-	// Unlock run calls locked during the flush
-	// This will get flushed with next flush call
-	g.secondary.Unlock()
+	return b
 }
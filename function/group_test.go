@@ -1,6 +1,8 @@
 package function
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -57,3 +59,170 @@ func TestGroup(t *testing.T) {
 		t.Fatal("n != 2")
 	}
 }
+
+func TestGroupRunContext(t *testing.T) {
+	var n int64
+
+	g := NewGroup(func() {
+		atomic.AddInt64(&n, 1)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	if err := g.RunContext(ctx); err != context.DeadlineExceeded {
+		t.Fatal("wrong error")
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- g.RunContext(context.Background())
+	}()
+
+	// wait for the goroutine to join the batch
+	time.Sleep(10 * time.Millisecond)
+	g.Flush()
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if atomic.LoadInt64(&n) != 1 {
+		t.Fatal("n != 1")
+	}
+}
+
+func TestGroupError(t *testing.T) {
+	e := errors.New("test error")
+
+	g := NewGroupE(func() error {
+		return e
+	})
+
+	// nothing to run, nothing to fail
+	if err := g.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(10)
+
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			wg.Done()
+			errs <- g.Run()
+		}()
+	}
+
+	wg.Wait()
+	time.Sleep(10 * time.Millisecond)
+
+	if err := g.Flush(); err != e {
+		t.Fatal("wrong error")
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := <-errs; err != e {
+			t.Fatal("wrong error")
+		}
+	}
+}
+
+func TestGroupBatchSize(t *testing.T) {
+	var n int64
+
+	g := NewGroup(func() {
+		atomic.AddInt64(&n, 1)
+	})
+
+	g.SetBatchSize(5)
+
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			errs <- g.Run()
+		}()
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if atomic.LoadInt64(&n) != 2 {
+		t.Fatal("n != 2")
+	}
+}
+
+func TestGroupPanic(t *testing.T) {
+	g := NewGroup(func() {
+		panic("test panic")
+	})
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- g.Run()
+	}()
+
+	// wait for the goroutine to join the batch
+	time.Sleep(10 * time.Millisecond)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+
+		g.Flush()
+	}()
+
+	select {
+	case err := <-errs:
+		if err != ErrPanic {
+			t.Fatal("wrong error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("not released")
+	}
+}
+
+func TestGroupBatchSizeFlush(t *testing.T) {
+	var n int64
+
+	g := NewGroup(func() {
+		atomic.AddInt64(&n, 1)
+	})
+
+	g.SetBatchSize(3)
+
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				g.Flush()
+			}
+		}
+	}()
+
+	errs := make(chan error, 100)
+	for i := 0; i < 100; i++ {
+		go func() {
+			errs <- g.Run()
+		}()
+	}
+
+	// explicit flushes release the rest
+	for i := 0; i < 100; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	close(stop)
+}
@@ -0,0 +1,41 @@
+package function
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ResettableOnce performs an action only once similar to sync.Once but it
+// can be reset to run the action again. This can be useful when resources
+// are closed and opened again (ex. unmapping and mapping a file again).
+// The zero value is ready to use.
+type ResettableOnce struct {
+	done  uint32
+	mutex sync.Mutex
+}
+
+// Do runs the function only if it's the first call after creating or after
+// resetting. Other calls will block until the running function completes.
+func (o *ResettableOnce) Do(fn func()) {
+	// fast path
+	if atomic.LoadUint32(&o.done) == 1 {
+		return
+	}
+
+	// slow path
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.done == 0 {
+		defer atomic.StoreUint32(&o.done, 1)
+		fn()
+	}
+}
+
+// Reset allows the next Do call to run its function.
+// If a function is running, this will wait until it's completed.
+func (o *ResettableOnce) Reset() {
+	o.mutex.Lock()
+	atomic.StoreUint32(&o.done, 0)
+	o.mutex.Unlock()
+}
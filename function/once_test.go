@@ -0,0 +1,39 @@
+package function
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestResettableOnce(t *testing.T) {
+	var n int
+	var o ResettableOnce
+
+	fn := func() {
+		n++
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(10)
+
+	for i := 0; i < 10; i++ {
+		go func() {
+			o.Do(fn)
+			wg.Done()
+		}()
+	}
+
+	wg.Wait()
+
+	if n != 1 {
+		t.Fatal("n != 1")
+	}
+
+	o.Reset()
+	o.Do(fn)
+	o.Do(fn)
+
+	if n != 2 {
+		t.Fatal("n != 2")
+	}
+}
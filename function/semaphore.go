@@ -0,0 +1,113 @@
+package function
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrWeight is returned when the user attempts to acquire a weight which
+	// is larger than the semaphore size. This can never be acquired.
+	ErrWeight = errors.New("weight is larger than semaphore size")
+)
+
+// Semaphore is a weighted semaphore which can be used to limit the number of
+// concurrent operations or the total amount of resources used by them.
+// Waiting goroutines acquire the semaphore in the order they were called.
+type Semaphore struct {
+	size    int64
+	curr    int64
+	waiters []*waiter
+	mutex   sync.Mutex
+}
+
+// waiter is a goroutine waiting to acquire the semaphore
+type waiter struct {
+	weight int64
+	ready  chan struct{}
+}
+
+// NewSemaphore creates a new weighted semaphore with given total weight
+func NewSemaphore(n int64) (s *Semaphore) {
+	return &Semaphore{size: n}
+}
+
+// Acquire acquires the semaphore with given weight blocking until resources
+// are available or the context is done. On failure, nothing is acquired.
+func (s *Semaphore) Acquire(ctx context.Context, weight int64) (err error) {
+	if weight > s.size {
+		return ErrWeight
+	}
+
+	s.mutex.Lock()
+	if len(s.waiters) == 0 && s.curr+weight <= s.size {
+		s.curr += weight
+		s.mutex.Unlock()
+		return nil
+	}
+
+	w := &waiter{weight: weight, ready: make(chan struct{})}
+	s.waiters = append(s.waiters, w)
+	s.mutex.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		select {
+		case <-w.ready:
+			// acquired just after the context is done
+			// release it because an error is returned
+			s.curr -= weight
+			s.notify()
+		default:
+			s.remove(w)
+			s.notify()
+		}
+
+		return ctx.Err()
+	}
+}
+
+// Release releases the semaphore with given weight
+func (s *Semaphore) Release(weight int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.curr -= weight
+	if s.curr < 0 {
+		panic("semaphore: released more than held")
+	}
+
+	s.notify()
+}
+
+// notify wakes up waiters in order while resources are available.
+// This should be called while holding the semaphore mutex.
+func (s *Semaphore) notify() {
+	for len(s.waiters) > 0 {
+		w := s.waiters[0]
+		if s.curr+w.weight > s.size {
+			break
+		}
+
+		s.curr += w.weight
+		s.waiters = s.waiters[1:]
+		close(w.ready)
+	}
+}
+
+// remove removes a waiter from the waiter list.
+// This should be called while holding the semaphore mutex.
+func (s *Semaphore) remove(w *waiter) {
+	for i, v := range s.waiters {
+		if v == w {
+			s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package function
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSemaphore(t *testing.T) {
+	var curr, max int64
+
+	s := NewSemaphore(3)
+	ctx := context.Background()
+
+	wg := sync.WaitGroup{}
+	wg.Add(20)
+
+	for i := 0; i < 20; i++ {
+		go func() {
+			defer wg.Done()
+
+			if err := s.Acquire(ctx, 1); err != nil {
+				t.Error(err)
+				return
+			}
+
+			n := atomic.AddInt64(&curr, 1)
+			for {
+				m := atomic.LoadInt64(&max)
+				if n <= m || atomic.CompareAndSwapInt64(&max, m, n) {
+					break
+				}
+			}
+
+			time.Sleep(time.Millisecond)
+			atomic.AddInt64(&curr, -1)
+			s.Release(1)
+		}()
+	}
+
+	wg.Wait()
+
+	if max > 3 {
+		t.Fatal("max > 3")
+	}
+}
+
+func TestSemaphoreContext(t *testing.T) {
+	s := NewSemaphore(2)
+
+	if err := s.Acquire(context.Background(), 3); err != ErrWeight {
+		t.Fatal("wrong error")
+	}
+
+	if err := s.Acquire(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	if err := s.Acquire(ctx, 1); err != context.DeadlineExceeded {
+		t.Fatal("wrong error")
+	}
+
+	s.Release(2)
+
+	if err := s.Acquire(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,61 @@
+package function
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrPanic is returned to callers waiting for a call which panicked.
+	// The caller which ran the function receives the panic.
+	ErrPanic = errors.New("function call panicked")
+)
+
+// Single deduplicates concurrent function calls using a key. When the function
+// is called while another call with the same key is running, it waits for the
+// running call to complete and returns the same result instead of running it.
+// This can be useful for loading resources (ex. loading segment files).
+type Single struct {
+	calls map[string]*call
+	mutex sync.Mutex
+}
+
+// call is a function call which is running or completed
+type call struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+// NewSingle creates a new call deduplicator
+func NewSingle() (s *Single) {
+	return &Single{calls: map[string]*call{}}
+}
+
+// Do runs the function with given key only if it's not already running.
+// All callers of the same key will receive the same value and error.
+// If the function panics, waiting callers receive ErrPanic.
+func (s *Single) Do(key string, fn func() (interface{}, error)) (val interface{}, err error) {
+	s.mutex.Lock()
+	if c, ok := s.calls[key]; ok {
+		s.mutex.Unlock()
+		<-c.done
+		return c.val, c.err
+	}
+
+	c := &call{done: make(chan struct{}), err: ErrPanic}
+	s.calls[key] = c
+	s.mutex.Unlock()
+
+	// the call is removed even if the function panics
+	defer func() {
+		s.mutex.Lock()
+		delete(s.calls, key)
+		s.mutex.Unlock()
+
+		close(c.done)
+	}()
+
+	c.val, c.err = fn()
+	return c.val, c.err
+}
@@ -0,0 +1,103 @@
+package function
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSingle(t *testing.T) {
+	var n int64
+
+	s := NewSingle()
+	fn := func() (interface{}, error) {
+		time.Sleep(10 * time.Millisecond)
+		return atomic.AddInt64(&n, 1), nil
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(10)
+
+	for i := 0; i < 10; i++ {
+		go func() {
+			defer wg.Done()
+
+			val, err := s.Do("foo", fn)
+			if err != nil {
+				t.Error(err)
+			} else if val.(int64) != 1 {
+				t.Error("wrong value")
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if atomic.LoadInt64(&n) != 1 {
+		t.Fatal("n != 1")
+	}
+
+	// runs again after completing
+	if val, err := s.Do("foo", fn); err != nil {
+		t.Fatal(err)
+	} else if val.(int64) != 2 {
+		t.Fatal("wrong value")
+	}
+}
+
+func TestSingleError(t *testing.T) {
+	e := errors.New("test error")
+
+	s := NewSingle()
+	val, err := s.Do("foo", func() (interface{}, error) {
+		return nil, e
+	})
+
+	if err != e || val != nil {
+		t.Fatal("wrong result")
+	}
+}
+
+func TestSinglePanic(t *testing.T) {
+	s := NewSingle()
+	started := make(chan struct{})
+	waiting := make(chan error)
+
+	go func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic")
+			}
+		}()
+
+		s.Do("foo", func() (interface{}, error) {
+			close(started)
+			time.Sleep(10 * time.Millisecond)
+			panic("test panic")
+		})
+	}()
+
+	<-started
+	go func() {
+		_, err := s.Do("foo", func() (interface{}, error) {
+			return nil, nil
+		})
+
+		waiting <- err
+	}()
+
+	if err := <-waiting; err != ErrPanic && err != nil {
+		t.Fatal("expected ErrPanic", err)
+	}
+
+	// the key can be used again after the panic
+	val, err := s.Do("foo", func() (interface{}, error) {
+		return 1, nil
+	})
+
+	if err != nil || val != 1 {
+		t.Fatal("wrong result", val, err)
+	}
+}
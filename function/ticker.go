@@ -0,0 +1,102 @@
+package function
+
+import (
+	"sync"
+	"time"
+)
+
+// Ticker runs a function periodically in a background goroutine. Unlike using
+// time.Tick, the goroutine can be stopped and started again. Panics in the
+// function are recovered so that a single failure won't stop the loop.
+type Ticker struct {
+	payload  func()
+	interval time.Duration
+	trigger  chan struct{}
+	stopper  chan struct{}
+	stopped  chan struct{}
+	mutex    sync.Mutex
+}
+
+// NewTicker creates a new ticker using a function. The ticker will not run
+// the function until the Start method is called on the ticker. If interval
+// is not positive, the function only runs when TriggerNow is called.
+func NewTicker(fn func(), interval time.Duration) (t *Ticker) {
+	return &Ticker{
+		payload:  fn,
+		interval: interval,
+		trigger:  make(chan struct{}, 1),
+	}
+}
+
+// Start starts running the function periodically.
+// Calling Start on a running ticker has no effect.
+func (t *Ticker) Start() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.stopper != nil {
+		return
+	}
+
+	t.stopper = make(chan struct{})
+	t.stopped = make(chan struct{})
+	go t.loop(t.stopper, t.stopped)
+}
+
+// Stop stops the ticker and waits until the background goroutine exits.
+// If the function is running, this will wait until it's completed.
+func (t *Ticker) Stop() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.stopper == nil {
+		return
+	}
+
+	close(t.stopper)
+	<-t.stopped
+
+	t.stopper = nil
+	t.stopped = nil
+}
+
+// TriggerNow runs the function without waiting for the next tick.
+// The function runs in the background goroutine if the ticker is running.
+func (t *Ticker) TriggerNow() {
+	select {
+	case t.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// loop runs the function until the stopper channel is closed
+func (t *Ticker) loop(stopper, stopped chan struct{}) {
+	defer close(stopped)
+
+	var tick <-chan time.Time
+	if t.interval > 0 {
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-tick:
+			t.run()
+		case <-t.trigger:
+			t.run()
+		case <-stopper:
+			return
+		}
+	}
+}
+
+// run runs the function and recovers if it panics
+func (t *Ticker) run() {
+	defer func() {
+		recover()
+	}()
+
+	t.payload()
+}
@@ -0,0 +1,78 @@
+package function
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTicker(t *testing.T) {
+	var n int64
+
+	tk := NewTicker(func() {
+		atomic.AddInt64(&n, 1)
+		panic("test panic")
+	}, 10*time.Millisecond)
+
+	tk.Start()
+	time.Sleep(55 * time.Millisecond)
+	tk.Stop()
+
+	// should survive panics
+	if v := atomic.LoadInt64(&n); v < 3 {
+		t.Fatal("n < 3")
+	}
+
+	// should not run after stopping
+	v := atomic.LoadInt64(&n)
+	time.Sleep(20 * time.Millisecond)
+	if atomic.LoadInt64(&n) != v {
+		t.Fatal("ran after stop")
+	}
+}
+
+func TestTickerTriggerNow(t *testing.T) {
+	done := make(chan struct{})
+
+	tk := NewTicker(func() {
+		done <- struct{}{}
+	}, time.Hour)
+
+	tk.Start()
+	defer tk.Stop()
+
+	tk.TriggerNow()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("not triggered")
+	}
+}
+
+func TestTickerNoInterval(t *testing.T) {
+	var n int64
+
+	tk := NewTicker(func() {
+		atomic.AddInt64(&n, 1)
+	}, 0)
+
+	tk.Start()
+	defer tk.Stop()
+
+	// should not run periodically
+	time.Sleep(20 * time.Millisecond)
+	if atomic.LoadInt64(&n) != 0 {
+		t.Fatal("ran without trigger")
+	}
+
+	tk.TriggerNow()
+
+	for i := 0; atomic.LoadInt64(&n) == 0; i++ {
+		if i == 100 {
+			t.Fatal("not triggered")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
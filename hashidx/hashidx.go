@@ -0,0 +1,391 @@
+// Package hashidx maps fixed-size keys to offsets using an open addressed hash
+// table stored in a memory map. The table grows by doubling its buckets into
+// a new file which replaces the old file when it's complete. If the process
+// crashes while the index is open, it can be rebuilt from the record store.
+package hashidx
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"os"
+	"sync"
+
+	"github.com/kadirahq/go-tools/memmap"
+)
+
+const (
+	// header has the magic number, bucket count, key count,
+	// key size and a flag which is set while the index is open
+	headSize = 40
+
+	// magic number used to check whether the file is initialized
+	magic = 0x3158444948544b47
+
+	// bucket states
+	stEmpty   = 0
+	stUsed    = 1
+	stDeleted = 2
+
+	// maximum load factor before growing (percentage)
+	maxLoad = 75
+
+	// minimum number of buckets in a new index
+	minBuckets = 8
+)
+
+var (
+	// ErrNotFound is returned when the key does not exist in the index.
+	ErrNotFound = errors.New("key not found")
+
+	// ErrKey is returned when the key size is wrong.
+	ErrKey = errors.New("wrong key size")
+
+	// ErrDirty is returned when opening an index which was not closed
+	// properly. The index may be inconsistent and should be rebuilt.
+	ErrDirty = errors.New("index was not closed properly")
+
+	// ErrLayout is returned when the index file has a different key size.
+	ErrLayout = errors.New("index file has a different layout")
+)
+
+// Index is a hash index stored in a memory mapped file.
+type Index struct {
+	path  string
+	ksize int64
+	bsize int64
+	count int64
+	used  int64
+	nbkts int64
+	mmap  *memmap.Map
+	mutex sync.RWMutex
+}
+
+// Open opens or creates an index file on given path. New index files are
+// created with given number of buckets. Returns ErrDirty if the index was
+// not closed properly. Use Rebuild to create it again in that case.
+func Open(path string, ksize int, buckets int64) (ix *Index, err error) {
+	ix = &Index{path: path, ksize: int64(ksize)}
+	ix.bsize = 16 + (ix.ksize+7)/8*8
+
+	if buckets < minBuckets {
+		buckets = minBuckets
+	}
+
+	if head, size, err := readHead(path); err == nil {
+		enc := binary.LittleEndian
+
+		// check the key size before the file size so that opening with a
+		// different key size does not look like a partially written file
+		if enc.Uint64(head[0:]) == magic && enc.Uint64(head[24:]) != uint64(ix.ksize) {
+			return nil, ErrLayout
+		}
+
+		// a file with a partial header or bucket may be
+		// left behind by a crash while creating the index
+		size -= headSize
+		if size < minBuckets*ix.bsize || size%ix.bsize != 0 {
+			return nil, ErrDirty
+		}
+
+		buckets = size / ix.bsize
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if err := ix.load(path, buckets); err != nil {
+		return nil, err
+	}
+
+	return ix, nil
+}
+
+// Rebuild creates a new index file on given path replacing the existing file.
+// The function is called with a put function to add all keys to the index.
+func Rebuild(path string, ksize int, buckets int64, fn func(put func(key []byte, off int64) error) error) (ix *Index, err error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	ix, err = Open(path, ksize, buckets)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fn(ix.Put); err != nil {
+		ix.Close()
+		return nil, err
+	}
+
+	if err := ix.Sync(); err != nil {
+		ix.Close()
+		return nil, err
+	}
+
+	return ix, nil
+}
+
+// Len returns the number of keys in the index.
+func (ix *Index) Len() (n int64) {
+	ix.mutex.RLock()
+	defer ix.mutex.RUnlock()
+	return ix.count
+}
+
+// Get returns the offset stored with given key.
+func (ix *Index) Get(key []byte) (off int64, err error) {
+	if int64(len(key)) != ix.ksize {
+		return 0, ErrKey
+	}
+
+	ix.mutex.RLock()
+	defer ix.mutex.RUnlock()
+
+	b, found := ix.find(key)
+	if !found {
+		return 0, ErrNotFound
+	}
+
+	return int64(binary.LittleEndian.Uint64(b[8:])), nil
+}
+
+// Put stores the offset with given key replacing the existing offset.
+// The index grows automatically when buckets are running out.
+func (ix *Index) Put(key []byte, off int64) (err error) {
+	if int64(len(key)) != ix.ksize {
+		return ErrKey
+	}
+
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+
+	if (ix.used+1)*100 > ix.nbkts*maxLoad {
+		if err := ix.grow(); err != nil {
+			return err
+		}
+	}
+
+	ix.put(key, off)
+	return nil
+}
+
+// Delete removes the key from the index.
+func (ix *Index) Delete(key []byte) (err error) {
+	if int64(len(key)) != ix.ksize {
+		return ErrKey
+	}
+
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+
+	b, found := ix.find(key)
+	if !found {
+		return ErrNotFound
+	}
+
+	binary.LittleEndian.PutUint64(b, stDeleted)
+	ix.count--
+	ix.header()
+
+	return nil
+}
+
+// Sync writes index changes to the disk.
+func (ix *Index) Sync() (err error) {
+	ix.mutex.RLock()
+	defer ix.mutex.RUnlock()
+	return ix.mmap.Sync()
+}
+
+// Close marks the index as closed properly and closes the file.
+// Calling Close more than once has no effect.
+func (ix *Index) Close() (err error) {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+
+	if ix.mmap == nil {
+		return nil
+	}
+
+	binary.LittleEndian.PutUint64(ix.mmap.Data[32:], 0)
+	err = ix.mmap.Close()
+	ix.mmap = nil
+	return err
+}
+
+// readHead reads the header and the size of an index file.
+// The header is all zeros if the file is smaller than the header.
+func readHead(path string) (head []byte, size int64, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	size = info.Size()
+	if size < headSize {
+		return make([]byte, headSize), size, nil
+	}
+
+	head = make([]byte, headSize)
+	if _, err := file.ReadAt(head, 0); err != nil {
+		return nil, 0, err
+	}
+
+	return head, size, nil
+}
+
+// load maps the index file and checks the header
+func (ix *Index) load(path string, buckets int64) (err error) {
+	mmap, err := memmap.New(path, headSize+buckets*ix.bsize)
+	if err != nil {
+		return err
+	}
+
+	enc := binary.LittleEndian
+	data := mmap.Data
+
+	if enc.Uint64(data[0:]) != magic {
+		enc.PutUint64(data[8:], uint64(buckets))
+		enc.PutUint64(data[24:], uint64(ix.ksize))
+		enc.PutUint64(data[0:], magic)
+	} else if enc.Uint64(data[24:]) != uint64(ix.ksize) {
+		mmap.Close()
+		return ErrLayout
+	} else if enc.Uint64(data[32:]) != 0 {
+		mmap.Close()
+		return ErrDirty
+	}
+
+	// mark the index as open
+	enc.PutUint64(data[32:], 1)
+
+	ix.mmap = mmap
+	ix.nbkts = buckets
+	ix.count = int64(enc.Uint64(data[16:]))
+	ix.used = 0
+
+	for i := int64(0); i < buckets; i++ {
+		if enc.Uint64(ix.bucket(i)) != stEmpty {
+			ix.used++
+		}
+	}
+
+	return nil
+}
+
+// grow doubles the number of buckets by writing a new index file
+// and replacing the current file with it after syncing it.
+func (ix *Index) grow() (err error) {
+	tmp := &Index{path: ix.path + ".tmp", ksize: ix.ksize, bsize: ix.bsize}
+	if err := os.Remove(tmp.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := tmp.load(tmp.path, ix.nbkts*2); err != nil {
+		return err
+	}
+
+	enc := binary.LittleEndian
+	for i := int64(0); i < ix.nbkts; i++ {
+		b := ix.bucket(i)
+		if enc.Uint64(b) == stUsed {
+			tmp.put(b[16:16+ix.ksize], int64(enc.Uint64(b[8:])))
+		}
+	}
+
+	if err := tmp.mmap.Sync(); err != nil {
+		tmp.mmap.Close()
+		return err
+	}
+
+	if err := os.Rename(tmp.path, ix.path); err != nil {
+		tmp.mmap.Close()
+		return err
+	}
+
+	if err := ix.mmap.Close(); err != nil {
+		return err
+	}
+
+	ix.mmap = tmp.mmap
+	ix.nbkts = tmp.nbkts
+	ix.count = tmp.count
+	ix.used = tmp.used
+
+	return nil
+}
+
+// put adds or replaces a key. There should be an empty bucket.
+func (ix *Index) put(key []byte, off int64) {
+	enc := binary.LittleEndian
+
+	b, found := ix.find(key)
+	if !found {
+		if enc.Uint64(b) == stEmpty {
+			ix.used++
+		}
+
+		copy(b[16:], key)
+		ix.count++
+		ix.header()
+	}
+
+	enc.PutUint64(b[8:], uint64(off))
+	enc.PutUint64(b, stUsed)
+}
+
+// find returns the bucket with the key if it exists. Otherwise returns the
+// first empty or deleted bucket which can be used to store the key.
+func (ix *Index) find(key []byte) (b []byte, found bool) {
+	enc := binary.LittleEndian
+	h := hash(key)
+
+	var free []byte
+	for i := int64(0); i < ix.nbkts; i++ {
+		bkt := ix.bucket(int64((h + uint64(i)) % uint64(ix.nbkts)))
+
+		switch enc.Uint64(bkt) {
+		case stEmpty:
+			if free == nil {
+				free = bkt
+			}
+
+			return free, false
+		case stDeleted:
+			if free == nil {
+				free = bkt
+			}
+		case stUsed:
+			if string(bkt[16:16+ix.ksize]) == string(key) {
+				return bkt, true
+			}
+		}
+	}
+
+	return free, false
+}
+
+// bucket returns the bucket at given index
+func (ix *Index) bucket(i int64) (b []byte) {
+	off := headSize + i*ix.bsize
+	return ix.mmap.Data[off : off+ix.bsize]
+}
+
+// header updates the key count in the file header
+func (ix *Index) header() {
+	binary.LittleEndian.PutUint64(ix.mmap.Data[16:], uint64(ix.count))
+}
+
+// hash calculates the hash of a key
+func hash(key []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(key)
+	return h.Sum64()
+}
@@ -0,0 +1,214 @@
+package hashidx
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+var (
+	tmpfile = "/tmp/test-hashidx"
+)
+
+func setup(t *testing.T) func() {
+	if err := os.RemoveAll(tmpfile); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if err := os.RemoveAll(tmpfile); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func key(i int) []byte {
+	k := make([]byte, 8)
+	binary.LittleEndian.PutUint64(k, uint64(i))
+	return k
+}
+
+func TestIndex(t *testing.T) {
+	defer setup(t)()
+
+	ix, err := Open(tmpfile, 8, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// this will grow the index a few times
+	for i := 0; i < 100; i++ {
+		if err := ix.Put(key(i), int64(i*10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ix.Put(key(5), 5); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ix.Delete(key(6)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ix.Put([]byte{1}, 5); err != ErrKey {
+		t.Fatal("wrong error")
+	}
+
+	if err := ix.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ix, err = Open(tmpfile, 8, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ix.Close()
+
+	if ix.Len() != 99 {
+		t.Fatal("wrong length")
+	}
+
+	for i := 0; i < 100; i++ {
+		off, err := ix.Get(key(i))
+		switch i {
+		case 5:
+			if err != nil || off != 5 {
+				t.Fatal("wrong value")
+			}
+		case 6:
+			if err != ErrNotFound {
+				t.Fatal("wrong error")
+			}
+		default:
+			if err != nil || off != int64(i*10) {
+				t.Fatal("wrong value")
+			}
+		}
+	}
+}
+
+func TestRebuild(t *testing.T) {
+	defer setup(t)()
+
+	ix, err := Open(tmpfile, 8, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ix.Put(key(1), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate a crash (not closed properly)
+	if err := ix.mmap.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(tmpfile, 8, 8); err != ErrDirty {
+		t.Fatal("wrong error")
+	}
+
+	ix, err = Rebuild(tmpfile, 8, 8, func(put func(key []byte, off int64) error) error {
+		for i := 0; i < 20; i++ {
+			if err := put(key(i), int64(i)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ix.Close()
+
+	if ix.Len() != 20 {
+		t.Fatal("wrong length")
+	}
+
+	if off, err := ix.Get(key(15)); err != nil || off != 15 {
+		t.Fatal("wrong value")
+	}
+}
+
+func TestOpenTruncated(t *testing.T) {
+	defer setup(t)()
+
+	// empty file
+	if err := ioutil.WriteFile(tmpfile, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(tmpfile, 8, 8); err != ErrDirty {
+		t.Fatal("wrong error")
+	}
+
+	ix, err := Open(tmpfile+"-ok", 8, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(tmpfile + "-ok")
+
+	if err := ix.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// partial bucket at the end of the file
+	if err := os.Truncate(tmpfile+"-ok", headSize+minBuckets*ix.bsize-1); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(tmpfile+"-ok", 8, 8); err != ErrDirty {
+		t.Fatal("wrong error")
+	}
+
+	// rebuilding should fix the index file
+	ix, err = Rebuild(tmpfile, 8, 8, func(put func(key []byte, off int64) error) error {
+		return put(key(1), 1)
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ix.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	defer setup(t)()
+
+	ix, err := Open(tmpfile, 8, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ix.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// closing again has no effect
+	if err := ix.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(tmpfile, 16, 8); err != ErrLayout {
+		t.Fatal("wrong error", err)
+	}
+
+	ix, err = Open(tmpfile, 8, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ix.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
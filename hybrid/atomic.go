@@ -0,0 +1,109 @@
+package hybrid
+
+import (
+	"sync/atomic"
+)
+
+// Atomic methods use sync/atomic on the mapped value so that values in
+// memory mapped files (ex. counters) can be updated concurrently without
+// locks. 64 bit values should be 8 byte aligned on all architectures.
+
+// Load atomically loads the value
+func (v *Int32) Load() (val int32) {
+	return atomic.LoadInt32(v.Value)
+}
+
+// Store atomically stores the value
+func (v *Int32) Store(val int32) {
+	atomic.StoreInt32(v.Value, val)
+}
+
+// Add atomically adds delta to the value and returns the new value
+func (v *Int32) Add(delta int32) (new int32) {
+	return atomic.AddInt32(v.Value, delta)
+}
+
+// Swap atomically stores the value and returns the old value
+func (v *Int32) Swap(val int32) (old int32) {
+	return atomic.SwapInt32(v.Value, val)
+}
+
+// CompareAndSwap atomically replaces the value with new if it's equal to old
+func (v *Int32) CompareAndSwap(old, new int32) (swapped bool) {
+	return atomic.CompareAndSwapInt32(v.Value, old, new)
+}
+
+// Load atomically loads the value
+func (v *Int64) Load() (val int64) {
+	return atomic.LoadInt64(v.Value)
+}
+
+// Store atomically stores the value
+func (v *Int64) Store(val int64) {
+	atomic.StoreInt64(v.Value, val)
+}
+
+// Add atomically adds delta to the value and returns the new value
+func (v *Int64) Add(delta int64) (new int64) {
+	return atomic.AddInt64(v.Value, delta)
+}
+
+// Swap atomically stores the value and returns the old value
+func (v *Int64) Swap(val int64) (old int64) {
+	return atomic.SwapInt64(v.Value, val)
+}
+
+// CompareAndSwap atomically replaces the value with new if it's equal to old
+func (v *Int64) CompareAndSwap(old, new int64) (swapped bool) {
+	return atomic.CompareAndSwapInt64(v.Value, old, new)
+}
+
+// Load atomically loads the value
+func (v *Uint32) Load() (val uint32) {
+	return atomic.LoadUint32(v.Value)
+}
+
+// Store atomically stores the value
+func (v *Uint32) Store(val uint32) {
+	atomic.StoreUint32(v.Value, val)
+}
+
+// Add atomically adds delta to the value and returns the new value
+func (v *Uint32) Add(delta uint32) (new uint32) {
+	return atomic.AddUint32(v.Value, delta)
+}
+
+// Swap atomically stores the value and returns the old value
+func (v *Uint32) Swap(val uint32) (old uint32) {
+	return atomic.SwapUint32(v.Value, val)
+}
+
+// CompareAndSwap atomically replaces the value with new if it's equal to old
+func (v *Uint32) CompareAndSwap(old, new uint32) (swapped bool) {
+	return atomic.CompareAndSwapUint32(v.Value, old, new)
+}
+
+// Load atomically loads the value
+func (v *Uint64) Load() (val uint64) {
+	return atomic.LoadUint64(v.Value)
+}
+
+// Store atomically stores the value
+func (v *Uint64) Store(val uint64) {
+	atomic.StoreUint64(v.Value, val)
+}
+
+// Add atomically adds delta to the value and returns the new value
+func (v *Uint64) Add(delta uint64) (new uint64) {
+	return atomic.AddUint64(v.Value, delta)
+}
+
+// Swap atomically stores the value and returns the old value
+func (v *Uint64) Swap(val uint64) (old uint64) {
+	return atomic.SwapUint64(v.Value, val)
+}
+
+// CompareAndSwap atomically replaces the value with new if it's equal to old
+func (v *Uint64) CompareAndSwap(old, new uint64) (swapped bool) {
+	return atomic.CompareAndSwapUint64(v.Value, old, new)
+}
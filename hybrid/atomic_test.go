@@ -0,0 +1,47 @@
+package hybrid
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestAtomic(t *testing.T) {
+	d := make([]byte, 8)
+	v := NewUint64(d)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				v.Add(1)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// a second view on the same memory sees the same value
+	if n := NewUint64(d).Load(); n != 10000 {
+		t.Fatal("wrong value", n)
+	}
+
+	if old := v.Swap(5); old != 10000 {
+		t.Fatal("wrong value")
+	}
+
+	if v.CompareAndSwap(4, 6) || !v.CompareAndSwap(5, 6) {
+		t.Fatal("wrong swap")
+	}
+
+	v.Store(7)
+	if *v.Value != 7 {
+		t.Fatal("wrong value")
+	}
+
+	i := NewInt32(nil)
+	if i.Add(-2) != -2 || i.Load() != -2 {
+		t.Fatal("wrong value")
+	}
+}
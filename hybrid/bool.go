@@ -0,0 +1,67 @@
+package hybrid
+
+const (
+	// SzBool is the size of a bool value in bytes
+	SzBool = 1
+)
+
+// Bool values are not generated from templates because they do not have a
+// byte order. Bytes other than 0 and 1 are not valid bool values so mapped
+// bools should only be used with data written by EncodeBool or Bool.
+
+// EncodeBool updates the byte slice to match value
+func EncodeBool(d []byte, v *bool) {
+	Encode(d, v)
+}
+
+// DecodeBool updates the value to match the byte slice
+// Any byte other than 0 is decoded as true.
+func DecodeBool(d []byte, v *bool) {
+	*v = d[0] != 0
+}
+
+// Bool has a bool value and a byte slice using the same memory location.
+// Any changes done to one of these fields will reflect on the other.
+type Bool Value[bool]
+
+// NewBool will create a new Bool struct with given byte slice.
+// If the slice is nil, a new byte slice will be created for storage.
+// If the slice length is less than required length, it will panic.
+func NewBool(d []byte) *Bool {
+	return (*Bool)(NewValue[bool](d))
+}
+
+// NewBoolChecked is like NewBool but it returns an error
+// if the slice is too small for the value.
+func NewBoolChecked(d []byte) (*Bool, error) {
+	v, err := NewValueChecked[bool](d)
+	return (*Bool)(v), err
+}
+
+// Read updates the struct to use provided byte slice
+// This can be used when it's required to read data from
+func (v *Bool) Read(d []byte) {
+	(*Value[bool])(v).Read(d)
+}
+
+// ReadChecked is like Read but it returns an error
+// if the slice is too small for the value.
+func (v *Bool) ReadChecked(d []byte) (err error) {
+	return (*Value[bool])(v).ReadChecked(d)
+}
+
+// Bools has a bool slice and a byte slice using the same memory location.
+// Any changes done to one of these fields will reflect on the other.
+type Bools = Values[bool]
+
+// NewBools will create a new Bools struct with n values in the byte slice.
+// If the slice is nil, a new byte slice will be created for n values.
+// If the slice length is less than required length, it will panic.
+func NewBools(d []byte, n int) *Bools {
+	return NewValues[bool](d, n)
+}
+
+// MapBools returns a bool slice which uses the same memory as the byte slice.
+func MapBools(d []byte) []bool {
+	return Slice[bool](d)
+}
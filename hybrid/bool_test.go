@@ -0,0 +1,50 @@
+package hybrid
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBool(t *testing.T) {
+	u := true
+	d := []byte{0}
+
+	EncodeBool(d, &u)
+	if !bytes.Equal(d, []byte{1}) {
+		t.Fatal("wrong value")
+	}
+
+	u = false
+	DecodeBool([]byte{2}, &u)
+	if !u {
+		t.Fatal("wrong value")
+	}
+
+	v := NewBool(nil)
+	*v.Value = true
+	if !bytes.Equal(v.Bytes, []byte{1}) {
+		t.Fatal("wrong value")
+	}
+
+	v.Read(d)
+	if !*v.Value {
+		t.Fatal("wrong value")
+	}
+
+	if _, err := NewBoolChecked(d[:0]); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	s := NewBools(make([]byte, 3), 1)
+	if err := s.Append(true, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(s.Bytes, []byte{0, 1, 1}) {
+		t.Fatal("wrong value")
+	}
+
+	if b := MapBools([]byte{1, 0}); len(b) != 2 || !b[0] || b[1] {
+		t.Fatal("wrong value")
+	}
+}
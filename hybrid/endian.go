@@ -0,0 +1,74 @@
+package hybrid
+
+import (
+	"unsafe"
+)
+
+// Number is a constraint for types which can be encoded with a byte order.
+type Number interface {
+	~int8 | ~int16 | ~int32 | ~int64 |
+		~uint8 | ~uint16 | ~uint32 | ~uint64 |
+		~float32 | ~float64
+}
+
+// littleEndian is true when the machine uses little endian byte order
+var littleEndian = func() bool {
+	v := uint16(1)
+	return *(*byte)(unsafe.Pointer(&v)) == 1
+}()
+
+// IsLittleEndian returns true if the machine uses little endian byte order.
+// Values mapped with Value, Slice and Encode/Decode use this byte order.
+func IsLittleEndian() bool {
+	return littleEndian
+}
+
+// EncodeLE updates the byte slice to match value in little endian byte order
+func EncodeLE[T Number](d []byte, v *T) {
+	encode(d, v, littleEndian)
+}
+
+// DecodeLE updates the value to match the byte slice in little endian byte order
+func DecodeLE[T Number](d []byte, v *T) {
+	decode(d, v, littleEndian)
+}
+
+// EncodeBE updates the byte slice to match value in big endian byte order
+func EncodeBE[T Number](d []byte, v *T) {
+	encode(d, v, !littleEndian)
+}
+
+// DecodeBE updates the value to match the byte slice in big endian byte order
+func DecodeBE[T Number](d []byte, v *T) {
+	decode(d, v, !littleEndian)
+}
+
+// encode encodes the value and swaps bytes if the order is not native
+func encode[T Number](d []byte, v *T, native bool) {
+	Encode(d, v)
+	if !native {
+		swap(d[:Size[T]()])
+	}
+}
+
+// decode decodes the value after swapping bytes if the order is not native
+// Bytes are swapped on a copy so the byte slice is not modified.
+func decode[T Number](d []byte, v *T, native bool) {
+	if native {
+		Decode(d, v)
+		return
+	}
+
+	var b [8]byte
+	p := b[:Size[T]()]
+	copy(p, d[:len(p)])
+	swap(p)
+	Decode(p, v)
+}
+
+// swap reverses the order of bytes in the slice
+func swap(p []byte) {
+	for i, j := 0, len(p)-1; i < j; i, j = i+1, j-1 {
+		p[i], p[j] = p[j], p[i]
+	}
+}
@@ -0,0 +1,64 @@
+package hybrid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestEndian(t *testing.T) {
+	d := make([]byte, 8)
+	u := uint32(0x01020304)
+
+	EncodeLE(d, &u)
+	if binary.LittleEndian.Uint32(d) != u {
+		t.Fatal("wrong value")
+	}
+
+	EncodeBE(d, &u)
+	if binary.BigEndian.Uint32(d) != u {
+		t.Fatal("wrong value")
+	}
+
+	var v uint32
+	DecodeBE(d, &v)
+	if v != u {
+		t.Fatal("wrong value")
+	}
+
+	f := -1.5
+	binary.LittleEndian.PutUint64(d, math.Float64bits(f))
+
+	var g float64
+	DecodeLE(d, &g)
+	if g != f {
+		t.Fatal("wrong value")
+	}
+}
+
+func TestSwap(t *testing.T) {
+	// byte swapping is used for the non native byte order
+	// test it directly so that it's tested on all machines
+	u := uint64(0x0102030405060708)
+	d := make([]byte, 8)
+	s := make([]byte, 8)
+
+	Encode(d, &u)
+	encode(s, &u, false)
+	swap(d)
+
+	if !bytes.Equal(d, s) {
+		t.Fatal("wrong value")
+	}
+
+	var v uint64
+	decode(s, &v, false)
+	if v != u {
+		t.Fatal("wrong value")
+	}
+
+	if !bytes.Equal(d, s) {
+		t.Fatal("decode should not modify the slice")
+	}
+}
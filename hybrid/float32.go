@@ -1,52 +1,83 @@
 package hybrid
 
-import (
-	"reflect"
-	"unsafe"
-)
-
 const (
+	// SzFloat32 is the size of a float32 value in bytes
 	SzFloat32 = 4
 )
 
 // EncodeFloat32 updates the byte slice to match value
 func EncodeFloat32(d []byte, v *float32) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	value := (*float32)(unsafe.Pointer(head.Data))
-	*value = *v
+	Encode(d, v)
 }
 
 // DecodeFloat32 updates the value to match the byte slice
 func DecodeFloat32(d []byte, v *float32) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	value := (*float32)(unsafe.Pointer(head.Data))
-	*v = *value
+	Decode(d, v)
+}
+
+// EncodeFloat32LE updates the byte slice to match value in little endian byte order
+func EncodeFloat32LE(d []byte, v *float32) {
+	EncodeLE(d, v)
+}
+
+// DecodeFloat32LE updates the value to match the byte slice in little endian byte order
+func DecodeFloat32LE(d []byte, v *float32) {
+	DecodeLE(d, v)
+}
+
+// EncodeFloat32BE updates the byte slice to match value in big endian byte order
+func EncodeFloat32BE(d []byte, v *float32) {
+	EncodeBE(d, v)
+}
+
+// DecodeFloat32BE updates the value to match the byte slice in big endian byte order
+func DecodeFloat32BE(d []byte, v *float32) {
+	DecodeBE(d, v)
 }
 
 // Float32 has a float32 value and a byte slice using the same memory location.
 // Any changes done to one of these fields will reflect on the other.
-type Float32 struct {
-	Value *float32
-	Bytes []byte
-}
+type Float32 Value[float32]
 
 // NewFloat32 will create a new Float32 struct with given byte slice.
 // If the slice is nil, a new byte slice will be created for storage.
 // If the slice length is less than required length, it will panic.
 func NewFloat32(d []byte) *Float32 {
-	if d == nil {
-		d = make([]byte, SzFloat32)
-	}
+	return (*Float32)(NewValue[float32](d))
+}
 
-	v := &Float32{}
-	v.Read(d[:SzFloat32])
-	return v
+// NewFloat32Checked is like NewFloat32 but it returns an error
+// if the slice is too small or not aligned for the value.
+func NewFloat32Checked(d []byte) (*Float32, error) {
+	v, err := NewValueChecked[float32](d)
+	return (*Float32)(v), err
 }
 
 // Read updates the struct to use provided byte slice
 // This can be used when it's required to read data from
 func (v *Float32) Read(d []byte) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	v.Value = (*float32)(unsafe.Pointer(head.Data))
-	v.Bytes = d[:SzFloat32]
+	(*Value[float32])(v).Read(d)
+}
+
+// ReadChecked is like Read but it returns an error if the slice is
+// too small or not aligned for the value.
+func (v *Float32) ReadChecked(d []byte) (err error) {
+	return (*Value[float32])(v).ReadChecked(d)
+}
+
+// Float32s has a float32 slice and a byte slice using the same memory location.
+// Any changes done to one of these fields will reflect on the other.
+type Float32s = Values[float32]
+
+// NewFloat32s will create a new Float32s struct with n values in the byte slice.
+// If the slice is nil, a new byte slice will be created for n values.
+// If the slice length is less than required length, it will panic.
+func NewFloat32s(d []byte, n int) *Float32s {
+	return NewValues[float32](d, n)
+}
+
+// MapFloat32s returns a float32 slice which uses the same memory as the byte
+// slice. Trailing bytes which do not make a complete value are not included.
+func MapFloat32s(d []byte) []float32 {
+	return Slice[float32](d)
 }
@@ -55,6 +55,99 @@ func TestFloat32(t *testing.T) {
 	}
 }
 
+func TestFloat32Endian(t *testing.T) {
+	var u float32 = 5
+	d := make([]byte, SzFloat32)
+
+	EncodeFloat32LE(d, &u)
+	if !bytes.Equal(d, BinaryEncodeFloat32(5)) {
+		t.Fatal("wrong value")
+	}
+
+	var v float32
+	DecodeFloat32LE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+
+	b := bytes.NewBuffer(nil)
+	binary.Write(b, binary.BigEndian, u)
+
+	EncodeFloat32BE(d, &u)
+	if !bytes.Equal(d, b.Bytes()) {
+		t.Fatal("wrong value")
+	}
+
+	v = 0
+	DecodeFloat32BE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+}
+
+func TestFloat32Checked(t *testing.T) {
+	d := make([]byte, 2*SzFloat32)
+
+	v, err := NewFloat32Checked(d[SzFloat32:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*v.Value = 5
+	if !bytes.Equal(d[SzFloat32:], BinaryEncodeFloat32(5)) {
+		t.Fatal("wrong value")
+	}
+
+	if _, err := NewFloat32Checked(d[:SzFloat32-1]); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	if SzFloat32 > 1 {
+		if _, err := NewFloat32Checked(d[1:]); err != ErrAlign {
+			t.Fatal("expected ErrAlign")
+		}
+	}
+}
+
+func TestMapFloat32s(t *testing.T) {
+	d := append(BinaryEncodeFloat32(5), BinaryEncodeFloat32(10)...)
+
+	v := MapFloat32s(d)
+	if len(v) != 2 || v[0] != 5 || v[1] != 10 {
+		t.Fatal("wrong value")
+	}
+
+	v[1] = 15
+	if !bytes.Equal(d[SzFloat32:], BinaryEncodeFloat32(15)) {
+		t.Fatal("wrong value")
+	}
+
+	if v := MapFloat32s(d[:len(d)-1]); len(v) != 1 {
+		t.Fatal("wrong length")
+	}
+
+	if v := MapFloat32s(nil); len(v) != 0 {
+		t.Fatal("wrong length")
+	}
+}
+
+func TestFloat32s(t *testing.T) {
+	d := make([]byte, 3*SzFloat32)
+
+	v := NewFloat32s(d, 1)
+	if err := v.Append(5, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(d[2*SzFloat32:], BinaryEncodeFloat32(10)) {
+		t.Fatal("wrong value")
+	}
+
+	if err := v.Append(15); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+}
+
 func BenchmarkFloat32BinaryDecode(b *testing.B) {
 	var v float32
 	var d = make([]byte, b.N*SzFloat32)
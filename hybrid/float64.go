@@ -1,52 +1,83 @@
 package hybrid
 
-import (
-	"reflect"
-	"unsafe"
-)
-
 const (
+	// SzFloat64 is the size of a float64 value in bytes
 	SzFloat64 = 8
 )
 
 // EncodeFloat64 updates the byte slice to match value
 func EncodeFloat64(d []byte, v *float64) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	value := (*float64)(unsafe.Pointer(head.Data))
-	*value = *v
+	Encode(d, v)
 }
 
 // DecodeFloat64 updates the value to match the byte slice
 func DecodeFloat64(d []byte, v *float64) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	value := (*float64)(unsafe.Pointer(head.Data))
-	*v = *value
+	Decode(d, v)
+}
+
+// EncodeFloat64LE updates the byte slice to match value in little endian byte order
+func EncodeFloat64LE(d []byte, v *float64) {
+	EncodeLE(d, v)
+}
+
+// DecodeFloat64LE updates the value to match the byte slice in little endian byte order
+func DecodeFloat64LE(d []byte, v *float64) {
+	DecodeLE(d, v)
+}
+
+// EncodeFloat64BE updates the byte slice to match value in big endian byte order
+func EncodeFloat64BE(d []byte, v *float64) {
+	EncodeBE(d, v)
+}
+
+// DecodeFloat64BE updates the value to match the byte slice in big endian byte order
+func DecodeFloat64BE(d []byte, v *float64) {
+	DecodeBE(d, v)
 }
 
 // Float64 has a float64 value and a byte slice using the same memory location.
 // Any changes done to one of these fields will reflect on the other.
-type Float64 struct {
-	Value *float64
-	Bytes []byte
-}
+type Float64 Value[float64]
 
 // NewFloat64 will create a new Float64 struct with given byte slice.
 // If the slice is nil, a new byte slice will be created for storage.
 // If the slice length is less than required length, it will panic.
 func NewFloat64(d []byte) *Float64 {
-	if d == nil {
-		d = make([]byte, SzFloat64)
-	}
+	return (*Float64)(NewValue[float64](d))
+}
 
-	v := &Float64{}
-	v.Read(d[:SzFloat64])
-	return v
+// NewFloat64Checked is like NewFloat64 but it returns an error
+// if the slice is too small or not aligned for the value.
+func NewFloat64Checked(d []byte) (*Float64, error) {
+	v, err := NewValueChecked[float64](d)
+	return (*Float64)(v), err
 }
 
 // Read updates the struct to use provided byte slice
 // This can be used when it's required to read data from
 func (v *Float64) Read(d []byte) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	v.Value = (*float64)(unsafe.Pointer(head.Data))
-	v.Bytes = d[:SzFloat64]
+	(*Value[float64])(v).Read(d)
+}
+
+// ReadChecked is like Read but it returns an error if the slice is
+// too small or not aligned for the value.
+func (v *Float64) ReadChecked(d []byte) (err error) {
+	return (*Value[float64])(v).ReadChecked(d)
+}
+
+// Float64s has a float64 slice and a byte slice using the same memory location.
+// Any changes done to one of these fields will reflect on the other.
+type Float64s = Values[float64]
+
+// NewFloat64s will create a new Float64s struct with n values in the byte slice.
+// If the slice is nil, a new byte slice will be created for n values.
+// If the slice length is less than required length, it will panic.
+func NewFloat64s(d []byte, n int) *Float64s {
+	return NewValues[float64](d, n)
+}
+
+// MapFloat64s returns a float64 slice which uses the same memory as the byte
+// slice. Trailing bytes which do not make a complete value are not included.
+func MapFloat64s(d []byte) []float64 {
+	return Slice[float64](d)
 }
@@ -55,6 +55,99 @@ func TestFloat64(t *testing.T) {
 	}
 }
 
+func TestFloat64Endian(t *testing.T) {
+	var u float64 = 5
+	d := make([]byte, SzFloat64)
+
+	EncodeFloat64LE(d, &u)
+	if !bytes.Equal(d, BinaryEncodeFloat64(5)) {
+		t.Fatal("wrong value")
+	}
+
+	var v float64
+	DecodeFloat64LE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+
+	b := bytes.NewBuffer(nil)
+	binary.Write(b, binary.BigEndian, u)
+
+	EncodeFloat64BE(d, &u)
+	if !bytes.Equal(d, b.Bytes()) {
+		t.Fatal("wrong value")
+	}
+
+	v = 0
+	DecodeFloat64BE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+}
+
+func TestFloat64Checked(t *testing.T) {
+	d := make([]byte, 2*SzFloat64)
+
+	v, err := NewFloat64Checked(d[SzFloat64:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*v.Value = 5
+	if !bytes.Equal(d[SzFloat64:], BinaryEncodeFloat64(5)) {
+		t.Fatal("wrong value")
+	}
+
+	if _, err := NewFloat64Checked(d[:SzFloat64-1]); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	if SzFloat64 > 1 {
+		if _, err := NewFloat64Checked(d[1:]); err != ErrAlign {
+			t.Fatal("expected ErrAlign")
+		}
+	}
+}
+
+func TestMapFloat64s(t *testing.T) {
+	d := append(BinaryEncodeFloat64(5), BinaryEncodeFloat64(10)...)
+
+	v := MapFloat64s(d)
+	if len(v) != 2 || v[0] != 5 || v[1] != 10 {
+		t.Fatal("wrong value")
+	}
+
+	v[1] = 15
+	if !bytes.Equal(d[SzFloat64:], BinaryEncodeFloat64(15)) {
+		t.Fatal("wrong value")
+	}
+
+	if v := MapFloat64s(d[:len(d)-1]); len(v) != 1 {
+		t.Fatal("wrong length")
+	}
+
+	if v := MapFloat64s(nil); len(v) != 0 {
+		t.Fatal("wrong length")
+	}
+}
+
+func TestFloat64s(t *testing.T) {
+	d := make([]byte, 3*SzFloat64)
+
+	v := NewFloat64s(d, 1)
+	if err := v.Append(5, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(d[2*SzFloat64:], BinaryEncodeFloat64(10)) {
+		t.Fatal("wrong value")
+	}
+
+	if err := v.Append(15); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+}
+
 func BenchmarkFloat64BinaryDecode(b *testing.B) {
 	var v float64
 	var d = make([]byte, b.N*SzFloat64)
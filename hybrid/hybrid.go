@@ -0,0 +1,205 @@
+// Package hybrid maps values onto byte slices so that a value and its bytes
+// use the same memory location. This is mostly useful with memory mapped
+// data where values can be read and updated without encoding them.
+//
+// Values can be of any fixed size type without pointers: numbers, arrays
+// and structs made of them. Values use the byte order of the machine and
+// structs include the padding added by the compiler. Use the LE and BE
+// functions (ex. EncodeLE) to encode numbers with a fixed byte order when
+// data should be portable. Type specific functions (ex. EncodeUint64) are
+// generated wrappers (see scripts/Makefile).
+//
+// Go constraints cannot describe structs without pointers, so types are
+// checked at runtime. Constructors and Slice panic with ErrType and checked
+// constructors return it. Encode and Decode are not checked to keep them
+// fast in hot loops.
+package hybrid
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+var (
+	// ErrSize is returned when the byte slice is smaller than the value.
+	ErrSize = errors.New("slice is smaller than the value")
+
+	// ErrAlign is returned when the byte slice is not aligned for the value.
+	// Unaligned values can cause faults on some architectures (ex. ARM).
+	ErrAlign = errors.New("slice is not aligned for the value")
+
+	// ErrType is returned when the value type has pointers or a variable
+	// size (ex. strings, slices and maps) and it cannot be mapped onto bytes.
+	ErrType = errors.New("type cannot be mapped onto bytes")
+)
+
+// types caches results of checkType for each type
+var types sync.Map
+
+// Size returns the number of bytes used by a value of type T.
+func Size[T any]() int {
+	var v T
+	return int(unsafe.Sizeof(v))
+}
+
+// Encode updates the byte slice to match value
+// If the slice is shorter than the value, it will panic.
+func Encode[T any](d []byte, v *T) {
+	*(*T)(pointer[T](d)) = *v
+}
+
+// Decode updates the value to match the byte slice
+// If the slice is shorter than the value, it will panic.
+func Decode[T any](d []byte, v *T) {
+	*v = *(*T)(pointer[T](d))
+}
+
+// Slice returns a slice of values which uses the same memory as the byte
+// slice. Trailing bytes which do not make a complete value are not included.
+// It panics with ErrType if T values cannot be mapped onto bytes.
+func Slice[T any](d []byte) []T {
+	if err := checkType[T](); err != nil {
+		panic(err)
+	}
+
+	sz := Size[T]()
+	if sz == 0 || len(d) < sz {
+		return nil
+	}
+
+	return unsafe.Slice((*T)(unsafe.Pointer(&d[0])), len(d)/sz)
+}
+
+// Value has a value and a byte slice using the same memory location.
+// Any changes done to one of these fields will reflect on the other.
+type Value[T any] struct {
+	Value *T
+	Bytes []byte
+}
+
+// NewValue will create a new Value struct with given byte slice.
+// If the slice is nil, a new byte slice will be created for storage.
+// If the slice length is less than required length, it will panic.
+// It also panics with ErrType if T values cannot be mapped onto bytes.
+func NewValue[T any](d []byte) *Value[T] {
+	if err := checkType[T](); err != nil {
+		panic(err)
+	}
+
+	if d == nil {
+		d = make([]byte, Size[T]())
+	}
+
+	v := &Value[T]{}
+	v.Read(d)
+	return v
+}
+
+// NewValueChecked is like NewValue but it returns an error if the slice is
+// too small or not aligned for the value instead of panicking. Use NewValue
+// in hot loops where the slice is already known to be valid.
+func NewValueChecked[T any](d []byte) (v *Value[T], err error) {
+	if err := checkType[T](); err != nil {
+		return nil, err
+	}
+
+	if d == nil {
+		return NewValue[T](nil), nil
+	}
+
+	v = &Value[T]{}
+	if err := v.ReadChecked(d); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// Read updates the struct to use provided byte slice
+// This can be used when it's required to read data from
+func (v *Value[T]) Read(d []byte) {
+	v.Value = (*T)(pointer[T](d))
+	v.Bytes = d[:Size[T]()]
+}
+
+// ReadChecked is like Read but it returns an error if the slice is
+// too small or not aligned for the value. The struct is not changed
+// when an error is returned.
+func (v *Value[T]) ReadChecked(d []byte) (err error) {
+	if err := check[T](d); err != nil {
+		return err
+	}
+
+	v.Read(d)
+	return nil
+}
+
+// check makes sure the slice can hold a T value at an aligned address
+func check[T any](d []byte) (err error) {
+	if err := checkType[T](); err != nil {
+		return err
+	}
+
+	var v T
+	if len(d) < Size[T]() {
+		return ErrSize
+	}
+
+	if len(d) > 0 && uintptr(unsafe.Pointer(&d[0]))%unsafe.Alignof(v) != 0 {
+		return ErrAlign
+	}
+
+	return nil
+}
+
+// checkType makes sure that T values can be mapped onto bytes
+func checkType[T any]() (err error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if ok, found := types.Load(t); found {
+		if !ok.(bool) {
+			return ErrType
+		}
+
+		return nil
+	}
+
+	ok := fixed(t)
+	types.Store(t, ok)
+	if !ok {
+		return ErrType
+	}
+
+	return nil
+}
+
+// fixed checks whether the type has a fixed size without pointers
+func fixed(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	case reflect.Array:
+		return fixed(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if !fixed(t.Field(i).Type) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// pointer returns a pointer to the start of the byte slice
+// after making sure that it's large enough to hold a T value.
+func pointer[T any](d []byte) unsafe.Pointer {
+	d = d[:Size[T]()]
+	return unsafe.Pointer(unsafe.SliceData(d))
+}
@@ -0,0 +1,146 @@
+package hybrid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+type record struct {
+	ID    uint32
+	Flags uint16
+	Kind  uint16
+	Value float64
+}
+
+func TestStruct(t *testing.T) {
+	if sz := Size[record](); sz != 16 {
+		t.Fatal("wrong size", sz)
+	}
+
+	v := NewValue[record](nil)
+	v.Value.ID = 5
+	v.Value.Value = 1.5
+
+	b := bytes.NewBuffer(nil)
+	binary.Write(b, binary.LittleEndian, record{ID: 5, Value: 1.5})
+	if !bytes.Equal(v.Bytes, b.Bytes()) {
+		t.Fatal("wrong value")
+	}
+
+	var r record
+	Decode(v.Bytes, &r)
+	if r.ID != 5 || r.Value != 1.5 {
+		t.Fatal("wrong value")
+	}
+
+	r.Kind = 3
+	Encode(v.Bytes, &r)
+	if v.Value.Kind != 3 {
+		t.Fatal("wrong value")
+	}
+}
+
+func TestSlice(t *testing.T) {
+	d := make([]byte, 3*Size[record]()+1)
+
+	s := Slice[record](d)
+	if len(s) != 3 {
+		t.Fatal("wrong length")
+	}
+
+	s[2].ID = 7
+	var r record
+	Decode(d[2*Size[record]():], &r)
+	if r.ID != 7 {
+		t.Fatal("slices should use same memory")
+	}
+
+	if s := Slice[struct{}](d); s != nil {
+		t.Fatal("zero size values cannot be sliced")
+	}
+}
+
+func TestShort(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+
+	var v uint64
+	Decode(make([]byte, 4), &v)
+}
+
+func TestChecked(t *testing.T) {
+	d := make([]byte, 2*Size[record]())
+
+	v, err := NewValueChecked[record](d[Size[record]():])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v.ReadChecked(d[4:]); err != ErrAlign {
+		t.Fatal("expected ErrAlign")
+	}
+
+	if err := v.ReadChecked(d[Size[record]()+8:]); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	// the struct is not changed on errors
+	if &v.Bytes[0] != &d[Size[record]()] {
+		t.Fatal("wrong slice")
+	}
+
+	if v, err := NewValueChecked[record](nil); err != nil || len(v.Bytes) != Size[record]() {
+		t.Fatal("should create a new slice")
+	}
+}
+
+func TestType(t *testing.T) {
+	type pointers struct {
+		ID   uint64
+		Next *record
+	}
+
+	type arrays struct {
+		IDs  [4]uint32
+		Recs [2]record
+		On   bool
+	}
+
+	if _, err := NewValueChecked[arrays](nil); err != nil {
+		t.Fatal(err)
+	}
+
+	d := make([]byte, 64)
+	if _, err := NewValueChecked[*record](d); err != ErrType {
+		t.Fatal("expected ErrType")
+	}
+
+	if _, err := NewValueChecked[string](nil); err != ErrType {
+		t.Fatal("expected ErrType")
+	}
+
+	if _, err := NewValueChecked[pointers](d); err != ErrType {
+		t.Fatal("expected ErrType")
+	}
+
+	if _, err := NewValueChecked[[2][]byte](d); err != ErrType {
+		t.Fatal("expected ErrType")
+	}
+
+	v := NewValue[uint64](nil)
+	if err := v.ReadChecked(d); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if recover() != ErrType {
+			t.Fatal("expected panic")
+		}
+	}()
+
+	Slice[*record](d)
+}
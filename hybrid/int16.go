@@ -0,0 +1,83 @@
+package hybrid
+
+const (
+	// SzInt16 is the size of a int16 value in bytes
+	SzInt16 = 2
+)
+
+// EncodeInt16 updates the byte slice to match value
+func EncodeInt16(d []byte, v *int16) {
+	Encode(d, v)
+}
+
+// DecodeInt16 updates the value to match the byte slice
+func DecodeInt16(d []byte, v *int16) {
+	Decode(d, v)
+}
+
+// EncodeInt16LE updates the byte slice to match value in little endian byte order
+func EncodeInt16LE(d []byte, v *int16) {
+	EncodeLE(d, v)
+}
+
+// DecodeInt16LE updates the value to match the byte slice in little endian byte order
+func DecodeInt16LE(d []byte, v *int16) {
+	DecodeLE(d, v)
+}
+
+// EncodeInt16BE updates the byte slice to match value in big endian byte order
+func EncodeInt16BE(d []byte, v *int16) {
+	EncodeBE(d, v)
+}
+
+// DecodeInt16BE updates the value to match the byte slice in big endian byte order
+func DecodeInt16BE(d []byte, v *int16) {
+	DecodeBE(d, v)
+}
+
+// Int16 has a int16 value and a byte slice using the same memory location.
+// Any changes done to one of these fields will reflect on the other.
+type Int16 Value[int16]
+
+// NewInt16 will create a new Int16 struct with given byte slice.
+// If the slice is nil, a new byte slice will be created for storage.
+// If the slice length is less than required length, it will panic.
+func NewInt16(d []byte) *Int16 {
+	return (*Int16)(NewValue[int16](d))
+}
+
+// NewInt16Checked is like NewInt16 but it returns an error
+// if the slice is too small or not aligned for the value.
+func NewInt16Checked(d []byte) (*Int16, error) {
+	v, err := NewValueChecked[int16](d)
+	return (*Int16)(v), err
+}
+
+// Read updates the struct to use provided byte slice
+// This can be used when it's required to read data from
+func (v *Int16) Read(d []byte) {
+	(*Value[int16])(v).Read(d)
+}
+
+// ReadChecked is like Read but it returns an error if the slice is
+// too small or not aligned for the value.
+func (v *Int16) ReadChecked(d []byte) (err error) {
+	return (*Value[int16])(v).ReadChecked(d)
+}
+
+// Int16s has a int16 slice and a byte slice using the same memory location.
+// Any changes done to one of these fields will reflect on the other.
+type Int16s = Values[int16]
+
+// NewInt16s will create a new Int16s struct with n values in the byte slice.
+// If the slice is nil, a new byte slice will be created for n values.
+// If the slice length is less than required length, it will panic.
+func NewInt16s(d []byte, n int) *Int16s {
+	return NewValues[int16](d, n)
+}
+
+// MapInt16s returns a int16 slice which uses the same memory as the byte
+// slice. Trailing bytes which do not make a complete value are not included.
+func MapInt16s(d []byte) []int16 {
+	return Slice[int16](d)
+}
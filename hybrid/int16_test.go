@@ -0,0 +1,210 @@
+package hybrid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func BinaryEncodeInt16(v int16) []byte {
+	b := bytes.NewBuffer(nil)
+	binary.Write(b, binary.LittleEndian, v)
+	return b.Bytes()
+}
+
+func BinaryDecodeInt16(d []byte) int16 {
+	var v int16
+	b := bytes.NewBuffer(d)
+	binary.Read(b, binary.LittleEndian, &v)
+	return v
+}
+
+func TestInt16(t *testing.T) {
+	var u int16 = 5
+	d := BinaryEncodeInt16(0)
+
+	EncodeInt16(d, &u)
+	if !bytes.Equal(d, BinaryEncodeInt16(5)) {
+		t.Fatal("wrong value")
+	}
+
+	d = BinaryEncodeInt16(10)
+	DecodeInt16(d, &u)
+	if u != BinaryDecodeInt16(d) {
+		t.Fatal("wrong value")
+	}
+
+	// ---  ---  --- ---  ---  --- ---  ---  --- ---  ---  --- ---  ---
+
+	v := NewInt16(nil)
+	if !bytes.Equal(v.Bytes, BinaryEncodeInt16(0)) || *v.Value != 0 {
+		t.Fatal("wrong value")
+	}
+
+	*v.Value = 5
+	if !bytes.Equal(v.Bytes, BinaryEncodeInt16(5)) || *v.Value != 5 {
+		t.Fatal("wrong value")
+	}
+
+	d = BinaryEncodeInt16(10)
+	d = append(d, 1, 2, 3, 4, 5)
+	v.Read(d)
+
+	if !bytes.Equal(v.Bytes, d[:SzInt16]) || *v.Value != 10 {
+		t.Fatal("wrong value")
+	}
+}
+
+func TestInt16Endian(t *testing.T) {
+	var u int16 = 5
+	d := make([]byte, SzInt16)
+
+	EncodeInt16LE(d, &u)
+	if !bytes.Equal(d, BinaryEncodeInt16(5)) {
+		t.Fatal("wrong value")
+	}
+
+	var v int16
+	DecodeInt16LE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+
+	b := bytes.NewBuffer(nil)
+	binary.Write(b, binary.BigEndian, u)
+
+	EncodeInt16BE(d, &u)
+	if !bytes.Equal(d, b.Bytes()) {
+		t.Fatal("wrong value")
+	}
+
+	v = 0
+	DecodeInt16BE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+}
+
+func TestInt16Checked(t *testing.T) {
+	d := make([]byte, 2*SzInt16)
+
+	v, err := NewInt16Checked(d[SzInt16:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*v.Value = 5
+	if !bytes.Equal(d[SzInt16:], BinaryEncodeInt16(5)) {
+		t.Fatal("wrong value")
+	}
+
+	if _, err := NewInt16Checked(d[:SzInt16-1]); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	if SzInt16 > 1 {
+		if _, err := NewInt16Checked(d[1:]); err != ErrAlign {
+			t.Fatal("expected ErrAlign")
+		}
+	}
+}
+
+func TestMapInt16s(t *testing.T) {
+	d := append(BinaryEncodeInt16(5), BinaryEncodeInt16(10)...)
+
+	v := MapInt16s(d)
+	if len(v) != 2 || v[0] != 5 || v[1] != 10 {
+		t.Fatal("wrong value")
+	}
+
+	v[1] = 15
+	if !bytes.Equal(d[SzInt16:], BinaryEncodeInt16(15)) {
+		t.Fatal("wrong value")
+	}
+
+	if v := MapInt16s(d[:len(d)-1]); len(v) != 1 {
+		t.Fatal("wrong length")
+	}
+
+	if v := MapInt16s(nil); len(v) != 0 {
+		t.Fatal("wrong length")
+	}
+}
+
+func TestInt16s(t *testing.T) {
+	d := make([]byte, 3*SzInt16)
+
+	v := NewInt16s(d, 1)
+	if err := v.Append(5, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(d[2*SzInt16:], BinaryEncodeInt16(10)) {
+		t.Fatal("wrong value")
+	}
+
+	if err := v.Append(15); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+}
+
+func BenchmarkInt16BinaryDecode(b *testing.B) {
+	var v int16
+	var d = make([]byte, b.N*SzInt16)
+	var s = bytes.NewBuffer(d)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		binary.Read(s, binary.LittleEndian, v)
+	}
+}
+
+func BenchmarkInt16BinaryEncode(b *testing.B) {
+	var v int16
+	var d = make([]byte, b.N*SzInt16)
+	var s = bytes.NewBuffer(d)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		binary.Write(s, binary.LittleEndian, v)
+	}
+}
+
+func BenchmarkInt16Decode(b *testing.B) {
+	var d = make([]byte, SzInt16)
+	var v int16
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		DecodeInt16(d, &v)
+	}
+}
+
+func BenchmarkInt16Encode(b *testing.B) {
+	var d = make([]byte, SzInt16)
+	var v int16
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		EncodeInt16(d, &v)
+	}
+}
+
+func BenchmarkInt16Read(b *testing.B) {
+	var d = make([]byte, b.N*SzInt16)
+	var s = NewInt16(nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Read(d[i*SzInt16:])
+	}
+}
+
+func BenchmarkInt16Write(b *testing.B) {
+	var s = NewInt16(nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		*s.Value = 0
+	}
+}
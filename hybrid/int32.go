@@ -1,52 +1,83 @@
 package hybrid
 
-import (
-	"reflect"
-	"unsafe"
-)
-
 const (
+	// SzInt32 is the size of a int32 value in bytes
 	SzInt32 = 4
 )
 
 // EncodeInt32 updates the byte slice to match value
 func EncodeInt32(d []byte, v *int32) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	value := (*int32)(unsafe.Pointer(head.Data))
-	*value = *v
+	Encode(d, v)
 }
 
 // DecodeInt32 updates the value to match the byte slice
 func DecodeInt32(d []byte, v *int32) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	value := (*int32)(unsafe.Pointer(head.Data))
-	*v = *value
+	Decode(d, v)
+}
+
+// EncodeInt32LE updates the byte slice to match value in little endian byte order
+func EncodeInt32LE(d []byte, v *int32) {
+	EncodeLE(d, v)
+}
+
+// DecodeInt32LE updates the value to match the byte slice in little endian byte order
+func DecodeInt32LE(d []byte, v *int32) {
+	DecodeLE(d, v)
+}
+
+// EncodeInt32BE updates the byte slice to match value in big endian byte order
+func EncodeInt32BE(d []byte, v *int32) {
+	EncodeBE(d, v)
+}
+
+// DecodeInt32BE updates the value to match the byte slice in big endian byte order
+func DecodeInt32BE(d []byte, v *int32) {
+	DecodeBE(d, v)
 }
 
 // Int32 has a int32 value and a byte slice using the same memory location.
 // Any changes done to one of these fields will reflect on the other.
-type Int32 struct {
-	Value *int32
-	Bytes []byte
-}
+type Int32 Value[int32]
 
 // NewInt32 will create a new Int32 struct with given byte slice.
 // If the slice is nil, a new byte slice will be created for storage.
 // If the slice length is less than required length, it will panic.
 func NewInt32(d []byte) *Int32 {
-	if d == nil {
-		d = make([]byte, SzInt32)
-	}
+	return (*Int32)(NewValue[int32](d))
+}
 
-	v := &Int32{}
-	v.Read(d[:SzInt32])
-	return v
+// NewInt32Checked is like NewInt32 but it returns an error
+// if the slice is too small or not aligned for the value.
+func NewInt32Checked(d []byte) (*Int32, error) {
+	v, err := NewValueChecked[int32](d)
+	return (*Int32)(v), err
 }
 
 // Read updates the struct to use provided byte slice
 // This can be used when it's required to read data from
 func (v *Int32) Read(d []byte) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	v.Value = (*int32)(unsafe.Pointer(head.Data))
-	v.Bytes = d[:SzInt32]
+	(*Value[int32])(v).Read(d)
+}
+
+// ReadChecked is like Read but it returns an error if the slice is
+// too small or not aligned for the value.
+func (v *Int32) ReadChecked(d []byte) (err error) {
+	return (*Value[int32])(v).ReadChecked(d)
+}
+
+// Int32s has a int32 slice and a byte slice using the same memory location.
+// Any changes done to one of these fields will reflect on the other.
+type Int32s = Values[int32]
+
+// NewInt32s will create a new Int32s struct with n values in the byte slice.
+// If the slice is nil, a new byte slice will be created for n values.
+// If the slice length is less than required length, it will panic.
+func NewInt32s(d []byte, n int) *Int32s {
+	return NewValues[int32](d, n)
+}
+
+// MapInt32s returns a int32 slice which uses the same memory as the byte
+// slice. Trailing bytes which do not make a complete value are not included.
+func MapInt32s(d []byte) []int32 {
+	return Slice[int32](d)
 }
@@ -55,6 +55,99 @@ func TestInt32(t *testing.T) {
 	}
 }
 
+func TestInt32Endian(t *testing.T) {
+	var u int32 = 5
+	d := make([]byte, SzInt32)
+
+	EncodeInt32LE(d, &u)
+	if !bytes.Equal(d, BinaryEncodeInt32(5)) {
+		t.Fatal("wrong value")
+	}
+
+	var v int32
+	DecodeInt32LE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+
+	b := bytes.NewBuffer(nil)
+	binary.Write(b, binary.BigEndian, u)
+
+	EncodeInt32BE(d, &u)
+	if !bytes.Equal(d, b.Bytes()) {
+		t.Fatal("wrong value")
+	}
+
+	v = 0
+	DecodeInt32BE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+}
+
+func TestInt32Checked(t *testing.T) {
+	d := make([]byte, 2*SzInt32)
+
+	v, err := NewInt32Checked(d[SzInt32:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*v.Value = 5
+	if !bytes.Equal(d[SzInt32:], BinaryEncodeInt32(5)) {
+		t.Fatal("wrong value")
+	}
+
+	if _, err := NewInt32Checked(d[:SzInt32-1]); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	if SzInt32 > 1 {
+		if _, err := NewInt32Checked(d[1:]); err != ErrAlign {
+			t.Fatal("expected ErrAlign")
+		}
+	}
+}
+
+func TestMapInt32s(t *testing.T) {
+	d := append(BinaryEncodeInt32(5), BinaryEncodeInt32(10)...)
+
+	v := MapInt32s(d)
+	if len(v) != 2 || v[0] != 5 || v[1] != 10 {
+		t.Fatal("wrong value")
+	}
+
+	v[1] = 15
+	if !bytes.Equal(d[SzInt32:], BinaryEncodeInt32(15)) {
+		t.Fatal("wrong value")
+	}
+
+	if v := MapInt32s(d[:len(d)-1]); len(v) != 1 {
+		t.Fatal("wrong length")
+	}
+
+	if v := MapInt32s(nil); len(v) != 0 {
+		t.Fatal("wrong length")
+	}
+}
+
+func TestInt32s(t *testing.T) {
+	d := make([]byte, 3*SzInt32)
+
+	v := NewInt32s(d, 1)
+	if err := v.Append(5, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(d[2*SzInt32:], BinaryEncodeInt32(10)) {
+		t.Fatal("wrong value")
+	}
+
+	if err := v.Append(15); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+}
+
 func BenchmarkInt32BinaryDecode(b *testing.B) {
 	var v int32
 	var d = make([]byte, b.N*SzInt32)
@@ -1,52 +1,83 @@
 package hybrid
 
-import (
-	"reflect"
-	"unsafe"
-)
-
 const (
+	// SzInt64 is the size of a int64 value in bytes
 	SzInt64 = 8
 )
 
 // EncodeInt64 updates the byte slice to match value
 func EncodeInt64(d []byte, v *int64) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	value := (*int64)(unsafe.Pointer(head.Data))
-	*value = *v
+	Encode(d, v)
 }
 
 // DecodeInt64 updates the value to match the byte slice
 func DecodeInt64(d []byte, v *int64) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	value := (*int64)(unsafe.Pointer(head.Data))
-	*v = *value
+	Decode(d, v)
+}
+
+// EncodeInt64LE updates the byte slice to match value in little endian byte order
+func EncodeInt64LE(d []byte, v *int64) {
+	EncodeLE(d, v)
+}
+
+// DecodeInt64LE updates the value to match the byte slice in little endian byte order
+func DecodeInt64LE(d []byte, v *int64) {
+	DecodeLE(d, v)
+}
+
+// EncodeInt64BE updates the byte slice to match value in big endian byte order
+func EncodeInt64BE(d []byte, v *int64) {
+	EncodeBE(d, v)
+}
+
+// DecodeInt64BE updates the value to match the byte slice in big endian byte order
+func DecodeInt64BE(d []byte, v *int64) {
+	DecodeBE(d, v)
 }
 
 // Int64 has a int64 value and a byte slice using the same memory location.
 // Any changes done to one of these fields will reflect on the other.
-type Int64 struct {
-	Value *int64
-	Bytes []byte
-}
+type Int64 Value[int64]
 
 // NewInt64 will create a new Int64 struct with given byte slice.
 // If the slice is nil, a new byte slice will be created for storage.
 // If the slice length is less than required length, it will panic.
 func NewInt64(d []byte) *Int64 {
-	if d == nil {
-		d = make([]byte, SzInt64)
-	}
+	return (*Int64)(NewValue[int64](d))
+}
 
-	v := &Int64{}
-	v.Read(d[:SzInt64])
-	return v
+// NewInt64Checked is like NewInt64 but it returns an error
+// if the slice is too small or not aligned for the value.
+func NewInt64Checked(d []byte) (*Int64, error) {
+	v, err := NewValueChecked[int64](d)
+	return (*Int64)(v), err
 }
 
 // Read updates the struct to use provided byte slice
 // This can be used when it's required to read data from
 func (v *Int64) Read(d []byte) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	v.Value = (*int64)(unsafe.Pointer(head.Data))
-	v.Bytes = d[:SzInt64]
+	(*Value[int64])(v).Read(d)
+}
+
+// ReadChecked is like Read but it returns an error if the slice is
+// too small or not aligned for the value.
+func (v *Int64) ReadChecked(d []byte) (err error) {
+	return (*Value[int64])(v).ReadChecked(d)
+}
+
+// Int64s has a int64 slice and a byte slice using the same memory location.
+// Any changes done to one of these fields will reflect on the other.
+type Int64s = Values[int64]
+
+// NewInt64s will create a new Int64s struct with n values in the byte slice.
+// If the slice is nil, a new byte slice will be created for n values.
+// If the slice length is less than required length, it will panic.
+func NewInt64s(d []byte, n int) *Int64s {
+	return NewValues[int64](d, n)
+}
+
+// MapInt64s returns a int64 slice which uses the same memory as the byte
+// slice. Trailing bytes which do not make a complete value are not included.
+func MapInt64s(d []byte) []int64 {
+	return Slice[int64](d)
 }
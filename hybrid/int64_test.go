@@ -55,6 +55,99 @@ func TestInt64(t *testing.T) {
 	}
 }
 
+func TestInt64Endian(t *testing.T) {
+	var u int64 = 5
+	d := make([]byte, SzInt64)
+
+	EncodeInt64LE(d, &u)
+	if !bytes.Equal(d, BinaryEncodeInt64(5)) {
+		t.Fatal("wrong value")
+	}
+
+	var v int64
+	DecodeInt64LE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+
+	b := bytes.NewBuffer(nil)
+	binary.Write(b, binary.BigEndian, u)
+
+	EncodeInt64BE(d, &u)
+	if !bytes.Equal(d, b.Bytes()) {
+		t.Fatal("wrong value")
+	}
+
+	v = 0
+	DecodeInt64BE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+}
+
+func TestInt64Checked(t *testing.T) {
+	d := make([]byte, 2*SzInt64)
+
+	v, err := NewInt64Checked(d[SzInt64:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*v.Value = 5
+	if !bytes.Equal(d[SzInt64:], BinaryEncodeInt64(5)) {
+		t.Fatal("wrong value")
+	}
+
+	if _, err := NewInt64Checked(d[:SzInt64-1]); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	if SzInt64 > 1 {
+		if _, err := NewInt64Checked(d[1:]); err != ErrAlign {
+			t.Fatal("expected ErrAlign")
+		}
+	}
+}
+
+func TestMapInt64s(t *testing.T) {
+	d := append(BinaryEncodeInt64(5), BinaryEncodeInt64(10)...)
+
+	v := MapInt64s(d)
+	if len(v) != 2 || v[0] != 5 || v[1] != 10 {
+		t.Fatal("wrong value")
+	}
+
+	v[1] = 15
+	if !bytes.Equal(d[SzInt64:], BinaryEncodeInt64(15)) {
+		t.Fatal("wrong value")
+	}
+
+	if v := MapInt64s(d[:len(d)-1]); len(v) != 1 {
+		t.Fatal("wrong length")
+	}
+
+	if v := MapInt64s(nil); len(v) != 0 {
+		t.Fatal("wrong length")
+	}
+}
+
+func TestInt64s(t *testing.T) {
+	d := make([]byte, 3*SzInt64)
+
+	v := NewInt64s(d, 1)
+	if err := v.Append(5, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(d[2*SzInt64:], BinaryEncodeInt64(10)) {
+		t.Fatal("wrong value")
+	}
+
+	if err := v.Append(15); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+}
+
 func BenchmarkInt64BinaryDecode(b *testing.B) {
 	var v int64
 	var d = make([]byte, b.N*SzInt64)
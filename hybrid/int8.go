@@ -0,0 +1,83 @@
+package hybrid
+
+const (
+	// SzInt8 is the size of a int8 value in bytes
+	SzInt8 = 1
+)
+
+// EncodeInt8 updates the byte slice to match value
+func EncodeInt8(d []byte, v *int8) {
+	Encode(d, v)
+}
+
+// DecodeInt8 updates the value to match the byte slice
+func DecodeInt8(d []byte, v *int8) {
+	Decode(d, v)
+}
+
+// EncodeInt8LE updates the byte slice to match value in little endian byte order
+func EncodeInt8LE(d []byte, v *int8) {
+	EncodeLE(d, v)
+}
+
+// DecodeInt8LE updates the value to match the byte slice in little endian byte order
+func DecodeInt8LE(d []byte, v *int8) {
+	DecodeLE(d, v)
+}
+
+// EncodeInt8BE updates the byte slice to match value in big endian byte order
+func EncodeInt8BE(d []byte, v *int8) {
+	EncodeBE(d, v)
+}
+
+// DecodeInt8BE updates the value to match the byte slice in big endian byte order
+func DecodeInt8BE(d []byte, v *int8) {
+	DecodeBE(d, v)
+}
+
+// Int8 has a int8 value and a byte slice using the same memory location.
+// Any changes done to one of these fields will reflect on the other.
+type Int8 Value[int8]
+
+// NewInt8 will create a new Int8 struct with given byte slice.
+// If the slice is nil, a new byte slice will be created for storage.
+// If the slice length is less than required length, it will panic.
+func NewInt8(d []byte) *Int8 {
+	return (*Int8)(NewValue[int8](d))
+}
+
+// NewInt8Checked is like NewInt8 but it returns an error
+// if the slice is too small or not aligned for the value.
+func NewInt8Checked(d []byte) (*Int8, error) {
+	v, err := NewValueChecked[int8](d)
+	return (*Int8)(v), err
+}
+
+// Read updates the struct to use provided byte slice
+// This can be used when it's required to read data from
+func (v *Int8) Read(d []byte) {
+	(*Value[int8])(v).Read(d)
+}
+
+// ReadChecked is like Read but it returns an error if the slice is
+// too small or not aligned for the value.
+func (v *Int8) ReadChecked(d []byte) (err error) {
+	return (*Value[int8])(v).ReadChecked(d)
+}
+
+// Int8s has a int8 slice and a byte slice using the same memory location.
+// Any changes done to one of these fields will reflect on the other.
+type Int8s = Values[int8]
+
+// NewInt8s will create a new Int8s struct with n values in the byte slice.
+// If the slice is nil, a new byte slice will be created for n values.
+// If the slice length is less than required length, it will panic.
+func NewInt8s(d []byte, n int) *Int8s {
+	return NewValues[int8](d, n)
+}
+
+// MapInt8s returns a int8 slice which uses the same memory as the byte
+// slice. Trailing bytes which do not make a complete value are not included.
+func MapInt8s(d []byte) []int8 {
+	return Slice[int8](d)
+}
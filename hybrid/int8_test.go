@@ -0,0 +1,210 @@
+package hybrid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func BinaryEncodeInt8(v int8) []byte {
+	b := bytes.NewBuffer(nil)
+	binary.Write(b, binary.LittleEndian, v)
+	return b.Bytes()
+}
+
+func BinaryDecodeInt8(d []byte) int8 {
+	var v int8
+	b := bytes.NewBuffer(d)
+	binary.Read(b, binary.LittleEndian, &v)
+	return v
+}
+
+func TestInt8(t *testing.T) {
+	var u int8 = 5
+	d := BinaryEncodeInt8(0)
+
+	EncodeInt8(d, &u)
+	if !bytes.Equal(d, BinaryEncodeInt8(5)) {
+		t.Fatal("wrong value")
+	}
+
+	d = BinaryEncodeInt8(10)
+	DecodeInt8(d, &u)
+	if u != BinaryDecodeInt8(d) {
+		t.Fatal("wrong value")
+	}
+
+	// ---  ---  --- ---  ---  --- ---  ---  --- ---  ---  --- ---  ---
+
+	v := NewInt8(nil)
+	if !bytes.Equal(v.Bytes, BinaryEncodeInt8(0)) || *v.Value != 0 {
+		t.Fatal("wrong value")
+	}
+
+	*v.Value = 5
+	if !bytes.Equal(v.Bytes, BinaryEncodeInt8(5)) || *v.Value != 5 {
+		t.Fatal("wrong value")
+	}
+
+	d = BinaryEncodeInt8(10)
+	d = append(d, 1, 2, 3, 4, 5)
+	v.Read(d)
+
+	if !bytes.Equal(v.Bytes, d[:SzInt8]) || *v.Value != 10 {
+		t.Fatal("wrong value")
+	}
+}
+
+func TestInt8Endian(t *testing.T) {
+	var u int8 = 5
+	d := make([]byte, SzInt8)
+
+	EncodeInt8LE(d, &u)
+	if !bytes.Equal(d, BinaryEncodeInt8(5)) {
+		t.Fatal("wrong value")
+	}
+
+	var v int8
+	DecodeInt8LE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+
+	b := bytes.NewBuffer(nil)
+	binary.Write(b, binary.BigEndian, u)
+
+	EncodeInt8BE(d, &u)
+	if !bytes.Equal(d, b.Bytes()) {
+		t.Fatal("wrong value")
+	}
+
+	v = 0
+	DecodeInt8BE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+}
+
+func TestInt8Checked(t *testing.T) {
+	d := make([]byte, 2*SzInt8)
+
+	v, err := NewInt8Checked(d[SzInt8:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*v.Value = 5
+	if !bytes.Equal(d[SzInt8:], BinaryEncodeInt8(5)) {
+		t.Fatal("wrong value")
+	}
+
+	if _, err := NewInt8Checked(d[:SzInt8-1]); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	if SzInt8 > 1 {
+		if _, err := NewInt8Checked(d[1:]); err != ErrAlign {
+			t.Fatal("expected ErrAlign")
+		}
+	}
+}
+
+func TestMapInt8s(t *testing.T) {
+	d := append(BinaryEncodeInt8(5), BinaryEncodeInt8(10)...)
+
+	v := MapInt8s(d)
+	if len(v) != 2 || v[0] != 5 || v[1] != 10 {
+		t.Fatal("wrong value")
+	}
+
+	v[1] = 15
+	if !bytes.Equal(d[SzInt8:], BinaryEncodeInt8(15)) {
+		t.Fatal("wrong value")
+	}
+
+	if v := MapInt8s(d[:len(d)-1]); len(v) != 1 {
+		t.Fatal("wrong length")
+	}
+
+	if v := MapInt8s(nil); len(v) != 0 {
+		t.Fatal("wrong length")
+	}
+}
+
+func TestInt8s(t *testing.T) {
+	d := make([]byte, 3*SzInt8)
+
+	v := NewInt8s(d, 1)
+	if err := v.Append(5, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(d[2*SzInt8:], BinaryEncodeInt8(10)) {
+		t.Fatal("wrong value")
+	}
+
+	if err := v.Append(15); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+}
+
+func BenchmarkInt8BinaryDecode(b *testing.B) {
+	var v int8
+	var d = make([]byte, b.N*SzInt8)
+	var s = bytes.NewBuffer(d)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		binary.Read(s, binary.LittleEndian, v)
+	}
+}
+
+func BenchmarkInt8BinaryEncode(b *testing.B) {
+	var v int8
+	var d = make([]byte, b.N*SzInt8)
+	var s = bytes.NewBuffer(d)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		binary.Write(s, binary.LittleEndian, v)
+	}
+}
+
+func BenchmarkInt8Decode(b *testing.B) {
+	var d = make([]byte, SzInt8)
+	var v int8
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		DecodeInt8(d, &v)
+	}
+}
+
+func BenchmarkInt8Encode(b *testing.B) {
+	var d = make([]byte, SzInt8)
+	var v int8
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		EncodeInt8(d, &v)
+	}
+}
+
+func BenchmarkInt8Read(b *testing.B) {
+	var d = make([]byte, b.N*SzInt8)
+	var s = NewInt8(nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Read(d[i*SzInt8:])
+	}
+}
+
+func BenchmarkInt8Write(b *testing.B) {
+	var s = NewInt8(nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		*s.Value = 0
+	}
+}
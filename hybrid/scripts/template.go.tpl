@@ -1,52 +1,83 @@
 package hybrid
 
-import (
-	"reflect"
-	"unsafe"
-)
-
 const (
+	// Sz{{BG}} is the size of a {{SM}} value in bytes
 	Sz{{BG}} = {{SZ}}
 )
 
 // Encode{{BG}} updates the byte slice to match value
 func Encode{{BG}}(d []byte, v *{{SM}}) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	value := (*{{SM}})(unsafe.Pointer(head.Data))
-	*value = *v
+	Encode(d, v)
 }
 
 // Decode{{BG}} updates the value to match the byte slice
 func Decode{{BG}}(d []byte, v *{{SM}}) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	value := (*{{SM}})(unsafe.Pointer(head.Data))
-	*v = *value
+	Decode(d, v)
+}
+
+// Encode{{BG}}LE updates the byte slice to match value in little endian byte order
+func Encode{{BG}}LE(d []byte, v *{{SM}}) {
+	EncodeLE(d, v)
+}
+
+// Decode{{BG}}LE updates the value to match the byte slice in little endian byte order
+func Decode{{BG}}LE(d []byte, v *{{SM}}) {
+	DecodeLE(d, v)
+}
+
+// Encode{{BG}}BE updates the byte slice to match value in big endian byte order
+func Encode{{BG}}BE(d []byte, v *{{SM}}) {
+	EncodeBE(d, v)
+}
+
+// Decode{{BG}}BE updates the value to match the byte slice in big endian byte order
+func Decode{{BG}}BE(d []byte, v *{{SM}}) {
+	DecodeBE(d, v)
 }
 
 // {{BG}} has a {{SM}} value and a byte slice using the same memory location.
 // Any changes done to one of these fields will reflect on the other.
-type {{BG}} struct {
-	Value *{{SM}}
-	Bytes []byte
-}
+type {{BG}} Value[{{SM}}]
 
 // New{{BG}} will create a new {{BG}} struct with given byte slice.
 // If the slice is nil, a new byte slice will be created for storage.
 // If the slice length is less than required length, it will panic.
 func New{{BG}}(d []byte) *{{BG}} {
-	if d == nil {
-		d = make([]byte, Sz{{BG}})
-	}
+	return (*{{BG}})(NewValue[{{SM}}](d))
+}
 
-	v := &{{BG}}{}
-	v.Read(d[:Sz{{BG}}])
-	return v
+// New{{BG}}Checked is like New{{BG}} but it returns an error
+// if the slice is too small or not aligned for the value.
+func New{{BG}}Checked(d []byte) (*{{BG}}, error) {
+	v, err := NewValueChecked[{{SM}}](d)
+	return (*{{BG}})(v), err
 }
 
 // Read updates the struct to use provided byte slice
 // This can be used when it's required to read data from
 func (v *{{BG}}) Read(d []byte) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	v.Value = (*{{SM}})(unsafe.Pointer(head.Data))
-	v.Bytes = d[:Sz{{BG}}]
+	(*Value[{{SM}}])(v).Read(d)
+}
+
+// ReadChecked is like Read but it returns an error if the slice is
+// too small or not aligned for the value.
+func (v *{{BG}}) ReadChecked(d []byte) (err error) {
+	return (*Value[{{SM}}])(v).ReadChecked(d)
+}
+
+// {{BG}}s has a {{SM}} slice and a byte slice using the same memory location.
+// Any changes done to one of these fields will reflect on the other.
+type {{BG}}s = Values[{{SM}}]
+
+// New{{BG}}s will create a new {{BG}}s struct with n values in the byte slice.
+// If the slice is nil, a new byte slice will be created for n values.
+// If the slice length is less than required length, it will panic.
+func New{{BG}}s(d []byte, n int) *{{BG}}s {
+	return NewValues[{{SM}}](d, n)
+}
+
+// Map{{BG}}s returns a {{SM}} slice which uses the same memory as the byte
+// slice. Trailing bytes which do not make a complete value are not included.
+func Map{{BG}}s(d []byte) []{{SM}} {
+	return Slice[{{SM}}](d)
 }
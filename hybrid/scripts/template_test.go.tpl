@@ -55,6 +55,99 @@ func Test{{BG}}(t *testing.T) {
 	}
 }
 
+func Test{{BG}}Endian(t *testing.T) {
+	var u {{SM}} = 5
+	d := make([]byte, Sz{{BG}})
+
+	Encode{{BG}}LE(d, &u)
+	if !bytes.Equal(d, BinaryEncode{{BG}}(5)) {
+		t.Fatal("wrong value")
+	}
+
+	var v {{SM}}
+	Decode{{BG}}LE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+
+	b := bytes.NewBuffer(nil)
+	binary.Write(b, binary.BigEndian, u)
+
+	Encode{{BG}}BE(d, &u)
+	if !bytes.Equal(d, b.Bytes()) {
+		t.Fatal("wrong value")
+	}
+
+	v = 0
+	Decode{{BG}}BE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+}
+
+func Test{{BG}}Checked(t *testing.T) {
+	d := make([]byte, 2*Sz{{BG}})
+
+	v, err := New{{BG}}Checked(d[Sz{{BG}}:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*v.Value = 5
+	if !bytes.Equal(d[Sz{{BG}}:], BinaryEncode{{BG}}(5)) {
+		t.Fatal("wrong value")
+	}
+
+	if _, err := New{{BG}}Checked(d[:Sz{{BG}}-1]); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	if Sz{{BG}} > 1 {
+		if _, err := New{{BG}}Checked(d[1:]); err != ErrAlign {
+			t.Fatal("expected ErrAlign")
+		}
+	}
+}
+
+func TestMap{{BG}}s(t *testing.T) {
+	d := append(BinaryEncode{{BG}}(5), BinaryEncode{{BG}}(10)...)
+
+	v := Map{{BG}}s(d)
+	if len(v) != 2 || v[0] != 5 || v[1] != 10 {
+		t.Fatal("wrong value")
+	}
+
+	v[1] = 15
+	if !bytes.Equal(d[Sz{{BG}}:], BinaryEncode{{BG}}(15)) {
+		t.Fatal("wrong value")
+	}
+
+	if v := Map{{BG}}s(d[:len(d)-1]); len(v) != 1 {
+		t.Fatal("wrong length")
+	}
+
+	if v := Map{{BG}}s(nil); len(v) != 0 {
+		t.Fatal("wrong length")
+	}
+}
+
+func Test{{BG}}s(t *testing.T) {
+	d := make([]byte, 3*Sz{{BG}})
+
+	v := New{{BG}}s(d, 1)
+	if err := v.Append(5, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(d[2*Sz{{BG}}:], BinaryEncode{{BG}}(10)) {
+		t.Fatal("wrong value")
+	}
+
+	if err := v.Append(15); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+}
+
 func Benchmark{{BG}}BinaryDecode(b *testing.B) {
 	var v {{SM}}
 	var d = make([]byte, b.N*Sz{{BG}})
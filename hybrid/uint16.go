@@ -1,52 +1,83 @@
 package hybrid
 
-import (
-	"reflect"
-	"unsafe"
-)
-
 const (
+	// SzUint16 is the size of a uint16 value in bytes
 	SzUint16 = 2
 )
 
 // EncodeUint16 updates the byte slice to match value
 func EncodeUint16(d []byte, v *uint16) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	value := (*uint16)(unsafe.Pointer(head.Data))
-	*value = *v
+	Encode(d, v)
 }
 
 // DecodeUint16 updates the value to match the byte slice
 func DecodeUint16(d []byte, v *uint16) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	value := (*uint16)(unsafe.Pointer(head.Data))
-	*v = *value
+	Decode(d, v)
+}
+
+// EncodeUint16LE updates the byte slice to match value in little endian byte order
+func EncodeUint16LE(d []byte, v *uint16) {
+	EncodeLE(d, v)
+}
+
+// DecodeUint16LE updates the value to match the byte slice in little endian byte order
+func DecodeUint16LE(d []byte, v *uint16) {
+	DecodeLE(d, v)
+}
+
+// EncodeUint16BE updates the byte slice to match value in big endian byte order
+func EncodeUint16BE(d []byte, v *uint16) {
+	EncodeBE(d, v)
+}
+
+// DecodeUint16BE updates the value to match the byte slice in big endian byte order
+func DecodeUint16BE(d []byte, v *uint16) {
+	DecodeBE(d, v)
 }
 
 // Uint16 has a uint16 value and a byte slice using the same memory location.
 // Any changes done to one of these fields will reflect on the other.
-type Uint16 struct {
-	Value *uint16
-	Bytes []byte
-}
+type Uint16 Value[uint16]
 
 // NewUint16 will create a new Uint16 struct with given byte slice.
 // If the slice is nil, a new byte slice will be created for storage.
 // If the slice length is less than required length, it will panic.
 func NewUint16(d []byte) *Uint16 {
-	if d == nil {
-		d = make([]byte, SzUint16)
-	}
+	return (*Uint16)(NewValue[uint16](d))
+}
 
-	v := &Uint16{}
-	v.Read(d[:SzUint16])
-	return v
+// NewUint16Checked is like NewUint16 but it returns an error
+// if the slice is too small or not aligned for the value.
+func NewUint16Checked(d []byte) (*Uint16, error) {
+	v, err := NewValueChecked[uint16](d)
+	return (*Uint16)(v), err
 }
 
 // Read updates the struct to use provided byte slice
 // This can be used when it's required to read data from
 func (v *Uint16) Read(d []byte) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	v.Value = (*uint16)(unsafe.Pointer(head.Data))
-	v.Bytes = d[:SzUint16]
+	(*Value[uint16])(v).Read(d)
+}
+
+// ReadChecked is like Read but it returns an error if the slice is
+// too small or not aligned for the value.
+func (v *Uint16) ReadChecked(d []byte) (err error) {
+	return (*Value[uint16])(v).ReadChecked(d)
+}
+
+// Uint16s has a uint16 slice and a byte slice using the same memory location.
+// Any changes done to one of these fields will reflect on the other.
+type Uint16s = Values[uint16]
+
+// NewUint16s will create a new Uint16s struct with n values in the byte slice.
+// If the slice is nil, a new byte slice will be created for n values.
+// If the slice length is less than required length, it will panic.
+func NewUint16s(d []byte, n int) *Uint16s {
+	return NewValues[uint16](d, n)
+}
+
+// MapUint16s returns a uint16 slice which uses the same memory as the byte
+// slice. Trailing bytes which do not make a complete value are not included.
+func MapUint16s(d []byte) []uint16 {
+	return Slice[uint16](d)
 }
@@ -55,6 +55,99 @@ func TestUint16(t *testing.T) {
 	}
 }
 
+func TestUint16Endian(t *testing.T) {
+	var u uint16 = 5
+	d := make([]byte, SzUint16)
+
+	EncodeUint16LE(d, &u)
+	if !bytes.Equal(d, BinaryEncodeUint16(5)) {
+		t.Fatal("wrong value")
+	}
+
+	var v uint16
+	DecodeUint16LE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+
+	b := bytes.NewBuffer(nil)
+	binary.Write(b, binary.BigEndian, u)
+
+	EncodeUint16BE(d, &u)
+	if !bytes.Equal(d, b.Bytes()) {
+		t.Fatal("wrong value")
+	}
+
+	v = 0
+	DecodeUint16BE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+}
+
+func TestUint16Checked(t *testing.T) {
+	d := make([]byte, 2*SzUint16)
+
+	v, err := NewUint16Checked(d[SzUint16:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*v.Value = 5
+	if !bytes.Equal(d[SzUint16:], BinaryEncodeUint16(5)) {
+		t.Fatal("wrong value")
+	}
+
+	if _, err := NewUint16Checked(d[:SzUint16-1]); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	if SzUint16 > 1 {
+		if _, err := NewUint16Checked(d[1:]); err != ErrAlign {
+			t.Fatal("expected ErrAlign")
+		}
+	}
+}
+
+func TestMapUint16s(t *testing.T) {
+	d := append(BinaryEncodeUint16(5), BinaryEncodeUint16(10)...)
+
+	v := MapUint16s(d)
+	if len(v) != 2 || v[0] != 5 || v[1] != 10 {
+		t.Fatal("wrong value")
+	}
+
+	v[1] = 15
+	if !bytes.Equal(d[SzUint16:], BinaryEncodeUint16(15)) {
+		t.Fatal("wrong value")
+	}
+
+	if v := MapUint16s(d[:len(d)-1]); len(v) != 1 {
+		t.Fatal("wrong length")
+	}
+
+	if v := MapUint16s(nil); len(v) != 0 {
+		t.Fatal("wrong length")
+	}
+}
+
+func TestUint16s(t *testing.T) {
+	d := make([]byte, 3*SzUint16)
+
+	v := NewUint16s(d, 1)
+	if err := v.Append(5, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(d[2*SzUint16:], BinaryEncodeUint16(10)) {
+		t.Fatal("wrong value")
+	}
+
+	if err := v.Append(15); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+}
+
 func BenchmarkUint16BinaryDecode(b *testing.B) {
 	var v uint16
 	var d = make([]byte, b.N*SzUint16)
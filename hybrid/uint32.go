@@ -1,52 +1,83 @@
 package hybrid
 
-import (
-	"reflect"
-	"unsafe"
-)
-
 const (
+	// SzUint32 is the size of a uint32 value in bytes
 	SzUint32 = 4
 )
 
 // EncodeUint32 updates the byte slice to match value
 func EncodeUint32(d []byte, v *uint32) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	value := (*uint32)(unsafe.Pointer(head.Data))
-	*value = *v
+	Encode(d, v)
 }
 
 // DecodeUint32 updates the value to match the byte slice
 func DecodeUint32(d []byte, v *uint32) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	value := (*uint32)(unsafe.Pointer(head.Data))
-	*v = *value
+	Decode(d, v)
+}
+
+// EncodeUint32LE updates the byte slice to match value in little endian byte order
+func EncodeUint32LE(d []byte, v *uint32) {
+	EncodeLE(d, v)
+}
+
+// DecodeUint32LE updates the value to match the byte slice in little endian byte order
+func DecodeUint32LE(d []byte, v *uint32) {
+	DecodeLE(d, v)
+}
+
+// EncodeUint32BE updates the byte slice to match value in big endian byte order
+func EncodeUint32BE(d []byte, v *uint32) {
+	EncodeBE(d, v)
+}
+
+// DecodeUint32BE updates the value to match the byte slice in big endian byte order
+func DecodeUint32BE(d []byte, v *uint32) {
+	DecodeBE(d, v)
 }
 
 // Uint32 has a uint32 value and a byte slice using the same memory location.
 // Any changes done to one of these fields will reflect on the other.
-type Uint32 struct {
-	Value *uint32
-	Bytes []byte
-}
+type Uint32 Value[uint32]
 
 // NewUint32 will create a new Uint32 struct with given byte slice.
 // If the slice is nil, a new byte slice will be created for storage.
 // If the slice length is less than required length, it will panic.
 func NewUint32(d []byte) *Uint32 {
-	if d == nil {
-		d = make([]byte, SzUint32)
-	}
+	return (*Uint32)(NewValue[uint32](d))
+}
 
-	v := &Uint32{}
-	v.Read(d[:SzUint32])
-	return v
+// NewUint32Checked is like NewUint32 but it returns an error
+// if the slice is too small or not aligned for the value.
+func NewUint32Checked(d []byte) (*Uint32, error) {
+	v, err := NewValueChecked[uint32](d)
+	return (*Uint32)(v), err
 }
 
 // Read updates the struct to use provided byte slice
 // This can be used when it's required to read data from
 func (v *Uint32) Read(d []byte) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	v.Value = (*uint32)(unsafe.Pointer(head.Data))
-	v.Bytes = d[:SzUint32]
+	(*Value[uint32])(v).Read(d)
+}
+
+// ReadChecked is like Read but it returns an error if the slice is
+// too small or not aligned for the value.
+func (v *Uint32) ReadChecked(d []byte) (err error) {
+	return (*Value[uint32])(v).ReadChecked(d)
+}
+
+// Uint32s has a uint32 slice and a byte slice using the same memory location.
+// Any changes done to one of these fields will reflect on the other.
+type Uint32s = Values[uint32]
+
+// NewUint32s will create a new Uint32s struct with n values in the byte slice.
+// If the slice is nil, a new byte slice will be created for n values.
+// If the slice length is less than required length, it will panic.
+func NewUint32s(d []byte, n int) *Uint32s {
+	return NewValues[uint32](d, n)
+}
+
+// MapUint32s returns a uint32 slice which uses the same memory as the byte
+// slice. Trailing bytes which do not make a complete value are not included.
+func MapUint32s(d []byte) []uint32 {
+	return Slice[uint32](d)
 }
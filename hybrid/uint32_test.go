@@ -55,6 +55,99 @@ func TestUint32(t *testing.T) {
 	}
 }
 
+func TestUint32Endian(t *testing.T) {
+	var u uint32 = 5
+	d := make([]byte, SzUint32)
+
+	EncodeUint32LE(d, &u)
+	if !bytes.Equal(d, BinaryEncodeUint32(5)) {
+		t.Fatal("wrong value")
+	}
+
+	var v uint32
+	DecodeUint32LE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+
+	b := bytes.NewBuffer(nil)
+	binary.Write(b, binary.BigEndian, u)
+
+	EncodeUint32BE(d, &u)
+	if !bytes.Equal(d, b.Bytes()) {
+		t.Fatal("wrong value")
+	}
+
+	v = 0
+	DecodeUint32BE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+}
+
+func TestUint32Checked(t *testing.T) {
+	d := make([]byte, 2*SzUint32)
+
+	v, err := NewUint32Checked(d[SzUint32:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*v.Value = 5
+	if !bytes.Equal(d[SzUint32:], BinaryEncodeUint32(5)) {
+		t.Fatal("wrong value")
+	}
+
+	if _, err := NewUint32Checked(d[:SzUint32-1]); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	if SzUint32 > 1 {
+		if _, err := NewUint32Checked(d[1:]); err != ErrAlign {
+			t.Fatal("expected ErrAlign")
+		}
+	}
+}
+
+func TestMapUint32s(t *testing.T) {
+	d := append(BinaryEncodeUint32(5), BinaryEncodeUint32(10)...)
+
+	v := MapUint32s(d)
+	if len(v) != 2 || v[0] != 5 || v[1] != 10 {
+		t.Fatal("wrong value")
+	}
+
+	v[1] = 15
+	if !bytes.Equal(d[SzUint32:], BinaryEncodeUint32(15)) {
+		t.Fatal("wrong value")
+	}
+
+	if v := MapUint32s(d[:len(d)-1]); len(v) != 1 {
+		t.Fatal("wrong length")
+	}
+
+	if v := MapUint32s(nil); len(v) != 0 {
+		t.Fatal("wrong length")
+	}
+}
+
+func TestUint32s(t *testing.T) {
+	d := make([]byte, 3*SzUint32)
+
+	v := NewUint32s(d, 1)
+	if err := v.Append(5, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(d[2*SzUint32:], BinaryEncodeUint32(10)) {
+		t.Fatal("wrong value")
+	}
+
+	if err := v.Append(15); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+}
+
 func BenchmarkUint32BinaryDecode(b *testing.B) {
 	var v uint32
 	var d = make([]byte, b.N*SzUint32)
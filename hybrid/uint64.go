@@ -1,52 +1,83 @@
 package hybrid
 
-import (
-	"reflect"
-	"unsafe"
-)
-
 const (
+	// SzUint64 is the size of a uint64 value in bytes
 	SzUint64 = 8
 )
 
 // EncodeUint64 updates the byte slice to match value
 func EncodeUint64(d []byte, v *uint64) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	value := (*uint64)(unsafe.Pointer(head.Data))
-	*value = *v
+	Encode(d, v)
 }
 
 // DecodeUint64 updates the value to match the byte slice
 func DecodeUint64(d []byte, v *uint64) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	value := (*uint64)(unsafe.Pointer(head.Data))
-	*v = *value
+	Decode(d, v)
+}
+
+// EncodeUint64LE updates the byte slice to match value in little endian byte order
+func EncodeUint64LE(d []byte, v *uint64) {
+	EncodeLE(d, v)
+}
+
+// DecodeUint64LE updates the value to match the byte slice in little endian byte order
+func DecodeUint64LE(d []byte, v *uint64) {
+	DecodeLE(d, v)
+}
+
+// EncodeUint64BE updates the byte slice to match value in big endian byte order
+func EncodeUint64BE(d []byte, v *uint64) {
+	EncodeBE(d, v)
+}
+
+// DecodeUint64BE updates the value to match the byte slice in big endian byte order
+func DecodeUint64BE(d []byte, v *uint64) {
+	DecodeBE(d, v)
 }
 
 // Uint64 has a uint64 value and a byte slice using the same memory location.
 // Any changes done to one of these fields will reflect on the other.
-type Uint64 struct {
-	Value *uint64
-	Bytes []byte
-}
+type Uint64 Value[uint64]
 
 // NewUint64 will create a new Uint64 struct with given byte slice.
 // If the slice is nil, a new byte slice will be created for storage.
 // If the slice length is less than required length, it will panic.
 func NewUint64(d []byte) *Uint64 {
-	if d == nil {
-		d = make([]byte, SzUint64)
-	}
+	return (*Uint64)(NewValue[uint64](d))
+}
 
-	v := &Uint64{}
-	v.Read(d[:SzUint64])
-	return v
+// NewUint64Checked is like NewUint64 but it returns an error
+// if the slice is too small or not aligned for the value.
+func NewUint64Checked(d []byte) (*Uint64, error) {
+	v, err := NewValueChecked[uint64](d)
+	return (*Uint64)(v), err
 }
 
 // Read updates the struct to use provided byte slice
 // This can be used when it's required to read data from
 func (v *Uint64) Read(d []byte) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	v.Value = (*uint64)(unsafe.Pointer(head.Data))
-	v.Bytes = d[:SzUint64]
+	(*Value[uint64])(v).Read(d)
+}
+
+// ReadChecked is like Read but it returns an error if the slice is
+// too small or not aligned for the value.
+func (v *Uint64) ReadChecked(d []byte) (err error) {
+	return (*Value[uint64])(v).ReadChecked(d)
+}
+
+// Uint64s has a uint64 slice and a byte slice using the same memory location.
+// Any changes done to one of these fields will reflect on the other.
+type Uint64s = Values[uint64]
+
+// NewUint64s will create a new Uint64s struct with n values in the byte slice.
+// If the slice is nil, a new byte slice will be created for n values.
+// If the slice length is less than required length, it will panic.
+func NewUint64s(d []byte, n int) *Uint64s {
+	return NewValues[uint64](d, n)
+}
+
+// MapUint64s returns a uint64 slice which uses the same memory as the byte
+// slice. Trailing bytes which do not make a complete value are not included.
+func MapUint64s(d []byte) []uint64 {
+	return Slice[uint64](d)
 }
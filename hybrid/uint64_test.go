@@ -55,6 +55,99 @@ func TestUint64(t *testing.T) {
 	}
 }
 
+func TestUint64Endian(t *testing.T) {
+	var u uint64 = 5
+	d := make([]byte, SzUint64)
+
+	EncodeUint64LE(d, &u)
+	if !bytes.Equal(d, BinaryEncodeUint64(5)) {
+		t.Fatal("wrong value")
+	}
+
+	var v uint64
+	DecodeUint64LE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+
+	b := bytes.NewBuffer(nil)
+	binary.Write(b, binary.BigEndian, u)
+
+	EncodeUint64BE(d, &u)
+	if !bytes.Equal(d, b.Bytes()) {
+		t.Fatal("wrong value")
+	}
+
+	v = 0
+	DecodeUint64BE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+}
+
+func TestUint64Checked(t *testing.T) {
+	d := make([]byte, 2*SzUint64)
+
+	v, err := NewUint64Checked(d[SzUint64:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*v.Value = 5
+	if !bytes.Equal(d[SzUint64:], BinaryEncodeUint64(5)) {
+		t.Fatal("wrong value")
+	}
+
+	if _, err := NewUint64Checked(d[:SzUint64-1]); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	if SzUint64 > 1 {
+		if _, err := NewUint64Checked(d[1:]); err != ErrAlign {
+			t.Fatal("expected ErrAlign")
+		}
+	}
+}
+
+func TestMapUint64s(t *testing.T) {
+	d := append(BinaryEncodeUint64(5), BinaryEncodeUint64(10)...)
+
+	v := MapUint64s(d)
+	if len(v) != 2 || v[0] != 5 || v[1] != 10 {
+		t.Fatal("wrong value")
+	}
+
+	v[1] = 15
+	if !bytes.Equal(d[SzUint64:], BinaryEncodeUint64(15)) {
+		t.Fatal("wrong value")
+	}
+
+	if v := MapUint64s(d[:len(d)-1]); len(v) != 1 {
+		t.Fatal("wrong length")
+	}
+
+	if v := MapUint64s(nil); len(v) != 0 {
+		t.Fatal("wrong length")
+	}
+}
+
+func TestUint64s(t *testing.T) {
+	d := make([]byte, 3*SzUint64)
+
+	v := NewUint64s(d, 1)
+	if err := v.Append(5, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(d[2*SzUint64:], BinaryEncodeUint64(10)) {
+		t.Fatal("wrong value")
+	}
+
+	if err := v.Append(15); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+}
+
 func BenchmarkUint64BinaryDecode(b *testing.B) {
 	var v uint64
 	var d = make([]byte, b.N*SzUint64)
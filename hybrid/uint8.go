@@ -1,52 +1,83 @@
 package hybrid
 
-import (
-	"reflect"
-	"unsafe"
-)
-
 const (
+	// SzUint8 is the size of a uint8 value in bytes
 	SzUint8 = 1
 )
 
 // EncodeUint8 updates the byte slice to match value
 func EncodeUint8(d []byte, v *uint8) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	value := (*uint8)(unsafe.Pointer(head.Data))
-	*value = *v
+	Encode(d, v)
 }
 
 // DecodeUint8 updates the value to match the byte slice
 func DecodeUint8(d []byte, v *uint8) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	value := (*uint8)(unsafe.Pointer(head.Data))
-	*v = *value
+	Decode(d, v)
+}
+
+// EncodeUint8LE updates the byte slice to match value in little endian byte order
+func EncodeUint8LE(d []byte, v *uint8) {
+	EncodeLE(d, v)
+}
+
+// DecodeUint8LE updates the value to match the byte slice in little endian byte order
+func DecodeUint8LE(d []byte, v *uint8) {
+	DecodeLE(d, v)
+}
+
+// EncodeUint8BE updates the byte slice to match value in big endian byte order
+func EncodeUint8BE(d []byte, v *uint8) {
+	EncodeBE(d, v)
+}
+
+// DecodeUint8BE updates the value to match the byte slice in big endian byte order
+func DecodeUint8BE(d []byte, v *uint8) {
+	DecodeBE(d, v)
 }
 
 // Uint8 has a uint8 value and a byte slice using the same memory location.
 // Any changes done to one of these fields will reflect on the other.
-type Uint8 struct {
-	Value *uint8
-	Bytes []byte
-}
+type Uint8 Value[uint8]
 
 // NewUint8 will create a new Uint8 struct with given byte slice.
 // If the slice is nil, a new byte slice will be created for storage.
 // If the slice length is less than required length, it will panic.
 func NewUint8(d []byte) *Uint8 {
-	if d == nil {
-		d = make([]byte, SzUint8)
-	}
+	return (*Uint8)(NewValue[uint8](d))
+}
 
-	v := &Uint8{}
-	v.Read(d[:SzUint8])
-	return v
+// NewUint8Checked is like NewUint8 but it returns an error
+// if the slice is too small or not aligned for the value.
+func NewUint8Checked(d []byte) (*Uint8, error) {
+	v, err := NewValueChecked[uint8](d)
+	return (*Uint8)(v), err
 }
 
 // Read updates the struct to use provided byte slice
 // This can be used when it's required to read data from
 func (v *Uint8) Read(d []byte) {
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&d))
-	v.Value = (*uint8)(unsafe.Pointer(head.Data))
-	v.Bytes = d[:SzUint8]
+	(*Value[uint8])(v).Read(d)
+}
+
+// ReadChecked is like Read but it returns an error if the slice is
+// too small or not aligned for the value.
+func (v *Uint8) ReadChecked(d []byte) (err error) {
+	return (*Value[uint8])(v).ReadChecked(d)
+}
+
+// Uint8s has a uint8 slice and a byte slice using the same memory location.
+// Any changes done to one of these fields will reflect on the other.
+type Uint8s = Values[uint8]
+
+// NewUint8s will create a new Uint8s struct with n values in the byte slice.
+// If the slice is nil, a new byte slice will be created for n values.
+// If the slice length is less than required length, it will panic.
+func NewUint8s(d []byte, n int) *Uint8s {
+	return NewValues[uint8](d, n)
+}
+
+// MapUint8s returns a uint8 slice which uses the same memory as the byte
+// slice. Trailing bytes which do not make a complete value are not included.
+func MapUint8s(d []byte) []uint8 {
+	return Slice[uint8](d)
 }
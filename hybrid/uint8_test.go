@@ -55,6 +55,99 @@ func TestUint8(t *testing.T) {
 	}
 }
 
+func TestUint8Endian(t *testing.T) {
+	var u uint8 = 5
+	d := make([]byte, SzUint8)
+
+	EncodeUint8LE(d, &u)
+	if !bytes.Equal(d, BinaryEncodeUint8(5)) {
+		t.Fatal("wrong value")
+	}
+
+	var v uint8
+	DecodeUint8LE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+
+	b := bytes.NewBuffer(nil)
+	binary.Write(b, binary.BigEndian, u)
+
+	EncodeUint8BE(d, &u)
+	if !bytes.Equal(d, b.Bytes()) {
+		t.Fatal("wrong value")
+	}
+
+	v = 0
+	DecodeUint8BE(d, &v)
+	if v != 5 {
+		t.Fatal("wrong value")
+	}
+}
+
+func TestUint8Checked(t *testing.T) {
+	d := make([]byte, 2*SzUint8)
+
+	v, err := NewUint8Checked(d[SzUint8:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*v.Value = 5
+	if !bytes.Equal(d[SzUint8:], BinaryEncodeUint8(5)) {
+		t.Fatal("wrong value")
+	}
+
+	if _, err := NewUint8Checked(d[:SzUint8-1]); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	if SzUint8 > 1 {
+		if _, err := NewUint8Checked(d[1:]); err != ErrAlign {
+			t.Fatal("expected ErrAlign")
+		}
+	}
+}
+
+func TestMapUint8s(t *testing.T) {
+	d := append(BinaryEncodeUint8(5), BinaryEncodeUint8(10)...)
+
+	v := MapUint8s(d)
+	if len(v) != 2 || v[0] != 5 || v[1] != 10 {
+		t.Fatal("wrong value")
+	}
+
+	v[1] = 15
+	if !bytes.Equal(d[SzUint8:], BinaryEncodeUint8(15)) {
+		t.Fatal("wrong value")
+	}
+
+	if v := MapUint8s(d[:len(d)-1]); len(v) != 1 {
+		t.Fatal("wrong length")
+	}
+
+	if v := MapUint8s(nil); len(v) != 0 {
+		t.Fatal("wrong length")
+	}
+}
+
+func TestUint8s(t *testing.T) {
+	d := make([]byte, 3*SzUint8)
+
+	v := NewUint8s(d, 1)
+	if err := v.Append(5, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(d[2*SzUint8:], BinaryEncodeUint8(10)) {
+		t.Fatal("wrong value")
+	}
+
+	if err := v.Append(15); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+}
+
 func BenchmarkUint8BinaryDecode(b *testing.B) {
 	var v uint8
 	var d = make([]byte, b.N*SzUint8)
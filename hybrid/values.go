@@ -0,0 +1,49 @@
+package hybrid
+
+// Values has a slice of values and a byte slice using the same memory
+// location. The byte slice can be larger than the values currently in use
+// and Append uses the remaining space without allocating new memory. This
+// can be used to map a whole vector onto memory mapped data at once.
+type Values[T any] struct {
+	Values []T
+	Bytes  []byte
+}
+
+// NewValues will create a new Values struct with n values in the byte slice.
+// If the slice is nil, a new byte slice will be created for n values.
+// If the slice length is less than required length, it will panic.
+// It also panics with ErrType if T values cannot be mapped onto bytes.
+func NewValues[T any](d []byte, n int) *Values[T] {
+	if d == nil {
+		d = make([]byte, n*Size[T]())
+	}
+
+	v := &Values[T]{}
+	v.Read(d, n)
+	return v
+}
+
+// Read updates the struct to use first n values of provided byte slice
+// Rest of the byte slice can be used to append values later.
+func (v *Values[T]) Read(d []byte, n int) {
+	v.Values = Slice[T](d)[:n]
+	v.Bytes = d[:n*Size[T]()]
+}
+
+// Append adds values after current values using the rest of the byte slice.
+// If there's not enough space for all values, none of them are added and
+// ErrSize is returned. Values are never moved to a new memory location.
+func (v *Values[T]) Append(vals ...T) (err error) {
+	if cap(v.Values)-len(v.Values) < len(vals) {
+		return ErrSize
+	}
+
+	v.Values = append(v.Values, vals...)
+	v.Bytes = v.Bytes[:len(v.Values)*Size[T]()]
+	return nil
+}
+
+// Free returns the number of values which can be appended.
+func (v *Values[T]) Free() (n int) {
+	return cap(v.Values) - len(v.Values)
+}
@@ -0,0 +1,57 @@
+package hybrid
+
+import (
+	"testing"
+)
+
+func TestValues(t *testing.T) {
+	d := make([]byte, 4*Size[record]()+1)
+
+	v := NewValues[record](d, 1)
+	if len(v.Values) != 1 || len(v.Bytes) != Size[record]() {
+		t.Fatal("wrong length")
+	}
+
+	if n := v.Free(); n != 3 {
+		t.Fatal("wrong free space", n)
+	}
+
+	if err := v.Append(record{ID: 1}, record{ID: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(v.Bytes) != 3*Size[record]() {
+		t.Fatal("wrong length")
+	}
+
+	var r record
+	Decode(d[2*Size[record]():], &r)
+	if r.ID != 2 {
+		t.Fatal("values should use same memory")
+	}
+
+	if err := v.Append(record{}, record{}); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	if len(v.Values) != 3 {
+		t.Fatal("values should not be added")
+	}
+
+	v.Read(d, 2)
+	if len(v.Values) != 2 || v.Values[1].ID != 1 {
+		t.Fatal("wrong values")
+	}
+}
+
+func TestValuesNil(t *testing.T) {
+	v := NewValues[uint64](nil, 3)
+	if len(v.Values) != 3 || len(v.Bytes) != 24 || v.Free() != 0 {
+		t.Fatal("wrong length")
+	}
+
+	v = NewValues[uint64](nil, 0)
+	if len(v.Values) != 0 || v.Append(1) != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+}
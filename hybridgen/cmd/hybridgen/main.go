@@ -0,0 +1,50 @@
+// Command hybridgen generates hybrid view types for structs in a Go file.
+// When it's used with go:generate, the file defaults to $GOFILE.
+// See the hybridgen package for supported field types.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/kadirahq/go-tools/hybridgen"
+)
+
+func main() {
+	in := flag.String("in", os.Getenv("GOFILE"), "path to the Go file with structs")
+	types := flag.String("type", "", "comma separated list of struct names")
+	out := flag.String("out", "", "path to the output file (default: stdout)")
+	flag.Parse()
+
+	if *in == "" || *types == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*in, strings.Split(*types, ","), *out); err != nil {
+		fmt.Fprintln(os.Stderr, "hybridgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(in string, types []string, out string) (err error) {
+	src, err := ioutil.ReadFile(in)
+	if err != nil {
+		return err
+	}
+
+	gen, err := hybridgen.Generate(in, src, types)
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		_, err = os.Stdout.Write(gen)
+		return err
+	}
+
+	return ioutil.WriteFile(out, gen, 0644)
+}
@@ -0,0 +1,22 @@
+// Package example has views generated by hybridgen for records.go.
+package example
+
+//go:generate go run ../cmd/hybridgen -type Point,Label -out views.go
+
+// Point is a data point with a fixed size.
+type Point struct {
+	Time   int64
+	Value  float64
+	Count  uint32
+	Weight float32
+	Flags  uint8
+	Delta  int16
+	ID     [12]byte
+}
+
+// Label is a small record with unaligned fields.
+type Label struct {
+	Kind uint8
+	Key  uint32
+	Vals [3]uint16
+}
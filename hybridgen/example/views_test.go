@@ -0,0 +1,69 @@
+package example
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/kadirahq/go-tools/hybrid"
+)
+
+func TestLayout(t *testing.T) {
+	// views use the same layout as structs in memory
+	if PointViewSize != unsafe.Sizeof(Point{}) {
+		t.Fatal("wrong size")
+	}
+
+	if LabelViewSize != unsafe.Sizeof(Label{}) {
+		t.Fatal("wrong size")
+	}
+
+	in := Label{Kind: 1, Key: 2, Vals: [3]uint16{3, 4, 5}}
+	v := NewLabelView(nil)
+	v.Store(&in)
+
+	var out Label
+	hybrid.Decode(v.Bytes, &out)
+	if out != in {
+		t.Fatal("wrong record", out)
+	}
+}
+
+func TestView(t *testing.T) {
+	d := make([]byte, 2*PointViewSize)
+	v := NewPointView(d[PointViewSize:])
+
+	*v.Time = -123456789
+	*v.Value = 3.14
+	v.ID[2] = 7
+
+	var r Point
+	NewPointView(d[PointViewSize:]).Load(&r)
+	if r.Time != -123456789 || r.Value != 3.14 || r.ID[2] != 7 {
+		t.Fatal("wrong record", r)
+	}
+
+	r.Count = 42
+	v.Store(&r)
+	if *v.Count != 42 {
+		t.Fatal("wrong value")
+	}
+
+	v.Read(d)
+	if *v.Time != 0 || *v.Count != 0 {
+		t.Fatal("view should use new slice")
+	}
+}
+
+func TestAllocs(t *testing.T) {
+	d := make([]byte, PointViewSize)
+	v := NewPointView(nil)
+
+	n := testing.AllocsPerRun(100, func() {
+		v.Read(d)
+		*v.Value++
+	})
+
+	if n != 0 {
+		t.Fatal("unexpected allocations", n)
+	}
+}
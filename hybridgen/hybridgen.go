@@ -0,0 +1,231 @@
+// Package hybridgen generates view types which map all fields of a struct
+// onto a byte slice, like hybrid.Uint64 does for a single value. Each view
+// has a pointer field for each struct field and the pointers use the memory
+// of the byte slice. This is useful to read and update records in memory
+// mapped data without copying or encoding them. Views are generated from
+// structs in a Go source file with the hybridgen command:
+//
+//	//go:generate go run github.com/kadirahq/go-tools/hybridgen/cmd/hybridgen -type Point -out views.go
+//
+// Supported field types are intN, uintN (N is 8, 16, 32 or 64), byte,
+// float32, float64 and arrays of them (ex. "[16]byte"). Fields are aligned
+// to their size (or element size for arrays) and the view size is rounded
+// up to the largest alignment so views can be stored one after another.
+// Values use the byte order of the machine like the hybrid package.
+package hybridgen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"text/template"
+)
+
+var (
+	// ErrType is returned when a struct cannot be mapped to a view.
+	ErrType = errors.New("unsupported type")
+)
+
+// sizes of supported basic types
+var sizes = map[string]int64{
+	"int8":    1,
+	"uint8":   1,
+	"byte":    1,
+	"int16":   2,
+	"uint16":  2,
+	"int32":   4,
+	"uint32":  4,
+	"float32": 4,
+	"int64":   8,
+	"uint64":  8,
+	"float64": 8,
+}
+
+// field has information used by the template to generate code for a field
+type field struct {
+	Name string
+	Type string
+	Off  int64
+}
+
+// view has information used by the template to generate code for a view
+type view struct {
+	Name   string
+	Struct string
+	Size   int64
+	Fields []*field
+}
+
+// Generate generates view types for the named structs in the Go source
+// file. Views are named after structs (ex. PointView for Point) and they
+// are generated in the package of the source file.
+func Generate(filename string, src []byte, types []string) (out []byte, err error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	structs := map[string]*ast.StructType{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if ts, ok := n.(*ast.TypeSpec); ok {
+			if st, ok := ts.Type.(*ast.StructType); ok {
+				structs[ts.Name.Name] = st
+			}
+		}
+
+		return true
+	})
+
+	data := struct {
+		Package string
+		Views   []*view
+	}{Package: file.Name.Name}
+
+	for _, name := range types {
+		st, ok := structs[name]
+		if !ok {
+			return nil, fmt.Errorf("%v: struct %q not found", ErrType, name)
+		}
+
+		v, err := layout(name, st)
+		if err != nil {
+			return nil, err
+		}
+
+		data.Views = append(data.Views, v)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return nil, err
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+// layout finds field offsets and the size of the view
+func layout(name string, st *ast.StructType) (v *view, err error) {
+	v = &view{Name: name + "View", Struct: name}
+	align := int64(1)
+
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 {
+			return nil, fmt.Errorf("%v: embedded field in %s", ErrType, name)
+		}
+
+		typ, sz, al, err := fieldType(f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %s.%s", err, name, f.Names[0].Name)
+		}
+
+		for _, id := range f.Names {
+			if id.Name == "Bytes" || id.Name == "_" {
+				return nil, fmt.Errorf("%v: bad field name %q in %s", ErrType, id.Name, name)
+			}
+
+			off := (v.Size + al - 1) / al * al
+			v.Fields = append(v.Fields, &field{Name: id.Name, Type: typ, Off: off})
+			v.Size = off + sz
+		}
+
+		if al > align {
+			align = al
+		}
+	}
+
+	if v.Size == 0 {
+		return nil, fmt.Errorf("%v: struct %s has no data", ErrType, name)
+	}
+
+	v.Size = (v.Size + align - 1) / align * align
+	return v, nil
+}
+
+// fieldType returns the type, size and alignment of a field
+func fieldType(e ast.Expr) (typ string, sz, align int64, err error) {
+	switch t := e.(type) {
+	case *ast.Ident:
+		if sz, ok := sizes[t.Name]; ok {
+			return t.Name, sz, sz, nil
+		}
+	case *ast.ArrayType:
+		lit, ok := t.Len.(*ast.BasicLit)
+		if !ok || lit.Kind != token.INT {
+			break
+		}
+
+		n, err := strconv.ParseInt(lit.Value, 0, 64)
+		if err != nil || n <= 0 {
+			break
+		}
+
+		elem, sz, align, err := fieldType(t.Elt)
+		if err != nil {
+			return "", 0, 0, err
+		}
+
+		return fmt.Sprintf("[%d]%s", n, elem), n * sz, align, nil
+	}
+
+	return "", 0, 0, ErrType
+}
+
+var tmpl = template.Must(template.New("hybridgen").Parse(`// Code generated by hybridgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+"unsafe"
+)
+
+{{range .Views}}
+// {{.Name}}Size is the size of {{.Struct}} data in a byte slice.
+const {{.Name}}Size = {{.Size}}
+
+// {{.Name}} has {{.Struct}} fields and a byte slice using the same memory location.
+// Any changes done to the fields will reflect on the byte slice and vice versa.
+type {{.Name}} struct {
+{{range .Fields}}{{.Name}} *{{.Type}}
+{{end}}Bytes []byte
+}
+
+// New{{.Name}} will create a new {{.Name}} struct with given byte slice.
+// If the slice is nil, a new byte slice will be created for storage.
+// If the slice length is less than required length, it will panic.
+func New{{.Name}}(d []byte) *{{.Name}} {
+if d == nil {
+d = make([]byte, {{.Name}}Size)
+}
+
+v := &{{.Name}}{}
+v.Read(d)
+return v
+}
+
+// Read updates the view to use provided byte slice
+// The slice should be aligned to the largest field.
+func (v *{{.Name}}) Read(d []byte) {
+d = d[:{{.Name}}Size]
+{{range .Fields}}v.{{.Name}} = (*{{.Type}})(unsafe.Pointer(&d[{{.Off}}]))
+{{end}}v.Bytes = d
+}
+
+// Load copies field values from the view to the struct.
+func (v *{{.Name}}) Load(r *{{.Struct}}) {
+{{range .Fields}}r.{{.Name}} = *v.{{.Name}}
+{{end -}}
+}
+
+// Store copies field values from the struct to the view.
+func (v *{{.Name}}) Store(r *{{.Struct}}) {
+{{range .Fields}}*v.{{.Name}} = r.{{.Name}}
+{{end -}}
+}
+{{end}}
+`))
@@ -0,0 +1,52 @@
+package hybridgen
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestGenerateExample(t *testing.T) {
+	src, err := ioutil.ReadFile("example/records.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gen, err := Generate("records.go", src, []string{"Point", "Label"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp, err := ioutil.ReadFile("example/views.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run "go generate" in the example directory after changing templates
+	if !bytes.Equal(gen, exp) {
+		t.Fatal("generated code is different from example/views.go")
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	cases := []string{
+		`type P struct { A int8 }`,
+		`type T struct {}`,
+		`type T struct { A string }`,
+		`type T struct { A int }`,
+		`type T struct { A *int8 }`,
+		`type T struct { A []byte }`,
+		`type T struct { A [0]byte }`,
+		`type T struct { A [N]byte }`,
+		`type T struct { Bytes int8 }`,
+		`type T struct { P }`,
+	}
+
+	for i, c := range cases {
+		src := []byte("package a\n" + c)
+		if _, err := Generate("a.go", src, []string{"T"}); err == nil || !strings.HasPrefix(err.Error(), ErrType.Error()) {
+			t.Fatal("expected ErrType", i, err)
+		}
+	}
+}
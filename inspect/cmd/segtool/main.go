@@ -0,0 +1,234 @@
+// Command segtool inspects and repairs segment store directories.
+//
+//	segtool ls <base>
+//	segtool meta <path>
+//	segtool hexdump <base> <offset> <length>
+//	segtool records <base> <layout json> <offset> <count>
+//	segtool verify <base> [meta path]
+//	segtool repair <base>
+//	segtool truncate <base> <size>
+//
+// The base path is the path of segment files without the index (ex.
+// "/data/store/seg_"). Use -json to print results as JSON. Stores are locked
+// while they are repaired or truncated, use -wait to wait for other processes.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/kadirahq/go-tools/inspect"
+	"github.com/kadirahq/go-tools/layout"
+)
+
+var (
+	asJSON = flag.Bool("json", false, "print results as json")
+	wait   = flag.Duration("wait", 0, "time to wait for the store lock")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: segtool [-json] [-wait duration] <command> [arguments]")
+		fmt.Fprintln(os.Stderr, "commands: ls, meta, hexdump, records, verify, repair, truncate")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+	args := flag.Args()
+	if len(args) < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(args[0], args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "segtool:", err)
+		os.Exit(1)
+	}
+}
+
+func run(cmd string, args []string) (err error) {
+	switch {
+	case cmd == "ls":
+		segs, err := inspect.Segments(args[0])
+		if err != nil {
+			return err
+		}
+
+		if *asJSON {
+			return printJSON(segs)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "index\tsize\tmodified\tpath")
+		for _, s := range segs {
+			fmt.Fprintf(tw, "%d\t%d\t%s\t%s\n", s.Index, s.Size, s.ModTime.Format("2006-01-02 15:04:05"), s.Path)
+		}
+
+		return tw.Flush()
+
+	case cmd == "meta":
+		m, err := inspect.ReadMeta(args[0])
+		if err != nil {
+			return err
+		}
+
+		if *asJSON {
+			return printJSON(m)
+		}
+
+		fmt.Printf("version: %d\nsegs: %d\nsize: %d\nused: %d\n", m.Version, m.Segs, m.Size, m.Used)
+		for i, s := range m.Slots {
+			fmt.Printf("slot %d: gen=%d version=%d length=%d valid=%v\n", i, s.Gen, s.Version, s.Length, s.Valid)
+		}
+
+		for _, k := range keys(m.Ints) {
+			fmt.Printf("int %q: %d\n", k, m.Ints[k])
+		}
+
+		for _, k := range keys(m.Blobs) {
+			fmt.Printf("bytes %q: %x\n", k, m.Blobs[k])
+		}
+
+		return nil
+
+	case cmd == "hexdump" && len(args) == 3:
+		off, err := strconv.ParseInt(args[1], 0, 64)
+		if err != nil {
+			return err
+		}
+
+		n, err := strconv.ParseInt(args[2], 0, 64)
+		if err != nil {
+			return err
+		}
+
+		return inspect.Hexdump(os.Stdout, args[0], off, n)
+
+	case cmd == "records" && len(args) == 4:
+		l, err := readLayout(args[1])
+		if err != nil {
+			return err
+		}
+
+		off, err := strconv.ParseInt(args[2], 0, 64)
+		if err != nil {
+			return err
+		}
+
+		n, err := strconv.Atoi(args[3])
+		if err != nil {
+			return err
+		}
+
+		recs, err := inspect.Records(args[0], l, off, n)
+		if perr := printJSON(recs); perr != nil {
+			return perr
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		return err
+
+	case cmd == "verify":
+		meta := ""
+		if len(args) > 1 {
+			meta = args[1]
+		}
+
+		r, err := inspect.Verify(args[0], meta)
+		if err != nil {
+			return err
+		}
+
+		if *asJSON {
+			if err := printJSON(r); err != nil {
+				return err
+			}
+		} else {
+			for _, p := range r.Problems {
+				fmt.Println(p)
+			}
+		}
+
+		if !r.OK() {
+			os.Exit(3)
+		}
+
+		return nil
+
+	case cmd == "repair":
+		removed, err := inspect.Repair(args[0], *wait)
+		printRemoved(removed)
+		return err
+
+	case cmd == "truncate" && len(args) == 2:
+		sz, err := strconv.ParseInt(args[1], 0, 64)
+		if err != nil {
+			return err
+		}
+
+		removed, err := inspect.Truncate(args[0], sz, *wait)
+		printRemoved(removed)
+		return err
+	}
+
+	flag.Usage()
+	os.Exit(2)
+	return nil
+}
+
+// readLayout reads a layout encoded as JSON and validates it
+func readLayout(path string) (l *layout.Layout, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	l = &layout.Layout{}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, err
+	}
+
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func printRemoved(paths []string) {
+	for _, p := range paths {
+		fmt.Println("removed", p)
+	}
+}
+
+func keys(m interface{}) (ks []string) {
+	switch m := m.(type) {
+	case map[string]int64:
+		for k := range m {
+			ks = append(ks, k)
+		}
+	case map[string][]byte:
+		for k := range m {
+			ks = append(ks, k)
+		}
+	}
+
+	sort.Strings(ks)
+	return ks
+}
+
+func printJSON(v interface{}) (err error) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
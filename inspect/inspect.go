@@ -0,0 +1,402 @@
+// Package inspect reads segment store directories without opening them as
+// stores. It can list segment files, decode metadata files, dump ranges of
+// store data, check for common problems and repair stores left in a bad
+// state by a crash. Stores are locked while they are repaired or truncated
+// so they cannot be changed while they are used by other processes.
+package inspect
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kadirahq/go-tools/layout"
+	"github.com/kadirahq/go-tools/segments"
+	"github.com/kadirahq/go-tools/segments/segfile"
+)
+
+var (
+	// ErrNoSegments is returned when there are no segment files.
+	ErrNoSegments = errors.New("no segment files")
+)
+
+// Segment has information about a segment file.
+type Segment struct {
+	Index   int       `json:"index"`
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// Segments returns all segment files with given base path (ex. "dir/seg_")
+// sorted by their index. Files are returned even if there are gaps between
+// them or if they have different sizes. The first segment may not have index
+// 0 if segments were removed from the beginning of the store.
+func Segments(base string) (segs []Segment, err error) {
+	matches, err := filepath.Glob(base + "*")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range matches {
+		i, err := strconv.Atoi(strings.TrimPrefix(path, base))
+		if err != nil || i < 0 {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if info.IsDir() {
+			continue
+		}
+
+		segs = append(segs, Segment{
+			Index:   i,
+			Path:    path,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+	}
+
+	sort.Slice(segs, func(i, j int) bool { return segs[i].Index < segs[j].Index })
+	return segs, nil
+}
+
+// Meta has decoded fields of a metadata file and the state of its slots.
+type Meta struct {
+	Version uint32             `json:"version"`
+	Segs    int64              `json:"segs"`
+	Size    int64              `json:"size"`
+	Used    int64              `json:"used"`
+	Ints    map[string]int64   `json:"ints"`
+	Blobs   map[string][]byte  `json:"blobs"`
+	Slots   []segfile.MetaSlot `json:"slots"`
+}
+
+// ReadMeta reads and decodes a metadata file.
+func ReadMeta(path string) (m *Meta, err error) {
+	md, err := segfile.ReadMetadata(path)
+	if err != nil {
+		return nil, err
+	}
+
+	slots, err := segfile.CheckMetadata(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m = &Meta{
+		Version: md.Version(),
+		Segs:    md.Segs(),
+		Size:    md.Size(),
+		Used:    md.Used(),
+		Ints:    map[string]int64{},
+		Blobs:   map[string][]byte{},
+		Slots:   slots,
+	}
+
+	ints, blobs := md.Keys()
+	for _, k := range ints {
+		m.Ints[k], _ = md.Int(k)
+	}
+
+	for _, k := range blobs {
+		m.Blobs[k], _ = md.Bytes(k)
+	}
+
+	return m, nil
+}
+
+// ReadAt reads store data at given offset from segment files. The segment
+// size is taken from the first segment file. It returns io.EOF if there's
+// not enough data in the store.
+func ReadAt(base string, p []byte, off int64) (n int, err error) {
+	segs, err := Segments(base)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(segs) == 0 {
+		return 0, ErrNoSegments
+	}
+
+	size := segs[0].Size
+	first := int64(segs[0].Index)
+	for n < len(p) {
+		i, soff := off/size-first, off%size
+		if i < 0 {
+			return n, segments.ErrRemoved
+		}
+
+		if i >= int64(len(segs)) || segs[i].Index != int(i+first) {
+			return n, io.EOF
+		}
+
+		end := int64(len(p))
+		if sz := int64(n) + size - soff; sz < end {
+			end = sz
+		}
+
+		nr, err := readAt(segs[i].Path, p[n:end], soff)
+		n += nr
+		off += int64(nr)
+
+		if err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// Hexdump writes a hex dump of n bytes of store data starting at offset off.
+// Missing data at the end of the store is not an error, the dump stops there.
+func Hexdump(w io.Writer, base string, off, n int64) (err error) {
+	d := hex.Dumper(w)
+	buf := make([]byte, 4096)
+
+	for n > 0 {
+		sz := n
+		if sz > int64(len(buf)) {
+			sz = int64(len(buf))
+		}
+
+		nr, err := ReadAt(base, buf[:sz], off)
+		if _, err := d.Write(buf[:nr]); err != nil {
+			return err
+		}
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		off += int64(nr)
+		n -= int64(nr)
+	}
+
+	return d.Close()
+}
+
+// Records decodes n records with given layout starting at offset off.
+// Records are expected to be stored one after the other.
+func Records(base string, l *layout.Layout, off int64, n int) (recs []map[string]interface{}, err error) {
+	p := make([]byte, l.Size)
+
+	for i := 0; i < n; i++ {
+		if _, err := ReadAt(base, p, off+int64(i*l.Size)); err != nil {
+			return recs, err
+		}
+
+		rec, err := l.Decode(p)
+		if err != nil {
+			return recs, err
+		}
+
+		recs = append(recs, rec)
+	}
+
+	return recs, nil
+}
+
+// Report has problems found by Verify.
+type Report struct {
+	Segments    []Segment `json:"segments"`
+	SegmentSize int64     `json:"segmentSize"`
+	Meta        *Meta     `json:"meta,omitempty"`
+	Problems    []string  `json:"problems"`
+}
+
+// OK returns true if no problems were found.
+func (r *Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Verify checks segment files and the metadata file (if metaPath is not
+// empty) for problems. Segment files should have the same size and there
+// should be no gaps between them. Both metadata slots are checked with their
+// checksums and metadata fields are compared with segment files.
+func Verify(base, metaPath string) (r *Report, err error) {
+	segs, err := Segments(base)
+	if err != nil {
+		return nil, err
+	}
+
+	r = &Report{Segments: segs, Problems: []string{}}
+	if len(segs) > 0 {
+		r.SegmentSize = segs[0].Size
+	}
+
+	for i, s := range segs {
+		if want := segs[0].Index + i; s.Index != want {
+			r.addf("missing segment %d (found %s)", want, s.Path)
+			break
+		}
+
+		if s.Size != r.SegmentSize {
+			r.addf("segment %s has size %d (expected %d)", s.Path, s.Size, r.SegmentSize)
+		}
+	}
+
+	if metaPath == "" {
+		return r, nil
+	}
+
+	m, err := ReadMeta(metaPath)
+	if err != nil {
+		if slots, err := segfile.CheckMetadata(metaPath); err == nil {
+			r.Meta = &Meta{Slots: slots}
+		}
+
+		r.addf("cannot read metadata: %v", err)
+		return r, nil
+	}
+
+	r.Meta = m
+	for i, s := range m.Slots {
+		if s.Gen != 0 && !s.Valid {
+			r.addf("metadata slot %d (generation %d) has a bad checksum", i, s.Gen)
+		}
+	}
+
+	if m.Size != 0 && len(segs) > 0 && m.Size != r.SegmentSize {
+		r.addf("metadata segment size %d does not match files (%d)", m.Size, r.SegmentSize)
+	}
+
+	nsegs := int64(len(segs))
+	if nsegs > 0 {
+		nsegs += int64(segs[0].Index)
+	}
+
+	if m.Segs > nsegs {
+		r.addf("metadata has %d segments but there are %d files", m.Segs, len(segs))
+	}
+
+	if total := nsegs * r.SegmentSize; m.Used > total {
+		r.addf("metadata used size %d is larger than segments (%d)", m.Used, total)
+	}
+
+	return r, nil
+}
+
+// Repair removes segment files which cannot be loaded by stores. Segments
+// after a gap and segments at the end with a different size (ex. partially
+// allocated when the process crashed) are removed. Removed paths are returned.
+// It waits upto given timeout for the store lock and returns fsutils.ErrLocked
+// if the store is used by another process.
+func Repair(base string, timeout time.Duration) (removed []string, err error) {
+	lock, err := segments.LockStore(base, true, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	defer lock.Close()
+
+	segs, err := Segments(base)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(segs) == 0 {
+		return nil, nil
+	}
+
+	// number of segments which can be kept
+	// segments before the first one may have been removed
+	n := 0
+	for ; n < len(segs); n++ {
+		if segs[n].Index != segs[0].Index+n || segs[n].Size != segs[0].Size {
+			break
+		}
+	}
+
+	for _, s := range segs[n:] {
+		if err := os.Remove(s.Path); err != nil {
+			return removed, err
+		}
+
+		removed = append(removed, s.Path)
+	}
+
+	return removed, nil
+}
+
+// Truncate discards store data after sz bytes. Segment files which are not
+// required are removed and the rest of the last segment is filled with zeros.
+// The store is locked the same way as Repair.
+func Truncate(base string, sz int64, timeout time.Duration) (removed []string, err error) {
+	lock, err := segments.LockStore(base, true, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	defer lock.Close()
+
+	segs, err := Segments(base)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(segs) == 0 {
+		return nil, ErrNoSegments
+	}
+
+	size := segs[0].Size
+	n := int((sz + size - 1) / size)
+
+	for i := len(segs) - 1; i >= 0 && segs[i].Index >= n; i-- {
+		if err := os.Remove(segs[i].Path); err != nil {
+			return removed, err
+		}
+
+		removed = append(removed, segs[i].Path)
+	}
+
+	// segments before the first one may have been removed
+	last := n - 1 - segs[0].Index
+	if soff := sz % size; soff != 0 && last >= 0 && last < len(segs) && segs[last].Index == n-1 {
+		file, err := os.OpenFile(segs[last].Path, os.O_RDWR, 0644)
+		if err != nil {
+			return removed, err
+		}
+
+		defer file.Close()
+
+		if _, err := file.WriteAt(make([]byte, size-soff), soff); err != nil {
+			return removed, err
+		}
+
+		if err := file.Sync(); err != nil {
+			return removed, err
+		}
+	}
+
+	return removed, nil
+}
+
+// addf adds a problem to the report
+func (r *Report) addf(format string, args ...interface{}) {
+	r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+}
+
+// readAt reads from a file at given offset
+func readAt(path string, p []byte, off int64) (n int, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+
+	defer file.Close()
+	return file.ReadAt(p, off)
+}
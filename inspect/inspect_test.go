@@ -0,0 +1,196 @@
+package inspect
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kadirahq/go-tools/fsutils"
+	"github.com/kadirahq/go-tools/layout"
+	"github.com/kadirahq/go-tools/segments"
+	"github.com/kadirahq/go-tools/segments/segfile"
+)
+
+var (
+	tmpdir  = "/tmp/test-inspect/"
+	tmpbase = tmpdir + "seg_"
+	tmpmeta = tmpdir + "meta"
+)
+
+func setup(t *testing.T) func() {
+	if err := os.RemoveAll(tmpdir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(tmpdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if err := os.RemoveAll(tmpdir); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// write creates segment files with given sizes filled with the index
+func write(t *testing.T, sizes map[int]int) {
+	for i, sz := range sizes {
+		data := bytes.Repeat([]byte{byte('a' + i)}, sz)
+		if err := ioutil.WriteFile(tmpbase+strconv.Itoa(i), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSegments(t *testing.T) {
+	defer setup(t)()
+	write(t, map[int]int{0: 8, 1: 8, 10: 8})
+
+	if err := ioutil.WriteFile(tmpbase+"x", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	segs, err := Segments(tmpbase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(segs) != 3 || segs[0].Index != 0 || segs[1].Index != 1 || segs[2].Index != 10 {
+		t.Fatal("wrong segments")
+	}
+}
+
+func TestHexdump(t *testing.T) {
+	defer setup(t)()
+	write(t, map[int]int{0: 8, 1: 8})
+
+	buf := &bytes.Buffer{}
+	if err := Hexdump(buf, tmpbase, 6, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "|aabbbbbbbb|") {
+		t.Fatal("wrong dump", buf.String())
+	}
+}
+
+func TestRecords(t *testing.T) {
+	defer setup(t)()
+	write(t, map[int]int{0: 8, 1: 8})
+
+	l, err := layout.New("test-inspect", 1, &layout.Field{Name: "a", Type: "[2]byte"}, &layout.Field{Name: "b", Type: "uint8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recs, err := Records(tmpbase, l, 6, 6)
+	if err != io.EOF {
+		t.Fatal("expected io.EOF")
+	}
+
+	if len(recs) != 3 || string(recs[0]["a"].([]byte)) != "aa" || recs[0]["b"] != uint64('b') {
+		t.Fatal("wrong records")
+	}
+}
+
+func TestVerifyRepair(t *testing.T) {
+	defer setup(t)()
+	write(t, map[int]int{0: 8, 1: 8, 2: 3, 4: 8})
+
+	m, err := segfile.NewMetadata(tmpmeta, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.SetSize(8)
+	m.SetSegs(2)
+	m.SetUsed(12)
+	m.SetInt("foo", 5)
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Verify(tmpbase, tmpmeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.Problems) != 2 || r.Meta.Ints["foo"] != 5 {
+		t.Fatal("wrong report", r.Problems)
+	}
+
+	removed, err := Repair(tmpbase, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(removed) != 2 {
+		t.Fatal("wrong removed files")
+	}
+
+	if r, err := Verify(tmpbase, tmpmeta); err != nil || !r.OK() {
+		t.Fatal("expected no problems")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	defer setup(t)()
+	write(t, map[int]int{0: 8, 1: 8, 2: 8})
+
+	removed, err := Truncate(tmpbase, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(removed) != 1 {
+		t.Fatal("wrong removed files")
+	}
+
+	data, err := ioutil.ReadFile(tmpbase + "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, []byte("bb\x00\x00\x00\x00\x00\x00")) {
+		t.Fatal("wrong data")
+	}
+}
+
+func TestRepairLocked(t *testing.T) {
+	defer setup(t)()
+	write(t, map[int]int{0: 8, 1: 8, 2: 3})
+
+	// shared locks need a lock file created by a writer
+	if err := ioutil.WriteFile(tmpbase+"lock", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := segments.LockStore(tmpbase, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer lock.Close()
+
+	if _, err := Repair(tmpbase, 0); err != fsutils.ErrLocked {
+		t.Fatal("expected ErrLocked", err)
+	}
+
+	if _, err := Truncate(tmpbase, 10, 0); err != fsutils.ErrLocked {
+		t.Fatal("expected ErrLocked", err)
+	}
+
+	segs, err := Segments(tmpbase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(segs) != 3 {
+		t.Fatal("segments were removed while the store was locked")
+	}
+}
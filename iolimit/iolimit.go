@@ -0,0 +1,190 @@
+// Package iolimit limits the throughput of readers and writers with a token
+// bucket. A limiter can be shared by many readers and writers to limit their
+// total throughput (ex. all background copies). Readers and writers can also
+// report throughput to a metric store.
+package iolimit
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/kadirahq/go-tools/monitor"
+)
+
+var (
+	// ErrRate is returned when the rate or burst size is not positive.
+	ErrRate = errors.New("rate and burst should be positive")
+)
+
+// Limiter is a token bucket which allows rate bytes per second with bursts
+// of at most burst bytes. All methods are safe to use concurrently.
+type Limiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	mutex  sync.Mutex
+}
+
+// NewLimiter creates a limiter which allows given number of bytes per second.
+// The bucket starts full so the first burst bytes are not delayed.
+func NewLimiter(rate, burst int64) (l *Limiter, err error) {
+	if rate <= 0 || burst <= 0 {
+		return nil, ErrRate
+	}
+
+	l = &Limiter{
+		rate:   float64(rate),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+
+	return l, nil
+}
+
+// SetRate changes the number of bytes allowed per second.
+func (l *Limiter) SetRate(rate int64) (err error) {
+	if rate <= 0 {
+		return ErrRate
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.refill(time.Now())
+	l.rate = float64(rate)
+	return nil
+}
+
+// Burst returns the maximum number of bytes which can be taken at once.
+func (l *Limiter) Burst() (n int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return int64(l.burst)
+}
+
+// Wait takes n tokens from the bucket and blocks until they're available.
+// If n is larger than the burst size, it waits for the whole amount.
+// Waiting callers are served in the order they arrive.
+func (l *Limiter) Wait(n int64) {
+	l.mutex.Lock()
+	now := time.Now()
+	l.refill(now)
+
+	// tokens can go negative, later callers wait for earlier callers
+	l.tokens -= float64(n)
+	delay := time.Duration(0)
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mutex.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// refill adds tokens for the time elapsed since the last refill
+func (l *Limiter) refill(now time.Time) {
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+
+	l.last = now
+}
+
+// meter tracks throughput in a metric store
+type meter struct {
+	store *monitor.Store
+	key   string
+}
+
+func (m *meter) track(n int) {
+	if m.store != nil && n > 0 {
+		m.store.Track(m.key, int64(n))
+	}
+}
+
+// Reader limits the throughput of an io.Reader.
+type Reader struct {
+	r     io.Reader
+	limit *Limiter
+	meter meter
+}
+
+// NewReader creates a reader which reads from r using the limiter.
+func NewReader(r io.Reader, l *Limiter) (lr *Reader) {
+	return &Reader{r: r, limit: l}
+}
+
+// Report tracks bytes read per second in the metric store with given key.
+// This should be called before using the reader.
+func (r *Reader) Report(m *monitor.Store, key string) {
+	m.Register(key, monitor.Rate)
+	r.meter = meter{store: m, key: key}
+}
+
+// Read implements the io.Reader interface. Reads are limited to the burst
+// size and it waits for tokens after reading data.
+func (r *Reader) Read(p []byte) (n int, err error) {
+	if burst := r.limit.Burst(); int64(len(p)) > burst {
+		p = p[:burst]
+	}
+
+	n, err = r.r.Read(p)
+	if n > 0 {
+		r.limit.Wait(int64(n))
+		r.meter.track(n)
+	}
+
+	return n, err
+}
+
+// Writer limits the throughput of an io.Writer.
+type Writer struct {
+	w     io.Writer
+	limit *Limiter
+	meter meter
+}
+
+// NewWriter creates a writer which writes to w using the limiter.
+func NewWriter(w io.Writer, l *Limiter) (lw *Writer) {
+	return &Writer{w: w, limit: l}
+}
+
+// Report tracks bytes written per second in the metric store with given key.
+// This should be called before using the writer.
+func (w *Writer) Report(m *monitor.Store, key string) {
+	m.Register(key, monitor.Rate)
+	w.meter = meter{store: m, key: key}
+}
+
+// Write implements the io.Writer interface. Data is written in chunks of at
+// most the burst size and it waits for tokens before writing each chunk.
+func (w *Writer) Write(p []byte) (n int, err error) {
+	burst := w.limit.Burst()
+
+	for len(p) > 0 {
+		chunk := p
+		if int64(len(chunk)) > burst {
+			chunk = chunk[:burst]
+		}
+
+		w.limit.Wait(int64(len(chunk)))
+		m, err := w.w.Write(chunk)
+		n += m
+		w.meter.track(m)
+
+		if err != nil {
+			return n, err
+		}
+
+		p = p[m:]
+	}
+
+	return n, nil
+}
@@ -0,0 +1,86 @@
+package iolimit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/kadirahq/go-tools/monitor"
+)
+
+func TestLimiter(t *testing.T) {
+	if _, err := NewLimiter(0, 10); err != ErrRate {
+		t.Fatal("expected ErrRate")
+	}
+
+	l, err := NewLimiter(1000, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	l.Wait(100)
+	if time.Since(start) > 20*time.Millisecond {
+		t.Fatal("burst should not wait")
+	}
+
+	l.Wait(100)
+	if d := time.Since(start); d < 80*time.Millisecond {
+		t.Fatal("should wait for tokens", d)
+	}
+
+	if err := l.SetRate(-1); err != ErrRate {
+		t.Fatal("expected ErrRate")
+	}
+}
+
+func TestReader(t *testing.T) {
+	l, err := NewLimiter(10000, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := bytes.Repeat([]byte("a"), 2000)
+	r := NewReader(bytes.NewReader(data), l)
+	m := monitor.New("test-iolimit")
+	r.Report(m, "read")
+
+	start := time.Now()
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(out, data) {
+		t.Fatal("wrong data")
+	}
+
+	if d := time.Since(start); d < 80*time.Millisecond {
+		t.Fatal("should be limited", d)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	l, err := NewLimiter(10000, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf, l)
+
+	start := time.Now()
+	data := bytes.Repeat([]byte("a"), 2500)
+	if n, err := w.Write(data); err != nil || n != len(data) {
+		t.Fatal("write failed", err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("wrong data")
+	}
+
+	if d := time.Since(start); d < 130*time.Millisecond {
+		t.Fatal("should be limited", d)
+	}
+}
@@ -0,0 +1,365 @@
+// Package kv is a small embedded key-value store. All changes are appended
+// to a write-ahead log (a segment store) and a persistent hash index (see
+// hashidx) maps each key to the log record with its latest value. Keys are
+// stored in the index by their hash and checked against the log record.
+// If the process crashes, the index is rebuilt from the log when it's opened.
+package kv
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/kadirahq/go-tools/hashidx"
+	"github.com/kadirahq/go-tools/wal"
+)
+
+// record operation types
+const (
+	opPut byte = iota + 1
+	opDel
+)
+
+const (
+	// size of key hashes stored in the index
+	hashSize = 16
+
+	// number of buckets in a new index
+	indexBuckets = 1024
+)
+
+var (
+	// ErrNotFound is returned when the key does not exist in the store.
+	ErrNotFound = errors.New("key not found")
+
+	// ErrRecord is returned when a log record cannot be decoded.
+	ErrRecord = errors.New("invalid kv record")
+)
+
+// DB is an embedded key-value store. All methods are safe to use
+// concurrently. Changes are durable after the Sync method returns.
+type DB struct {
+	log   *wal.Log
+	index *hashidx.Index
+	mutex sync.RWMutex
+}
+
+// Open opens or creates a key-value store in given directory. Options are
+// used for the write-ahead log. Use nil to use default wal options.
+// The index is rebuilt from the log if it was not closed properly.
+func Open(dir string, opts *wal.Options) (db *DB, err error) {
+	log, err := wal.Open(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ipath := path.Join(dir, "index")
+
+	// a missing index is created from the log
+	_, err = os.Stat(ipath)
+	rebuild := os.IsNotExist(err)
+
+	index, err := hashidx.Open(ipath, hashSize, indexBuckets)
+	if err == hashidx.ErrDirty {
+		rebuild = true
+		if err := os.Remove(ipath); err != nil {
+			log.Close()
+			return nil, err
+		}
+
+		index, err = hashidx.Open(ipath, hashSize, indexBuckets)
+	}
+
+	if err != nil {
+		log.Close()
+		return nil, err
+	}
+
+	db = &DB{log: log, index: index}
+
+	if rebuild {
+		if err := db.replay(); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+// Get returns the value stored with given key.
+// Returns ErrNotFound if the key does not exist.
+func (db *DB) Get(key []byte) (val []byte, err error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	seq, err := db.index.Get(hash(key))
+	if err == hashidx.ErrNotFound {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	k, val, err := db.record(uint64(seq))
+	if err != nil {
+		return nil, err
+	}
+
+	// a different key with the same hash
+	if string(k) != string(key) {
+		return nil, ErrNotFound
+	}
+
+	return val, nil
+}
+
+// Put stores the value with given key replacing the existing value.
+func (db *DB) Put(key, val []byte) (err error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	seq, err := db.log.Append(encode(opPut, key, val))
+	if err != nil {
+		return err
+	}
+
+	return db.index.Put(hash(key), int64(seq))
+}
+
+// Delete removes the key from the store.
+// Deleting a key which does not exist has no effect.
+func (db *DB) Delete(key []byte) (err error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	h := hash(key)
+	seq, err := db.index.Get(h)
+	if err == hashidx.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// a different key with the same hash
+	if k, _, err := db.record(uint64(seq)); err != nil {
+		return err
+	} else if string(k) != string(key) {
+		return nil
+	}
+
+	if _, err := db.log.Append(encode(opDel, key, nil)); err != nil {
+		return err
+	}
+
+	return db.index.Delete(h)
+}
+
+// Iterate calls the function for each key-value pair in key order.
+// Iteration stops if the function returns false. The store cannot be
+// modified from the function but Get can be used inside it. Live records
+// are found by scanning the log so it takes longer when the log is large.
+func (db *DB) Iterate(fn func(key, val []byte) bool) (err error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	seqs := map[string]uint64{}
+	err = db.live(func(key []byte, seq uint64) error {
+		seqs[string(key)] = seq
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(seqs))
+	for k := range seqs {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		_, val, err := db.record(seqs[k])
+		if err != nil {
+			return err
+		}
+
+		if !fn([]byte(k), val) {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Len returns the number of keys in the store.
+func (db *DB) Len() (n int) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	return int(db.index.Len())
+}
+
+// Compact appends the latest value of each key to the end of the log and
+// discards older records. Segment files which only have discarded records
+// are removed so the log does not grow forever when values are replaced or
+// deleted. Compacted records are synced before older records are discarded.
+func (db *DB) Compact() (err error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	head := db.log.Tail()
+
+	// records appended here are after the head so they're not visited
+	err = db.live(func(key []byte, seq uint64) error {
+		_, val, err := db.record(seq)
+		if err != nil {
+			return err
+		}
+
+		next, err := db.log.Append(encode(opPut, key, val))
+		if err != nil {
+			return err
+		}
+
+		return db.index.Put(hash(key), int64(next))
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err := db.log.Flush(); err != nil {
+		return err
+	}
+
+	return db.log.Truncate(head)
+}
+
+// Sync blocks until all changes made before calling it are on the disk.
+// The index is not synced, it's rebuilt from the log after a crash.
+func (db *DB) Sync() (err error) {
+	return db.log.Sync()
+}
+
+// Close syncs all changes to the disk and closes the store.
+// The index is closed after the log so it's rebuilt if closing fails.
+func (db *DB) Close() (err error) {
+	if err := db.log.Close(); err != nil {
+		return err
+	}
+
+	return db.index.Close()
+}
+
+// replay adds all changes in the log to an empty index
+func (db *DB) replay() (err error) {
+	it := db.log.Iter(db.log.Head())
+	for {
+		seq, p, err := it.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		op, key, _, err := decode(p)
+		if err != nil {
+			return err
+		}
+
+		switch op {
+		case opPut:
+			err = db.index.Put(hash(key), int64(seq))
+		case opDel:
+			if err = db.index.Delete(hash(key)); err == hashidx.ErrNotFound {
+				err = nil
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return db.index.Sync()
+}
+
+// live calls the function with the key and the sequence number of each
+// record in the log which has the latest value of its key. Records added
+// while scanning the log are not visited.
+func (db *DB) live(fn func(key []byte, seq uint64) error) (err error) {
+	tail := db.log.Tail()
+	it := db.log.Iter(db.log.Head())
+
+	for {
+		seq, p, err := it.Next()
+		if err == io.EOF || err == nil && seq >= tail {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		op, key, _, err := decode(p)
+		if err != nil {
+			return err
+		}
+
+		if op != opPut {
+			continue
+		}
+
+		if cur, err := db.index.Get(hash(key)); err == nil && uint64(cur) == seq {
+			if err := fn(key, seq); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// record reads the key and the value from the log record at given sequence
+// number
+func (db *DB) record(seq uint64) (key, val []byte, err error) {
+	_, p, err := db.log.Iter(seq).Next()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, key, val, err = decode(p)
+	return key, val, err
+}
+
+// hash returns the hash of a key stored in the index
+func hash(key []byte) []byte {
+	h := sha256.Sum256(key)
+	return h[:hashSize]
+}
+
+// encode encodes a record as [op][key size][key][value]
+func encode(op byte, key, val []byte) (p []byte) {
+	p = make([]byte, 5, 5+len(key)+len(val))
+	p[0] = op
+	binary.LittleEndian.PutUint32(p[1:], uint32(len(key)))
+	p = append(p, key...)
+	p = append(p, val...)
+	return p
+}
+
+// decode decodes a record encoded with encode
+func decode(p []byte) (op byte, key, val []byte, err error) {
+	if len(p) < 5 {
+		return 0, nil, nil, ErrRecord
+	}
+
+	sz := int64(binary.LittleEndian.Uint32(p[1:]))
+	if 5+sz > int64(len(p)) {
+		return 0, nil, nil, ErrRecord
+	}
+
+	return p[0], p[5 : 5+sz], p[5+sz:], nil
+}
@@ -0,0 +1,216 @@
+package kv
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kadirahq/go-tools/wal"
+)
+
+var (
+	tmpdir = "/tmp/test-kv/"
+	opts   = &wal.Options{
+		SegmentSize:   1024,
+		SyncInterval:  time.Millisecond,
+		MaxRecordSize: 1024,
+	}
+)
+
+func setup(t *testing.T) func() {
+	if err := os.RemoveAll(tmpdir); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if err := os.RemoveAll(tmpdir); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDB(t *testing.T) {
+	defer setup(t)()
+
+	db, err := Open(tmpdir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{"c", "a", "b"} {
+		if err := db.Put([]byte(k), []byte(k+k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.Put([]byte("a"), []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Delete([]byte("b")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Get([]byte("b")); err != ErrNotFound {
+		t.Fatal("wrong error")
+	}
+
+	if err := db.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = Open(tmpdir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	if db.Len() != 2 {
+		t.Fatal("wrong length")
+	}
+
+	if val, err := db.Get([]byte("a")); err != nil || !bytes.Equal(val, []byte("x")) {
+		t.Fatal("wrong value")
+	}
+
+	keys := []string{}
+	err = db.Iterate(func(key, val []byte) bool {
+		keys = append(keys, string(key))
+		return true
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "c" {
+		t.Fatal("wrong order")
+	}
+}
+
+func TestCompact(t *testing.T) {
+	defer setup(t)()
+
+	db, err := Open(tmpdir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// replace values to fill a few segments
+	val := bytes.Repeat([]byte("v"), 100)
+	for i := 0; i < 50; i++ {
+		if err := db.Put([]byte("a"), val); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := db.Put([]byte("b"), val); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.Delete([]byte("b")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(tmpdir + "seg_0"); !os.IsNotExist(err) {
+		t.Fatal("old segments should be removed")
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = Open(tmpdir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	if db.Len() != 1 {
+		t.Fatal("wrong length")
+	}
+
+	if v, err := db.Get([]byte("a")); err != nil || !bytes.Equal(v, val) {
+		t.Fatal("wrong value")
+	}
+
+	if _, err := db.Get([]byte("b")); err != ErrNotFound {
+		t.Fatal("wrong error")
+	}
+}
+
+func TestRebuild(t *testing.T) {
+	defer setup(t)()
+
+	db, err := Open(tmpdir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{"a", "b", "c"} {
+		if err := db.Put([]byte(k), []byte(k+k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.Delete([]byte("b")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the process crashed without closing the index
+	if err := db.log.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = Open(tmpdir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if db.Len() != 2 {
+		t.Fatal("wrong length")
+	}
+
+	if val, err := db.Get([]byte("c")); err != nil || !bytes.Equal(val, []byte("cc")) {
+		t.Fatal("wrong value")
+	}
+
+	if _, err := db.Get([]byte("b")); err != ErrNotFound {
+		t.Fatal("wrong error")
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the index is created again if it's missing
+	if err := os.Remove(tmpdir + "index"); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = Open(tmpdir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	if db.Len() != 2 {
+		t.Fatal("wrong length")
+	}
+
+	if val, err := db.Get([]byte("a")); err != nil || !bytes.Equal(val, []byte("aa")) {
+		t.Fatal("wrong value")
+	}
+}
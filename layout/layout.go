@@ -0,0 +1,301 @@
+// Package layout keeps a registry of fixed-size binary record layouts.
+// Packages register named and versioned layouts with their fields. Each
+// layout has an ID calculated from its name, version and fields which stores
+// can write to their headers. When the store is opened again, the stored ID is
+// compared with the registered layout to detect version mismatches and tools
+// can use the ID to find the layout and decode records without knowing types.
+//
+// Field types are the same as packgen types: bool, intN, uintN (N is 8, 16,
+// 32 or 64), byte, float32, float64 and byte arrays (ex. "[16]byte"). Numbers
+// are stored in little endian byte order (hybrid views on little endian
+// machines and packgen records use the same format).
+package layout
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kadirahq/go-tools/checksum"
+	"github.com/kadirahq/go-tools/packgen"
+)
+
+var (
+	// ErrInvalid is returned when a layout has invalid fields.
+	ErrInvalid = errors.New("invalid layout")
+
+	// ErrExists is returned when a different layout is already registered
+	// with the same name and version.
+	ErrExists = errors.New("layout already registered")
+
+	// ErrMismatch is returned when a stored layout ID does not match.
+	ErrMismatch = errors.New("layout mismatch")
+
+	// ErrSize is returned when the data is smaller than the layout.
+	ErrSize = errors.New("data is smaller than the layout")
+
+	registry = map[uint64]*Layout{}
+	mutex    sync.RWMutex
+)
+
+// Field is a field in a layout.
+type Field struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Offset int    `json:"offset"`
+	Size   int    `json:"size"`
+}
+
+// Layout describes a fixed-size binary record.
+type Layout struct {
+	Name    string   `json:"name"`
+	Version uint32   `json:"version"`
+	Size    int      `json:"size"`
+	Fields  []*Field `json:"fields"`
+}
+
+// New creates a layout with fields packed one after the other in the given
+// order. Field sizes are taken from their types. Use this with hybrid views
+// or records which do not have padding between fields.
+func New(name string, version uint32, fields ...*Field) (l *Layout, err error) {
+	l = &Layout{Name: name, Version: version, Fields: fields}
+
+	for _, f := range fields {
+		sz, err := typeSize(f.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		f.Offset = l.Size
+		f.Size = sz
+		l.Size += sz
+	}
+
+	return l, l.Validate()
+}
+
+// FromPackgen creates a layout from a packgen record type.
+func FromPackgen(t *packgen.Type, version uint32) (l *Layout, err error) {
+	fields := make([]*Field, len(t.Fields))
+	for i, f := range t.Fields {
+		fields[i] = &Field{Name: f.Name, Type: f.Type}
+	}
+
+	return New(t.Name, version, fields...)
+}
+
+// Validate checks whether fields are within the layout and do not overlap.
+func (l *Layout) Validate() (err error) {
+	if l.Name == "" || l.Size <= 0 {
+		return ErrInvalid
+	}
+
+	fields := append([]*Field{}, l.Fields...)
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Offset < fields[j].Offset })
+
+	end := 0
+	names := map[string]bool{}
+	for _, f := range fields {
+		sz, err := typeSize(f.Type)
+		if err != nil {
+			return err
+		}
+
+		if f.Name == "" || names[f.Name] || f.Size != sz || f.Offset < end || f.Offset+f.Size > l.Size {
+			return fmt.Errorf("%v: field %q in %s", ErrInvalid, f.Name, l.Name)
+		}
+
+		names[f.Name] = true
+		end = f.Offset + f.Size
+	}
+
+	return nil
+}
+
+// ID returns an identifier calculated from the name, version, size and
+// fields of the layout. Any change to the layout changes its ID.
+func (l *Layout) ID() (id uint64) {
+	buf := []byte(l.Name)
+	buf = binary.LittleEndian.AppendUint32(buf, l.Version)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(l.Size))
+
+	for _, f := range l.Fields {
+		buf = append(buf, 0)
+		buf = append(buf, f.Name...)
+		buf = append(buf, 0)
+		buf = append(buf, f.Type...)
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(f.Offset))
+	}
+
+	return checksum.XXH64(buf, 0)
+}
+
+// String returns the name and the version of the layout (ex. "point@2").
+func (l *Layout) String() string {
+	return l.Name + "@" + strconv.FormatUint(uint64(l.Version), 10)
+}
+
+// Decode reads all fields of a record. Numbers are returned as int64, uint64
+// or float64, bools as bool and byte arrays as byte slices.
+func (l *Layout) Decode(p []byte) (vals map[string]interface{}, err error) {
+	if len(p) < l.Size {
+		return nil, ErrSize
+	}
+
+	vals = make(map[string]interface{}, len(l.Fields))
+	for _, f := range l.Fields {
+		vals[f.Name] = decode(f, p[f.Offset:f.Offset+f.Size])
+	}
+
+	return vals, nil
+}
+
+// Register adds a layout to the registry. Registering the same layout again
+// has no effect. A different layout with the same name and version cannot be
+// registered, the version should be changed when the layout is changed.
+func Register(l *Layout) (err error) {
+	if err := l.Validate(); err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	id := l.ID()
+	if _, ok := registry[id]; ok {
+		return nil
+	}
+
+	for _, r := range registry {
+		if r.Name == l.Name && r.Version == l.Version {
+			return fmt.Errorf("%v: %s", ErrExists, l)
+		}
+	}
+
+	registry[id] = l
+	return nil
+}
+
+// MustRegister registers a layout and panics on errors. It can be used to
+// register layouts in package level variable declarations.
+func MustRegister(l *Layout, err error) *Layout {
+	if err != nil {
+		panic(err)
+	}
+
+	if err := Register(l); err != nil {
+		panic(err)
+	}
+
+	return l
+}
+
+// ByID returns the layout registered with given ID.
+func ByID(id uint64) (l *Layout, ok bool) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	l, ok = registry[id]
+	return l, ok
+}
+
+// Lookup returns the layout registered with given name and version.
+func Lookup(name string, version uint32) (l *Layout, ok bool) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	for _, r := range registry {
+		if r.Name == name && r.Version == version {
+			return r, true
+		}
+	}
+
+	return nil, false
+}
+
+// Versions returns all registered versions of a layout sorted by version.
+func Versions(name string) (ls []*Layout) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	for _, r := range registry {
+		if r.Name == name {
+			ls = append(ls, r)
+		}
+	}
+
+	sort.Slice(ls, func(i, j int) bool { return ls[i].Version < ls[j].Version })
+	return ls
+}
+
+// Check compares a layout ID read from a store with the expected layout.
+// The error describes the stored layout if it's registered.
+func Check(id uint64, l *Layout) (err error) {
+	if id == l.ID() {
+		return nil
+	}
+
+	if s, ok := ByID(id); ok {
+		return fmt.Errorf("%v: stored %s, expected %s", ErrMismatch, s, l)
+	}
+
+	return fmt.Errorf("%v: unknown layout %016x, expected %s", ErrMismatch, id, l)
+}
+
+// typeSize returns the size of a field type in bytes
+func typeSize(t string) (sz int, err error) {
+	switch t {
+	case "bool", "int8", "uint8", "byte":
+		return 1, nil
+	case "int16", "uint16":
+		return 2, nil
+	case "int32", "uint32", "float32":
+		return 4, nil
+	case "int64", "uint64", "float64":
+		return 8, nil
+	}
+
+	if strings.HasPrefix(t, "[") && strings.HasSuffix(t, "]byte") {
+		if n, err := strconv.Atoi(t[1 : len(t)-5]); err == nil && n > 0 {
+			return n, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%v: unsupported type %q", ErrInvalid, t)
+}
+
+// decode decodes a field value
+func decode(f *Field, p []byte) (val interface{}) {
+	enc := binary.LittleEndian
+
+	switch f.Type {
+	case "bool":
+		return p[0] != 0
+	case "int8":
+		return int64(int8(p[0]))
+	case "uint8", "byte":
+		return uint64(p[0])
+	case "int16":
+		return int64(int16(enc.Uint16(p)))
+	case "uint16":
+		return uint64(enc.Uint16(p))
+	case "int32":
+		return int64(int32(enc.Uint32(p)))
+	case "uint32":
+		return uint64(enc.Uint32(p))
+	case "int64":
+		return int64(enc.Uint64(p))
+	case "uint64":
+		return enc.Uint64(p)
+	case "float32":
+		return float64(math.Float32frombits(enc.Uint32(p)))
+	case "float64":
+		return math.Float64frombits(enc.Uint64(p))
+	}
+
+	return append([]byte{}, p...)
+}
@@ -0,0 +1,119 @@
+package layout
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/kadirahq/go-tools/packgen"
+	"github.com/kadirahq/go-tools/packgen/example"
+)
+
+func TestNew(t *testing.T) {
+	l, err := New("test-new", 1, &Field{Name: "a", Type: "int64"}, &Field{Name: "b", Type: "[3]byte"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l.Size != 11 || l.Fields[1].Offset != 8 || l.Fields[1].Size != 3 {
+		t.Fatal("wrong layout")
+	}
+
+	if _, err := New("test-new", 1, &Field{Name: "a", Type: "int"}); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if _, err := New("test-new", 1, &Field{Name: "a", Type: "int8"}, &Field{Name: "a", Type: "int8"}); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	l.Fields[1].Offset = 6
+	if err := l.Validate(); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestPackgen(t *testing.T) {
+	file, err := os.Open("../packgen/example/schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	s, err := packgen.ReadSchema(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := FromPackgen(s.Types[0], 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l.Size != example.PointSize {
+		t.Fatal("wrong size")
+	}
+
+	pt := &example.Point{Time: -5, Value: 1.5, Count: 7, Weight: 0.25, Flags: 3, Valid: true, Delta: -2}
+	copy(pt.ID[:], "abc")
+
+	p := make([]byte, l.Size)
+	pt.Pack(p)
+
+	vals, err := l.Decode(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if vals["Time"] != int64(-5) || vals["Value"] != 1.5 || vals["Count"] != uint64(7) ||
+		vals["Weight"] != 0.25 || vals["Flags"] != uint64(3) || vals["Valid"] != true ||
+		vals["Delta"] != int64(-2) || !bytes.HasPrefix(vals["ID"].([]byte), []byte("abc")) {
+		t.Fatal("wrong values", vals)
+	}
+
+	if _, err := l.Decode(p[:10]); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	v1, _ := New("test-reg", 1, &Field{Name: "a", Type: "int64"})
+	v2, _ := New("test-reg", 2, &Field{Name: "a", Type: "int64"}, &Field{Name: "b", Type: "bool"})
+	bad, _ := New("test-reg", 1, &Field{Name: "a", Type: "uint64"})
+
+	if v1.ID() == v2.ID() || v1.ID() == bad.ID() {
+		t.Fatal("expected different ids")
+	}
+
+	MustRegister(v1, nil)
+	MustRegister(v2, nil)
+
+	if err := Register(v1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Register(bad); err == nil {
+		t.Fatal("expected ErrExists")
+	}
+
+	if l, ok := ByID(v2.ID()); !ok || l != v2 {
+		t.Fatal("wrong layout")
+	}
+
+	if l, ok := Lookup("test-reg", 1); !ok || l != v1 {
+		t.Fatal("wrong layout")
+	}
+
+	if ls := Versions("test-reg"); len(ls) != 2 || ls[0] != v1 {
+		t.Fatal("wrong versions")
+	}
+
+	if err := Check(v2.ID(), v2); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Check(v1.ID(), v2); err == nil || err.Error() != ErrMismatch.Error()+": stored test-reg@1, expected test-reg@2" {
+		t.Fatal("wrong error", err)
+	}
+}
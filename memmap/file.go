@@ -0,0 +1,586 @@
+package memmap
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kadirahq/go-tools/fsutils"
+)
+
+var (
+	// ErrClosed is used when the user attempts to use a closed file.
+	ErrClosed = errors.New("file is closed")
+
+	// ErrOffset is used when the user attempts to use a negative offset.
+	ErrOffset = errors.New("invalid file offset")
+
+	// ErrReadOnly is used when the user attempts to change a read-only file.
+	ErrReadOnly = errors.New("file is read-only")
+)
+
+// FileOptions for memory mapped files
+type FileOptions struct {
+	// GrowthFactor is used to calculate the new memory map size when data
+	// is written after the end of the memory map. Larger values reduce the
+	// number of times the file is extended and the memory map is resized.
+	GrowthFactor float64
+
+	// MinGrowth is the minimum number of bytes added to the memory map
+	// when it grows. It's useful to reduce resizing small files.
+	MinGrowth int64
+
+	// ReadOnly opens the file with a shared lock so other read-only users
+	// can use it at the same time. Changing the file returns ErrReadOnly.
+	// Otherwise, an exclusive lock is used so only one process can write.
+	ReadOnly bool
+
+	// LockTimeout is the time to wait for other processes to release the
+	// file lock before returning fsutils.ErrLocked (see fsutils.WaitLock).
+	LockTimeout time.Duration
+}
+
+// DefaultFileOptions is used when options are not given to OpenFileWith.
+var DefaultFileOptions = &FileOptions{
+	GrowthFactor: 1.5,
+	MinGrowth:    1 << 20,
+}
+
+// File is a growable file which is accessed using a memory map. The memory
+// map can be larger than the data in the file. The end of data is tracked
+// separately and the file is truncated to it when the file is closed. If the
+// process crashes before closing the file, it may have zeroes at the end.
+//
+// The file is extended in chunks and the memory map is only resized when
+// data is written after its end. Other reads and writes can run in parallel.
+//
+// The file is locked while it's open (see FileOptions.ReadOnly) so multiple
+// processes cannot change it at the same time.
+type File struct {
+	opts  *FileOptions
+	mmap  *Map
+	mapmx sync.RWMutex
+	lock  *os.File
+	path  string
+	size  int64
+	offs  int64
+	offmx sync.Mutex
+}
+
+// NewFile opens a memory mapped file on given path.
+// A new file will be created on given path if necessary.
+func NewFile(path string) (f *File, err error) {
+	return OpenFileWith(path, nil)
+}
+
+// OpenFileWith opens a memory mapped file on given path with options.
+// A new file will be created on given path if necessary unless it's
+// opened with the ReadOnly option. Read-only files are opened and
+// mapped without write access.
+func OpenFileWith(path string, opts *FileOptions) (f *File, err error) {
+	if opts == nil {
+		opts = DefaultFileOptions
+	}
+
+	mode := fmode
+	if opts.ReadOnly {
+		mode = os.O_RDONLY
+	}
+
+	file, err := os.OpenFile(path, mode, fperm)
+	if err != nil {
+		return nil, err
+	}
+
+	// the file is kept open to hold the lock
+	err = fsutils.WaitLock(file, !opts.ReadOnly, opts.LockTimeout)
+	if err != nil && err != fsutils.ErrUnsupported {
+		file.Close()
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	size := info.Size()
+	msize := mapSize(size)
+
+	var m *Map
+	if opts.ReadOnly {
+		// read-only files are not extended, only data before the end of
+		// the file is mapped (empty files are mapped with a single page)
+		if size > 0 {
+			msize = size
+		}
+
+		m, err = mapFile(file, msize, &Options{ReadOnly: true})
+	} else {
+		if size != msize {
+			if err := file.Truncate(msize); err != nil {
+				file.Close()
+				return nil, err
+			}
+		}
+
+		m, err = OpenFile(file, msize, nil)
+	}
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	f = &File{
+		opts: opts,
+		mmap: m,
+		lock: file,
+		path: path,
+		size: size,
+	}
+
+	return f, nil
+}
+
+// Read implements the io.Reader interface
+func (f *File) Read(p []byte) (n int, err error) {
+	f.offmx.Lock()
+	defer f.offmx.Unlock()
+
+	n, err = f.ReadAt(p, f.offs)
+	f.offs += int64(n)
+	return n, err
+}
+
+// Write implements the io.Writer interface
+func (f *File) Write(p []byte) (n int, err error) {
+	f.offmx.Lock()
+	defer f.offmx.Unlock()
+
+	n, err = f.WriteAt(p, f.offs)
+	f.offs += int64(n)
+	return n, err
+}
+
+// ReadFrom implements the io.ReaderFrom interface
+// Data is read directly into the memory map starting from the current
+// offset until the reader returns io.EOF. The file grows when necessary.
+func (f *File) ReadFrom(r io.Reader) (n int64, err error) {
+	if f.opts.ReadOnly {
+		return 0, ErrReadOnly
+	}
+
+	f.offmx.Lock()
+	defer f.offmx.Unlock()
+
+	for {
+		f.mapmx.RLock()
+		if f.mmap == nil {
+			f.mapmx.RUnlock()
+			return n, ErrClosed
+		}
+
+		if f.offs >= int64(len(f.mmap.Data)) {
+			f.mapmx.RUnlock()
+
+			f.mapmx.Lock()
+			err := f.grow(f.offs + 1)
+			f.mapmx.Unlock()
+
+			if err != nil {
+				return n, err
+			}
+
+			continue
+		}
+
+		nr, err := r.Read(f.mmap.Data[f.offs:])
+		f.extend(f.offs + int64(nr))
+		f.mapmx.RUnlock()
+
+		n += int64(nr)
+		f.offs += int64(nr)
+
+		if err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+	}
+}
+
+// WriteTo implements the io.WriterTo interface
+// Data is written directly from the memory map starting from the current
+// offset until the end of the file.
+func (f *File) WriteTo(w io.Writer) (n int64, err error) {
+	f.offmx.Lock()
+	defer f.offmx.Unlock()
+
+	f.mapmx.RLock()
+	defer f.mapmx.RUnlock()
+
+	if f.mmap == nil {
+		return 0, ErrClosed
+	}
+
+	size := atomic.LoadInt64(&f.size)
+	if f.offs >= size {
+		return 0, nil
+	}
+
+	nw, err := w.Write(f.mmap.Data[f.offs:size])
+	f.offs += int64(nw)
+	return int64(nw), err
+}
+
+// Append writes data at the end of the file and returns the offset where
+// it was written. It does not use or change the offset used by Read/Write.
+// Space is reserved before writing so concurrent appends do not overlap.
+// Concurrent readers may see zeroes in reserved space until it's written.
+// The end of data never moves past the memory map so readers can use it.
+func (f *File) Append(p []byte) (off int64, err error) {
+	if f.opts.ReadOnly {
+		return 0, ErrReadOnly
+	}
+
+	sz := int64(len(p))
+
+	// fast path: reserve space if it's already mapped
+	f.mapmx.RLock()
+	if f.mmap == nil {
+		f.mapmx.RUnlock()
+		return 0, ErrClosed
+	}
+
+	for {
+		off = atomic.LoadInt64(&f.size)
+		if off+sz > int64(len(f.mmap.Data)) {
+			break
+		}
+
+		if atomic.CompareAndSwapInt64(&f.size, off, off+sz) {
+			copy(f.mmap.Data[off:], p)
+			f.mapmx.RUnlock()
+			return off, nil
+		}
+	}
+	f.mapmx.RUnlock()
+
+	// slow path: grow the memory map before reserving space
+	// the size is only changed after data is in the memory map
+	f.mapmx.Lock()
+	defer f.mapmx.Unlock()
+
+	if f.mmap == nil {
+		return 0, ErrClosed
+	}
+
+	off = f.size
+	if err := f.grow(off + sz); err != nil {
+		return 0, err
+	}
+
+	copy(f.mmap.Data[off:], p)
+	f.size = off + sz
+	return off, nil
+}
+
+// Seek implements the io.Seeker interface
+func (f *File) Seek(offset int64, whence int) (off int64, err error) {
+	f.offmx.Lock()
+	defer f.offmx.Unlock()
+
+	switch whence {
+	case 0:
+		// from start
+		off = offset
+	case 1:
+		// from current
+		off = f.offs + offset
+	case 2:
+		// from end
+		off = atomic.LoadInt64(&f.size) + offset
+	}
+
+	if off < 0 {
+		return f.offs, ErrOffset
+	}
+
+	f.offs = off
+	return off, nil
+}
+
+// ReadAt implements the io.ReaderAt interface
+func (f *File) ReadAt(p []byte, off int64) (n int, err error) {
+	f.mapmx.RLock()
+	defer f.mapmx.RUnlock()
+
+	if f.mmap == nil {
+		return 0, ErrClosed
+	}
+
+	if off < 0 {
+		return 0, ErrOffset
+	}
+
+	size := atomic.LoadInt64(&f.size)
+	if off >= size {
+		return 0, io.EOF
+	}
+
+	n = copy(p, f.mmap.Data[off:size])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// WriteAt implements the io.WriterAt interface
+// The file grows if data is written after the end of the file. The memory
+// map is only resized (with an exclusive lock) if it's not large enough.
+func (f *File) WriteAt(p []byte, off int64) (n int, err error) {
+	if f.opts.ReadOnly {
+		return 0, ErrReadOnly
+	}
+
+	if off < 0 {
+		return 0, ErrOffset
+	}
+
+	end := off + int64(len(p))
+
+	// fast path
+	f.mapmx.RLock()
+	if f.mmap == nil {
+		f.mapmx.RUnlock()
+		return 0, ErrClosed
+	}
+
+	if end <= int64(len(f.mmap.Data)) {
+		n = copy(f.mmap.Data[off:], p)
+		f.extend(end)
+		f.mapmx.RUnlock()
+		return n, nil
+	}
+	f.mapmx.RUnlock()
+
+	// slow path
+	return f.writeSlow(p, off)
+}
+
+// Size implements the fs.Sizer interface
+// It returns the size of data in the file (not the memory map size).
+func (f *File) Size() (sz int64, err error) {
+	f.mapmx.RLock()
+	defer f.mapmx.RUnlock()
+
+	if f.mmap == nil {
+		return 0, ErrClosed
+	}
+
+	return atomic.LoadInt64(&f.size), nil
+}
+
+// Truncate implements the fs.Truncator interface
+// Both the file and the memory map are resized when the file shrinks.
+func (f *File) Truncate(sz int64) (err error) {
+	if f.opts.ReadOnly {
+		return ErrReadOnly
+	}
+
+	f.mapmx.Lock()
+	defer f.mapmx.Unlock()
+
+	if f.mmap == nil {
+		return ErrClosed
+	}
+
+	if sz < 0 {
+		return ErrOffset
+	}
+
+	if sz > f.size {
+		if err := f.grow(sz); err != nil {
+			return err
+		}
+
+		f.size = sz
+		return nil
+	}
+
+	// data after the end of the file should always be zeroes
+	// it will be visible again if the file grows after this
+	data := f.mmap.Data[sz:f.size]
+	for i := range data {
+		data[i] = 0
+	}
+
+	if msize := mapSize(sz); msize != int64(len(f.mmap.Data)) {
+		if err := f.mmap.Resize(msize); err != nil {
+			return err
+		}
+	}
+
+	f.size = sz
+	return nil
+}
+
+// Sync implements the fs.Syncer interface
+func (f *File) Sync() (err error) {
+	f.mapmx.RLock()
+	defer f.mapmx.RUnlock()
+
+	if f.mmap == nil {
+		return ErrClosed
+	}
+
+	return f.mmap.Sync()
+}
+
+// Close implements the io.Closer interface
+// The file is truncated to the size of its data after unmapping it.
+// The lock is released after truncating the file.
+func (f *File) Close() (err error) {
+	f.mapmx.Lock()
+	defer f.mapmx.Unlock()
+
+	if f.mmap == nil {
+		return ErrClosed
+	}
+
+	if err := f.mmap.Close(); err != nil {
+		return err
+	}
+
+	f.mmap = nil
+
+	if !f.opts.ReadOnly {
+		if err := f.lock.Truncate(f.size); err != nil {
+			f.lock.Close()
+			return err
+		}
+	}
+
+	return f.lock.Close()
+}
+
+// writeSlow grows the memory map with an exclusive lock and writes data
+func (f *File) writeSlow(p []byte, off int64) (n int, err error) {
+	f.mapmx.Lock()
+	defer f.mapmx.Unlock()
+
+	if f.mmap == nil {
+		return 0, ErrClosed
+	}
+
+	end := off + int64(len(p))
+	if err := f.grow(end); err != nil {
+		return 0, err
+	}
+
+	n = copy(f.mmap.Data[off:], p)
+	f.extend(end)
+	return n, nil
+}
+
+// Cursor reads and writes a File using its own offset. A file can have many
+// cursors and they can be used in parallel. A cursor should be used by one
+// goroutine at a time.
+type Cursor struct {
+	file *File
+	offs int64
+}
+
+// NewCursor creates a cursor which starts from the beginning of the file.
+// Cursors do not use or change the offset used by File Read/Write/Seek.
+func (f *File) NewCursor() (c *Cursor) {
+	return &Cursor{file: f}
+}
+
+// Read implements the io.Reader interface
+func (c *Cursor) Read(p []byte) (n int, err error) {
+	n, err = c.file.ReadAt(p, c.offs)
+	c.offs += int64(n)
+	return n, err
+}
+
+// Write implements the io.Writer interface
+func (c *Cursor) Write(p []byte) (n int, err error) {
+	n, err = c.file.WriteAt(p, c.offs)
+	c.offs += int64(n)
+	return n, err
+}
+
+// Seek implements the io.Seeker interface
+func (c *Cursor) Seek(offset int64, whence int) (off int64, err error) {
+	switch whence {
+	case 0:
+		// from start
+		off = offset
+	case 1:
+		// from current
+		off = c.offs + offset
+	case 2:
+		// from end
+		sz, err := c.file.Size()
+		if err != nil {
+			return c.offs, err
+		}
+
+		off = sz + offset
+	}
+
+	if off < 0 {
+		return c.offs, ErrOffset
+	}
+
+	c.offs = off
+	return off, nil
+}
+
+// extend sets the end of data if it's after the current end
+func (f *File) extend(end int64) {
+	for size := atomic.LoadInt64(&f.size); end > size; size = atomic.LoadInt64(&f.size) {
+		if atomic.CompareAndSwapInt64(&f.size, size, end) {
+			break
+		}
+	}
+}
+
+// grow makes sure that the memory map has at least sz bytes. The memory map
+// is extended in chunks using options to avoid resizing it on every write.
+// This should be called while holding the write lock.
+func (f *File) grow(sz int64) (err error) {
+	msize := int64(len(f.mmap.Data))
+	if sz <= msize {
+		return nil
+	}
+
+	next := int64(float64(msize) * f.opts.GrowthFactor)
+	if min := msize + f.opts.MinGrowth; next < min {
+		next = min
+	}
+
+	if next < sz {
+		next = sz
+	}
+
+	return f.mmap.Resize(mapSize(next))
+}
+
+// mapSize returns the memory map size required for sz bytes of data
+// Memory maps cannot be empty so at least one page is always mapped.
+func mapSize(sz int64) int64 {
+	ps := int64(os.Getpagesize())
+	if sz < ps {
+		return ps
+	}
+
+	return (sz + ps - 1) / ps * ps
+}
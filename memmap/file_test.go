@@ -0,0 +1,611 @@
+package memmap
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/kadirahq/go-tools/fsutils"
+)
+
+var (
+	tmpdata = "/tmp/test-memmap-file"
+)
+
+func TestFileAppend(t *testing.T) {
+	if err := os.RemoveAll(tmpdata); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpdata)
+
+	f, err := NewFile(tmpdata)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if off, err := f.Append([]byte("hello ")); err != nil {
+		t.Fatal(err)
+	} else if off != 0 {
+		t.Fatal("wrong offset")
+	}
+
+	if off, err := f.Append([]byte("world")); err != nil {
+		t.Fatal(err)
+	} else if off != 6 {
+		t.Fatal("wrong offset")
+	}
+
+	big := make([]byte, 3*os.Getpagesize())
+	big[len(big)-1] = 1
+	if off, err := f.Append(big); err != nil {
+		t.Fatal(err)
+	} else if off != 11 {
+		t.Fatal("wrong offset")
+	}
+
+	if sz, err := f.Size(); err != nil {
+		t.Fatal(err)
+	} else if sz != int64(11+len(big)) {
+		t.Fatal("wrong size")
+	}
+
+	p := make([]byte, 20)
+	if n, err := f.Read(p); err != nil {
+		t.Fatal(err)
+	} else if n != 20 || string(p[:11]) != "hello world" {
+		t.Fatal("wrong data")
+	}
+
+	if _, err := f.ReadAt(p, int64(len(big))); err != io.EOF {
+		t.Fatal("expected io.EOF")
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(tmpdata)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data) != 11+len(big) || string(data[:11]) != "hello world" {
+		t.Fatal("wrong file data")
+	}
+
+	f, err = NewFile(tmpdata)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	if off, err := f.Append([]byte("!")); err != nil {
+		t.Fatal(err)
+	} else if off != int64(11+len(big)) {
+		t.Fatal("wrong offset after reopening")
+	}
+}
+
+func TestFileTruncate(t *testing.T) {
+	if err := os.RemoveAll(tmpdata); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpdata)
+
+	f, err := NewFile(tmpdata)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ps := os.Getpagesize()
+	data := make([]byte, 4*ps)
+	for i := range data {
+		data[i] = 1
+	}
+
+	if _, err := f.WriteAt(data, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Truncate(5); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.mmap.Data) != ps {
+		t.Fatal("memory map should shrink")
+	}
+
+	if err := f.Truncate(100); err != nil {
+		t.Fatal(err)
+	}
+
+	p := make([]byte, 100)
+	if _, err := f.ReadAt(p, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, b := range p {
+		if (i < 5 && b != 1) || (i >= 5 && b != 0) {
+			t.Fatal("wrong data after truncating")
+		}
+	}
+
+	if err := f.Truncate(-1); err != ErrOffset {
+		t.Fatal("expected ErrOffset")
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Close(); err != ErrClosed {
+		t.Fatal("expected ErrClosed")
+	}
+
+	info, err := os.Stat(tmpdata)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Size() != 100 {
+		t.Fatal("wrong file size")
+	}
+}
+
+func TestFileGrowth(t *testing.T) {
+	if err := os.RemoveAll(tmpdata); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpdata)
+
+	ps := int64(os.Getpagesize())
+	f, err := OpenFileWith(tmpdata, &FileOptions{GrowthFactor: 2, MinGrowth: ps})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	if _, err := f.WriteAt([]byte{1}, ps); err != nil {
+		t.Fatal(err)
+	}
+
+	if int64(len(f.mmap.Data)) != 2*ps {
+		t.Fatal("memory map should double")
+	}
+
+	if _, err := f.WriteAt([]byte{1}, 10*ps); err != nil {
+		t.Fatal(err)
+	}
+
+	if int64(len(f.mmap.Data)) != 11*ps {
+		t.Fatal("memory map should fit the data")
+	}
+
+	if sz, err := f.Size(); err != nil {
+		t.Fatal(err)
+	} else if sz != 10*ps+1 {
+		t.Fatal("wrong size")
+	}
+}
+
+func TestFileConcurrent(t *testing.T) {
+	if err := os.RemoveAll(tmpdata); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpdata)
+
+	f, err := OpenFileWith(tmpdata, &FileOptions{GrowthFactor: 1, MinGrowth: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	const (
+		workers = 4
+		records = 1000
+	)
+
+	done := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		go func(b byte) {
+			p := []byte{b, b, b, b, b, b, b, b}
+			for j := 0; j < records; j++ {
+				if _, err := f.Append(p); err != nil {
+					done <- err
+					return
+				}
+			}
+
+			done <- nil
+		}(byte(i + 1))
+	}
+
+	for i := 0; i < workers; i++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := make([]byte, workers*records*8)
+	if n, err := f.ReadAt(p, 0); err != nil || n != len(p) {
+		t.Fatal("wrong size", n, err)
+	}
+
+	counts := map[byte]int{}
+	for i := 0; i < len(p); i += 8 {
+		for j := 1; j < 8; j++ {
+			if p[i+j] != p[i] {
+				t.Fatal("appends should not overlap")
+			}
+		}
+
+		counts[p[i]]++
+	}
+
+	for i := 0; i < workers; i++ {
+		if counts[byte(i+1)] != records {
+			t.Fatal("missing records")
+		}
+	}
+}
+
+func TestFileAppendRead(t *testing.T) {
+	if err := os.RemoveAll(tmpdata); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpdata)
+
+	// the memory map grows one page at a time so appends often resize it
+	f, err := OpenFileWith(tmpdata, &FileOptions{GrowthFactor: 1, MinGrowth: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+
+	go func() {
+		p := make([]byte, 1000)
+		for i := 0; i < 2000; i++ {
+			if _, err := f.Append(p); err != nil {
+				done <- err
+				return
+			}
+		}
+
+		close(stop)
+		done <- nil
+	}()
+
+	// readers use the end of data while the file grows
+	p := make([]byte, 100)
+	for {
+		select {
+		case <-stop:
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+
+			return
+		default:
+		}
+
+		sz, err := f.Size()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := f.ReadAt(p, sz/2); err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+
+		c := f.NewCursor()
+		if _, err := c.Seek(-int64(len(p)), 2); err == nil {
+			if _, err := c.Read(p); err != nil && err != io.EOF {
+				t.Fatal(err)
+			}
+		}
+
+		if _, err := f.WriteTo(ioutil.Discard); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func benchmarkFileAppend(b *testing.B, opts *FileOptions) {
+	if err := os.RemoveAll(tmpdata); err != nil {
+		b.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpdata)
+
+	f, err := OpenFileWith(tmpdata, opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defer f.Close()
+
+	p := make([]byte, 512)
+	b.SetBytes(int64(len(p)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := f.Append(p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFileAppendPageGrowth(b *testing.B) {
+	benchmarkFileAppend(b, &FileOptions{GrowthFactor: 1, MinGrowth: 0})
+}
+
+func BenchmarkFileAppendChunkGrowth(b *testing.B) {
+	benchmarkFileAppend(b, DefaultFileOptions)
+}
+
+func TestFileReaderFromWriterTo(t *testing.T) {
+	if err := os.RemoveAll(tmpdata); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpdata)
+
+	f, err := OpenFileWith(tmpdata, &FileOptions{GrowthFactor: 1, MinGrowth: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	data := make([]byte, 3*os.Getpagesize()+10)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	if n, err := f.ReadFrom(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	} else if n != int64(len(data)) {
+		t.Fatal("wrong n")
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if n, err := io.Copy(buf, f); err != nil {
+		t.Fatal(err)
+	} else if n != int64(len(data)) {
+		t.Fatal("wrong n")
+	}
+
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("wrong data")
+	}
+}
+
+func TestFileCursors(t *testing.T) {
+	if err := os.RemoveAll(tmpdata); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpdata)
+
+	f, err := NewFile(tmpdata)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	const (
+		workers = 4
+		records = 1000
+	)
+
+	// each cursor writes records for one worker in a separate area
+	done := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		go func(i int) {
+			c := f.NewCursor()
+			if _, err := c.Seek(int64(i*records*8), 0); err != nil {
+				done <- err
+				return
+			}
+
+			b := byte(i + 1)
+			p := []byte{b, b, b, b, b, b, b, b}
+			for j := 0; j < records; j++ {
+				if _, err := c.Write(p); err != nil {
+					done <- err
+					return
+				}
+			}
+
+			done <- nil
+		}(i)
+	}
+
+	for i := 0; i < workers; i++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < workers; i++ {
+		go func(i int) {
+			c := f.NewCursor()
+			if _, err := c.Seek(int64(i*records*8), 0); err != nil {
+				done <- err
+				return
+			}
+
+			p := make([]byte, records*8)
+			if _, err := io.ReadFull(c, p); err != nil {
+				done <- err
+				return
+			}
+
+			for _, b := range p {
+				if b != byte(i+1) {
+					done <- errors.New("wrong data")
+					return
+				}
+			}
+
+			done <- nil
+		}(i)
+	}
+
+	for i := 0; i < workers; i++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := f.NewCursor()
+	if off, err := c.Seek(-8, 2); err != nil {
+		t.Fatal(err)
+	} else if off != workers*records*8-8 {
+		t.Fatal("wrong offset")
+	}
+
+	if _, err := c.Seek(-1, 0); err != ErrOffset {
+		t.Fatal("expected ErrOffset")
+	}
+
+	if off, err := f.Seek(0, 1); err != nil || off != 0 {
+		t.Fatal("cursors should not change the file offset")
+	}
+}
+
+func TestFileReadOnly(t *testing.T) {
+	if err := os.RemoveAll(tmpdata); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpdata)
+
+	f, err := NewFile(tmpdata)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	// writers use exclusive locks
+	if _, err := NewFile(tmpdata); err != fsutils.ErrLocked {
+		t.Fatal("expected ErrLocked")
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &FileOptions{ReadOnly: true}
+	r1, err := OpenFileWith(tmpdata, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// read-only files can be shared
+	r2, err := OpenFileWith(tmpdata, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := make([]byte, 5)
+	if _, err := r2.ReadAt(p, 0); err != nil {
+		t.Fatal(err)
+	} else if string(p) != "hello" {
+		t.Fatal("wrong data")
+	}
+
+	if _, err := r1.WriteAt(p, 0); err != ErrReadOnly {
+		t.Fatal("expected ErrReadOnly")
+	}
+
+	if _, err := r1.Append(p); err != ErrReadOnly {
+		t.Fatal("expected ErrReadOnly")
+	}
+
+	if err := r1.Truncate(0); err != ErrReadOnly {
+		t.Fatal("expected ErrReadOnly")
+	}
+
+	if _, err := NewFile(tmpdata); err != fsutils.ErrLocked {
+		t.Fatal("expected ErrLocked")
+	}
+
+	if err := r1.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r2.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// read-only files are not changed
+	if data, err := ioutil.ReadFile(tmpdata); err != nil {
+		t.Fatal(err)
+	} else if string(data) != "hello" {
+		t.Fatal("wrong file")
+	}
+}
+
+func TestFileReadOnlyPerms(t *testing.T) {
+	if err := os.RemoveAll(tmpdata); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpdata)
+
+	opts := &FileOptions{ReadOnly: true}
+
+	// read-only opens should not create files
+	if _, err := OpenFileWith(tmpdata, opts); !os.IsNotExist(err) {
+		t.Fatal("expected a not exist error")
+	}
+
+	if _, err := os.Stat(tmpdata); !os.IsNotExist(err) {
+		t.Fatal("file should not exist")
+	}
+
+	if err := ioutil.WriteFile(tmpdata, []byte("hello"), 0444); err != nil {
+		t.Fatal(err)
+	}
+
+	// files without write permission can be opened read-only
+	f, err := OpenFileWith(tmpdata, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := make([]byte, 5)
+	if _, err := f.ReadAt(p, 0); err != nil {
+		t.Fatal(err)
+	} else if string(p) != "hello" {
+		t.Fatal("wrong data")
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
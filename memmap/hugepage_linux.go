@@ -0,0 +1,62 @@
+package memmap
+
+import (
+	"os"
+	"reflect"
+	"syscall"
+	"unsafe"
+)
+
+// size of transparent huge pages on most linux systems
+const hugePageSize = 2 << 20
+
+// mmapHuge maps the file at an address aligned to the huge page size and
+// asks the kernel to use transparent huge pages. An aligned address range
+// is reserved with an anonymous map and the file is mapped on top of it.
+func mmapHuge(file *os.File, size int64, prot, flag int) (m *Map, err error) {
+	ps := uintptr(os.Getpagesize())
+	length := (uintptr(size) + ps - 1) / ps * ps
+	rsize := length + hugePageSize
+
+	addr, _, errno := syscall.Syscall6(syscall.SYS_MMAP, 0, rsize, syscall.PROT_NONE,
+		syscall.MAP_PRIVATE|syscall.MAP_ANON, ^uintptr(0), 0)
+	if errno != 0 {
+		return nil, syscall.Errno(errno)
+	}
+
+	start := (addr + hugePageSize - 1) &^ (hugePageSize - 1)
+	_, _, errno = syscall.Syscall6(syscall.SYS_MMAP, start, uintptr(size), uintptr(prot),
+		uintptr(flag|syscall.MAP_FIXED), file.Fd(), 0)
+	if errno != 0 {
+		munmap(addr, rsize)
+		return nil, syscall.Errno(errno)
+	}
+
+	// release reserved space before and after the file map
+	if start > addr {
+		munmap(addr, start-addr)
+	}
+
+	if end := start + length; end < addr+rsize {
+		munmap(end, addr+rsize-end)
+	}
+
+	// this fails on kernels without transparent huge pages
+	// the memory map can still be used with normal pages
+	syscall.Syscall(syscall.SYS_MADVISE, start, length, syscall.MADV_HUGEPAGE)
+
+	var data []byte
+	head := (*reflect.SliceHeader)(unsafe.Pointer(&data))
+	head.Data = start
+	head.Len = int(size)
+	head.Cap = int(size)
+
+	m = &Map{raw: true}
+	m.set(data)
+	return m, nil
+}
+
+// munmap unmaps a memory range
+func munmap(addr, length uintptr) {
+	syscall.Syscall(syscall.SYS_MUNMAP, addr, length, 0)
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package memmap
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapHuge maps the file with normal pages because transparent
+// huge pages are only supported on linux.
+func mmapHuge(file *os.File, size int64, prot, flag int) (m *Map, err error) {
+	return mmap(file, size, &Options{Private: flag == syscall.MAP_PRIVATE, ReadOnly: prot == syscall.PROT_READ})
+}
@@ -3,17 +3,12 @@ package memmap
 import (
 	"errors"
 	"os"
-	"reflect"
-	"syscall"
-	"unsafe"
+	"strings"
 )
 
 const (
 	fmode = os.O_RDWR | os.O_CREATE
 	fperm = 0644
-	mflag = syscall.MAP_SHARED
-	mprot = syscall.PROT_READ | syscall.PROT_WRITE
-	msync = syscall.MS_SYNC
 )
 
 var (
@@ -24,20 +19,128 @@ var (
 	// ErrBadSz is used when the user attempts to create a memory map
 	// with an existing file but its size if not equal to expected size.
 	ErrBadSz = errors.New("cannot create mmap with empty file")
+
+	// ErrRange is used when the user attempts to sync a range which is not
+	// within the memory map.
+	ErrRange = errors.New("range is not within the memory map")
+
+	// ErrAnon is used when the user attempts to resize an anonymous map.
+	ErrAnon = errors.New("cannot resize anonymous memory map")
+
+	// ErrPrivate is used when the user attempts to resize a private map.
+	// Resizing it would change the size of the file.
+	ErrPrivate = errors.New("cannot resize private memory map")
+
+	// ErrLockLimit is used when memory pages cannot be locked because it would
+	// exceed the limit of locked memory (RLIMIT_MEMLOCK on unix systems).
+	ErrLockLimit = errors.New("locked memory limit exceeded")
+
+	// ErrName is used when the shared memory name is empty or has slashes.
+	ErrName = errors.New("invalid shared memory name")
+
+	// ErrAdvice is used when the advice is not one of the Advice constants.
+	ErrAdvice = errors.New("unknown memory map advice")
+)
+
+// Advice tells the kernel how the memory map is going to be used.
+type Advice int
+
+// Memory map advice values (see madvise)
+const (
+	// Normal removes previous advice.
+	Normal Advice = iota
+
+	// Sequential expects pages to be accessed in order. The kernel can read
+	// ahead more aggressively and free pages soon after they're accessed.
+	Sequential
+
+	// Random expects pages to be accessed in random order. Read ahead is
+	// reduced.
+	Random
+
+	// WillNeed expects pages to be accessed soon. The kernel can start
+	// reading them in the background.
+	WillNeed
+
+	// DontNeed expects pages not to be accessed soon. The kernel can free
+	// resources used for them. Changes are not lost for shared file maps.
+	DontNeed
 )
 
 // Map is a struct which abstracts memory map system calls and provides a fast
 // and easy to use api. The Map should be unmapped when not in use.
 type Map struct {
-	Data []byte
-	hlen uintptr
-	hadr uintptr
+	Data   []byte
+	path   string
+	anon   bool
+	priv   bool
+	rdonly bool
+	hlen   uintptr
+	hadr   uintptr
+
+	// handles used on windows to flush and unmap
+	// and the name of a shared file mapping
+	hmap   uintptr
+	hfile  uintptr
+	name   string
+	locked bool
+
+	// set when the memory map was created or moved with raw system
+	// calls and it should be unmapped with munmap (not syscall.Munmap)
+	raw bool
+}
+
+// LockPolicy decides whether memory maps are locked in physical memory
+// when they're created.
+type LockPolicy int
+
+// Lock policies
+const (
+	// LockDisabled does not lock memory maps.
+	LockDisabled LockPolicy = iota
+
+	// LockBestEffort tries to lock memory maps but ignores failures.
+	// Use Locked to check how much memory is resident.
+	LockBestEffort
+
+	// LockRequired locks memory maps and fails if they cannot be locked.
+	LockRequired
+)
+
+// Options for memory maps
+type Options struct {
+	// Lock decides whether the memory map is locked in physical memory.
+	Lock LockPolicy
+
+	// HugePages requests transparent huge pages for the memory map to reduce
+	// TLB misses with large maps. The memory map is aligned to the huge page
+	// size. This is only supported on linux and ignored on other platforms.
+	HugePages bool
+
+	// Private creates a copy-on-write memory map. Changes are only visible
+	// to this map and they're never written to the file. Sync methods do
+	// nothing with private maps.
+	Private bool
+
+	// ReadOnly maps the file without write access so files opened without
+	// write permission can be mapped. The memory map must not be changed.
+	ReadOnly bool
 }
 
+// DefaultOptions is used when options are not given to Open.
+var DefaultOptions = &Options{}
+
 // New creates a new memory map struct on given path
 // A new file will be created on given path if necessary.
 // The file will be truncated to given size if it's empty.
 func New(path string, size int64) (m *Map, err error) {
+	return Open(path, size, nil)
+}
+
+// Open creates a new memory map struct on given path with options.
+// A new file will be created on given path if necessary.
+// The file will be truncated to given size if it's empty.
+func Open(path string, size int64, opts *Options) (m *Map, err error) {
 	if size == 0 {
 		return nil, ErrZeroSz
 	}
@@ -50,7 +153,7 @@ func New(path string, size int64) (m *Map, err error) {
 	// don't need this
 	defer file.Close()
 
-	m, err = MapFile(file, size)
+	m, err = OpenFile(file, size, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -61,6 +164,17 @@ func New(path string, size int64) (m *Map, err error) {
 // MapFile creates a new memory map struct from an os.File
 // The file will be truncated to given size if it's empty.
 func MapFile(file *os.File, size int64) (m *Map, err error) {
+	return OpenFile(file, size, nil)
+}
+
+// OpenFile creates a new memory map struct from an os.File with options.
+// The file will be truncated to given size if it's empty unless it's mapped
+// with the ReadOnly option.
+func OpenFile(file *os.File, size int64, opts *Options) (m *Map, err error) {
+	if opts == nil {
+		opts = DefaultOptions
+	}
+
 	if size == 0 {
 		return nil, ErrZeroSz
 	}
@@ -71,7 +185,7 @@ func MapFile(file *os.File, size int64) (m *Map, err error) {
 	}
 
 	if sz := info.Size(); sz != size {
-		if sz != 0 {
+		if sz != 0 || opts.ReadOnly {
 			// file already exists with different size
 			// this can be caused by corrupted files
 			return nil, ErrBadSz
@@ -86,57 +200,189 @@ func MapFile(file *os.File, size int64) (m *Map, err error) {
 		sz = size
 	}
 
-	fd := file.Fd()
-	data, err := syscall.Mmap(int(fd), 0, int(size), mprot, mflag)
+	return mapFile(file, size, opts)
+}
+
+// mapFile creates a memory map without checking the file size
+func mapFile(file *os.File, size int64, opts *Options) (m *Map, err error) {
+	m, err = mmap(file, size, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	m.path = file.Name()
+	m.priv = opts.Private
+
+	if opts.Lock != LockDisabled {
+		if err := m.Lock(); err != nil && opts.Lock == LockRequired {
+			m.unmap()
+			return nil, err
+		}
+	}
+
+	track(m)
+	return m, nil
+}
+
+// NewAnon creates a shared memory map which is not backed by a file. The
+// memory is initialized with zeros and it's released when the map is closed.
+// Child processes created with fork share the memory with the parent.
+func NewAnon(size int64) (m *Map, err error) {
+	if size == 0 {
+		return nil, ErrZeroSz
+	}
+
+	m, err = mmapAnon(size)
 	if err != nil {
 		return nil, err
 	}
 
-	// get slice header to get memory address and length
-	head := (*reflect.SliceHeader)(unsafe.Pointer(&data))
+	m.anon = true
+	track(m)
+	return m, nil
+}
 
-	m = &Map{
-		Data: data,
-		hadr: head.Data,
-		hlen: uintptr(head.Len),
+// OpenShared creates or opens a named shared memory region which can be
+// mapped by other processes using the same name. On unix systems, regions
+// are files in /dev/shm (or the temporary directory if it does not exist)
+// and they exist until Unlink is called. On windows, named file mappings
+// are used and they're removed when all maps are closed.
+func OpenShared(name string, size int64) (m *Map, err error) {
+	if name == "" || strings.ContainsAny(name, "/\\") {
+		return nil, ErrName
 	}
 
+	if size == 0 {
+		return nil, ErrZeroSz
+	}
+
+	m, err = openShared(name, size)
+	if err != nil {
+		return nil, err
+	}
+
+	track(m)
 	return m, nil
 }
 
+// Unlink removes a named shared memory region. Existing maps can still be
+// used until they're closed but new maps will use a new region.
+func Unlink(name string) (err error) {
+	if name == "" || strings.ContainsAny(name, "/\\") {
+		return ErrName
+	}
+
+	return unlinkShared(name)
+}
+
+// Resize changes the size of the file and the memory map. The file is opened
+// again using the path used to map it. The Data field is replaced with the new
+// mapping and slices of the old Data field must not be used after resizing it.
+// Memory pages locked with Lock may need to be locked again.
+func (m *Map) Resize(size int64) (err error) {
+	if size == 0 {
+		return ErrZeroSz
+	}
+
+	if m.anon {
+		return ErrAnon
+	}
+
+	if m.priv {
+		return ErrPrivate
+	}
+
+	if size == int64(m.hlen) {
+		return nil
+	}
+
+	file, err := os.OpenFile(m.path, os.O_RDWR, fperm)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+	return m.resize(file, size)
+}
+
 // Lock loads all memory pages in physical memory. This can take a long time for
 // larger files but access to these memory locations will be faster.
+// ErrLockLimit is returned if the locked memory limit is not large enough.
 func (m *Map) Lock() (err error) {
-	if err := syscall.Mlock(m.Data); err != nil {
-		return err
+	return m.lock()
+}
+
+// Locked returns the number of bytes of the memory map which are resident in
+// physical memory. All bytes should be resident after locking the map.
+func (m *Map) Locked() (n int64, err error) {
+	return m.resident()
+}
+
+// Advise gives advice about how the whole memory map is going to be used.
+// It's only a hint and it's ignored on platforms without madvise.
+func (m *Map) Advise(advice Advice) (err error) {
+	if advice < Normal || advice > DontNeed {
+		return ErrAdvice
 	}
 
-	return nil
+	return m.advise(advice)
 }
 
 // Sync synchronizes the memory map with the mapped file. This can be used to
 // ensure that all data is written to the disk successfully. Calling the Sync
 // method is necessary to survive OS kernel level panics and crashes.
 func (m *Map) Sync() (err error) {
-	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC, m.hadr, m.hlen, msync)
-	if errno != 0 {
-		err := syscall.Errno(errno)
-		return err
+	if m.anon || m.priv {
+		return nil
 	}
 
-	return nil
+	return m.sync()
+}
+
+// SyncAsync schedules writing changes to the mapped file and returns without
+// waiting for them to be on the disk. Use Sync to make changes durable.
+func (m *Map) SyncAsync() (err error) {
+	if m.anon || m.priv {
+		return nil
+	}
+
+	return m.syncRange(0, int64(m.hlen), true)
+}
+
+// SyncRange synchronizes sz bytes starting from offset off with the mapped
+// file. This implements the fs.SyncRanger interface. Syncing only the changed
+// range can be much faster than syncing large memory maps completely.
+func (m *Map) SyncRange(sz, off int64) (err error) {
+	if off < 0 || sz < 0 || off+sz > int64(m.hlen) {
+		return ErrRange
+	}
+
+	if sz == 0 || m.anon || m.priv {
+		return nil
+	}
+
+	return m.syncRange(off, sz, false)
 }
 
 // Close unmaps data and closes the file handler. Changes done to the memory
 // map will be synced to the disk before closing to prevent data loss.
+// Closing a map which is already closed (ex. with CloseAll) does nothing.
 func (m *Map) Close() (err error) {
+	if m.Data == nil {
+		return nil
+	}
+
 	if err := m.Sync(); err != nil {
 		return err
 	}
 
-	if err := syscall.Munmap(m.Data); err != nil {
+	if err := m.unmap(); err != nil {
 		return err
 	}
 
+	untrack(m)
+	m.Data = nil
+	m.hadr = 0
+	m.hlen = 0
 	return nil
 }
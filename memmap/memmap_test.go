@@ -1,9 +1,12 @@
 package memmap
 
 import (
+	"io/ioutil"
 	"os"
 	"reflect"
+	"runtime"
 	"testing"
+	"unsafe"
 )
 
 var (
@@ -130,3 +133,315 @@ func TestData(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestResize(t *testing.T) {
+	if err := os.RemoveAll(tmpfile); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpfile)
+
+	mmap, err := New(tmpfile, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copy(mmap.Data, "hello")
+	copy(mmap.Data[4090:], "world")
+
+	if err := mmap.Resize(4 * 4096); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mmap.Data) != 4*4096 || string(mmap.Data[:5]) != "hello" ||
+		string(mmap.Data[4090:4095]) != "world" {
+		t.Fatal("wrong data after growing")
+	}
+
+	mmap.Data[4*4096-1] = 1
+
+	if err := mmap.Resize(100); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mmap.Data) != 100 || string(mmap.Data[:5]) != "hello" {
+		t.Fatal("wrong data after shrinking")
+	}
+
+	if err := mmap.Resize(0); err != ErrZeroSz {
+		t.Fatal("expected ErrZeroSz")
+	}
+
+	if err := mmap.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(tmpfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Size() != 100 {
+		t.Fatal("wrong file size")
+	}
+}
+
+func TestSyncRange(t *testing.T) {
+	if err := os.RemoveAll(tmpfile); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpfile)
+
+	mmap, err := New(tmpfile, 3*4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer mmap.Close()
+
+	copy(mmap.Data[5000:], "hello")
+	if err := mmap.SyncRange(5, 5000); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mmap.SyncRange(10, 3*4096-5); err != ErrRange {
+		t.Fatal("expected ErrRange")
+	}
+
+	if err := mmap.SyncAsync(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(tmpfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data[5000:5005]) != "hello" {
+		t.Fatal("wrong data")
+	}
+}
+
+func TestAdvise(t *testing.T) {
+	if err := os.RemoveAll(tmpfile); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpfile)
+
+	mmap, err := New(tmpfile, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer mmap.Close()
+
+	copy(mmap.Data, "hello")
+
+	for _, a := range []Advice{Sequential, Random, WillNeed, DontNeed, Normal} {
+		if err := mmap.Advise(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if string(mmap.Data[:5]) != "hello" {
+		t.Fatal("wrong data")
+	}
+
+	if err := mmap.Advise(Advice(100)); err != ErrAdvice {
+		t.Fatal("expected ErrAdvice")
+	}
+}
+
+func TestHugePages(t *testing.T) {
+	if err := os.RemoveAll(tmpfile); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpfile)
+
+	mmap, err := Open(tmpfile, 3<<20+10, &Options{HugePages: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := uintptr(unsafe.Pointer(&mmap.Data[0]))
+	if runtime.GOOS == "linux" && addr%(2<<20) != 0 {
+		t.Fatal("memory map should be aligned")
+	}
+
+	copy(mmap.Data[3<<20:], "hello")
+
+	if err := mmap.Resize(5 << 20); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mmap.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(tmpfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data) != 5<<20 || string(data[3<<20:3<<20+5]) != "hello" {
+		t.Fatal("wrong data")
+	}
+}
+
+func TestNewAnon(t *testing.T) {
+	if _, err := NewAnon(0); err != ErrZeroSz {
+		t.Fatal("expected ErrZeroSz")
+	}
+
+	mmap, err := NewAnon(4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mmap.Data) != 4096 || mmap.Data[100] != 0 {
+		t.Fatal("wrong data")
+	}
+
+	copy(mmap.Data, "hello")
+
+	if err := mmap.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mmap.Resize(8192); err != ErrAnon {
+		t.Fatal("expected ErrAnon")
+	}
+
+	if err := mmap.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenShared(t *testing.T) {
+	name := "test-memmap-shared"
+	Unlink(name)
+
+	if _, err := OpenShared("a/b", 10); err != ErrName {
+		t.Fatal("expected ErrName")
+	}
+
+	m1, err := OpenShared(name, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer m1.Close()
+
+	m2, err := OpenShared(name, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer m2.Close()
+
+	copy(m1.Data, "hello")
+	if string(m2.Data[:5]) != "hello" {
+		t.Fatal("memory should be shared")
+	}
+
+	if err := Unlink(name); err != nil {
+		t.Fatal(err)
+	}
+
+	m3, err := OpenShared(name, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer Unlink(name)
+	defer m3.Close()
+
+	if m3.Data[0] != 0 {
+		t.Fatal("expected a new region")
+	}
+}
+
+func TestPrivate(t *testing.T) {
+	if err := os.RemoveAll(tmpfile); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpfile)
+
+	if err := ioutil.WriteFile(tmpfile, []byte("hello world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(tmpfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	mmap, err := OpenFile(file, 11, &Options{Private: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copy(mmap.Data, "HELLO")
+	if string(mmap.Data) != "HELLO world" {
+		t.Fatal("wrong data")
+	}
+
+	if err := mmap.Resize(100); err != ErrPrivate {
+		t.Fatal("expected ErrPrivate")
+	}
+
+	if err := mmap.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(tmpfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "hello world" {
+		t.Fatal("changes should not be written")
+	}
+}
+
+func TestLockPolicy(t *testing.T) {
+	if err := os.RemoveAll(tmpfile); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpfile)
+
+	mmap, err := Open(tmpfile, 3*4096, &Options{Lock: LockBestEffort})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer mmap.Close()
+
+	n, err := mmap.Locked()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n < 0 || n > 3*4096 {
+		t.Fatal("wrong locked size")
+	}
+
+	if err := mmap.Lock(); err == ErrLockLimit {
+		t.Skip("locked memory limit is too small")
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := mmap.Locked(); err != nil {
+		t.Fatal(err)
+	} else if n != 3*4096 {
+		t.Fatal("all pages should be resident")
+	}
+}
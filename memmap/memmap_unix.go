@@ -0,0 +1,204 @@
+//go:build !windows
+// +build !windows
+
+package memmap
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	mflag  = syscall.MAP_SHARED
+	mprot  = syscall.PROT_READ | syscall.PROT_WRITE
+	msync  = syscall.MS_SYNC
+	masync = syscall.MS_ASYNC
+)
+
+// mmap maps the file to memory
+func mmap(file *os.File, size int64, opts *Options) (m *Map, err error) {
+	flag := mflag
+	if opts.Private {
+		flag = syscall.MAP_PRIVATE
+	}
+
+	prot := mprot
+	if opts.ReadOnly {
+		prot = syscall.PROT_READ
+	}
+
+	if opts.HugePages {
+		return mmapHuge(file, size, prot, flag)
+	}
+
+	fd := file.Fd()
+	data, err := syscall.Mmap(int(fd), 0, int(size), prot, flag)
+	if err != nil {
+		return nil, err
+	}
+
+	m = &Map{}
+	m.set(data)
+	return m, nil
+}
+
+// mmapAnon creates a shared anonymous memory map
+func mmapAnon(size int64) (m *Map, err error) {
+	data, err := syscall.Mmap(-1, 0, int(size), mprot, mflag|syscall.MAP_ANON)
+	if err != nil {
+		return nil, err
+	}
+
+	m = &Map{}
+	m.set(data)
+	return m, nil
+}
+
+// shmPath returns the path of a shared memory file
+func shmPath(name string) (path string) {
+	dir := "/dev/shm"
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		dir = os.TempDir()
+	}
+
+	return filepath.Join(dir, name)
+}
+
+// openShared maps a file in the shared memory directory
+func openShared(name string, size int64) (m *Map, err error) {
+	return Open(shmPath(name), size, nil)
+}
+
+// unlinkShared removes a file in the shared memory directory
+func unlinkShared(name string) (err error) {
+	return os.Remove(shmPath(name))
+}
+
+// lock locks memory pages with mlock
+func (m *Map) lock() (err error) {
+	err = syscall.Mlock(m.Data)
+	if err == syscall.ENOMEM || err == syscall.EAGAIN {
+		return ErrLockLimit
+	} else if err != nil {
+		return err
+	}
+
+	m.locked = true
+	return nil
+}
+
+// resident counts resident memory pages with mincore
+func (m *Map) resident() (n int64, err error) {
+	ps := int64(os.Getpagesize())
+	vec := make([]byte, (int64(m.hlen)+ps-1)/ps)
+
+	_, _, errno := syscall.Syscall(syscall.SYS_MINCORE, m.hadr, m.hlen, uintptr(unsafe.Pointer(&vec[0])))
+	if errno != 0 {
+		return 0, syscall.Errno(errno)
+	}
+
+	for _, v := range vec {
+		if v&1 != 0 {
+			n += ps
+		}
+	}
+
+	if n > int64(m.hlen) {
+		n = int64(m.hlen)
+	}
+
+	return n, nil
+}
+
+// advise gives advice with madvise
+func (m *Map) advise(advice Advice) (err error) {
+	var flag int
+	switch advice {
+	case Normal:
+		flag = syscall.MADV_NORMAL
+	case Sequential:
+		flag = syscall.MADV_SEQUENTIAL
+	case Random:
+		flag = syscall.MADV_RANDOM
+	case WillNeed:
+		flag = syscall.MADV_WILLNEED
+	case DontNeed:
+		flag = syscall.MADV_DONTNEED
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_MADVISE, m.hadr, m.hlen, uintptr(flag))
+	if errno != 0 {
+		return syscall.Errno(errno)
+	}
+
+	return nil
+}
+
+// sync flushes the memory map with msync
+func (m *Map) sync() (err error) {
+	return m.syncRange(0, int64(m.hlen), false)
+}
+
+// syncRange flushes a range of the memory map with msync. The start
+// of the range is moved to a page boundary as required by msync.
+func (m *Map) syncRange(off, sz int64, async bool) (err error) {
+	ps := int64(os.Getpagesize())
+	start := off / ps * ps
+	sz += off - start
+
+	flag := msync
+	if async {
+		flag = masync
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC, m.hadr+uintptr(start), uintptr(sz), uintptr(flag))
+	if errno != 0 {
+		return syscall.Errno(errno)
+	}
+
+	return nil
+}
+
+// unmap unmaps the memory map
+func (m *Map) unmap() (err error) {
+	if !m.raw {
+		return syscall.Munmap(m.Data)
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_MUNMAP, m.hadr, m.hlen, 0)
+	if errno != 0 {
+		return syscall.Errno(errno)
+	}
+
+	return nil
+}
+
+// resize truncates the file and remaps it. The file is grown before
+// remapping it and shrunk after remapping to avoid accessing memory
+// pages which are not backed by the file.
+func (m *Map) resize(file *os.File, size int64) (err error) {
+	if size > int64(m.hlen) {
+		if err := file.Truncate(size); err != nil {
+			return err
+		}
+
+		return m.remap(file, size)
+	}
+
+	if err := m.remap(file, size); err != nil {
+		return err
+	}
+
+	return file.Truncate(size)
+}
+
+// set updates data and header fields using the memory map
+func (m *Map) set(data []byte) {
+	head := (*reflect.SliceHeader)(unsafe.Pointer(&data))
+	m.Data = data
+	m.hadr = head.Data
+	m.hlen = uintptr(head.Len)
+}
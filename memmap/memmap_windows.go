@@ -0,0 +1,195 @@
+package memmap
+
+import (
+	"os"
+	"reflect"
+	"syscall"
+	"unsafe"
+)
+
+// mmap maps the file to memory with CreateFileMapping and MapViewOfFile.
+// The file handle is duplicated because the file can be closed after
+// mapping it but it's required to flush file buffers on Sync.
+// Huge pages are not supported.
+func mmap(file *os.File, size int64, opts *Options) (m *Map, err error) {
+	var hfile syscall.Handle
+	proc, _ := syscall.GetCurrentProcess()
+	fh := syscall.Handle(file.Fd())
+	if err := syscall.DuplicateHandle(proc, fh, proc, &hfile, 0, false, syscall.DUPLICATE_SAME_ACCESS); err != nil {
+		return nil, os.NewSyscallError("DuplicateHandle", err)
+	}
+
+	m = &Map{hfile: uintptr(hfile), priv: opts.Private, rdonly: opts.ReadOnly}
+	if err := m.view(size); err != nil {
+		syscall.CloseHandle(hfile)
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// mmapAnon creates a file mapping backed by the paging file
+func mmapAnon(size int64) (m *Map, err error) {
+	m = &Map{hfile: uintptr(syscall.InvalidHandle)}
+	if err := m.view(size); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// openShared creates or opens a named file mapping backed by the paging file
+func openShared(name string, size int64) (m *Map, err error) {
+	m = &Map{hfile: uintptr(syscall.InvalidHandle), anon: true, name: name}
+	if err := m.view(size); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// unlinkShared does nothing because named file mappings are removed
+// when all handles are closed
+func unlinkShared(name string) (err error) {
+	return nil
+}
+
+// view creates a file mapping and maps a view of given size
+func (m *Map) view(size int64) (err error) {
+	var name *uint16
+	if m.name != "" {
+		if name, err = syscall.UTF16PtrFromString(`Local\` + m.name); err != nil {
+			return err
+		}
+	}
+
+	prot, access := uint32(syscall.PAGE_READWRITE), uint32(syscall.FILE_MAP_WRITE)
+	if m.rdonly {
+		prot, access = syscall.PAGE_READONLY, syscall.FILE_MAP_READ
+	} else if m.priv {
+		prot, access = syscall.PAGE_WRITECOPY, syscall.FILE_MAP_COPY
+	}
+
+	hi, lo := uint32(size>>32), uint32(size)
+	hmap, err := syscall.CreateFileMapping(syscall.Handle(m.hfile), nil, prot, hi, lo, name)
+	if err != nil {
+		return os.NewSyscallError("CreateFileMapping", err)
+	}
+
+	addr, err := syscall.MapViewOfFile(hmap, access, 0, 0, uintptr(size))
+	if err != nil {
+		syscall.CloseHandle(hmap)
+		return os.NewSyscallError("MapViewOfFile", err)
+	}
+
+	var data []byte
+	head := (*reflect.SliceHeader)(unsafe.Pointer(&data))
+	head.Data = addr
+	head.Len = int(size)
+	head.Cap = int(size)
+
+	m.Data = data
+	m.hadr = addr
+	m.hlen = uintptr(size)
+	m.hmap = uintptr(hmap)
+	return nil
+}
+
+// error returned by VirtualLock when the working set is too small
+const errWorkingSetQuota = syscall.Errno(1453)
+
+// lock locks memory pages with VirtualLock
+func (m *Map) lock() (err error) {
+	err = syscall.VirtualLock(m.hadr, m.hlen)
+	if err == errWorkingSetQuota {
+		return ErrLockLimit
+	} else if err != nil {
+		return os.NewSyscallError("VirtualLock", err)
+	}
+
+	m.locked = true
+	return nil
+}
+
+// resident returns the size of the map if it's locked. Checking which
+// pages are resident is not supported on windows.
+func (m *Map) resident() (n int64, err error) {
+	if m.locked {
+		return int64(m.hlen), nil
+	}
+
+	return 0, nil
+}
+
+// advise does nothing because windows does not have madvise
+func (m *Map) advise(advice Advice) (err error) {
+	return nil
+}
+
+// sync flushes the whole view
+func (m *Map) sync() (err error) {
+	return m.syncRange(0, int64(m.hlen), false)
+}
+
+// syncRange writes dirty pages with FlushViewOfFile and flushes file buffers
+// because FlushViewOfFile does not wait for data to be on the disk. File
+// buffers are not flushed in async mode.
+func (m *Map) syncRange(off, sz int64, async bool) (err error) {
+	if err := syscall.FlushViewOfFile(m.hadr+uintptr(off), uintptr(sz)); err != nil {
+		return os.NewSyscallError("FlushViewOfFile", err)
+	}
+
+	if async {
+		return nil
+	}
+
+	if err := syscall.FlushFileBuffers(syscall.Handle(m.hfile)); err != nil {
+		return os.NewSyscallError("FlushFileBuffers", err)
+	}
+
+	return nil
+}
+
+// resize maps the file again with a new size. Files cannot be truncated
+// while they're mapped so the view is removed before truncating the file.
+func (m *Map) resize(file *os.File, size int64) (err error) {
+	if err := m.sync(); err != nil {
+		return err
+	}
+
+	if err := m.unview(); err != nil {
+		return err
+	}
+
+	if err := file.Truncate(size); err != nil {
+		return err
+	}
+
+	return m.view(size)
+}
+
+// unview unmaps the view and closes the file mapping
+func (m *Map) unview() (err error) {
+	if err := syscall.UnmapViewOfFile(m.hadr); err != nil {
+		return os.NewSyscallError("UnmapViewOfFile", err)
+	}
+
+	if err := syscall.CloseHandle(syscall.Handle(m.hmap)); err != nil {
+		return os.NewSyscallError("CloseHandle", err)
+	}
+
+	return nil
+}
+
+// unmap unmaps the view and closes handles
+func (m *Map) unmap() (err error) {
+	if err := m.unview(); err != nil {
+		return err
+	}
+
+	if m.anon {
+		return nil
+	}
+
+	return syscall.CloseHandle(syscall.Handle(m.hfile))
+}
@@ -0,0 +1,87 @@
+package memmap
+
+import (
+	"sync"
+)
+
+// registry tracks open memory maps when it's enabled
+var registry = struct {
+	sync.Mutex
+	enabled bool
+	maps    map[*Map]struct{}
+}{
+	maps: map[*Map]struct{}{},
+}
+
+// EnableRegistry starts tracking memory maps created after calling it in a
+// process-wide registry until they're closed. Tracked maps can be synced or
+// closed together with SyncAll and CloseAll (ex. in a shutdown hook when the
+// process receives SIGTERM). The registry is disabled by default.
+func EnableRegistry() {
+	registry.Lock()
+	registry.enabled = true
+	registry.Unlock()
+}
+
+// DisableRegistry stops tracking new memory maps and forgets tracked maps.
+func DisableRegistry() {
+	registry.Lock()
+	registry.enabled = false
+	registry.maps = map[*Map]struct{}{}
+	registry.Unlock()
+}
+
+// SyncAll synchronizes all tracked memory maps with their files. It tries to
+// sync all maps even if some of them fail and returns the first error.
+func SyncAll() (err error) {
+	for _, m := range tracked() {
+		if e := m.Sync(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// CloseAll syncs and closes all tracked memory maps. It tries to close all
+// maps even if some of them fail and returns the first error. Closed maps
+// must not be used after this, so it should only be used when the process
+// is terminating and other goroutines are no longer using memory maps.
+func CloseAll() (err error) {
+	for _, m := range tracked() {
+		if e := m.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// track adds a memory map to the registry if it's enabled
+func track(m *Map) {
+	registry.Lock()
+	if registry.enabled {
+		registry.maps[m] = struct{}{}
+	}
+	registry.Unlock()
+}
+
+// untrack removes a memory map from the registry
+func untrack(m *Map) {
+	registry.Lock()
+	delete(registry.maps, m)
+	registry.Unlock()
+}
+
+// tracked returns all tracked memory maps
+func tracked() (maps []*Map) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	maps = make([]*Map, 0, len(registry.maps))
+	for m := range registry.maps {
+		maps = append(maps, m)
+	}
+
+	return maps
+}
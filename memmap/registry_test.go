@@ -0,0 +1,75 @@
+package memmap
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	if err := os.RemoveAll(tmpfile); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpfile)
+
+	EnableRegistry()
+	defer DisableRegistry()
+
+	m1, err := New(tmpfile, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer m1.Close()
+
+	m2, err := NewAnon(4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer m2.Close()
+
+	if n := len(tracked()); n != 2 {
+		t.Fatal("wrong number of tracked maps", n)
+	}
+
+	copy(m1.Data, "hello")
+	if err := SyncAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(tmpfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data[:5]) != "hello" {
+		t.Fatal("wrong data")
+	}
+
+	if err := CloseAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(tracked()); n != 0 {
+		t.Fatal("closed maps should not be tracked")
+	}
+
+	if m1.Data != nil || m2.Data != nil {
+		t.Fatal("maps should be closed")
+	}
+}
+
+func TestRegistryDisabled(t *testing.T) {
+	m, err := NewAnon(4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer m.Close()
+
+	if n := len(tracked()); n != 0 {
+		t.Fatal("maps should not be tracked")
+	}
+}
@@ -0,0 +1,31 @@
+package memmap
+
+import (
+	"os"
+	"reflect"
+	"syscall"
+	"unsafe"
+)
+
+// flag used to allow mremap to move the memory map
+const mremapMayMove = 1
+
+// remap resizes the memory map with mremap. The mapping is moved
+// if it cannot be resized in place. Memory maps created with syscall.Mmap
+// cannot be unmapped with syscall.Munmap after this.
+func (m *Map) remap(file *os.File, size int64) (err error) {
+	addr, _, errno := syscall.Syscall6(syscall.SYS_MREMAP, m.hadr, m.hlen, uintptr(size), mremapMayMove, 0, 0)
+	if errno != 0 {
+		return syscall.Errno(errno)
+	}
+
+	var data []byte
+	head := (*reflect.SliceHeader)(unsafe.Pointer(&data))
+	head.Data = addr
+	head.Len = int(size)
+	head.Cap = int(size)
+
+	m.set(data)
+	m.raw = true
+	return nil
+}
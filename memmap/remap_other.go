@@ -0,0 +1,25 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package memmap
+
+import (
+	"os"
+	"syscall"
+)
+
+// remap unmaps and maps the file again because mremap is only
+// available on linux.
+func (m *Map) remap(file *os.File, size int64) (err error) {
+	if err := syscall.Munmap(m.Data); err != nil {
+		return err
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), mprot, mflag)
+	if err != nil {
+		return err
+	}
+
+	m.set(data)
+	return nil
+}
@@ -0,0 +1,54 @@
+// Command packgen generates record packing code from a schema file.
+// See the packgen package for the schema format.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/kadirahq/go-tools/packgen"
+)
+
+func main() {
+	schema := flag.String("schema", "", "path to the schema file (json)")
+	out := flag.String("out", "", "path to the output file (default: stdout)")
+	flag.Parse()
+
+	if *schema == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*schema, *out); err != nil {
+		fmt.Fprintln(os.Stderr, "packgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(schema, out string) (err error) {
+	file, err := os.Open(schema)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	s, err := packgen.ReadSchema(file)
+	if err != nil {
+		return err
+	}
+
+	src, err := packgen.Generate(s)
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+
+	return ioutil.WriteFile(out, src, 0644)
+}
@@ -0,0 +1,4 @@
+// Package example has records generated by packgen from schema.json.
+package example
+
+//go:generate go run ../cmd/packgen -schema schema.json -out records.go
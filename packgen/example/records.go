@@ -0,0 +1,57 @@
+// Code generated by packgen. DO NOT EDIT.
+
+package example
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// PointSize is the packed size of Point in bytes.
+const PointSize = 40
+
+// Point is a fixed-size record.
+type Point struct {
+	Time   int64
+	Value  float64
+	Count  uint32
+	Weight float32
+	Flags  uint8
+	Valid  bool
+	Delta  int16
+	ID     [12]byte
+}
+
+// Size returns the packed size of the record in bytes.
+func (r *Point) Size() int64 {
+	return PointSize
+}
+
+// Pack writes the record to the slice.
+func (r *Point) Pack(p []byte) {
+	_ = p[PointSize-1]
+	binary.LittleEndian.PutUint64(p[0:], uint64(r.Time))
+	binary.LittleEndian.PutUint64(p[8:], math.Float64bits(r.Value))
+	binary.LittleEndian.PutUint32(p[16:], uint32(r.Count))
+	binary.LittleEndian.PutUint32(p[20:], math.Float32bits(r.Weight))
+	p[24] = byte(r.Flags)
+	p[25] = 0
+	if r.Valid {
+		p[25] = 1
+	}
+	binary.LittleEndian.PutUint16(p[26:], uint16(r.Delta))
+	copy(p[28:40], r.ID[:])
+}
+
+// Unpack reads the record from the slice.
+func (r *Point) Unpack(p []byte) {
+	_ = p[PointSize-1]
+	r.Time = int64(binary.LittleEndian.Uint64(p[0:]))
+	r.Value = math.Float64frombits(binary.LittleEndian.Uint64(p[8:]))
+	r.Count = uint32(binary.LittleEndian.Uint32(p[16:]))
+	r.Weight = math.Float32frombits(binary.LittleEndian.Uint32(p[20:]))
+	r.Flags = uint8(p[24])
+	r.Valid = p[25] != 0
+	r.Delta = int16(binary.LittleEndian.Uint16(p[26:]))
+	copy(r.ID[:], p[28:40])
+}
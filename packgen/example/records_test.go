@@ -0,0 +1,71 @@
+package example
+
+import (
+	"testing"
+
+	"github.com/kadirahq/go-tools/fs/memstore"
+	"github.com/kadirahq/go-tools/packgen"
+)
+
+func point() *Point {
+	return &Point{
+		Time:   -123456789,
+		Value:  3.14,
+		Count:  42,
+		Weight: 0.5,
+		Flags:  7,
+		Valid:  true,
+		Delta:  -2,
+		ID:     [12]byte{1, 2, 3},
+	}
+}
+
+func TestPackUnpack(t *testing.T) {
+	p := make([]byte, PointSize)
+	in := point()
+	in.Pack(p)
+
+	out := &Point{}
+	out.Unpack(p)
+
+	if *out != *in {
+		t.Fatal("wrong record", out)
+	}
+
+	if err := packgen.Pack(in, p[1:]); err != packgen.ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := memstore.New()
+	in := point()
+
+	if err := packgen.WriteAt(s, in, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	out := &Point{}
+	if err := packgen.ReadAt(s, out, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	if *out != *in {
+		t.Fatal("wrong record", out)
+	}
+}
+
+func TestAllocs(t *testing.T) {
+	p := make([]byte, PointSize)
+	in := point()
+	out := &Point{}
+
+	n := testing.AllocsPerRun(100, func() {
+		in.Pack(p)
+		out.Unpack(p)
+	})
+
+	if n != 0 {
+		t.Fatal("unexpected allocations", n)
+	}
+}
@@ -0,0 +1,212 @@
+package packgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+var (
+	// ErrSchema is returned when the schema is not valid.
+	ErrSchema = errors.New("invalid schema")
+)
+
+// Schema describes records to generate in a package.
+type Schema struct {
+	Package string  `json:"package"`
+	Types   []*Type `json:"records"`
+}
+
+// Type describes a record type with fixed-size fields.
+type Type struct {
+	Name   string   `json:"name"`
+	Fields []*Field `json:"fields"`
+}
+
+// Field is a record field. Supported types are bool, intN, uintN (N is 8, 16,
+// 32 or 64), float32, float64 and byte arrays (ex. "[16]byte").
+type Field struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// ReadSchema reads a schema encoded as JSON. For example:
+//
+//	{"package": "points", "records": [{"name": "Point", "fields": [
+//	  {"name": "Time", "type": "int64"},
+//	  {"name": "Value", "type": "float64"}
+//	]}]}
+func ReadSchema(r io.Reader) (s *Schema, err error) {
+	s = &Schema{}
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// field has information used by the template to generate code for a field
+type field struct {
+	Name   string
+	Type   string
+	Off    int64
+	Size   int64
+	Pack   string
+	Unpack string
+}
+
+// record has information used by the template to generate code for a record
+type record struct {
+	Name   string
+	Size   int64
+	Fields []*field
+}
+
+// Generate generates Go source code for all records in the schema.
+func Generate(s *Schema) (src []byte, err error) {
+	if !token.IsIdentifier(s.Package) {
+		return nil, fmt.Errorf("%v: bad package name %q", ErrSchema, s.Package)
+	}
+
+	data := struct {
+		Package string
+		Binary  bool
+		Math    bool
+		Records []*record
+	}{Package: s.Package}
+
+	names := map[string]bool{}
+	for _, t := range s.Types {
+		if !token.IsExported(t.Name) || !token.IsIdentifier(t.Name) || names[t.Name] {
+			return nil, fmt.Errorf("%v: bad record name %q", ErrSchema, t.Name)
+		}
+
+		names[t.Name] = true
+		rec := &record{Name: t.Name}
+		fnames := map[string]bool{}
+
+		for _, f := range t.Fields {
+			if !token.IsIdentifier(f.Name) || fnames[f.Name] {
+				return nil, fmt.Errorf("%v: bad field name %q in %s", ErrSchema, f.Name, t.Name)
+			}
+
+			fnames[f.Name] = true
+			fd, err := encoder(f, rec.Size)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %s.%s", err, t.Name, f.Name)
+			}
+
+			data.Binary = data.Binary || fd.Size > 1 && !strings.HasPrefix(fd.Type, "[")
+			data.Math = data.Math || strings.HasPrefix(fd.Type, "float")
+
+			rec.Fields = append(rec.Fields, fd)
+			rec.Size += fd.Size
+		}
+
+		if rec.Size == 0 {
+			return nil, fmt.Errorf("%v: record %s has no fields", ErrSchema, t.Name)
+		}
+
+		data.Records = append(data.Records, rec)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return nil, err
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+// encoder creates pack and unpack statements for the field
+func encoder(f *Field, off int64) (fd *field, err error) {
+	fd = &field{Name: f.Name, Type: f.Type, Off: off}
+	p := fmt.Sprintf("p[%d:]", off)
+	v := "r." + f.Name
+
+	switch f.Type {
+	case "bool":
+		fd.Size = 1
+		fd.Pack = fmt.Sprintf("p[%d] = 0\nif %s {\np[%d] = 1\n}", off, v, off)
+		fd.Unpack = fmt.Sprintf("%s = p[%d] != 0", v, off)
+	case "int8", "uint8", "byte":
+		fd.Size = 1
+		fd.Pack = fmt.Sprintf("p[%d] = byte(%s)", off, v)
+		fd.Unpack = fmt.Sprintf("%s = %s(p[%d])", v, f.Type, off)
+	case "int16", "uint16", "int32", "uint32", "int64", "uint64":
+		bits := strings.TrimPrefix(strings.TrimPrefix(f.Type, "u"), "int")
+		n, _ := strconv.Atoi(bits)
+		fd.Size = int64(n / 8)
+		fd.Pack = fmt.Sprintf("binary.LittleEndian.PutUint%s(%s, uint%s(%s))", bits, p, bits, v)
+		fd.Unpack = fmt.Sprintf("%s = %s(binary.LittleEndian.Uint%s(%s))", v, f.Type, bits, p)
+	case "float32", "float64":
+		bits := strings.TrimPrefix(f.Type, "float")
+		n, _ := strconv.Atoi(bits)
+		fd.Size = int64(n / 8)
+		fd.Pack = fmt.Sprintf("binary.LittleEndian.PutUint%s(%s, math.Float%sbits(%s))", bits, p, bits, v)
+		fd.Unpack = fmt.Sprintf("%s = math.Float%sfrombits(binary.LittleEndian.Uint%s(%s))", v, bits, bits, p)
+	default:
+		if !strings.HasPrefix(f.Type, "[") || !strings.HasSuffix(f.Type, "]byte") {
+			return nil, fmt.Errorf("%v: unsupported type %q", ErrSchema, f.Type)
+		}
+
+		n, err := strconv.Atoi(f.Type[1 : len(f.Type)-5])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("%v: unsupported type %q", ErrSchema, f.Type)
+		}
+
+		fd.Size = int64(n)
+		fd.Pack = fmt.Sprintf("copy(p[%d:%d], %s[:])", off, off+fd.Size, v)
+		fd.Unpack = fmt.Sprintf("copy(%s[:], p[%d:%d])", v, off, off+fd.Size)
+	}
+
+	return fd, nil
+}
+
+var tmpl = template.Must(template.New("packgen").Parse(`// Code generated by packgen. DO NOT EDIT.
+
+package {{.Package}}
+
+{{if or .Binary .Math}}import (
+{{if .Binary}}"encoding/binary"{{end}}
+{{if .Math}}"math"{{end}}
+)
+{{end}}
+
+{{range .Records}}
+// {{.Name}}Size is the packed size of {{.Name}} in bytes.
+const {{.Name}}Size = {{.Size}}
+
+// {{.Name}} is a fixed-size record.
+type {{.Name}} struct {
+{{range .Fields}}{{.Name}} {{.Type}}
+{{end}}
+}
+
+// Size returns the packed size of the record in bytes.
+func (r *{{.Name}}) Size() int64 {
+return {{.Name}}Size
+}
+
+// Pack writes the record to the slice.
+func (r *{{.Name}}) Pack(p []byte) {
+_ = p[{{.Name}}Size-1]
+{{range .Fields}}{{.Pack}}
+{{end -}}
+}
+
+// Unpack reads the record from the slice.
+func (r *{{.Name}}) Unpack(p []byte) {
+_ = p[{{.Name}}Size-1]
+{{range .Fields}}{{.Unpack}}
+{{end -}}
+}
+{{end}}
+`))
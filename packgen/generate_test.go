@@ -0,0 +1,60 @@
+package packgen
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGenerateExample(t *testing.T) {
+	file, err := os.Open("example/schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	s, err := ReadSchema(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := Generate(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp, err := ioutil.ReadFile("example/records.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run "go generate" in the example directory after changing templates
+	if !bytes.Equal(src, exp) {
+		t.Fatal("generated code is different from example/records.go")
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	cases := []string{
+		`{"package": "a-b", "records": []}`,
+		`{"package": "a", "records": [{"name": "point", "fields": [{"name": "A", "type": "int8"}]}]}`,
+		`{"package": "a", "records": [{"name": "P", "fields": []}]}`,
+		`{"package": "a", "records": [{"name": "P", "fields": [{"name": "A", "type": "string"}]}]}`,
+		`{"package": "a", "records": [{"name": "P", "fields": [{"name": "A", "type": "[0]byte"}]}]}`,
+		`{"package": "a", "records": [{"name": "P", "fields": [{"name": "A", "type": "int8"}, {"name": "A", "type": "int8"}]}]}`,
+	}
+
+	for i, c := range cases {
+		s, err := ReadSchema(strings.NewReader(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := Generate(s); err == nil || !strings.HasPrefix(err.Error(), ErrSchema.Error()) {
+			t.Fatal("expected ErrSchema", i, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Package packgen generates functions which pack fixed-size records into byte
+// slices and unpack them without reflection or allocations. Records are
+// described with a schema and generated with the packgen command:
+//
+//	go run github.com/kadirahq/go-tools/packgen/cmd/packgen -schema s.json -out records.go
+//
+// Generated types implement the Record interface. Use WriteAt and ReadAt to
+// pack records directly into slices provided by a store (using SliceAt).
+package packgen
+
+import (
+	"errors"
+	"io"
+
+	"github.com/kadirahq/go-tools/fs"
+)
+
+var (
+	// ErrSize is returned when a slice is smaller than the record size.
+	ErrSize = errors.New("slice is smaller than the record")
+)
+
+// Record is implemented by generated record types.
+type Record interface {
+	// Size returns the packed size of the record in bytes.
+	Size() int64
+
+	// Pack writes the record to the slice. The slice should be large enough.
+	Pack(p []byte)
+
+	// Unpack reads the record from the slice. The slice should be large enough.
+	Unpack(p []byte)
+}
+
+// Store is a store which can provide slices of its data (ex. segments.Store).
+type Store interface {
+	io.ReaderAt
+	io.WriterAt
+	fs.SlicerAt
+}
+
+// WriteAt packs the record directly into the store at given offset. If the
+// store cannot provide a slice for the whole record (ex. the record spans two
+// segments), the record is packed into a buffer and written with WriteAt.
+func WriteAt(s Store, r Record, off int64) (err error) {
+	sz := r.Size()
+	if p, err := s.SliceAt(sz, off); err == nil && int64(len(p)) == sz {
+		r.Pack(p)
+		return nil
+	}
+
+	p := make([]byte, sz)
+	r.Pack(p)
+
+	_, err = s.WriteAt(p, off)
+	return err
+}
+
+// ReadAt unpacks the record directly from the store at given offset. If the
+// store cannot provide a slice for the whole record, it's read into a buffer.
+func ReadAt(s Store, r Record, off int64) (err error) {
+	sz := r.Size()
+	if p, err := s.SliceAt(sz, off); err == nil && int64(len(p)) == sz {
+		r.Unpack(p)
+		return nil
+	}
+
+	p := make([]byte, sz)
+	if _, err := s.ReadAt(p, off); err != nil {
+		return err
+	}
+
+	r.Unpack(p)
+	return nil
+}
+
+// Pack packs the record into the slice after checking its size.
+func Pack(r Record, p []byte) (err error) {
+	if int64(len(p)) < r.Size() {
+		return ErrSize
+	}
+
+	r.Pack(p)
+	return nil
+}
+
+// Unpack unpacks the record from the slice after checking its size.
+func Unpack(r Record, p []byte) (err error) {
+	if int64(len(p)) < r.Size() {
+		return ErrSize
+	}
+
+	r.Unpack(p)
+	return nil
+}
@@ -0,0 +1,233 @@
+// Package pager divides a segment store into fixed-size pages and keeps a
+// limited number of pages in memory. Pages are pinned while they're used and
+// unpinned pages are evicted in least recently used order. Dirty pages are
+// written back to the store when they're evicted or flushed.
+//
+// Pagers created with NewDirect use slices of the store memory (SliceAt) as
+// pages when possible. Use it with memory mapped stores (ex. segmmap) with a
+// segment size which is a multiple of the page size. Flushing a direct page
+// syncs the page range instead of copying it to the store.
+package pager
+
+import (
+	"container/list"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/kadirahq/go-tools/segments"
+)
+
+var (
+	// ErrPoolFull is returned when all pages in memory are pinned.
+	ErrPoolFull = errors.New("all pages are pinned")
+
+	// ErrNotPinned is returned when unpinning a page which is not pinned.
+	ErrNotPinned = errors.New("page is not pinned")
+
+	// ErrPageSize is returned when the page size or capacity is not valid.
+	ErrPageSize = errors.New("invalid page size or capacity")
+)
+
+// Page is a page of the store in memory.
+type Page struct {
+	ID   int64
+	Data []byte
+
+	pins   int
+	dirty  bool
+	direct bool
+	elem   *list.Element
+}
+
+// Pager keeps pages of a store in memory. All methods are safe to use
+// concurrently but the data of a page should be synchronized by the user.
+type Pager struct {
+	store segments.Store
+	psize int64
+	cap   int
+	slice bool
+	pages map[int64]*Page
+	lru   *list.List
+	mutex sync.Mutex
+}
+
+// New creates a pager for the store which keeps at most capacity pages
+// of given size in memory. Pages are read to buffers and written back.
+func New(s segments.Store, psize int64, capacity int) (p *Pager, err error) {
+	return newPager(s, psize, capacity, false)
+}
+
+// NewDirect creates a pager which uses store memory for pages. The store
+// should return its own memory from SliceAt instead of copies.
+func NewDirect(s segments.Store, psize int64, capacity int) (p *Pager, err error) {
+	return newPager(s, psize, capacity, true)
+}
+
+func newPager(s segments.Store, psize int64, capacity int, slice bool) (p *Pager, err error) {
+	if psize <= 0 || capacity <= 0 {
+		return nil, ErrPageSize
+	}
+
+	p = &Pager{
+		store: s,
+		psize: psize,
+		cap:   capacity,
+		slice: slice,
+		pages: map[int64]*Page{},
+		lru:   list.New(),
+	}
+
+	return p, nil
+}
+
+// PageSize returns the size of a page in bytes.
+func (p *Pager) PageSize() (sz int64) {
+	return p.psize
+}
+
+// Pin loads the page with given ID (if necessary) and pins it in memory.
+// The page will not be evicted until it's unpinned with Unpin.
+func (p *Pager) Pin(id int64) (pg *Page, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if pg, ok := p.pages[id]; ok {
+		pg.pins++
+		p.lru.MoveToFront(pg.elem)
+		return pg, nil
+	}
+
+	if len(p.pages) >= p.cap {
+		if err := p.evict(); err != nil {
+			return nil, err
+		}
+	}
+
+	pg, err = p.load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	pg.pins = 1
+	pg.elem = p.lru.PushFront(pg)
+	p.pages[id] = pg
+
+	return pg, nil
+}
+
+// Unpin releases a pinned page. Set dirty to true if the page was changed.
+func (p *Pager) Unpin(pg *Page, dirty bool) (err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if pg.pins <= 0 {
+		return ErrNotPinned
+	}
+
+	pg.pins--
+	pg.dirty = pg.dirty || dirty
+	return nil
+}
+
+// Flush writes all dirty pages to the store and syncs the store.
+func (p *Pager) Flush() (err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, pg := range p.pages {
+		if err := p.write(pg); err != nil {
+			return err
+		}
+	}
+
+	return p.store.Sync()
+}
+
+// Len returns the number of pages in memory.
+func (p *Pager) Len() (n int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.pages)
+}
+
+// Close flushes all dirty pages. It does not close the store.
+func (p *Pager) Close() (err error) {
+	if err := p.Flush(); err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.pages = map[int64]*Page{}
+	p.lru.Init()
+	return nil
+}
+
+// evict removes the least recently used page which is not pinned
+func (p *Pager) evict() (err error) {
+	for e := p.lru.Back(); e != nil; e = e.Prev() {
+		pg := e.Value.(*Page)
+		if pg.pins > 0 {
+			continue
+		}
+
+		if err := p.write(pg); err != nil {
+			return err
+		}
+
+		p.lru.Remove(e)
+		delete(p.pages, pg.ID)
+		return nil
+	}
+
+	return ErrPoolFull
+}
+
+// load reads a page from the store
+func (p *Pager) load(id int64) (pg *Page, err error) {
+	off := id * p.psize
+	pg = &Page{ID: id}
+
+	if p.slice {
+		if err := p.store.Ensure(off + p.psize); err != nil {
+			return nil, err
+		}
+
+		data, err := p.store.SliceAt(p.psize, off)
+		if err == nil && int64(len(data)) == p.psize {
+			pg.Data = data
+			pg.direct = true
+			return pg, nil
+		}
+	}
+
+	pg.Data = make([]byte, p.psize)
+	if _, err := p.store.ReadAt(pg.Data, off); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return pg, nil
+}
+
+// write writes a dirty page to the store
+func (p *Pager) write(pg *Page) (err error) {
+	if !pg.dirty {
+		return nil
+	}
+
+	off := pg.ID * p.psize
+	if pg.direct {
+		err = p.store.SyncRange(p.psize, off)
+	} else {
+		_, err = p.store.WriteAt(pg.Data, off)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	pg.dirty = false
+	return nil
+}
@@ -0,0 +1,130 @@
+package pager
+
+import (
+	"os"
+	"testing"
+
+	"github.com/kadirahq/go-tools/fs/memstore"
+	"github.com/kadirahq/go-tools/segments/segmmap"
+)
+
+var (
+	tmpdir = "/tmp/test-pager/"
+)
+
+func setup(t *testing.T) func() {
+	if err := os.RemoveAll(tmpdir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(tmpdir, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if err := os.RemoveAll(tmpdir); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPinEvict(t *testing.T) {
+	s := memstore.New()
+	p, err := New(s, 8, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pg0, err := p.Pin(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copy(pg0.Data, "page0")
+	if err := p.Unpin(pg0, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Unpin(pg0, false); err != ErrNotPinned {
+		t.Fatal("expected ErrNotPinned")
+	}
+
+	pg1, err := p.Pin(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// page 0 is evicted and written to the store
+	pg2, err := p.Pin(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(s.Bytes()[:5]) != "page0" {
+		t.Fatal("dirty page was not written")
+	}
+
+	if _, err := p.Pin(3); err != ErrPoolFull {
+		t.Fatal("expected ErrPoolFull")
+	}
+
+	// pinning a page again uses the same page
+	again, err := p.Pin(1)
+	if err != nil || again != pg1 {
+		t.Fatal("expected the same page", err)
+	}
+
+	p.Unpin(pg1, false)
+	p.Unpin(pg1, false)
+	p.Unpin(pg2, false)
+
+	pg0, err = p.Pin(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(pg0.Data[:5]) != "page0" {
+		t.Fatal("wrong page data", pg0.Data)
+	}
+
+	if p.Len() != 2 {
+		t.Fatal("wrong length", p.Len())
+	}
+}
+
+func TestDirect(t *testing.T) {
+	defer setup(t)()
+
+	s, err := segmmap.New(tmpdir+"seg_", 32, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	p, err := NewDirect(s, 16, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pg, err := p.Pin(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !pg.direct {
+		t.Fatal("expected a direct page")
+	}
+
+	copy(pg.Data, "direct")
+	p.Unpin(pg, true)
+
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 6)
+	if _, err := s.ReadAt(data, 48); err != nil || string(data) != "direct" {
+		t.Fatal("wrong data", string(data), err)
+	}
+}
@@ -0,0 +1,209 @@
+// Package pcounter stores named int64 counters in a memory mapped file so that
+// counter values survive restarts and can be read by other processes. One
+// process should create counters while others can read them (ex. to export
+// them as metrics). Counter values are updated with atomic operations.
+package pcounter
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kadirahq/go-tools/function"
+	"github.com/kadirahq/go-tools/hybrid"
+	"github.com/kadirahq/go-tools/memmap"
+	"github.com/kadirahq/go-tools/monitor"
+)
+
+const (
+	// header has the magic number, the capacity and the number of counters
+	headSize = 64
+
+	// each slot has the value, the name size and the name (one cache line)
+	slotSize = 64
+
+	// MaxName is the maximum length of a counter name in bytes.
+	MaxName = slotSize - 9
+
+	// magic number used to check whether the file is initialized
+	magic = 0x31544e4355504b47
+)
+
+var (
+	// ErrFull is returned when all counter slots are used.
+	ErrFull = errors.New("no free counter slots")
+
+	// ErrName is returned when the counter name is empty or too long.
+	ErrName = errors.New("invalid counter name")
+
+	// ErrLayout is returned when the file has a different capacity.
+	ErrLayout = errors.New("counter file has a different layout")
+
+	// ErrInterval is returned when the export interval is not positive.
+	ErrInterval = errors.New("invalid export interval")
+)
+
+// Array is a fixed capacity array of named counters in a memory map.
+type Array struct {
+	mmap  *memmap.Map
+	cap   int64
+	count *int64
+	names map[string]*Counter
+	mutex sync.Mutex
+}
+
+// Counter is a counter value stored in the memory map.
+type Counter struct {
+	val *int64
+}
+
+// Open opens or creates a counter file with space for given number of
+// counters. Existing counters and their values are loaded from the file.
+func Open(path string, capacity int64) (a *Array, err error) {
+	mmap, err := memmap.New(path, headSize+capacity*slotSize)
+	if err == memmap.ErrBadSz {
+		return nil, ErrLayout
+	} else if err != nil {
+		return nil, err
+	}
+
+	mg := hybrid.NewInt64(mmap.Data[0:]).Value
+	cp := hybrid.NewInt64(mmap.Data[8:]).Value
+
+	if atomic.LoadInt64(mg) != magic {
+		*cp = capacity
+		atomic.StoreInt64(mg, magic)
+	} else if *cp != capacity {
+		mmap.Close()
+		return nil, ErrLayout
+	}
+
+	a = &Array{
+		mmap:  mmap,
+		cap:   capacity,
+		count: hybrid.NewInt64(mmap.Data[16:]).Value,
+		names: map[string]*Counter{},
+	}
+
+	a.load()
+	return a, nil
+}
+
+// Get returns the counter with given name. A new counter is added if the
+// array does not have a counter with the name.
+func (a *Array) Get(name string) (c *Counter, err error) {
+	if len(name) == 0 || len(name) > MaxName {
+		return nil, ErrName
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	// counters may have been added by another process
+	a.load()
+
+	if c, ok := a.names[name]; ok {
+		return c, nil
+	}
+
+	n := atomic.LoadInt64(a.count)
+	if n >= a.cap {
+		return nil, ErrFull
+	}
+
+	slot := a.slot(n)
+	slot[8] = byte(len(name))
+	copy(slot[9:], name)
+
+	// the counter is visible to readers after the count is updated
+	atomic.StoreInt64(a.count, n+1)
+
+	c = &Counter{val: hybrid.NewInt64(slot).Value}
+	a.names[name] = c
+	return c, nil
+}
+
+// Values returns the values of all counters with their names.
+func (a *Array) Values() (vals map[string]int64) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.load()
+
+	vals = make(map[string]int64, len(a.names))
+	for name, c := range a.names {
+		vals[name] = c.Value()
+	}
+
+	return vals
+}
+
+// Report tracks the current values of all counters as gauges in the
+// metric store. Counters are registered when they're reported first.
+func (a *Array) Report(m *monitor.Store) {
+	for name, val := range a.Values() {
+		m.Register(name, monitor.Gauge)
+		m.Track(name, val)
+	}
+}
+
+// Export reports counter values to the metric store at given interval.
+// Stop the ticker to stop exporting values.
+func (a *Array) Export(m *monitor.Store, interval time.Duration) (t *function.Ticker, err error) {
+	if interval <= 0 {
+		return nil, ErrInterval
+	}
+
+	t = function.NewTicker(func() { a.Report(m) }, interval)
+	t.Start()
+	return t, nil
+}
+
+// Sync writes counter values to the disk.
+func (a *Array) Sync() (err error) {
+	return a.mmap.Sync()
+}
+
+// Close unmaps the counter file. Counters should not be used after this.
+func (a *Array) Close() (err error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.mmap.Close()
+}
+
+// load adds counters which are not in the names map yet
+func (a *Array) load() {
+	n := atomic.LoadInt64(a.count)
+	for i := int64(len(a.names)); i < n; i++ {
+		slot := a.slot(i)
+		name := string(slot[9 : 9+int(slot[8])])
+		a.names[name] = &Counter{val: hybrid.NewInt64(slot).Value}
+	}
+}
+
+// slot returns the memory used by the counter at given index
+func (a *Array) slot(i int64) (p []byte) {
+	off := headSize + i*slotSize
+	return a.mmap.Data[off : off+slotSize]
+}
+
+// Add adds n to the counter and returns the new value.
+func (c *Counter) Add(n int64) (val int64) {
+	return atomic.AddInt64(c.val, n)
+}
+
+// Inc adds one to the counter and returns the new value.
+func (c *Counter) Inc() (val int64) {
+	return atomic.AddInt64(c.val, 1)
+}
+
+// Set sets the counter value.
+func (c *Counter) Set(val int64) {
+	atomic.StoreInt64(c.val, val)
+}
+
+// Value returns the counter value.
+func (c *Counter) Value() (val int64) {
+	return atomic.LoadInt64(c.val)
+}
@@ -0,0 +1,146 @@
+package pcounter
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kadirahq/go-tools/monitor"
+)
+
+var (
+	tmpfile = "/tmp/test-pcounter"
+)
+
+func setup(t *testing.T) func() {
+	if err := os.RemoveAll(tmpfile); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if err := os.RemoveAll(tmpfile); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCounters(t *testing.T) {
+	defer setup(t)()
+
+	a, err := Open(tmpfile, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := a.Get("writes")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Inc()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// a second view of the same file (ex. another process)
+	b, err := Open(tmpfile, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer b.Close()
+
+	if _, err := a.Get("reads"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.Get("other"); err != ErrFull {
+		t.Fatal("expected ErrFull")
+	}
+
+	if _, err := a.Get(strings.Repeat("x", MaxName+1)); err != ErrName {
+		t.Fatal("expected ErrName")
+	}
+
+	vals := b.Values()
+	if len(vals) != 2 || vals["writes"] != 1000 || vals["reads"] != 0 {
+		t.Fatal("wrong values", vals)
+	}
+
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(tmpfile, 3); err != ErrLayout {
+		t.Fatal("expected ErrLayout")
+	}
+
+	// values survive reopening the file
+	a, err = Open(tmpfile, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer a.Close()
+
+	c, err = a.Get("writes")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Add(5) != 1005 {
+		t.Fatal("wrong value", c.Value())
+	}
+
+	m := monitor.New("pcounter")
+	a.Report(m)
+
+	if v := monitor.Values()["app.pcounter:writes"]; v != 1005 {
+		t.Fatal("wrong metric value", v)
+	}
+}
+
+func TestExport(t *testing.T) {
+	defer setup(t)()
+
+	a, err := Open(tmpfile, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer a.Close()
+
+	c, err := a.Get("exported")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Add(7)
+
+	m := monitor.New("pcounter")
+	if _, err := a.Export(m, 0); err != ErrInterval {
+		t.Fatal("wrong error")
+	}
+
+	tk, err := a.Export(m, 5*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	tk.Stop()
+
+	if v := monitor.Values()["app.pcounter:exported"]; v != 7 {
+		t.Fatal("wrong metric value", v)
+	}
+}
@@ -0,0 +1,320 @@
+// Package queue implements a fixed capacity ring buffer over a memory mapped
+// file. Multiple goroutines or processes can push and pop records using the
+// same file. Use a file in a memory backed file system (ex. /dev/shm) to
+// share records between processes without writing them to the disk.
+//
+// A process which crashes while pushing or popping a record leaves its slot
+// claimed. Other processes cannot get past that slot (Pop returns ErrEmpty or
+// Push returns ErrFull forever) until Repair is called. Repair should only
+// be called while no other process is using the queue (ex. by a supervisor
+// after restarting crashed processes).
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/kadirahq/go-tools/hybrid"
+	"github.com/kadirahq/go-tools/memmap"
+)
+
+const (
+	// magic number used to check whether the file is initialized
+	magic = 0x3155455551544b47
+
+	// header has the magic number, head, tail, capacity and record size
+	// head and tail are placed in separate cache lines to avoid sharing
+	headSize = 192
+
+	// slot header has the sequence number and the record size
+	slotHead = 16
+
+	// poll interval used by blocking Push and Pop calls
+	pollInterval = 100 * time.Microsecond
+
+	// record size used for slots published by Repair
+	// consumers release these slots without using them
+	skipSize = ^uint64(0)
+)
+
+var (
+	// ErrFull is returned when pushing to a full queue
+	ErrFull = errors.New("queue is full")
+
+	// ErrEmpty is returned when popping from an empty queue
+	ErrEmpty = errors.New("queue is empty")
+
+	// ErrSize is returned when the record is larger than the record size
+	ErrSize = errors.New("record is too large")
+
+	// ErrCap is returned when the capacity is not a power of two
+	ErrCap = errors.New("capacity should be a power of two")
+
+	// ErrLayout is returned when an existing queue file was created with
+	// a different capacity or record size.
+	ErrLayout = errors.New("queue file has a different layout")
+
+	// ErrCorrupt is returned when a record has an invalid size.
+	// The slot is released so the queue can still be used.
+	ErrCorrupt = errors.New("queue record is corrupted")
+)
+
+// Queue is a multi producer, multi consumer ring buffer in a memory map.
+// Each slot has a sequence number which is used to claim and publish it.
+type Queue struct {
+	mmap  *memmap.Map
+	head  *uint64
+	tail  *uint64
+	mask  uint64
+	rsize int64
+	ssize int64
+}
+
+// Open opens or creates a queue file on given path. The capacity should be
+// a power of two and records larger than rsize bytes cannot be pushed.
+func Open(path string, capacity, rsize int64) (q *Queue, err error) {
+	if capacity <= 0 || capacity&(capacity-1) != 0 {
+		return nil, ErrCap
+	}
+
+	// keep slots aligned for atomic operations
+	ssize := slotHead + (rsize+7)/8*8
+	mmap, err := memmap.New(path, headSize+capacity*ssize)
+	if err != nil {
+		return nil, err
+	}
+
+	q = &Queue{
+		mmap:  mmap,
+		head:  uint64At(mmap.Data, 64),
+		tail:  uint64At(mmap.Data, 128),
+		mask:  uint64(capacity - 1),
+		rsize: rsize,
+		ssize: ssize,
+	}
+
+	mg := uint64At(mmap.Data, 0)
+	cp := uint64At(mmap.Data, 8)
+	rs := uint64At(mmap.Data, 16)
+
+	if atomic.LoadUint64(mg) != magic {
+		*cp = uint64(capacity)
+		*rs = uint64(rsize)
+
+		for i := uint64(0); i <= q.mask; i++ {
+			atomic.StoreUint64(q.seq(i), i)
+		}
+
+		// written last to mark the queue as initialized
+		atomic.StoreUint64(mg, magic)
+	} else if *cp != uint64(capacity) || *rs != uint64(rsize) {
+		mmap.Close()
+		return nil, ErrLayout
+	}
+
+	return q, nil
+}
+
+// TryPush adds a record to the queue without blocking.
+// Returns ErrFull if there are no free slots in the queue.
+func (q *Queue) TryPush(p []byte) (err error) {
+	if int64(len(p)) > q.rsize {
+		return ErrSize
+	}
+
+	pos := atomic.LoadUint64(q.tail)
+	for {
+		seq := atomic.LoadUint64(q.seq(pos))
+		dif := int64(seq - pos)
+
+		if dif < 0 {
+			return ErrFull
+		}
+
+		if dif > 0 {
+			pos = atomic.LoadUint64(q.tail)
+			continue
+		}
+
+		if atomic.CompareAndSwapUint64(q.tail, pos, pos+1) {
+			break
+		}
+
+		pos = atomic.LoadUint64(q.tail)
+	}
+
+	slot := q.slot(pos)
+	*uint64At(slot, 8) = uint64(len(p))
+	copy(slot[slotHead:], p)
+
+	// publish the record to consumers
+	atomic.StoreUint64(q.seq(pos), pos+1)
+	return nil
+}
+
+// TryPopFunc removes a record from the queue without blocking and calls the
+// function with the record data. The data is not copied so it should not be
+// used after the function returns. Returns ErrEmpty if there are no records.
+// Returns ErrCorrupt if the record size in the slot is not valid.
+func (q *Queue) TryPopFunc(fn func(p []byte)) (err error) {
+	for {
+		pos, err := q.claim()
+		if err != nil {
+			return err
+		}
+
+		slot := q.slot(pos)
+		sz := *uint64At(slot, 8)
+
+		if sz == skipSize {
+			q.release(pos)
+			continue
+		}
+
+		if sz > uint64(q.rsize) {
+			q.release(pos)
+			return ErrCorrupt
+		}
+
+		fn(slot[slotHead : slotHead+int64(sz)])
+		q.release(pos)
+		return nil
+	}
+}
+
+// claim claims the slot at the head of the queue for a consumer
+func (q *Queue) claim() (pos uint64, err error) {
+	pos = atomic.LoadUint64(q.head)
+	for {
+		seq := atomic.LoadUint64(q.seq(pos))
+		dif := int64(seq - (pos + 1))
+
+		if dif < 0 {
+			return 0, ErrEmpty
+		}
+
+		if dif > 0 {
+			pos = atomic.LoadUint64(q.head)
+			continue
+		}
+
+		if atomic.CompareAndSwapUint64(q.head, pos, pos+1) {
+			return pos, nil
+		}
+
+		pos = atomic.LoadUint64(q.head)
+	}
+}
+
+// release releases a slot claimed by a consumer to producers
+func (q *Queue) release(pos uint64) {
+	atomic.StoreUint64(q.seq(pos), pos+q.mask+1)
+}
+
+// Repair releases slots left claimed by processes which crashed while
+// pushing or popping records. Slots claimed by producers are skipped by
+// consumers and slots claimed by consumers are released to producers.
+// It should only be called while no other process is using the queue
+// because slots being pushed or popped look the same as abandoned slots.
+// Returns the number of repaired slots.
+func (q *Queue) Repair() (n int) {
+	head := atomic.LoadUint64(q.head)
+	tail := atomic.LoadUint64(q.tail)
+
+	for i := uint64(0); i <= q.mask; i++ {
+		seq := atomic.LoadUint64(q.seq(i))
+
+		switch {
+		case seq&q.mask == i && int64(seq-tail) < 0 && int64(seq-head) >= 0:
+			// claimed by a producer but not published
+			*uint64At(q.slot(seq), 8) = skipSize
+			atomic.StoreUint64(q.seq(seq), seq+1)
+			n++
+		case (seq-1)&q.mask == i && int64(seq-1-head) < 0 && int64(seq-1-tail) < 0:
+			// claimed by a consumer but not released
+			q.release(seq - 1)
+			n++
+		}
+	}
+
+	return n
+}
+
+// TryPop removes a record from the queue without blocking and returns a copy.
+// Returns ErrEmpty if there are no records in the queue.
+func (q *Queue) TryPop() (p []byte, err error) {
+	err = q.TryPopFunc(func(d []byte) {
+		p = append([]byte{}, d...)
+	})
+
+	return p, err
+}
+
+// Push adds a record to the queue. If the queue is full, it waits until
+// a slot is available or the context is done.
+func (q *Queue) Push(ctx context.Context, p []byte) (err error) {
+	for {
+		if err := q.TryPush(p); err != ErrFull {
+			return err
+		}
+
+		if err := wait(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// Pop removes a record from the queue. If the queue is empty, it waits until
+// a record is available or the context is done.
+func (q *Queue) Pop(ctx context.Context) (p []byte, err error) {
+	for {
+		if p, err := q.TryPop(); err != ErrEmpty {
+			return p, err
+		}
+
+		if err := wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Len returns the number of records in the queue. The value may be stale
+// if other goroutines or processes are using the queue at the same time.
+func (q *Queue) Len() (n int64) {
+	head := atomic.LoadUint64(q.head)
+	tail := atomic.LoadUint64(q.tail)
+	return int64(tail - head)
+}
+
+// Close unmaps the queue file. Records remain in the file.
+func (q *Queue) Close() (err error) {
+	return q.mmap.Close()
+}
+
+// slot returns the memory used by the slot for given position
+func (q *Queue) slot(pos uint64) (p []byte) {
+	off := headSize + int64(pos&q.mask)*q.ssize
+	return q.mmap.Data[off : off+q.ssize]
+}
+
+// seq returns the sequence number of the slot for given position
+func (q *Queue) seq(pos uint64) *uint64 {
+	return uint64At(q.slot(pos), 0)
+}
+
+// uint64At returns a pointer to a uint64 value stored at given offset
+func uint64At(d []byte, off int64) *uint64 {
+	return hybrid.NewUint64(d[off:]).Value
+}
+
+// wait waits for a poll interval or until the context is done
+func wait(ctx context.Context) (err error) {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(pollInterval):
+		return nil
+	}
+}
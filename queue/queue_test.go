@@ -0,0 +1,233 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var (
+	tmpfile = "/tmp/test-queue"
+)
+
+func setup(t *testing.T) func() {
+	if err := os.RemoveAll(tmpfile); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if err := os.RemoveAll(tmpfile); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestQueue(t *testing.T) {
+	defer setup(t)()
+
+	if _, err := Open(tmpfile, 3, 8); err != ErrCap {
+		t.Fatal("wrong error")
+	}
+
+	q, err := Open(tmpfile, 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := q.TryPop(); err != ErrEmpty {
+		t.Fatal("wrong error")
+	}
+
+	if err := q.TryPush(make([]byte, 9)); err != ErrSize {
+		t.Fatal("wrong error")
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := q.TryPush([]byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := q.TryPush([]byte{4}); err != ErrFull {
+		t.Fatal("wrong error")
+	}
+
+	if p, err := q.TryPop(); err != nil || !bytes.Equal(p, []byte{0}) {
+		t.Fatal("wrong record")
+	}
+
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(tmpfile, 4, 16); err == nil {
+		t.Fatal("should fail")
+	}
+
+	// records should persist
+	q, err = Open(tmpfile, 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer q.Close()
+
+	if q.Len() != 3 {
+		t.Fatal("wrong length")
+	}
+
+	for i := 1; i < 4; i++ {
+		if p, err := q.TryPop(); err != nil || !bytes.Equal(p, []byte{byte(i)}) {
+			t.Fatal("wrong record")
+		}
+	}
+}
+
+func TestQueueBlocking(t *testing.T) {
+	defer setup(t)()
+
+	q, err := Open(tmpfile, 8, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer q.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	if _, err := q.Pop(ctx); err != context.DeadlineExceeded {
+		t.Fatal("wrong error")
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(4)
+
+	for i := 0; i < 4; i++ {
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				p := []byte(strconv.Itoa(i*100 + j))
+				if err := q.Push(context.Background(), p); err != nil {
+					t.Error(err)
+				}
+			}
+		}(i)
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < 400; i++ {
+		p, err := q.Pop(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		seen[string(p)] = true
+	}
+
+	wg.Wait()
+
+	if len(seen) != 400 {
+		t.Fatal("missing records")
+	}
+}
+
+func TestQueueRepair(t *testing.T) {
+	defer setup(t)()
+
+	q, err := Open(tmpfile, 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer q.Close()
+
+	if n := q.Repair(); n != 0 {
+		t.Fatal("nothing to repair", n)
+	}
+
+	// a producer crashed after claiming a slot
+	atomic.AddUint64(q.tail, 1)
+
+	if err := q.TryPush([]byte{1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := q.TryPop(); err != ErrEmpty {
+		t.Fatal("wrong error")
+	}
+
+	if n := q.Repair(); n != 1 {
+		t.Fatal("wrong count", n)
+	}
+
+	if p, err := q.TryPop(); err != nil || !bytes.Equal(p, []byte{1}) {
+		t.Fatal("wrong record")
+	}
+
+	// a consumer crashed after claiming a slot
+	for i := 0; i < 4; i++ {
+		if err := q.TryPush([]byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	atomic.AddUint64(q.head, 1)
+
+	for i := 1; i < 4; i++ {
+		if p, err := q.TryPop(); err != nil || !bytes.Equal(p, []byte{byte(i)}) {
+			t.Fatal("wrong record")
+		}
+	}
+
+	// the claimed slot is the next slot for producers
+	if err := q.TryPush([]byte{0}); err != ErrFull {
+		t.Fatal("wrong error")
+	}
+
+	if n := q.Repair(); n != 1 {
+		t.Fatal("wrong count", n)
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := q.TryPush([]byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if q.Len() != 4 {
+		t.Fatal("wrong length")
+	}
+}
+
+func TestQueueCorrupt(t *testing.T) {
+	defer setup(t)()
+
+	q, err := Open(tmpfile, 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer q.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := q.TryPush([]byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	*uint64At(q.slot(0), 8) = 9
+
+	if _, err := q.TryPop(); err != ErrCorrupt {
+		t.Fatal("wrong error")
+	}
+
+	if p, err := q.TryPop(); err != nil || !bytes.Equal(p, []byte{1}) {
+		t.Fatal("wrong record")
+	}
+}
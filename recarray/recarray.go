@@ -0,0 +1,209 @@
+// Package recarray stores an array of fixed-size records in a segment store.
+// The number of records is persisted in a metadata file. Records can be
+// accessed without copying them and used with hybrid types. For example:
+//
+//	rec, _ := arr.Slice(i)
+//	val := hybrid.NewUint64(rec[8:])
+//	*val.Value++
+//
+// Records can span segment boundaries unless the array is created with
+// NewAligned which places records so that they never cross segments.
+package recarray
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/kadirahq/go-tools/segments"
+	"github.com/kadirahq/go-tools/segments/segfile"
+)
+
+const (
+	// metadata key used to store the number of records
+	lenKey = "recarray.len"
+
+	// metadata key used to store the segment size of aligned arrays
+	alignKey = "recarray.align"
+)
+
+var (
+	// ErrIndex is returned when the record index is out of range.
+	ErrIndex = errors.New("record index out of range")
+
+	// ErrSize is returned when the record size is wrong.
+	ErrSize = errors.New("wrong record size")
+
+	// ErrSpan is returned when a record spans a segment boundary and
+	// cannot be sliced. Use a segment size which is a multiple of the
+	// record size to avoid this.
+	ErrSpan = errors.New("record spans segments")
+
+	// ErrLayout is returned when the array was created with a different
+	// layout (ex. opening an aligned array with New).
+	ErrLayout = errors.New("array has a different layout")
+)
+
+// Array is an array of fixed-size records stored in a segment store.
+type Array struct {
+	store segments.Store
+	meta  *segfile.Metadata
+	rsize int64
+	count int64
+	mutex sync.RWMutex
+
+	// segment size and records per segment of aligned arrays
+	ssize int64
+	nseg  int64
+}
+
+// New creates an array of records with given record size using the store.
+// The number of records is loaded from and stored in the metadata.
+func New(s segments.Store, m *segfile.Metadata, rsize int64) (a *Array, err error) {
+	return newArray(s, m, rsize, 0)
+}
+
+// NewAligned creates an array of records which never span segment
+// boundaries. The store should use given segment size. Space at the end of
+// each segment which cannot fit a record is not used so Slice works with
+// all records. Existing arrays must be opened with the same segment size.
+func NewAligned(s segments.Store, m *segfile.Metadata, rsize, ssize int64) (a *Array, err error) {
+	if ssize <= 0 || rsize > ssize {
+		return nil, ErrSize
+	}
+
+	return newArray(s, m, rsize, ssize)
+}
+
+// newArray creates an array with given segment size (zero if not aligned)
+func newArray(s segments.Store, m *segfile.Metadata, rsize, ssize int64) (a *Array, err error) {
+	if rsize <= 0 {
+		return nil, ErrSize
+	}
+
+	// arrays without the key were created before aligning records
+	count, _ := m.Int(lenKey)
+	align, ok := m.Int(alignKey)
+	if (ok && align != ssize) || (!ok && count > 0 && ssize != 0) {
+		return nil, ErrLayout
+	}
+
+	m.SetInt(alignKey, ssize)
+
+	a = &Array{
+		store: s,
+		meta:  m,
+		rsize: rsize,
+		count: count,
+		ssize: ssize,
+	}
+
+	if ssize > 0 {
+		a.nseg = ssize / rsize
+	}
+
+	return a, nil
+}
+
+// Len returns the number of records in the array.
+func (a *Array) Len() (n int64) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.count
+}
+
+// Get copies the record at given index to the byte slice.
+func (a *Array) Get(i int64, p []byte) (err error) {
+	if int64(len(p)) != a.rsize {
+		return ErrSize
+	}
+
+	if err := a.check(i); err != nil {
+		return err
+	}
+
+	_, err = a.store.ReadAt(p, a.offset(i))
+	return err
+}
+
+// Put replaces the record at given index with the byte slice.
+func (a *Array) Put(i int64, p []byte) (err error) {
+	if int64(len(p)) != a.rsize {
+		return ErrSize
+	}
+
+	if err := a.check(i); err != nil {
+		return err
+	}
+
+	_, err = a.store.WriteAt(p, a.offset(i))
+	return err
+}
+
+// Append adds a record at the end of the array and returns its index.
+func (a *Array) Append(p []byte) (i int64, err error) {
+	if int64(len(p)) != a.rsize {
+		return 0, ErrSize
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	i = a.count
+	if _, err := a.store.WriteAt(p, a.offset(i)); err != nil {
+		return 0, err
+	}
+
+	a.count++
+	a.meta.SetInt(lenKey, a.count)
+
+	return i, nil
+}
+
+// Slice returns the record at given index without copying it. Changes made
+// to the slice are reflected in the store when it's backed by memory maps.
+func (a *Array) Slice(i int64) (p []byte, err error) {
+	if err := a.check(i); err != nil {
+		return nil, err
+	}
+
+	p, err = a.store.SliceAt(a.rsize, a.offset(i))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(p)) != a.rsize {
+		return nil, ErrSpan
+	}
+
+	return p, nil
+}
+
+// Sync writes records and the number of records to the disk.
+func (a *Array) Sync() (err error) {
+	if err := a.store.Sync(); err != nil {
+		return err
+	}
+
+	return a.meta.Sync()
+}
+
+// offset returns the store offset of the record at given index
+func (a *Array) offset(i int64) (off int64) {
+	if a.nseg == 0 {
+		return i * a.rsize
+	}
+
+	return i/a.nseg*a.ssize + i%a.nseg*a.rsize
+}
+
+// check checks whether the index is in range
+func (a *Array) check(i int64) (err error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	if i < 0 || i >= a.count {
+		return ErrIndex
+	}
+
+	return nil
+}
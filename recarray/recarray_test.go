@@ -0,0 +1,186 @@
+package recarray
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/kadirahq/go-tools/hybrid"
+	"github.com/kadirahq/go-tools/segments/segfile"
+	"github.com/kadirahq/go-tools/segments/segmmap"
+)
+
+var (
+	tmpdir = "/tmp/test-recarray/"
+)
+
+func setup(t *testing.T) func() {
+	if err := os.RemoveAll(tmpdir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(tmpdir, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if err := os.RemoveAll(tmpdir); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func open(t *testing.T) (a *Array, close func()) {
+	s, err := segmmap.New(tmpdir+"seg_", 32, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := segfile.NewMetadata(tmpdir+"meta", 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err = New(s, m, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a, func() {
+		if err := a.Sync(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := m.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestArray(t *testing.T) {
+	defer setup(t)()
+
+	a, close := open(t)
+
+	for i := 0; i < 5; i++ {
+		p := bytes.Repeat([]byte{byte(i)}, 16)
+		if n, err := a.Append(p); err != nil {
+			t.Fatal(err)
+		} else if n != int64(i) {
+			t.Fatal("wrong index")
+		}
+	}
+
+	if err := a.Put(2, bytes.Repeat([]byte{9}, 16)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Put(5, make([]byte, 16)); err != ErrIndex {
+		t.Fatal("wrong error")
+	}
+
+	if _, err := a.Append(make([]byte, 15)); err != ErrSize {
+		t.Fatal("wrong error")
+	}
+
+	close()
+
+	a, close = open(t)
+	defer close()
+
+	if a.Len() != 5 {
+		t.Fatal("wrong length")
+	}
+
+	p := make([]byte, 16)
+	if err := a.Get(2, p); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(p, bytes.Repeat([]byte{9}, 16)) {
+		t.Fatal("wrong values")
+	}
+}
+
+func TestSlice(t *testing.T) {
+	defer setup(t)()
+
+	a, close := open(t)
+	defer close()
+
+	if _, err := a.Append(make([]byte, 16)); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := a.Slice(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val := hybrid.NewUint64(rec[8:])
+	*val.Value = 5
+
+	p := make([]byte, 16)
+	if err := a.Get(0, p); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(p[8:], val.Bytes) {
+		t.Fatal("wrong values")
+	}
+}
+
+func TestAligned(t *testing.T) {
+	defer setup(t)()
+
+	s, err := segmmap.New(tmpdir+"seg_", 32, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	m, err := segfile.NewMetadata(tmpdir+"meta", 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer m.Close()
+
+	if _, err := NewAligned(s, m, 40, 32); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	// only 2 records fit in each segment
+	a, err := NewAligned(s, m, 12, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := a.Append(bytes.Repeat([]byte{byte(i)}, 12)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := int64(0); i < 5; i++ {
+		rec, err := a.Slice(i)
+		if err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(rec, bytes.Repeat([]byte{byte(i)}, 12)) {
+			t.Fatal("wrong values")
+		}
+	}
+
+	if _, err := New(s, m, 12); err != ErrLayout {
+		t.Fatal("expected ErrLayout")
+	}
+
+	if _, err := NewAligned(s, m, 12, 64); err != ErrLayout {
+		t.Fatal("expected ErrLayout")
+	}
+}
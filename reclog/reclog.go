@@ -0,0 +1,96 @@
+// Package reclog appends variable size records to a segment store and reads
+// them in order. Records are framed with their size and a checksum (see
+// segments.Appender) so iterators stop at the first record which was not
+// completely written before a crash instead of returning corrupted data.
+// Records are identified by their offsets in the store.
+package reclog
+
+import (
+	"io"
+
+	"github.com/kadirahq/go-tools/segments"
+)
+
+// Log is a log of variable size records in a segment store.
+// All methods are safe to use concurrently.
+type Log struct {
+	store segments.Store
+	app   *segments.Appender
+}
+
+// New creates a log which writes records to the store starting from given
+// offset. Existing records are scanned to find the end of the log.
+func New(s segments.Store, off int64) (l *Log, err error) {
+	app, err := segments.NewAppender(s, off)
+	if err != nil {
+		return nil, err
+	}
+
+	l = &Log{store: s, app: app}
+	return l, nil
+}
+
+// Append writes a record at the end of the log and returns its offset.
+// The record may not be on the disk until the Sync method returns.
+func (l *Log) Append(p []byte) (off int64, err error) {
+	return l.app.Append(p)
+}
+
+// Tail returns the offset which will be used for the next record.
+func (l *Log) Tail() (off int64) {
+	return l.app.Tail()
+}
+
+// Sync writes records to the disk.
+func (l *Log) Sync() (err error) {
+	return l.store.Sync()
+}
+
+// Iter returns an iterator which reads records starting from given offset
+// until the end of the log. Records appended after creating the iterator
+// are not read.
+func (l *Log) Iter(off int64) (it *Iterator) {
+	return &Iterator{store: l.store, next: off, end: l.Tail()}
+}
+
+// Iterator reads records in order.
+type Iterator struct {
+	store segments.Store
+	next  int64
+	end   int64
+	torn  bool
+}
+
+// NewIterator returns an iterator which reads records in the store starting
+// from given offset until it finds an invalid record or the end of records.
+// This can be used to read records without opening the log for writing.
+func NewIterator(s segments.Store, off int64) (it *Iterator) {
+	return &Iterator{store: s, next: off, end: -1}
+}
+
+// Next returns the next record and its offset. It returns io.EOF at the end
+// of the log and when the next record is not valid (use Torn to check).
+func (it *Iterator) Next() (off int64, p []byte, err error) {
+	if it.torn || (it.end >= 0 && it.next >= it.end) {
+		return 0, nil, io.EOF
+	}
+
+	p, next, err := segments.ReadRecord(it.store, it.next)
+	if err == segments.ErrRecord {
+		it.torn = true
+		return 0, nil, io.EOF
+	} else if err != nil {
+		return 0, nil, err
+	}
+
+	off = it.next
+	it.next = next
+	return off, p, nil
+}
+
+// Torn returns whether the iterator stopped at an invalid record. This
+// happens when the record was partially written before a crash or when
+// the iterator was not created with the offset of a record.
+func (it *Iterator) Torn() (torn bool) {
+	return it.torn
+}
@@ -0,0 +1,131 @@
+package reclog
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/kadirahq/go-tools/segments"
+	"github.com/kadirahq/go-tools/segments/segmmap"
+)
+
+var (
+	tmpdir = "/tmp/test-reclog/"
+)
+
+func setup(t *testing.T) func() {
+	if err := os.RemoveAll(tmpdir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(tmpdir, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if err := os.RemoveAll(tmpdir); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func record(i int) []byte {
+	return bytes.Repeat([]byte(strconv.Itoa(i)), i)
+}
+
+func TestLog(t *testing.T) {
+	defer setup(t)()
+
+	s, err := segmmap.New(tmpdir+"seg_", 16, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	l, err := New(s, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offs := make([]int64, 10)
+	for i := range offs {
+		if offs[i], err = l.Append(record(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	it := l.Iter(offs[3])
+
+	// records appended after creating the iterator are not read
+	if _, err := l.Append(record(10)); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 3; i < 10; i++ {
+		off, p, err := it.Next()
+		if err != nil {
+			t.Fatal(err)
+		} else if off != offs[i] || !bytes.Equal(p, record(i)) {
+			t.Fatal("wrong record")
+		}
+	}
+
+	if _, _, err := it.Next(); err != io.EOF {
+		t.Fatal("expected io.EOF")
+	} else if it.Torn() {
+		t.Fatal("should not be torn")
+	}
+}
+
+func TestTorn(t *testing.T) {
+	defer setup(t)()
+
+	s, err := segmmap.New(tmpdir+"seg_", 16, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	l, err := New(s, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last int64
+	for i := 0; i < 5; i++ {
+		if last, err = l.Append(record(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the last record was partially written
+	if _, err := s.WriteAt([]byte{0}, last+segments.RecordHeaderSize); err != nil {
+		t.Fatal(err)
+	}
+
+	it := NewIterator(s, 0)
+	for i := 0; i < 4; i++ {
+		if _, p, err := it.Next(); err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(p, record(i)) {
+			t.Fatal("wrong record")
+		}
+	}
+
+	if _, _, err := it.Next(); err != io.EOF {
+		t.Fatal("expected io.EOF")
+	} else if !it.Torn() {
+		t.Fatal("should be torn")
+	}
+
+	l, err = New(s, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if l.Tail() != last {
+		t.Fatal("torn record should be the end")
+	}
+}
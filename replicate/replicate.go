@@ -0,0 +1,435 @@
+// Package replicate copies changes made to a segment store on a primary to
+// segment stores on followers over TCP. The primary reports written ranges to
+// the Source which sends them to all connected followers. A follower (Sink)
+// connects with the offset it has already received so that it can catch up
+// after reconnecting. Each frame has a checksum which is verified by the sink.
+package replicate
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/kadirahq/go-tools/checksum"
+	"github.com/kadirahq/go-tools/segments"
+)
+
+const (
+	// magic number sent by the sink when connecting
+	magic = 0x31504552
+
+	// handshake has the magic number and the offset to start from
+	handSize = 12
+
+	// frame header has the offset, the data size and the checksum
+	headSize = 16
+
+	// maximum size of data in a single frame
+	maxFrame = 64 * 1024
+)
+
+var (
+	// ErrHandshake is returned when a connection sends a bad handshake.
+	ErrHandshake = errors.New("bad replication handshake")
+
+	// ErrChecksum is returned when a frame has a bad checksum.
+	ErrChecksum = errors.New("replication frame checksum mismatch")
+
+	// ErrFrame is returned when a frame is larger than the maximum size.
+	ErrFrame = errors.New("replication frame is too large")
+)
+
+// span is a range of bytes in the store
+type span struct {
+	off int64
+	end int64
+}
+
+// Source sends changes made to a store to connected followers.
+type Source struct {
+	store    segments.Store
+	end      int64
+	sessions map[*session]bool
+	listener net.Listener
+	closed   bool
+	wg       sync.WaitGroup
+	mutex    sync.Mutex
+}
+
+// NewSource creates a source for the store. The end offset is the size of
+// the data already in the store which is sent to followers when catching up.
+func NewSource(s segments.Store, end int64) (src *Source) {
+	return &Source{
+		store:    s,
+		end:      end,
+		sessions: map[*session]bool{},
+	}
+}
+
+// Written reports that a range of the store has been written. The range is
+// sent to all connected followers. Call this after writing to the store.
+func (src *Source) Written(off, sz int64) {
+	src.mutex.Lock()
+	defer src.mutex.Unlock()
+
+	if off+sz > src.end {
+		src.end = off + sz
+	}
+
+	for s := range src.sessions {
+		if s.ready {
+			s.push(span{off, off + sz})
+		}
+	}
+}
+
+// End returns the end offset of data written to the store.
+func (src *Source) End() (off int64) {
+	src.mutex.Lock()
+	defer src.mutex.Unlock()
+	return src.end
+}
+
+// Serve accepts connections from followers until the listener is closed.
+// Connections accepted after closing the source are closed immediately.
+func (src *Source) Serve(l net.Listener) (err error) {
+	src.mutex.Lock()
+	if src.closed {
+		src.mutex.Unlock()
+		l.Close()
+		return net.ErrClosed
+	}
+
+	src.listener = l
+	src.mutex.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		s := &session{conn: conn, notify: make(chan struct{}, 1), done: make(chan struct{})}
+
+		src.mutex.Lock()
+		if src.closed {
+			src.mutex.Unlock()
+			conn.Close()
+			continue
+		}
+
+		src.sessions[s] = true
+		src.wg.Add(1)
+		src.mutex.Unlock()
+
+		go src.serve(s)
+	}
+}
+
+// Close closes the listener and all follower connections and waits until
+// session goroutines exit. Calling Close more than once has no effect.
+func (src *Source) Close() (err error) {
+	src.mutex.Lock()
+	if src.closed {
+		src.mutex.Unlock()
+		return nil
+	}
+
+	src.closed = true
+	for s := range src.sessions {
+		s.close()
+	}
+
+	if src.listener != nil {
+		err = src.listener.Close()
+	}
+	src.mutex.Unlock()
+
+	src.wg.Wait()
+	return err
+}
+
+// serve reads the handshake and sends changes to the follower
+func (src *Source) serve(s *session) {
+	defer src.wg.Done()
+	defer s.close()
+
+	defer func() {
+		src.mutex.Lock()
+		delete(src.sessions, s)
+		src.mutex.Unlock()
+	}()
+
+	conn := s.conn
+	hand := make([]byte, handSize)
+	if _, err := io.ReadFull(conn, hand); err != nil {
+		return
+	}
+
+	if binary.LittleEndian.Uint32(hand[0:]) != magic {
+		return
+	}
+
+	off := int64(binary.LittleEndian.Uint64(hand[4:]))
+
+	// changes are pushed to the session after this
+	src.mutex.Lock()
+	if off < src.end {
+		s.push(span{off, src.end})
+	}
+
+	s.ready = true
+	src.mutex.Unlock()
+
+	w := bufio.NewWriterSize(conn, headSize+maxFrame)
+	buf := make([]byte, headSize+maxFrame)
+
+	for {
+		sp, ok := s.pop()
+		if !ok {
+			select {
+			case <-s.notify:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+
+		for off := sp.off; off < sp.end; off += maxFrame {
+			sz := sp.end - off
+			if sz > maxFrame {
+				sz = maxFrame
+			}
+
+			data := buf[headSize : headSize+sz]
+			if _, err := src.store.ReadAt(data, off); err != nil {
+				return
+			}
+
+			binary.LittleEndian.PutUint64(buf[0:], uint64(off))
+			binary.LittleEndian.PutUint32(buf[8:], uint32(sz))
+			binary.LittleEndian.PutUint32(buf[12:], checksum.CRC32C(data))
+
+			if _, err := w.Write(buf[:headSize+sz]); err != nil {
+				return
+			}
+		}
+
+		// flush only when there are no more pending changes
+		if s.empty() {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// session has pending changes for a connected follower
+type session struct {
+	conn    net.Conn
+	ready   bool // guarded by the source mutex
+	pending []span
+	notify  chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	mutex   sync.Mutex
+}
+
+// push adds a range to pending changes merging it with the last range
+func (s *session) push(sp span) {
+	s.mutex.Lock()
+	n := len(s.pending)
+	if n > 0 && sp.off <= s.pending[n-1].end && sp.end >= s.pending[n-1].off {
+		last := &s.pending[n-1]
+		if sp.off < last.off {
+			last.off = sp.off
+		}
+
+		if sp.end > last.end {
+			last.end = sp.end
+		}
+	} else {
+		s.pending = append(s.pending, sp)
+	}
+	s.mutex.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pop removes the first pending range
+func (s *session) pop() (sp span, ok bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.pending) == 0 {
+		return span{}, false
+	}
+
+	sp = s.pending[0]
+	s.pending = s.pending[1:]
+	return sp, true
+}
+
+// empty checks whether there are pending ranges
+func (s *session) empty() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.pending) == 0
+}
+
+// close stops the session and closes the connection
+func (s *session) close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.conn.Close()
+	})
+}
+
+// Sink applies changes received from a source to a store. The store is
+// synced before the offset is moved forward so data before the offset
+// is on the disk.
+type Sink struct {
+	store segments.Store
+	off   int64
+	ahead []span
+	mutex sync.Mutex
+}
+
+// NewSink creates a sink for the store. The offset is the end of the data
+// already received from the source (ex. the value of Offset before restart).
+func NewSink(s segments.Store, off int64) (snk *Sink) {
+	return &Sink{store: s, off: off}
+}
+
+// Offset returns the end offset of data received from the source. All data
+// before the offset has been received, ranges received after a gap are not
+// included until the gap is filled.
+func (snk *Sink) Offset() (off int64) {
+	snk.mutex.Lock()
+	defer snk.mutex.Unlock()
+	return snk.off
+}
+
+// Run connects to the source and applies changes until the context is done
+// or the connection fails. Call it again to resume from the current offset.
+func (snk *Sink) Run(ctx context.Context, addr string) (err error) {
+	d := net.Dialer{}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	hand := make([]byte, handSize)
+	binary.LittleEndian.PutUint32(hand[0:], magic)
+	binary.LittleEndian.PutUint64(hand[4:], uint64(snk.Offset()))
+	if _, err := conn.Write(hand); err != nil {
+		return snk.err(ctx, err)
+	}
+
+	r := bufio.NewReaderSize(conn, headSize+maxFrame)
+	head := make([]byte, headSize)
+	buf := make([]byte, maxFrame)
+
+	// ranges written to the store but not synced yet
+	var written []span
+
+	for {
+		if _, err := io.ReadFull(r, head); err != nil {
+			return snk.err(ctx, err)
+		}
+
+		off := int64(binary.LittleEndian.Uint64(head[0:]))
+		sz := int64(binary.LittleEndian.Uint32(head[8:]))
+		if sz > maxFrame {
+			return ErrFrame
+		}
+
+		data := buf[:sz]
+		if _, err := io.ReadFull(r, data); err != nil {
+			return snk.err(ctx, err)
+		}
+
+		if checksum.CRC32C(data) != binary.LittleEndian.Uint32(head[12:]) {
+			return ErrChecksum
+		}
+
+		if _, err := snk.store.WriteAt(data, off); err != nil {
+			return err
+		}
+
+		written = append(written, span{off, off + sz})
+
+		// sync when there are no more buffered frames
+		if r.Buffered() == 0 {
+			if err := snk.store.Sync(); err != nil {
+				return err
+			}
+
+			for _, sp := range written {
+				snk.received(sp)
+			}
+
+			written = written[:0]
+		}
+	}
+}
+
+// received adds a range to received data and moves the offset forward if
+// the range is not after a gap. Ranges after a gap are kept in order until
+// the gap is filled so that they are resent after reconnecting.
+func (snk *Sink) received(sp span) {
+	snk.mutex.Lock()
+	defer snk.mutex.Unlock()
+
+	if sp.end <= snk.off {
+		return
+	}
+
+	if sp.off > snk.off {
+		i := sort.Search(len(snk.ahead), func(i int) bool { return snk.ahead[i].off > sp.off })
+		snk.ahead = append(snk.ahead, span{})
+		copy(snk.ahead[i+1:], snk.ahead[i:])
+		snk.ahead[i] = sp
+		return
+	}
+
+	snk.off = sp.end
+	n := 0
+	for ; n < len(snk.ahead) && snk.ahead[n].off <= snk.off; n++ {
+		if snk.ahead[n].end > snk.off {
+			snk.off = snk.ahead[n].end
+		}
+	}
+
+	snk.ahead = snk.ahead[n:]
+}
+
+// err returns the context error if the context is done
+func (snk *Sink) err(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
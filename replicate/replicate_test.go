@@ -0,0 +1,229 @@
+package replicate
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kadirahq/go-tools/fs/memstore"
+)
+
+func wait(t *testing.T, snk *Sink, off int64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for snk.Offset() < off {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for offset", off, snk.Offset())
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReplicate(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	primary := memstore.New()
+	src := NewSource(primary, 0)
+	go src.Serve(l)
+	defer src.Close()
+
+	write := func(p []byte, off int64) {
+		if _, err := primary.WriteAt(p, off); err != nil {
+			t.Fatal(err)
+		}
+
+		src.Written(off, int64(len(p)))
+	}
+
+	big := bytes.Repeat([]byte{7}, 3*maxFrame/2)
+	write(big, 0)
+
+	follower := memstore.New()
+	snk := NewSink(follower, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- snk.Run(ctx, l.Addr().String()) }()
+
+	wait(t, snk, int64(len(big)))
+
+	// in place changes and appends are both replicated
+	write([]byte("abc"), 10)
+	write([]byte("tail"), int64(len(big)))
+	wait(t, snk, int64(len(big)+4))
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatal("expected context.Canceled", err)
+	}
+
+	// changes made while disconnected are sent when resuming
+	write([]byte("more"), int64(len(big)+4))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go snk.Run(ctx, l.Addr().String())
+	wait(t, snk, int64(len(big)+8))
+
+	if !bytes.Equal(primary.Bytes(), follower.Bytes()) {
+		t.Fatal("stores are different")
+	}
+}
+
+func TestReconnectOutOfOrder(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	primary := memstore.New()
+	src := NewSource(primary, 0)
+	go src.Serve(l)
+	defer src.Close()
+
+	write := func(p []byte, off int64) {
+		if _, err := primary.WriteAt(p, off); err != nil {
+			t.Fatal(err)
+		}
+
+		src.Written(off, int64(len(p)))
+	}
+
+	follower := memstore.New()
+	snk := NewSink(follower, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- snk.Run(ctx, l.Addr().String()) }()
+
+	write([]byte("0123456789"), 0)
+	wait(t, snk, 10)
+
+	// the range after the gap is received before the gap is written
+	write([]byte("klmnopqrst"), 20)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(follower.Bytes()) < 30 {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for data after the gap")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	if off := snk.Offset(); off != 10 {
+		t.Fatal("offset should stop at the gap", off)
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatal("expected context.Canceled", err)
+	}
+
+	// the gap is written while disconnected
+	write([]byte("abcdefghij"), 10)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go snk.Run(ctx, l.Addr().String())
+	wait(t, snk, 30)
+
+	if !bytes.Equal(primary.Bytes(), follower.Bytes()) {
+		t.Fatal("stores are different")
+	}
+}
+
+func TestSinkReceived(t *testing.T) {
+	snk := NewSink(memstore.New(), 0)
+
+	spans := []span{{30, 40}, {10, 20}, {50, 60}, {0, 5}, {5, 15}, {18, 32}}
+	offs := []int64{0, 0, 0, 5, 20, 40}
+
+	for i, sp := range spans {
+		snk.received(sp)
+		if off := snk.Offset(); off != offs[i] {
+			t.Fatal("wrong offset", i, off, offs[i])
+		}
+	}
+
+	if len(snk.ahead) != 1 || snk.ahead[0] != (span{50, 60}) {
+		t.Fatal("wrong ranges after the gap", snk.ahead)
+	}
+}
+
+// syncStore counts Sync calls made by the sink
+type syncStore struct {
+	*memstore.Store
+	syncs int64
+}
+
+func (s *syncStore) Sync() (err error) {
+	atomic.AddInt64(&s.syncs, 1)
+	return s.Store.Sync()
+}
+
+func TestSourceClose(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	primary := memstore.New()
+	src := NewSource(primary, 0)
+	served := make(chan error)
+	go func() { served <- src.Serve(l) }()
+
+	if _, err := primary.WriteAt([]byte("data"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	src.Written(0, 4)
+
+	follower := &syncStore{Store: memstore.New()}
+	snk := NewSink(follower, 0)
+
+	done := make(chan error)
+	go func() { done <- snk.Run(context.Background(), l.Addr().String()) }()
+
+	wait(t, snk, 4)
+
+	// data is synced before moving the offset
+	if atomic.LoadInt64(&follower.syncs) == 0 {
+		t.Fatal("store is not synced")
+	}
+
+	if err := src.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// sessions have exited after Close returns
+	src.mutex.Lock()
+	n := len(src.sessions)
+	src.mutex.Unlock()
+
+	if n != 0 {
+		t.Fatal("sessions are still running")
+	}
+
+	if err := <-done; err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if err := <-served; err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if err := src.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := src.Serve(l); err == nil {
+		t.Fatal("should not serve after closing")
+	}
+}
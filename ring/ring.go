@@ -0,0 +1,202 @@
+// Package ring stores a fixed capacity circular buffer of records in a
+// segment store. The head and the tail of the buffer are persisted in a
+// metadata file. When the buffer is full, pushing a record overwrites the
+// oldest record. This can be used for bounded on-disk queues (ex. keeping
+// the latest N events). Use the queue package to share a buffer between
+// processes without overwriting records.
+package ring
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"github.com/kadirahq/go-tools/segments"
+	"github.com/kadirahq/go-tools/segments/segfile"
+)
+
+const (
+	// slot header has the record size
+	slotHead = 4
+
+	// metadata keys used to store the layout, the head and the tail
+	capKey  = "ring.cap"
+	sizeKey = "ring.rsize"
+	headKey = "ring.head"
+	tailKey = "ring.tail"
+)
+
+var (
+	// ErrEmpty is returned when reading from an empty buffer.
+	ErrEmpty = errors.New("ring buffer is empty")
+
+	// ErrSize is returned when the record is larger than the record size
+	// or when the record size or the capacity is not valid.
+	ErrSize = errors.New("wrong record size")
+
+	// ErrLayout is returned when the metadata was created for a buffer
+	// with a different capacity or record size.
+	ErrLayout = errors.New("ring buffer has a different layout")
+)
+
+// Ring is a circular buffer of records with a maximum size. Each record is
+// stored in a fixed size slot. The head and the tail are counters which are
+// never reset so the slot of a record is its counter modulo the capacity.
+type Ring struct {
+	store    segments.Store
+	meta     *segfile.Metadata
+	rsize    int64
+	ssize    int64
+	capacity int64
+	head     int64
+	tail     int64
+	mutex    sync.Mutex
+}
+
+// New creates a circular buffer which can have upto n records of upto
+// rsize bytes using the store. The head and the tail are loaded from and
+// stored in the metadata.
+func New(s segments.Store, m *segfile.Metadata, rsize, n int64) (r *Ring, err error) {
+	if rsize <= 0 || n <= 0 {
+		return nil, ErrSize
+	}
+
+	if c, ok := m.Int(capKey); ok && c != n {
+		return nil, ErrLayout
+	}
+
+	if sz, ok := m.Int(sizeKey); ok && sz != rsize {
+		return nil, ErrLayout
+	}
+
+	m.SetInt(capKey, n)
+	m.SetInt(sizeKey, rsize)
+
+	head, _ := m.Int(headKey)
+	tail, _ := m.Int(tailKey)
+
+	r = &Ring{
+		store:    s,
+		meta:     m,
+		rsize:    rsize,
+		ssize:    slotHead + rsize,
+		capacity: n,
+		head:     head,
+		tail:     tail,
+	}
+
+	return r, nil
+}
+
+// Len returns the number of records in the buffer.
+func (r *Ring) Len() (n int64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.tail - r.head
+}
+
+// Cap returns the maximum number of records in the buffer.
+func (r *Ring) Cap() (n int64) {
+	return r.capacity
+}
+
+// Push adds a record at the tail of the buffer. If the buffer is full, the
+// oldest record is removed and overwritten. It returns whether a record was
+// overwritten.
+func (r *Ring) Push(p []byte) (overwritten bool, err error) {
+	if int64(len(p)) > r.rsize {
+		return false, ErrSize
+	}
+
+	slot := make([]byte, slotHead+len(p))
+	binary.LittleEndian.PutUint32(slot, uint32(len(p)))
+	copy(slot[slotHead:], p)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// move the head before writing so the oldest record is
+	// not visible after its slot is partially overwritten
+	if r.tail-r.head == r.capacity {
+		r.head++
+		r.meta.SetInt(headKey, r.head)
+		overwritten = true
+	}
+
+	if _, err := r.store.WriteAt(slot, r.offset(r.tail)); err != nil {
+		return overwritten, err
+	}
+
+	r.tail++
+	r.meta.SetInt(tailKey, r.tail)
+
+	return overwritten, nil
+}
+
+// Peek returns the record at the head of the buffer without removing it.
+func (r *Ring) Peek() (p []byte, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.head == r.tail {
+		return nil, ErrEmpty
+	}
+
+	return r.read(r.head)
+}
+
+// Pop removes the record at the head of the buffer and returns it.
+func (r *Ring) Pop() (p []byte, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.head == r.tail {
+		return nil, ErrEmpty
+	}
+
+	p, err = r.read(r.head)
+	if err != nil {
+		return nil, err
+	}
+
+	r.head++
+	r.meta.SetInt(headKey, r.head)
+
+	return p, nil
+}
+
+// Sync writes records to the disk before writing the head and the tail.
+func (r *Ring) Sync() (err error) {
+	if err := r.store.Sync(); err != nil {
+		return err
+	}
+
+	return r.meta.Sync()
+}
+
+// read reads the record with given counter
+// This should be called while holding the mutex.
+func (r *Ring) read(n int64) (p []byte, err error) {
+	off := r.offset(n)
+	head := make([]byte, slotHead)
+	if _, err := r.store.ReadAt(head, off); err != nil {
+		return nil, err
+	}
+
+	sz := int64(binary.LittleEndian.Uint32(head))
+	if sz > r.rsize {
+		return nil, ErrSize
+	}
+
+	p = make([]byte, sz)
+	if _, err := r.store.ReadAt(p, off+slotHead); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// offset returns the store offset of the slot used by given counter
+func (r *Ring) offset(n int64) (off int64) {
+	return (n % r.capacity) * r.ssize
+}
@@ -0,0 +1,154 @@
+package ring
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/kadirahq/go-tools/segments/segfile"
+	"github.com/kadirahq/go-tools/segments/segmmap"
+)
+
+var (
+	tmpdir = "/tmp/test-ring/"
+)
+
+func setup(t *testing.T) func() {
+	if err := os.RemoveAll(tmpdir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(tmpdir, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if err := os.RemoveAll(tmpdir); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func open(t *testing.T, rsize, n int64) (r *Ring, close func()) {
+	s, err := segmmap.New(tmpdir+"seg_", 32, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := segfile.NewMetadata(tmpdir+"meta", 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = New(s, m, rsize, n)
+	if err != nil {
+		s.Close()
+		m.Close()
+		t.Fatal(err)
+	}
+
+	return r, func() {
+		if err := r.Sync(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := m.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRing(t *testing.T) {
+	defer setup(t)()
+
+	r, close := open(t, 10, 3)
+
+	if _, err := r.Pop(); err != ErrEmpty {
+		t.Fatal("expected ErrEmpty")
+	}
+
+	if _, err := r.Push(make([]byte, 11)); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	for i := 0; i < 5; i++ {
+		overwritten, err := r.Push([]byte("record-" + strconv.Itoa(i)))
+		if err != nil {
+			t.Fatal(err)
+		} else if overwritten != (i >= 3) {
+			t.Fatal("wrong overwritten")
+		}
+	}
+
+	if r.Len() != 3 {
+		t.Fatal("wrong length")
+	}
+
+	// oldest records are overwritten
+	if p, err := r.Peek(); err != nil {
+		t.Fatal(err)
+	} else if string(p) != "record-2" {
+		t.Fatal("wrong record")
+	}
+
+	if p, err := r.Pop(); err != nil {
+		t.Fatal(err)
+	} else if string(p) != "record-2" {
+		t.Fatal("wrong record")
+	}
+
+	close()
+
+	// head and tail are persisted
+	r, close = open(t, 10, 3)
+	defer close()
+
+	if r.Len() != 2 {
+		t.Fatal("wrong length")
+	}
+
+	for i := 3; i < 5; i++ {
+		if p, err := r.Pop(); err != nil {
+			t.Fatal(err)
+		} else if string(p) != "record-"+strconv.Itoa(i) {
+			t.Fatal("wrong record")
+		}
+	}
+
+	if _, err := r.Peek(); err != ErrEmpty {
+		t.Fatal("expected ErrEmpty")
+	}
+}
+
+func TestLayout(t *testing.T) {
+	defer setup(t)()
+
+	_, close := open(t, 10, 3)
+	close()
+
+	s, err := segmmap.New(tmpdir+"seg_", 32, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	m, err := segfile.NewMetadata(tmpdir+"meta", 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer m.Close()
+
+	if _, err := New(s, m, 10, 4); err != ErrLayout {
+		t.Fatal("expected ErrLayout")
+	}
+
+	if _, err := New(s, m, 0, 4); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+}
@@ -0,0 +1,51 @@
+package secure
+
+import (
+	"sync/atomic"
+)
+
+// Bool is a bool value which can be accessed atomically.
+// The zero value is ready to use and has the value false.
+type Bool struct {
+	val uint32
+}
+
+// NewBool creates a new Bool with given value
+func NewBool(v bool) *Bool {
+	return &Bool{val: b2u(v)}
+}
+
+// Get atomically loads the value
+func (b *Bool) Get() bool {
+	return atomic.LoadUint32(&b.val) == 1
+}
+
+// Set atomically stores the value
+func (b *Bool) Set(v bool) {
+	atomic.StoreUint32(&b.val, b2u(v))
+}
+
+// CompareAndSwap sets the value to new only if the current value is old.
+// Returns true if the value is changed by this call. This can be used to
+// make sure that only one goroutine changes the state (ex. open -> closed).
+func (b *Bool) CompareAndSwap(old, new bool) (swapped bool) {
+	return atomic.CompareAndSwapUint32(&b.val, b2u(old), b2u(new))
+}
+
+// Toggle atomically inverts the value and returns the new value
+func (b *Bool) Toggle() bool {
+	for {
+		old := atomic.LoadUint32(&b.val)
+		if atomic.CompareAndSwapUint32(&b.val, old, old^1) {
+			return old == 0
+		}
+	}
+}
+
+func b2u(v bool) uint32 {
+	if v {
+		return 1
+	}
+
+	return 0
+}
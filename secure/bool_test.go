@@ -0,0 +1,51 @@
+package secure
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBool(t *testing.T) {
+	b := &Bool{}
+	if b.Get() {
+		t.Fatal("wrong value")
+	}
+
+	b.Set(true)
+	if !b.Get() {
+		t.Fatal("wrong value")
+	}
+
+	if b.Toggle() || b.Get() {
+		t.Fatal("wrong value")
+	}
+
+	if !b.Toggle() || !b.Get() {
+		t.Fatal("wrong value")
+	}
+}
+
+func TestBoolCompareAndSwap(t *testing.T) {
+	var n int64
+	b := NewBool(true)
+
+	wg := sync.WaitGroup{}
+	wg.Add(100)
+
+	for i := 0; i < 100; i++ {
+		go func() {
+			if b.CompareAndSwap(true, false) {
+				atomic.AddInt64(&n, 1)
+			}
+
+			wg.Done()
+		}()
+	}
+
+	wg.Wait()
+
+	if n != 1 || b.Get() {
+		t.Fatal("only one should swap")
+	}
+}
@@ -0,0 +1,54 @@
+package secure
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// Counter is a cumulative int64 counter which detects overflows. By default
+// the counter wraps around on overflow similar to atomic.AddInt64. Set the
+// Saturate field to stop at the int64 limits instead. The OnOverflow field
+// is called with the value before the change and the delta on overflows.
+// Fields should be set before using the counter from multiple goroutines.
+type Counter struct {
+	val        int64
+	Saturate   bool
+	OnOverflow func(val, delta int64)
+}
+
+// Inc atomically increments the counter by 1 and returns the new value
+func (c *Counter) Inc() int64 {
+	return c.Add(1)
+}
+
+// Add atomically adds delta to the counter and returns the new value
+func (c *Counter) Add(delta int64) int64 {
+	for {
+		old := atomic.LoadInt64(&c.val)
+		new := old + delta
+
+		over := (delta > 0 && new < old) || (delta < 0 && new > old)
+		if over && c.Saturate {
+			if delta > 0 {
+				new = math.MaxInt64
+			} else {
+				new = math.MinInt64
+			}
+		}
+
+		if !atomic.CompareAndSwapInt64(&c.val, old, new) {
+			continue
+		}
+
+		if over && c.OnOverflow != nil {
+			c.OnOverflow(old, delta)
+		}
+
+		return new
+	}
+}
+
+// Value atomically loads the counter value
+func (c *Counter) Value() int64 {
+	return atomic.LoadInt64(&c.val)
+}
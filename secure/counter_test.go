@@ -0,0 +1,56 @@
+package secure
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	c := &Counter{}
+
+	wg := sync.WaitGroup{}
+	wg.Add(100)
+
+	for i := 0; i < 100; i++ {
+		go func() {
+			c.Inc()
+			wg.Done()
+		}()
+	}
+
+	wg.Wait()
+
+	if c.Value() != 100 {
+		t.Fatal("wrong value")
+	}
+
+	if c.Add(-50) != 50 {
+		t.Fatal("wrong value")
+	}
+}
+
+func TestCounterOverflow(t *testing.T) {
+	var n int
+
+	c := &Counter{OnOverflow: func(val, delta int64) {
+		n++
+	}}
+
+	c.Add(math.MaxInt64)
+	if v := c.Inc(); v != math.MinInt64 || n != 1 {
+		t.Fatal("should wrap around")
+	}
+
+	c = &Counter{Saturate: true}
+	c.Add(math.MaxInt64)
+	if v := c.Inc(); v != math.MaxInt64 {
+		t.Fatal("should saturate")
+	}
+
+	c.Add(math.MinInt64)
+	c.Add(math.MinInt64)
+	if v := c.Value(); v != math.MinInt64 {
+		t.Fatal("should saturate")
+	}
+}
@@ -0,0 +1,4 @@
+// Package secure provides types which are safe to use from multiple
+// goroutines. Values are accessed using atomic operations wherever possible
+// so that they can be used on hot paths without holding a mutex.
+package secure
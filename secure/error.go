@@ -0,0 +1,38 @@
+package secure
+
+import (
+	"sync"
+)
+
+// Error holds an error which can be set and read from multiple goroutines.
+// This can be used to report errors from background goroutines to callers.
+// The zero value is ready to use and holds a nil error.
+type Error struct {
+	err   error
+	mutex sync.RWMutex
+}
+
+// Get returns the error without clearing it
+func (e *Error) Get() (err error) {
+	e.mutex.RLock()
+	err = e.err
+	e.mutex.RUnlock()
+	return err
+}
+
+// Set stores the error replacing the existing error
+func (e *Error) Set(err error) {
+	e.mutex.Lock()
+	e.err = err
+	e.mutex.Unlock()
+}
+
+// Take returns the error and clears it so that
+// the same error will not be reported twice.
+func (e *Error) Take() (err error) {
+	e.mutex.Lock()
+	err = e.err
+	e.err = nil
+	e.mutex.Unlock()
+	return err
+}
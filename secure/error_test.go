@@ -0,0 +1,28 @@
+package secure
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestError(t *testing.T) {
+	e := &Error{}
+	if e.Get() != nil {
+		t.Fatal("wrong value")
+	}
+
+	err := errors.New("test error")
+	e.Set(err)
+
+	if e.Get() != err {
+		t.Fatal("wrong value")
+	}
+
+	if e.Take() != err {
+		t.Fatal("wrong value")
+	}
+
+	if e.Get() != nil || e.Take() != nil {
+		t.Fatal("should be cleared")
+	}
+}
@@ -0,0 +1,44 @@
+package secure
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// Float64 is a float64 value which can be accessed atomically.
+// The zero value is ready to use and has the value 0.
+type Float64 struct {
+	bits uint64
+}
+
+// NewFloat64 creates a new Float64 with given value
+func NewFloat64(v float64) *Float64 {
+	return &Float64{bits: math.Float64bits(v)}
+}
+
+// Get atomically loads the value
+func (n *Float64) Get() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&n.bits))
+}
+
+// Set atomically stores the value
+func (n *Float64) Set(v float64) {
+	atomic.StoreUint64(&n.bits, math.Float64bits(v))
+}
+
+// Add atomically adds delta to the value and returns the new value
+func (n *Float64) Add(delta float64) float64 {
+	for {
+		old := atomic.LoadUint64(&n.bits)
+		new := math.Float64frombits(old) + delta
+		if atomic.CompareAndSwapUint64(&n.bits, old, math.Float64bits(new)) {
+			return new
+		}
+	}
+}
+
+// CompareAndSwap sets the value to new only if the current value is old.
+// Returns true if the value is changed by this call.
+func (n *Float64) CompareAndSwap(old, new float64) (swapped bool) {
+	return atomic.CompareAndSwapUint64(&n.bits, math.Float64bits(old), math.Float64bits(new))
+}
@@ -0,0 +1,42 @@
+package secure
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestFloat64(t *testing.T) {
+	n := NewFloat64(5)
+	if n.Get() != 5 {
+		t.Fatal("wrong value")
+	}
+
+	n.Set(10)
+	if n.Get() != 10 {
+		t.Fatal("wrong value")
+	}
+
+	if n.CompareAndSwap(5, 20) || n.Get() != 10 {
+		t.Fatal("should not swap")
+	}
+
+	if !n.CompareAndSwap(10, 20) || n.Get() != 20 {
+		t.Fatal("should swap")
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(100)
+
+	for i := 0; i < 100; i++ {
+		go func() {
+			n.Add(1)
+			wg.Done()
+		}()
+	}
+
+	wg.Wait()
+
+	if n.Get() != 120 {
+		t.Fatal("wrong value")
+	}
+}
@@ -0,0 +1,37 @@
+package secure
+
+import (
+	"sync/atomic"
+)
+
+// Int64 is a int64 value which can be accessed atomically.
+// The zero value is ready to use and has the value 0.
+type Int64 struct {
+	val int64
+}
+
+// NewInt64 creates a new Int64 with given value
+func NewInt64(v int64) *Int64 {
+	return &Int64{val: v}
+}
+
+// Get atomically loads the value
+func (n *Int64) Get() int64 {
+	return atomic.LoadInt64(&n.val)
+}
+
+// Set atomically stores the value
+func (n *Int64) Set(v int64) {
+	atomic.StoreInt64(&n.val, v)
+}
+
+// Add atomically adds delta to the value and returns the new value
+func (n *Int64) Add(delta int64) int64 {
+	return atomic.AddInt64(&n.val, delta)
+}
+
+// CompareAndSwap sets the value to new only if the current value is old.
+// Returns true if the value is changed by this call.
+func (n *Int64) CompareAndSwap(old, new int64) (swapped bool) {
+	return atomic.CompareAndSwapInt64(&n.val, old, new)
+}
@@ -0,0 +1,36 @@
+package secure
+
+import (
+	"sync"
+)
+
+// Locked wraps a value with a read-write mutex. The value can only be used
+// inside With and RWith functions so that the lock is always released after
+// the critical section even if the function returns early or panics.
+// The zero value is ready to use and has the zero value of T.
+type Locked[T any] struct {
+	val   T
+	mutex sync.RWMutex
+}
+
+// NewLocked creates a new Locked value with given value
+func NewLocked[T any](v T) *Locked[T] {
+	return &Locked[T]{val: v}
+}
+
+// With runs the function while holding the write lock.
+// The function can modify the value using the pointer.
+// The pointer should not be used after the function returns.
+func (l *Locked[T]) With(fn func(v *T)) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	fn(&l.val)
+}
+
+// RWith runs the function while holding the read lock.
+// Multiple RWith functions can run at the same time.
+func (l *Locked[T]) RWith(fn func(v T)) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	fn(l.val)
+}
@@ -0,0 +1,31 @@
+package secure
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestLocked(t *testing.T) {
+	l := NewLocked([]int{})
+
+	wg := sync.WaitGroup{}
+	wg.Add(100)
+
+	for i := 0; i < 100; i++ {
+		go func(i int) {
+			l.With(func(v *[]int) {
+				*v = append(*v, i)
+			})
+
+			wg.Done()
+		}(i)
+	}
+
+	wg.Wait()
+
+	l.RWith(func(v []int) {
+		if len(v) != 100 {
+			t.Fatal("wrong length")
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package secure
+
+import (
+	"context"
+	"sync"
+)
+
+// Signal is a flag which can be waited on. Once it's set, all goroutines
+// waiting on the signal are released. It cannot be unset after that.
+// The zero value is ready to use and is not set.
+type Signal struct {
+	done  chan struct{}
+	set   bool
+	mutex sync.Mutex
+}
+
+// Set sets the flag and releases all waiting goroutines.
+// Setting an already set signal has no effect.
+func (s *Signal) Set() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.set {
+		return
+	}
+
+	s.init()
+	s.set = true
+	close(s.done)
+}
+
+// IsSet returns whether the flag is set
+func (s *Signal) IsSet() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.set
+}
+
+// Done returns a channel which is closed when the flag is set
+func (s *Signal) Done() <-chan struct{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.init()
+	return s.done
+}
+
+// Wait blocks until the flag is set or the context is done.
+// If the context is done first, the context error is returned.
+func (s *Signal) Wait(ctx context.Context) (err error) {
+	select {
+	case <-s.Done():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// init creates the channel if it's not created yet.
+// This should be called while holding the mutex.
+func (s *Signal) init() {
+	if s.done == nil {
+		s.done = make(chan struct{})
+	}
+}
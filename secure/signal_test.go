@@ -0,0 +1,43 @@
+package secure
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSignal(t *testing.T) {
+	s := &Signal{}
+	if s.IsSet() {
+		t.Fatal("wrong value")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	if err := s.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatal("wrong error")
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- s.Wait(context.Background())
+	}()
+
+	s.Set()
+	s.Set()
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.IsSet() {
+		t.Fatal("wrong value")
+	}
+
+	select {
+	case <-s.Done():
+	default:
+		t.Fatal("should be closed")
+	}
+}
@@ -0,0 +1,35 @@
+package secure
+
+import (
+	"sync/atomic"
+)
+
+// String is a string value which can be accessed atomically.
+// The zero value is ready to use and has an empty string.
+type String struct {
+	val atomic.Value
+}
+
+// NewString creates a new String with given value
+func NewString(v string) *String {
+	s := &String{}
+	s.val.Store(v)
+	return s
+}
+
+// Get atomically loads the value
+func (s *String) Get() string {
+	v, _ := s.val.Load().(string)
+	return v
+}
+
+// Set atomically stores the value
+func (s *String) Set(v string) {
+	s.val.Store(v)
+}
+
+// Swap atomically stores the new value and returns the old value
+func (s *String) Swap(v string) (old string) {
+	old, _ = s.val.Swap(v).(string)
+	return old
+}
@@ -0,0 +1,26 @@
+package secure
+
+import (
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	s := &String{}
+	if s.Get() != "" {
+		t.Fatal("wrong value")
+	}
+
+	s = NewString("foo")
+	if s.Get() != "foo" {
+		t.Fatal("wrong value")
+	}
+
+	s.Set("bar")
+	if s.Get() != "bar" {
+		t.Fatal("wrong value")
+	}
+
+	if old := s.Swap("baz"); old != "bar" || s.Get() != "baz" {
+		t.Fatal("wrong value")
+	}
+}
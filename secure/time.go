@@ -0,0 +1,45 @@
+package secure
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Time is a timestamp which can be accessed atomically. It's stored as unix
+// nanoseconds so it can be updated from hot paths without holding a mutex.
+// The zero value is ready to use and has the zero time value.
+type Time struct {
+	nsec int64
+}
+
+// NewTime creates a new Time with given value
+func NewTime(t time.Time) *Time {
+	v := &Time{}
+	v.Set(t)
+	return v
+}
+
+// Get atomically loads the time
+func (t *Time) Get() time.Time {
+	nsec := atomic.LoadInt64(&t.nsec)
+	if nsec == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, nsec)
+}
+
+// Set atomically stores the time
+func (t *Time) Set(v time.Time) {
+	var nsec int64
+	if !v.IsZero() {
+		nsec = v.UnixNano()
+	}
+
+	atomic.StoreInt64(&t.nsec, nsec)
+}
+
+// Since returns the time elapsed since the stored time
+func (t *Time) Since() time.Duration {
+	return time.Since(t.Get())
+}
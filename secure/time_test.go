@@ -0,0 +1,30 @@
+package secure
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTime(t *testing.T) {
+	v := &Time{}
+	if !v.Get().IsZero() {
+		t.Fatal("wrong value")
+	}
+
+	now := time.Now()
+	v.Set(now)
+
+	if !v.Get().Equal(now) {
+		t.Fatal("wrong value")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if v.Since() < 10*time.Millisecond {
+		t.Fatal("wrong duration")
+	}
+
+	v = NewTime(time.Time{})
+	if !v.Get().IsZero() {
+		t.Fatal("wrong value")
+	}
+}
@@ -0,0 +1,37 @@
+package secure
+
+import (
+	"sync/atomic"
+)
+
+// Uint64 is a uint64 value which can be accessed atomically.
+// The zero value is ready to use and has the value 0.
+type Uint64 struct {
+	val uint64
+}
+
+// NewUint64 creates a new Uint64 with given value
+func NewUint64(v uint64) *Uint64 {
+	return &Uint64{val: v}
+}
+
+// Get atomically loads the value
+func (n *Uint64) Get() uint64 {
+	return atomic.LoadUint64(&n.val)
+}
+
+// Set atomically stores the value
+func (n *Uint64) Set(v uint64) {
+	atomic.StoreUint64(&n.val, v)
+}
+
+// Add atomically adds delta to the value and returns the new value
+func (n *Uint64) Add(delta uint64) uint64 {
+	return atomic.AddUint64(&n.val, delta)
+}
+
+// CompareAndSwap sets the value to new only if the current value is old.
+// Returns true if the value is changed by this call.
+func (n *Uint64) CompareAndSwap(old, new uint64) (swapped bool) {
+	return atomic.CompareAndSwapUint64(&n.val, old, new)
+}
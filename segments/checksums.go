@@ -0,0 +1,116 @@
+package segments
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/kadirahq/go-tools/checksum"
+)
+
+var (
+	// ErrNoChecksums is returned when checksums are used with a store which
+	// was opened without enabling them.
+	ErrNoChecksums = errors.New("checksums are not enabled")
+)
+
+// Checksum calculates the checksum of a segment. The checksum is never zero
+// because zero is used when a segment does not have a checksum.
+func Checksum(p []byte) uint64 {
+	if sum := checksum.XXH64(p, 0); sum != 0 {
+		return sum
+	}
+
+	return 1
+}
+
+// ChecksumReader calculates the checksum of a segment using a reader.
+func ChecksumReader(r io.Reader) (sum uint64, err error) {
+	d := checksum.NewXXH64(0)
+	if _, err := io.Copy(d, r); err != nil {
+		return 0, err
+	}
+
+	if sum := d.Sum64(); sum != 0 {
+		return sum, nil
+	}
+
+	return 1, nil
+}
+
+// SumFile stores a checksum for each segment in a sidecar file. Checksums
+// are stored as 8 byte values at segment index * 8. Segments which are not
+// in the file (or have a zero value) do not have a checksum.
+type SumFile struct {
+	file  *os.File
+	mutex sync.Mutex
+}
+
+// OpenSumFile opens a checksum file creating it if necessary.
+func OpenSumFile(path string) (f *SumFile, err error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SumFile{file: file}, nil
+}
+
+// Get returns the checksum of a segment (zero if it does not have one).
+func (f *SumFile) Get(i int64) (sum uint64, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	buf := make([]byte, 8)
+	if _, err := f.file.ReadAt(buf, i*8); err == io.EOF {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint64(buf), nil
+}
+
+// Set sets the checksum of a segment. Use Sync to write it to the disk.
+func (f *SumFile) Set(i int64, sum uint64) (err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, sum)
+	_, err = f.file.WriteAt(buf, i*8)
+	return err
+}
+
+// Sync writes checksums to the disk.
+func (f *SumFile) Sync() (err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.file.Sync()
+}
+
+// Truncate removes checksums of segments starting from segment n.
+func (f *SumFile) Truncate(n int64) (err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	info, err := f.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() <= n*8 {
+		return nil
+	}
+
+	return f.file.Truncate(n * 8)
+}
+
+// Close closes the checksum file.
+func (f *SumFile) Close() (err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.file.Close()
+}
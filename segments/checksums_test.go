@@ -0,0 +1,63 @@
+package segments
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	p := []byte("hello world")
+	sum, err := ChecksumReader(bytes.NewReader(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sum == 0 || sum != Checksum(p) {
+		t.Fatal("wrong checksum")
+	}
+}
+
+func TestSumFile(t *testing.T) {
+	path := "/tmp/test-segments-sums"
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(path)
+
+	f, err := OpenSumFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	if sum, err := f.Get(3); err != nil || sum != 0 {
+		t.Fatal("expected no checksum")
+	}
+
+	if err := f.Set(3, 123); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if sum, err := f.Get(3); err != nil || sum != 123 {
+		t.Fatal("wrong checksum")
+	}
+
+	if sum, err := f.Get(1); err != nil || sum != 0 {
+		t.Fatal("expected no checksum")
+	}
+
+	if err := f.Truncate(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if sum, err := f.Get(3); err != nil || sum != 0 {
+		t.Fatal("checksum should be removed")
+	}
+}
@@ -0,0 +1,102 @@
+package segments
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	// HeaderVersion is the current version of the segment store format.
+	HeaderVersion = 1
+
+	// header file size (magic, version and segment size)
+	headerSize = 16
+)
+
+// headerMagic identifies segment store header files
+var headerMagic = []byte("KSEG")
+
+var (
+	// ErrHeader is returned when the header file is not a segment store
+	// header or it was created with an unsupported format version.
+	ErrHeader = errors.New("bad segment store header")
+)
+
+// CheckHeader validates the header file of a segment store. The header
+// records the format version and the segment size used to create the store.
+// If the header file does not exist, it's created unless the store is opened
+// read-only. Stores created before header files were added may already have
+// segments so the check function is called to validate existing segments
+// before creating the header (ex. CheckSegSize). ErrSegSize is returned if
+// the store was created with a different segment size.
+func CheckHeader(path string, size int64, readOnly bool, check func() error) (err error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		if check != nil {
+			if err := check(); err != nil {
+				return err
+			}
+		}
+
+		if readOnly {
+			return nil
+		}
+
+		return writeHeader(path, size)
+	} else if err != nil {
+		return err
+	}
+
+	sz, err := decodeHeader(data)
+	if err != nil {
+		return err
+	}
+
+	if sz != size {
+		return ErrSegSize
+	}
+
+	return nil
+}
+
+// writeHeader creates a header file and syncs it
+func writeHeader(path string, size int64) (err error) {
+	data := encodeHeader(size)
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// encodeHeader creates header data for given segment size
+func encodeHeader(size int64) []byte {
+	data := make([]byte, headerSize)
+	copy(data, headerMagic)
+	binary.LittleEndian.PutUint32(data[4:], HeaderVersion)
+	binary.LittleEndian.PutUint64(data[8:], uint64(size))
+	return data
+}
+
+// decodeHeader validates header data and returns the segment size
+func decodeHeader(data []byte) (size int64, err error) {
+	if len(data) != headerSize || string(data[:4]) != string(headerMagic) {
+		return 0, ErrHeader
+	}
+
+	if v := binary.LittleEndian.Uint32(data[4:]); v != HeaderVersion {
+		return 0, ErrHeader
+	}
+
+	return int64(binary.LittleEndian.Uint64(data[8:])), nil
+}
@@ -0,0 +1,51 @@
+package segments
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestCheckHeader(t *testing.T) {
+	path := "/tmp/test-segments-header"
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(path)
+
+	// existing segments are checked before creating the header
+	bad := func() error { return ErrSegSize }
+	if err := CheckHeader(path, 10, false, bad); err != ErrSegSize {
+		t.Fatal("expected ErrSegSize")
+	}
+
+	// read-only stores do not create the header
+	if err := CheckHeader(path, 10, true, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("header should not be created")
+	}
+
+	if err := CheckHeader(path, 10, false, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckHeader(path, 10, false, bad); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckHeader(path, 20, false, nil); err != ErrSegSize {
+		t.Fatal("expected ErrSegSize")
+	}
+
+	if err := ioutil.WriteFile(path, []byte("not a header"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckHeader(path, 10, false, nil); err != ErrHeader {
+		t.Fatal("expected ErrHeader")
+	}
+}
@@ -0,0 +1,45 @@
+package segments
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/kadirahq/go-tools/fsutils"
+)
+
+var (
+	// ErrReadOnly is returned when the user attempts to change a store
+	// which was opened read-only.
+	ErrReadOnly = errors.New("store is read-only")
+)
+
+// LockStore takes an advisory lock on the lock file of a segment store
+// (base + "lock") so multiple processes cannot corrupt the store by writing
+// to it at the same time. Writers should use exclusive locks and read-only
+// users should use shared locks. It waits upto given timeout for the lock
+// and returns fsutils.ErrLocked if another process is using the store.
+// The lock is released when the returned file is closed. The store is not
+// locked on platforms which do not support file locks. Shared locks do not
+// create the lock file so read-only users can open stores without write
+// access, a not exist error is returned if the store was never opened to
+// write.
+func LockStore(base string, exclusive bool, timeout time.Duration) (file *os.File, err error) {
+	if exclusive {
+		file, err = os.OpenFile(base+"lock", os.O_RDWR|os.O_CREATE, 0644)
+	} else {
+		file, err = os.Open(base + "lock")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = fsutils.WaitLock(file, exclusive, timeout)
+	if err != nil && err != fsutils.ErrUnsupported {
+		file.Close()
+		return nil, err
+	}
+
+	return file, nil
+}
@@ -0,0 +1,64 @@
+package segments
+
+import (
+	"os"
+	"testing"
+
+	"github.com/kadirahq/go-tools/fsutils"
+)
+
+func TestLockStore(t *testing.T) {
+	base := "/tmp/test-segments-lock_"
+	if err := os.RemoveAll(base + "lock"); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(base + "lock")
+
+	// shared locks do not create the lock file
+	if _, err := LockStore(base, false, 0); !os.IsNotExist(err) {
+		t.Fatal("expected a not exist error")
+	}
+
+	c, err := LockStore(base, true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r1, err := LockStore(base, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r2, err := LockStore(base, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LockStore(base, true, 0); err != fsutils.ErrLocked {
+		t.Fatal("expected ErrLocked")
+	}
+
+	if err := r1.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r2.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := LockStore(base, true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+
+	if _, err := LockStore(base, false, 0); err != fsutils.ErrLocked {
+		t.Fatal("expected ErrLocked")
+	}
+}
@@ -0,0 +1,186 @@
+package segments
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"sync"
+)
+
+const (
+	// RecordHeaderSize is the size of the frame header written before each
+	// record (the record size and a checksum of the size and the record).
+	RecordHeaderSize = 8
+
+	// MaxRecordSize is the maximum size of a record in bytes.
+	MaxRecordSize = 1<<32 - 1
+)
+
+var (
+	// ErrRecord is returned when a record frame is not valid. This happens
+	// when the record was partially written before a crash (a torn write)
+	// or when the offset is not the start of a record.
+	ErrRecord = errors.New("bad record frame")
+
+	// ErrRecordSize is returned when the record is larger than MaxRecordSize.
+	ErrRecordSize = errors.New("record is too large")
+)
+
+// Appender appends framed records to a segment store. Each record has a
+// header with its size and a checksum so a record is either read completely
+// or it's detected as invalid if it was not fully written before a crash.
+// Records can be written across segment boundaries. Use ReadRecord to read
+// records. All methods are safe to use concurrently.
+type Appender struct {
+	store Store
+	tail  int64
+	mutex sync.Mutex
+}
+
+// NewAppender creates an appender which writes records to the store
+// starting from given offset. Existing records after the offset are scanned
+// to find where they end. If the last record was partially written before a
+// crash, it's cleared so that it cannot be mistaken for a valid record when
+// new records are written over it.
+func NewAppender(s Store, off int64) (a *Appender, err error) {
+	a = &Appender{store: s, tail: off}
+
+	for {
+		_, next, err := ReadRecord(s, a.tail)
+		if err == io.EOF {
+			return a, nil
+		} else if err == ErrRecord {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		a.tail = next
+	}
+
+	torn, err := a.torn()
+	if err != nil {
+		return nil, err
+	}
+
+	if torn {
+		if err := a.clear(); err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+// Append writes a record after the last record and returns its offset.
+// The frame is written with a single write. The record may not be on the
+// disk until the store is synced.
+func (a *Appender) Append(p []byte) (off int64, err error) {
+	if int64(len(p)) > MaxRecordSize {
+		return 0, ErrRecordSize
+	}
+
+	rec := make([]byte, RecordHeaderSize+len(p))
+	binary.LittleEndian.PutUint32(rec[0:], uint32(len(p)))
+	copy(rec[RecordHeaderSize:], p)
+	binary.LittleEndian.PutUint32(rec[4:], recordSum(rec[0:4], p))
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	off = a.tail
+	if _, err := a.store.WriteAt(rec, off); err != nil {
+		return 0, err
+	}
+
+	a.tail += int64(len(rec))
+	return off, nil
+}
+
+// Tail returns the offset where the next record will be written.
+func (a *Appender) Tail() (off int64) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.tail
+}
+
+// torn checks whether there's a partially written record at the tail.
+// Stores are filled with zeroes so anything else was written before.
+func (a *Appender) torn() (torn bool, err error) {
+	head := make([]byte, RecordHeaderSize)
+	n, err := a.store.ReadAt(head, a.tail)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+
+	return !IsZero(head[:n]), nil
+}
+
+// clear removes data after the tail. The store is truncated and data
+// after the tail in the last segment is filled with zeroes.
+func (a *Appender) clear() (err error) {
+	if err := a.store.Truncate(a.tail); err != nil {
+		return err
+	}
+
+	sz, err := a.store.Size()
+	if err != nil {
+		return err
+	}
+
+	if sz <= a.tail {
+		return nil
+	}
+
+	_, err = a.store.WriteAt(make([]byte, sz-a.tail), a.tail)
+	return err
+}
+
+// ReadRecord reads the record at given offset and returns the offset of
+// the next record. ErrRecord is returned if the record is not valid and
+// io.EOF is returned if there's no record at the offset (the store only has
+// zeroes or ends there).
+func ReadRecord(s Store, off int64) (p []byte, next int64, err error) {
+	head := make([]byte, RecordHeaderSize)
+	n, err := s.ReadAt(head, off)
+	if err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+
+	if IsZero(head[:n]) {
+		return nil, 0, io.EOF
+	} else if n < RecordHeaderSize {
+		return nil, 0, ErrRecord
+	}
+
+	// avoid allocating memory for records with corrupted sizes
+	sz := int64(binary.LittleEndian.Uint32(head[0:]))
+	end, err := s.Size()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if off+RecordHeaderSize+sz > end {
+		return nil, 0, ErrRecord
+	}
+
+	p = make([]byte, sz)
+	if _, err := s.ReadAt(p, off+RecordHeaderSize); err == io.EOF {
+		return nil, 0, ErrRecord
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	if binary.LittleEndian.Uint32(head[4:]) != recordSum(head[0:4], p) {
+		return nil, 0, ErrRecord
+	}
+
+	return p, off + RecordHeaderSize + sz, nil
+}
+
+// recordSum calculates the checksum of the size field and the record
+func recordSum(sz, p []byte) uint32 {
+	sum := crc32.ChecksumIEEE(sz)
+	return crc32.Update(sum, crc32.IEEETable, p)
+}
@@ -0,0 +1,113 @@
+package segments
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/kadirahq/go-tools/fs/memstore"
+)
+
+func TestAppender(t *testing.T) {
+	s := memstore.New()
+
+	a, err := NewAppender(s, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recs := [][]byte{[]byte("hello"), {}, bytes.Repeat([]byte("x"), 100)}
+	offs := make([]int64, len(recs))
+
+	for i, rec := range recs {
+		if offs[i], err = a.Append(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if offs[1] != RecordHeaderSize+5 {
+		t.Fatal("wrong offset")
+	}
+
+	off := int64(0)
+	for i, rec := range recs {
+		p, next, err := ReadRecord(s, off)
+		if err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(p, rec) {
+			t.Fatal("wrong record", i)
+		}
+
+		off = next
+	}
+
+	if _, _, err := ReadRecord(s, off); err != io.EOF {
+		t.Fatal("expected io.EOF")
+	}
+
+	if _, _, err := ReadRecord(s, 1); err != ErrRecord {
+		t.Fatal("expected ErrRecord")
+	}
+
+	// existing records are scanned to find the end
+	a, err = NewAppender(s, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if a.Tail() != off {
+		t.Fatal("wrong tail")
+	}
+}
+
+func TestAppenderTorn(t *testing.T) {
+	s := memstore.New()
+
+	a, err := NewAppender(s, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.Append([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	off, err := a.Append([]byte("world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := a.Append([]byte("later"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the second record was partially written but the next one was not
+	if _, err := s.WriteAt([]byte("W"), off+RecordHeaderSize); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := ReadRecord(s, off); err != ErrRecord {
+		t.Fatal("expected ErrRecord")
+	}
+
+	a, err = NewAppender(s, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if a.Tail() != off {
+		t.Fatal("torn record should be the end")
+	}
+
+	// records after the torn record should be cleared
+	if _, _, err := ReadRecord(s, next); err != io.EOF {
+		t.Fatal("expected io.EOF")
+	}
+
+	if _, err := a.Append([]byte("again")); err != nil {
+		t.Fatal(err)
+	}
+
+	if p, _, err := ReadRecord(s, off); err != nil {
+		t.Fatal(err)
+	} else if string(p) != "again" {
+		t.Fatal("wrong record")
+	}
+}
@@ -0,0 +1,670 @@
+package segfile
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kadirahq/go-tools/function"
+	"github.com/kadirahq/go-tools/memmap"
+	"github.com/kadirahq/go-tools/secure"
+)
+
+const (
+	// DefaultSyncInterval is the sync interval used by NewMetadata.
+	// Sync calls made within this interval are coalesced together.
+	DefaultSyncInterval = 20 * time.Millisecond
+
+	// size of the segs, size and used fields
+	mheadSize = 24
+
+	// size of the slot header (generation, checksum, length, version)
+	// version 1 slots did not have the version field in the header
+	mslotHead   = 20
+	mslotHeadV1 = 16
+)
+
+// MetaVersion is the metadata format version written by this package.
+// Older versions are loaded and converted to this version on next flush.
+//
+//	version 0: fields written at the start of the file without slots
+//	version 1: A/B slots with generation, checksum and length
+//	version 2: A/B slots with format version in the slot header
+//	version 3: checksum also covers the generation, length and version
+const MetaVersion = 3
+
+var (
+	// ErrMetaFull is returned when encoded metadata does not fit in the
+	// metadata file. Use a larger metadata file or store less user data.
+	ErrMetaFull = errors.New("metadata does not fit in file")
+
+	// ErrMetaBad is returned when the metadata file has invalid content.
+	// This can be caused by corrupted files or a wrong file path.
+	ErrMetaBad = errors.New("invalid metadata content")
+
+	// ErrMetaRead is returned when the user attempts to modify or sync
+	// metadata loaded with ReadMetadata. It's only a snapshot.
+	ErrMetaRead = errors.New("metadata is read only")
+
+	// ErrMetaVersion is returned when the metadata file is written with
+	// a newer format version which is not supported by this package.
+	ErrMetaVersion = errors.New("unsupported metadata version")
+
+	// ErrMetaClosed is returned when the user attempts to sync metadata
+	// after closing it. Sync calls waiting while closing also get it.
+	ErrMetaClosed = errors.New("metadata is closed")
+
+	// ErrMetaKey is returned when a user defined key is longer than
+	// MaxMetaKey bytes. Key lengths are stored as 16 bit integers.
+	ErrMetaKey = errors.New("metadata key is too long")
+)
+
+// MaxMetaKey is the maximum length of user defined keys in bytes.
+const MaxMetaKey = 1<<16 - 1
+
+// Metadata persists information about a segment store in a memory mapped
+// file. In addition to segs/size/used fields, applications can store their
+// own values using keys. Changes are written to the file in the background
+// and concurrent sync calls are coalesced into a single msync call.
+//
+// The file is split into two slots and each flush writes to the slot which
+// is not currently in use with a higher generation number and a checksum.
+// If the process crashes while writing, the other slot is still valid and
+// it's loaded automatically when the metadata file is opened again.
+type Metadata struct {
+	gen   uint64
+	ver   uint32
+	segs  int64
+	size  int64
+	used  int64
+	ints  map[string]int64
+	blobs map[string][]byte
+	mutex sync.RWMutex
+	dirty bool
+	mmap  *memmap.Map
+	group *function.Group
+	tick  *function.Ticker
+	watch *function.Ticker
+	ctx   context.Context
+	stop  context.CancelFunc
+	shut  secure.Bool
+}
+
+// NewMetadata creates or loads a metadata file on given path.
+// The file will be created with given size if it does not exist.
+func NewMetadata(path string, sz int64) (m *Metadata, err error) {
+	return NewMetadataInterval(path, sz, DefaultSyncInterval)
+}
+
+// NewMetadataInterval creates or loads a metadata file on given path which
+// syncs changes to the disk every interval. Use a smaller interval to reduce
+// the Sync latency or a larger interval to reduce the number of msync calls.
+// DefaultSyncInterval is used if the interval is not positive.
+func NewMetadataInterval(path string, sz int64, interval time.Duration) (m *Metadata, err error) {
+	if interval <= 0 {
+		interval = DefaultSyncInterval
+	}
+
+	mmap, err := memmap.New(path, sz)
+	if err != nil {
+		return nil, err
+	}
+
+	m = &Metadata{mmap: mmap}
+	if err := m.load(mmap.Data); err != nil {
+		mmap.Close()
+		return nil, err
+	}
+
+	m.group = function.NewGroupE(m.flush)
+	m.tick = function.NewTicker(func() { m.group.Flush() }, interval)
+	m.ctx, m.stop = context.WithCancel(context.Background())
+	m.tick.Start()
+
+	return m, nil
+}
+
+// ReadMetadata reads a metadata file on given path without memory mapping it.
+// The result is only a snapshot and it cannot be modified or synced to disk.
+func ReadMetadata(path string) (m *Metadata, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m = &Metadata{}
+	if err := m.load(data); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// MetaSlot describes a slot of a metadata file.
+// Unused slots have a zero generation number.
+type MetaSlot struct {
+	Gen     uint64
+	Version uint32
+	Length  int
+	Valid   bool
+}
+
+// CheckMetadata reads a metadata file and checks both slots. Files written
+// with version 0 do not have slots and their slots will not be valid.
+func CheckMetadata(path string) (slots []MetaSlot, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	slots = make([]MetaSlot, 2)
+	for i := uint64(0); i < 2; i++ {
+		slot := mslot(data, i)
+		if len(slot) < mslotHead {
+			return nil, ErrMetaBad
+		}
+
+		ms := &slots[i]
+		ms.Gen = binary.LittleEndian.Uint64(slot[0:])
+		if ms.Gen == 0 {
+			continue
+		}
+
+		if p, v := mcheck(slot); p != nil {
+			ms.Version = v
+			ms.Length = len(p)
+			ms.Valid = true
+		}
+	}
+
+	return slots, nil
+}
+
+// WatchMetadata reads a metadata file on given path similar to ReadMetadata
+// but it reloads the file every interval if it's changed. This can be used to
+// follow metadata of a store used by another process. If the file cannot be
+// loaded, previous values are kept until the next successful reload.
+// If the interval is not positive, the file is not watched after loading.
+// Use the Close method to stop watching the file.
+func WatchMetadata(path string, interval time.Duration) (m *Metadata, err error) {
+	m, err = ReadMetadata(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if interval > 0 {
+		m.watch = function.NewTicker(func() { m.reload(path) }, interval)
+		m.watch.Start()
+	}
+
+	return m, nil
+}
+
+// Segs returns the number of segments
+func (m *Metadata) Segs() int64 {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.segs
+}
+
+// Size returns the segment size
+func (m *Metadata) Size() int64 {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.size
+}
+
+// Used returns the number of bytes used in the store
+func (m *Metadata) Used() int64 {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.used
+}
+
+// Version returns the format version of the loaded metadata file.
+// It will be changed to MetaVersion after writing it to the disk.
+func (m *Metadata) Version() uint32 {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.ver
+}
+
+// SetSegs sets the number of segments
+func (m *Metadata) SetSegs(n int64) {
+	m.update(func() { m.segs = n })
+}
+
+// SetSize sets the segment size
+func (m *Metadata) SetSize(n int64) {
+	m.update(func() { m.size = n })
+}
+
+// SetUsed sets the number of bytes used in the store
+func (m *Metadata) SetUsed(n int64) {
+	m.update(func() { m.used = n })
+}
+
+// Int returns a user defined integer value stored with given key.
+// The boolean result will be false if the key does not exist.
+func (m *Metadata) Int(key string) (val int64, ok bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	val, ok = m.ints[key]
+	return val, ok
+}
+
+// SetInt stores a user defined integer value with given key.
+// ErrMetaKey is returned if the key is longer than MaxMetaKey.
+func (m *Metadata) SetInt(key string, val int64) (err error) {
+	if len(key) > MaxMetaKey {
+		return ErrMetaKey
+	}
+
+	m.update(func() {
+		if m.ints == nil {
+			m.ints = map[string]int64{}
+		}
+
+		m.ints[key] = val
+	})
+
+	return nil
+}
+
+// Bytes returns a copy of a user defined byte slice stored with given key.
+// The boolean result will be false if the key does not exist.
+func (m *Metadata) Bytes(key string) (val []byte, ok bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	val, ok = m.blobs[key]
+	if !ok {
+		return nil, false
+	}
+
+	return append([]byte{}, val...), true
+}
+
+// SetBytes stores a copy of a user defined byte slice with given key.
+// ErrMetaKey is returned if the key is longer than MaxMetaKey.
+func (m *Metadata) SetBytes(key string, val []byte) (err error) {
+	if len(key) > MaxMetaKey {
+		return ErrMetaKey
+	}
+
+	val = append([]byte{}, val...)
+
+	m.update(func() {
+		if m.blobs == nil {
+			m.blobs = map[string][]byte{}
+		}
+
+		m.blobs[key] = val
+	})
+
+	return nil
+}
+
+// Keys returns sorted keys of user defined integers and byte slices
+func (m *Metadata) Keys() (ints, blobs []string) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	ints = make([]string, 0, len(m.ints))
+	for k := range m.ints {
+		ints = append(ints, k)
+	}
+
+	blobs = make([]string, 0, len(m.blobs))
+	for k := range m.blobs {
+		blobs = append(blobs, k)
+	}
+
+	sort.Strings(ints)
+	sort.Strings(blobs)
+	return ints, blobs
+}
+
+// Sync blocks until metadata changes are written to the disk.
+// Sync calls made by multiple goroutines are grouped together.
+func (m *Metadata) Sync() (err error) {
+	if m.mmap == nil {
+		return ErrMetaRead
+	}
+
+	if m.shut.Get() {
+		return ErrMetaClosed
+	}
+
+	err = m.group.RunContext(m.ctx)
+	if err == context.Canceled {
+		return ErrMetaClosed
+	}
+
+	return err
+}
+
+// Close stops the background sync loop, writes metadata changes to the disk
+// and closes the file. Goroutines waiting on Sync are released before it
+// returns. Calling Close more than once has no effect.
+func (m *Metadata) Close() (err error) {
+	if !m.shut.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	if m.watch != nil {
+		m.watch.Stop()
+	}
+
+	if m.mmap == nil {
+		return nil
+	}
+
+	// the ticker goroutine exits before Stop returns
+	// release goroutines waiting on Sync after that
+	m.tick.Stop()
+	ferr := m.group.Flush()
+	m.stop()
+
+	if err := m.flush(); err != nil {
+		return err
+	}
+
+	if err := m.mmap.Close(); err != nil {
+		return err
+	}
+
+	return ferr
+}
+
+// reload loads the file again and updates fields if the generation changed
+func (m *Metadata) reload(path string) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	tmp := &Metadata{}
+	if err := tmp.load(data); err != nil {
+		return
+	}
+
+	m.mutex.Lock()
+	if tmp.gen != m.gen || tmp.ver != m.ver {
+		m.gen = tmp.gen
+		m.ver = tmp.ver
+		m.segs = tmp.segs
+		m.size = tmp.size
+		m.used = tmp.used
+		m.ints = tmp.ints
+		m.blobs = tmp.blobs
+	}
+	m.mutex.Unlock()
+}
+
+// update runs the function with the write lock and marks it as dirty
+func (m *Metadata) update(fn func()) {
+	m.mutex.Lock()
+	fn()
+	m.dirty = true
+	m.mutex.Unlock()
+}
+
+// flush writes metadata to the memory map and syncs it to the disk
+func (m *Metadata) flush() (err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !m.dirty {
+		return nil
+	}
+
+	data := m.encode()
+	gen := m.gen + 1
+
+	slot := mslot(m.mmap.Data, gen)
+	if len(data) > len(slot)-mslotHead {
+		return ErrMetaFull
+	}
+
+	// a partially written slot will fail the checksum test
+	// and the previous generation will be loaded instead
+	copy(slot[mslotHead:], data)
+	enc := binary.LittleEndian
+	enc.PutUint32(slot[16:], MetaVersion)
+	enc.PutUint32(slot[12:], uint32(len(data)))
+	enc.PutUint64(slot[0:], gen)
+	enc.PutUint32(slot[8:], msum(slot, len(data)))
+
+	if err := m.mmap.Sync(); err != nil {
+		return err
+	}
+
+	m.gen = gen
+	m.ver = MetaVersion
+	m.dirty = false
+	return nil
+}
+
+// load decodes metadata from the valid slot with the highest generation.
+// If the file is empty, metadata fields will have their zero values.
+// Files written with older format versions are also loaded here.
+func (m *Metadata) load(data []byte) (err error) {
+	var best []byte
+	var gen uint64
+	var ver uint32
+
+	for i := uint64(0); i < 2; i++ {
+		slot := mslot(data, i)
+		if len(slot) < mslotHead {
+			return ErrMetaBad
+		}
+
+		g := binary.LittleEndian.Uint64(slot[0:])
+		if g == 0 {
+			continue
+		}
+
+		if p, v := mcheck(slot); p != nil && g > gen {
+			best, gen, ver = p, g, v
+		}
+	}
+
+	if best != nil {
+		if ver > MetaVersion {
+			return ErrMetaVersion
+		}
+
+		m.gen = gen
+		m.ver = ver
+		_, err := m.decode(best)
+		return err
+	}
+
+	// the first flush writes to the second slot so the first slot
+	// is still empty if the process crashed while writing it
+	if mzero(mslot(data, 0)) {
+		m.ver = MetaVersion
+		m.ints = map[string]int64{}
+		m.blobs = map[string][]byte{}
+		return nil
+	}
+
+	// version 0 files do not have a checksum
+	// trailing bytes must be zero to accept it
+	if rest, err := m.decode(data); err == nil && mzero(rest) {
+		m.ver = 0
+		return nil
+	}
+
+	return ErrMetaBad
+}
+
+// mslot returns the slot used to store given generation.
+func mslot(data []byte, gen uint64) (slot []byte) {
+	half := len(data) / 2
+	if gen%2 == 0 {
+		return data[:half]
+	}
+
+	return data[half : 2*half]
+}
+
+// mcheck returns the payload and the format version if the slot has a valid
+// checksum. The checksum also tells whether the slot has a version field.
+func mcheck(slot []byte) (p []byte, ver uint32) {
+	enc := binary.LittleEndian
+	sum := enc.Uint32(slot[8:])
+	sz := int(enc.Uint32(slot[12:]))
+
+	if sz <= len(slot)-mslotHead {
+		p, ver = slot[mslotHead:mslotHead+sz], enc.Uint32(slot[16:])
+		if ver >= 3 && msum(slot, sz) == sum {
+			return p, ver
+		}
+
+		// version 2 checksum only covers the version and the payload
+		if ver == 2 && crc32.ChecksumIEEE(slot[16:mslotHead+sz]) == sum {
+			return p, ver
+		}
+	}
+
+	if sz <= len(slot)-mslotHeadV1 {
+		if p = slot[mslotHeadV1 : mslotHeadV1+sz]; crc32.ChecksumIEEE(p) == sum {
+			return p, 1
+		}
+	}
+
+	return nil, 0
+}
+
+// msum calculates the checksum of a slot with given payload size. It covers
+// all slot header fields except the checksum and the payload.
+func msum(slot []byte, sz int) uint32 {
+	sum := crc32.ChecksumIEEE(slot[0:8])
+	return crc32.Update(sum, crc32.IEEETable, slot[12:mslotHead+sz])
+}
+
+// mzero checks whether all bytes are zero
+func mzero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// encode encodes metadata fields into a byte slice.
+// Keys are sorted so the result is always the same.
+func (m *Metadata) encode() (data []byte) {
+	enc := binary.LittleEndian
+	data = make([]byte, mheadSize, 512)
+
+	enc.PutUint64(data[0:], uint64(m.segs))
+	enc.PutUint64(data[8:], uint64(m.size))
+	enc.PutUint64(data[16:], uint64(m.used))
+
+	ikeys := make([]string, 0, len(m.ints))
+	for k := range m.ints {
+		ikeys = append(ikeys, k)
+	}
+
+	bkeys := make([]string, 0, len(m.blobs))
+	for k := range m.blobs {
+		bkeys = append(bkeys, k)
+	}
+
+	sort.Strings(ikeys)
+	sort.Strings(bkeys)
+
+	data = enc.AppendUint32(data, uint32(len(ikeys)))
+	for _, k := range ikeys {
+		data = enc.AppendUint16(data, uint16(len(k)))
+		data = append(data, k...)
+		data = enc.AppendUint64(data, uint64(m.ints[k]))
+	}
+
+	data = enc.AppendUint32(data, uint32(len(bkeys)))
+	for _, k := range bkeys {
+		v := m.blobs[k]
+		data = enc.AppendUint16(data, uint16(len(k)))
+		data = append(data, k...)
+		data = enc.AppendUint32(data, uint32(len(v)))
+		data = append(data, v...)
+	}
+
+	return data
+}
+
+// decode decodes metadata fields from a byte slice.
+// Trailing bytes after encoded fields are returned.
+func (m *Metadata) decode(data []byte) (rest []byte, err error) {
+	r := &reader{data: data}
+
+	m.segs = int64(r.uint64())
+	m.size = int64(r.uint64())
+	m.used = int64(r.uint64())
+	m.ints = map[string]int64{}
+	m.blobs = map[string][]byte{}
+
+	for i, n := uint32(0), r.uint32(); i < n && r.err == nil; i++ {
+		k := string(r.bytes(int(r.uint16())))
+		m.ints[k] = int64(r.uint64())
+	}
+
+	for i, n := uint32(0), r.uint32(); i < n && r.err == nil; i++ {
+		k := string(r.bytes(int(r.uint16())))
+		m.blobs[k] = append([]byte{}, r.bytes(int(r.uint32()))...)
+	}
+
+	return r.data, r.err
+}
+
+// reader reads little endian values from a byte slice
+// If there are not enough bytes, the err field is set.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) bytes(n int) (p []byte) {
+	if r.err != nil || n > len(r.data) {
+		r.err = ErrMetaBad
+		return nil
+	}
+
+	p = r.data[:n]
+	r.data = r.data[n:]
+	return p
+}
+
+func (r *reader) uint16() uint16 {
+	if p := r.bytes(2); p != nil {
+		return binary.LittleEndian.Uint16(p)
+	}
+
+	return 0
+}
+
+func (r *reader) uint32() uint32 {
+	if p := r.bytes(4); p != nil {
+		return binary.LittleEndian.Uint32(p)
+	}
+
+	return 0
+}
+
+func (r *reader) uint64() uint64 {
+	if p := r.bytes(8); p != nil {
+		return binary.LittleEndian.Uint64(p)
+	}
+
+	return 0
+}
@@ -0,0 +1,509 @@
+package segfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+var (
+	tmpmeta = tmpdir + "meta"
+)
+
+func TestMetadata(t *testing.T) {
+	defer setup(t)()
+
+	m, err := NewMetadata(tmpmeta, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Segs() != 0 || m.Size() != 0 || m.Used() != 0 {
+		t.Fatal("wrong values")
+	}
+
+	m.SetSegs(2)
+	m.SetSize(10)
+	m.SetUsed(15)
+	m.SetInt("foo", 5)
+	m.SetBytes("bar", []byte("baz"))
+
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := ReadMetadata(tmpmeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Segs() != 2 || r.Size() != 10 || r.Used() != 15 {
+		t.Fatal("wrong values")
+	}
+
+	if v, ok := r.Int("foo"); !ok || v != 5 {
+		t.Fatal("wrong value")
+	}
+
+	if v, ok := r.Bytes("bar"); !ok || !bytes.Equal(v, []byte("baz")) {
+		t.Fatal("wrong value")
+	}
+
+	if _, ok := r.Int("bar"); ok {
+		t.Fatal("should not exist")
+	}
+
+	if err := r.Sync(); err != ErrMetaRead {
+		t.Fatal("wrong error")
+	}
+
+	m.SetUsed(20)
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err = NewMetadata(tmpmeta, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer m.Close()
+
+	if m.Used() != 20 {
+		t.Fatal("wrong value")
+	}
+
+	if v, ok := m.Int("foo"); !ok || v != 5 {
+		t.Fatal("wrong value")
+	}
+}
+
+func TestMetadataKey(t *testing.T) {
+	defer setup(t)()
+
+	m, err := NewMetadata(tmpmeta, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer m.Close()
+
+	key := string(make([]byte, MaxMetaKey+1))
+	if err := m.SetInt(key, 1); err != ErrMetaKey {
+		t.Fatal("expected ErrMetaKey")
+	}
+
+	if err := m.SetBytes(key, nil); err != ErrMetaKey {
+		t.Fatal("expected ErrMetaKey")
+	}
+
+	if _, ok := m.Int(key); ok {
+		t.Fatal("should not exist")
+	}
+}
+
+func TestMetadataFull(t *testing.T) {
+	defer setup(t)()
+
+	m, err := NewMetadata(tmpmeta, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer m.Close()
+
+	m.SetBytes("foo", make([]byte, 200))
+	if err := m.Sync(); err != ErrMetaFull {
+		t.Fatal("wrong error")
+	}
+
+	m.SetBytes("foo", nil)
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMetadataRecover(t *testing.T) {
+	defer setup(t)()
+
+	m, err := NewMetadata(tmpmeta, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.SetUsed(10)
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	m.SetUsed(20)
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// corrupt the slot with the latest generation
+	slot := mslot(m.mmap.Data, m.gen)
+	slot[mslotHead+16]++
+
+	if err := m.mmap.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err = NewMetadata(tmpmeta, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer m.Close()
+
+	if m.Used() != 10 {
+		t.Fatal("should load previous generation")
+	}
+
+	// both slots are corrupted
+	slot = mslot(m.mmap.Data, m.gen)
+	slot[mslotHead+16]++
+
+	if _, err := ReadMetadata(tmpmeta); err != ErrMetaBad {
+		t.Fatal("wrong error")
+	}
+}
+
+func TestMetadataTornFirst(t *testing.T) {
+	defer setup(t)()
+
+	m, err := NewMetadata(tmpmeta, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.SetUsed(10)
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first flush was torn
+	slot := mslot(m.mmap.Data, m.gen)
+	slot[mslotHead+16]++
+
+	if err := m.mmap.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err = NewMetadata(tmpmeta, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Used() != 0 {
+		t.Fatal("should load empty metadata")
+	}
+
+	m.SetUsed(20)
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := ReadMetadata(tmpmeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Used() != 20 {
+		t.Fatal("wrong value")
+	}
+}
+
+func TestMetadataTornGen(t *testing.T) {
+	defer setup(t)()
+
+	m, err := NewMetadata(tmpmeta, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, used := range []int64{10, 20, 30} {
+		m.SetUsed(used)
+		if err := m.Sync(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a torn write changed the generation of the older slot
+	// so it looks newer than the slot with the latest value
+	slot := mslot(m.mmap.Data, m.gen-1)
+	binary.LittleEndian.PutUint64(slot[0:], m.gen+1)
+
+	if err := m.mmap.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := ReadMetadata(tmpmeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Used() != 30 {
+		t.Fatal("should ignore the slot with a bad generation")
+	}
+}
+
+func TestCheckMetadata(t *testing.T) {
+	defer setup(t)()
+
+	m, err := NewMetadata(tmpmeta, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.SetInt("b", 1)
+	m.SetInt("a", 2)
+	m.SetBytes("c", []byte("d"))
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	ints, blobs := m.Keys()
+	if len(ints) != 2 || ints[0] != "a" || ints[1] != "b" || len(blobs) != 1 {
+		t.Fatal("wrong keys")
+	}
+
+	slots, err := CheckMetadata(tmpmeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if slots[0].Gen != 0 || slots[1].Gen != 1 || !slots[1].Valid ||
+		slots[1].Version != MetaVersion || slots[1].Length == 0 {
+		t.Fatal("wrong slots")
+	}
+
+	// corrupt the slot
+	slot := mslot(m.mmap.Data, m.gen)
+	slot[mslotHead]++
+
+	slots, err = CheckMetadata(tmpmeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if slots[1].Gen != 1 || slots[1].Valid {
+		t.Fatal("expected invalid slot")
+	}
+
+	if err := m.mmap.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMetadataClose(t *testing.T) {
+	defer setup(t)()
+
+	m, err := NewMetadataInterval(tmpmeta, 1024, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.SetUsed(10)
+
+	done := make(chan error)
+	go func() {
+		done <- m.Sync()
+	}()
+
+	// wait for the goroutine to start waiting
+	time.Sleep(10 * time.Millisecond)
+
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Sync(); err != ErrMetaClosed {
+		t.Fatal("wrong error")
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := ReadMetadata(tmpmeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Used() != 10 {
+		t.Fatal("wrong value")
+	}
+}
+
+func TestMetadataVersions(t *testing.T) {
+	defer setup(t)()
+
+	src := &Metadata{used: 10, ints: map[string]int64{"foo": 5}}
+	payload := src.encode()
+
+	// version 0: fields at the start of the file
+	v0 := make([]byte, 1024)
+	copy(v0, payload)
+
+	// version 1: slot without the version field
+	v1 := make([]byte, 1024)
+	copy(v1[mslotHeadV1:], payload)
+	binary.LittleEndian.PutUint64(v1[0:], 1)
+	binary.LittleEndian.PutUint32(v1[8:], crc32.ChecksumIEEE(payload))
+	binary.LittleEndian.PutUint32(v1[12:], uint32(len(payload)))
+
+	// version 2: checksum does not cover the generation and the length
+	v2 := make([]byte, 1024)
+	copy(v2[mslotHead:], payload)
+	binary.LittleEndian.PutUint64(v2[0:], 1)
+	binary.LittleEndian.PutUint32(v2[16:], 2)
+	binary.LittleEndian.PutUint32(v2[8:], crc32.ChecksumIEEE(v2[16:mslotHead+len(payload)]))
+	binary.LittleEndian.PutUint32(v2[12:], uint32(len(payload)))
+
+	for ver, data := range [][]byte{v0, v1, v2} {
+		if err := ioutil.WriteFile(tmpmeta, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		m, err := NewMetadata(tmpmeta, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if m.Version() != uint32(ver) {
+			t.Fatal("wrong version")
+		}
+
+		if v, ok := m.Int("foo"); m.Used() != 10 || !ok || v != 5 {
+			t.Fatal("wrong values")
+		}
+
+		m.SetUsed(20)
+		if err := m.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := ReadMetadata(tmpmeta)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if r.Version() != MetaVersion || r.Used() != 20 {
+			t.Fatal("should be migrated")
+		}
+	}
+
+	// unsupported future version
+	m, err := NewMetadata(tmpmeta, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slot := mslot(m.mmap.Data, m.gen)
+	binary.LittleEndian.PutUint32(slot[16:], MetaVersion+1)
+	sz := binary.LittleEndian.Uint32(slot[12:])
+	binary.LittleEndian.PutUint32(slot[8:], msum(slot, int(sz)))
+
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadMetadata(tmpmeta); err != ErrMetaVersion {
+		t.Fatal("wrong error")
+	}
+}
+
+func TestWatchMetadata(t *testing.T) {
+	defer setup(t)()
+
+	m, err := NewMetadata(tmpmeta, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer m.Close()
+
+	m.SetUsed(10)
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := WatchMetadata(tmpmeta, 5*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Used() != 10 {
+		t.Fatal("wrong value")
+	}
+
+	m.SetUsed(20)
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if w.Used() != 20 {
+		t.Fatal("should reload")
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	m.SetUsed(30)
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if w.Used() != 20 {
+		t.Fatal("should not reload after closing")
+	}
+}
+
+func TestMetadataNoInterval(t *testing.T) {
+	defer setup(t)()
+
+	m, err := NewMetadataInterval(tmpmeta, 1024, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer m.Close()
+
+	// should sync using the default interval
+	m.SetUsed(10)
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := WatchMetadata(tmpmeta, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+
+	if w.Used() != 10 {
+		t.Fatal("wrong value")
+	}
+
+	m.SetUsed(20)
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if w.Used() != 10 {
+		t.Fatal("should not reload")
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"strconv"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/kadirahq/go-tools/fsutils"
 	"github.com/kadirahq/go-tools/segments"
 )
 
@@ -15,11 +17,32 @@ import (
 // the path to the segment file and the segment file prefix.
 // example: "/path/to/segment/files/prefix_"
 func LoadSegs(base string, size int64) (segs []*Segment, err error) {
+	return loadSegs(base, &Options{SegmentSize: size})
+}
+
+// loadSegs loads existing segment files using options
+// Read-only stores open them without write access.
+func loadSegs(base string, opts *Options) (segs []*Segment, err error) {
 	segs = []*Segment{}
 
-	for i := 0; true; i++ {
+	mode := os.O_RDWR
+	if opts.ReadOnly {
+		mode = os.O_RDONLY
+	}
+
+	first, err := segments.FirstIndex(base)
+	if err != nil {
+		return nil, err
+	}
+
+	// segments before the first one were removed
+	for i := int64(0); i < first; i++ {
+		segs = append(segs, &Segment{removed: 1})
+	}
+
+	for i := int(first); true; i++ {
 		path := base + strconv.Itoa(i)
-		seg, err := os.OpenFile(path, os.O_RDWR, 0644)
+		seg, err := os.OpenFile(path, mode, 0644)
 		if err != nil {
 			break
 		}
@@ -29,12 +52,12 @@ func LoadSegs(base string, size int64) (segs []*Segment, err error) {
 			return nil, err
 		}
 
-		if sz := info.Size(); sz != size {
+		if sz := info.Size(); sz != opts.SegmentSize {
 			err = segments.ErrSegSize
 			return nil, err
 		}
 
-		segs = append(segs, &Segment{seg, 0})
+		segs = append(segs, &Segment{File: seg})
 	}
 
 	return segs, nil
@@ -44,37 +67,142 @@ func LoadSegs(base string, size int64) (segs []*Segment, err error) {
 type Segment struct {
 	*os.File
 	dirty uint32
+
+	// set when the segment was removed (see Store.Remove)
+	removed uint32
+}
+
+// Options for segment stores
+type Options struct {
+	// Size of each segment file in bytes.
+	SegmentSize int64
+
+	// Checksums enables keeping a checksum for each segment in a sidecar
+	// file (base + "sums"). Checksums are updated when segments are synced
+	// and they're verified when the store is opened (see Store.Verify).
+	Checksums bool
+
+	// DisablePrealloc disables creating the next segment in the background
+	// before it's used. Segments are created when data is written to them.
+	DisablePrealloc bool
+
+	// ReadOnly opens the store with a shared lock so other read-only users
+	// can use it at the same time. Changing the store fails with
+	// segments.ErrReadOnly. Otherwise, an exclusive lock is used.
+	ReadOnly bool
+
+	// LockTimeout is the time to wait for other processes to release the
+	// store lock before returning fsutils.ErrLocked (see segments.LockStore).
+	LockTimeout time.Duration
 }
 
 // Store is a collection of segment files. Using a set of segment files can
 // be faster than using a single growing file. Also, it allocates faster.
 type Store struct {
-	segs  []*Segment
-	segmx *sync.RWMutex
 	base  string
 	size  int64
 	offs  int64
 	offmx *sync.Mutex
+
+	// lock file used to prevent other processes from changing the store
+	lock     *os.File
+	readonly bool
+
+	// loaded segments ([]*Segment) are replaced when segments are created
+	// or removed so they can be used without locking. The slice should not
+	// be modified after storing it. The mutex is used when changing it.
+	segs  atomic.Value
+	segmx *sync.Mutex
+
+	// segment checksums and segments which were
+	// corrupted when the store was opened
+	sums    *segments.SumFile
+	corrupt []int64
+
+	// segment indexes to create in the background (see Options.DisablePrealloc)
+	// the mutex protects the channel from being closed while sending to it
+	alloc   chan int64
+	allocmx sync.RWMutex
+	allocwg sync.WaitGroup
 }
 
+const (
+	// number of segments which can wait to be preallocated
+	allocQueue = 16
+)
+
 // New creates a collection of segment files on given path
 func New(base string, size int64) (s *Store, err error) {
-	segs, err := LoadSegs(base, size)
+	return Open(base, &Options{SegmentSize: size})
+}
+
+// Open creates a collection of segment files on given path with options
+// The segment size is recorded in a header file (base + "header") when the
+// store is created and opening it with a different size fails. The store is
+// locked while it's open so other processes cannot change it.
+func Open(base string, opts *Options) (s *Store, err error) {
+	lock, err := segments.LockStore(base, !opts.ReadOnly, opts.LockTimeout)
 	if err != nil {
 		return nil, err
 	}
 
+	check := func() error { return segments.CheckSegSize(base, opts.SegmentSize) }
+	if err := segments.CheckHeader(base+"header", opts.SegmentSize, opts.ReadOnly, check); err != nil {
+		lock.Close()
+		return nil, err
+	}
+
+	segs, err := loadSegs(base, opts)
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+
 	s = &Store{
-		segs:  segs,
-		segmx: &sync.RWMutex{},
-		base:  base,
-		size:  size,
-		offmx: &sync.Mutex{},
+		base:     base,
+		size:     opts.SegmentSize,
+		offmx:    &sync.Mutex{},
+		lock:     lock,
+		readonly: opts.ReadOnly,
+		segmx:    &sync.Mutex{},
+	}
+
+	s.segs.Store(segs)
+
+	if opts.Checksums {
+		s.sums, err = segments.OpenSumFile(base + "sums")
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+
+		s.corrupt, err = s.Verify()
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+
+	// read-only stores only use existing segments
+	if opts.ReadOnly {
+		return s, nil
+	}
+
+	// the first segment is preallocated while opening the store
+	first := int64(1)
+	if opts.DisablePrealloc {
+		first = 0
+	}
+
+	if err := s.ensure(first); err != nil {
+		s.Close()
+		return nil, err
 	}
 
-	if err := s.ensure(0); err != nil {
-		// TODO
-		_ = err
+	if !opts.DisablePrealloc {
+		s.alloc = make(chan int64, allocQueue)
+		s.allocwg.Add(1)
+		go s.allocate(s.alloc)
 	}
 
 	return s, nil
@@ -98,6 +226,87 @@ func (s *Store) Write(p []byte) (n int, err error) {
 	return n, err
 }
 
+// ReadFrom implements the io.ReaderFrom interface
+// Data is copied to segment files starting from the current offset until
+// the reader returns io.EOF. New segments are created if needed. Segment
+// files are used as writers so os.File can copy without a buffer if the
+// reader supports it (ex. copying from another file).
+func (s *Store) ReadFrom(r io.Reader) (n int64, err error) {
+	if s.readonly {
+		return 0, segments.ErrReadOnly
+	}
+
+	s.offmx.Lock()
+	defer s.offmx.Unlock()
+
+	for {
+		i, start := s.offs/s.size, s.offs%s.size
+		if err := s.ensure(i); err != nil {
+			return n, err
+		}
+
+		seg, err := s.get(i)
+		if err != nil {
+			return n, err
+		}
+
+		if _, err := seg.Seek(start, io.SeekStart); err != nil {
+			return n, err
+		}
+
+		c, err := io.CopyN(seg.File, r, s.size-start)
+		if c > 0 {
+			// mark the segment as changed
+			atomic.StoreUint32(&seg.dirty, 1)
+		}
+
+		n += c
+		s.offs += c
+
+		if err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+	}
+}
+
+// WriteTo implements the io.WriterTo interface
+// Data is copied from segment files starting from the current offset until
+// the end of the last segment. Segment files are used as readers so the
+// writer can copy without a buffer if it supports it (ex. sockets).
+func (s *Store) WriteTo(w io.Writer) (n int64, err error) {
+	s.offmx.Lock()
+	defer s.offmx.Unlock()
+
+	end := int64(len(s.loaded())) * s.size
+	if s.offs >= end {
+		return 0, nil
+	}
+
+	fn := func(i, start, end int64) (stop bool, err error) {
+		seg, err := s.get(i)
+		if err == io.EOF {
+			return true, nil
+		} else if err != nil {
+			return false, err
+		}
+
+		if _, err := seg.Seek(start, io.SeekStart); err != nil {
+			return false, err
+		}
+
+		c, err := io.CopyN(w, seg.File, end-start)
+		n += c
+		s.offs += c
+
+		return false, err
+	}
+
+	err = segments.Bounds(s.size, s.offs, end, fn)
+	return n, err
+}
+
 // Slice implements the fs.Slicer interface
 func (s *Store) Slice(sz int64) (p []byte, err error) {
 	s.offmx.Lock()
@@ -119,10 +328,8 @@ func (s *Store) Seek(offset int64, whence int) (off int64, err error) {
 		s.offs += offset
 	case 2:
 		// from file end
-		s.segmx.RLock()
-		end := int64(len(s.segs)) * s.size
+		end := int64(len(s.loaded())) * s.size
 		s.offs = end + offset
-		s.segmx.RUnlock()
 	}
 	off = s.offs
 	s.offmx.Unlock()
@@ -136,16 +343,13 @@ func (s *Store) ReadAt(p []byte, off int64) (n int, err error) {
 	toread := p[:]
 
 	fn := func(i, start, end int64) (stop bool, err error) {
-		s.segmx.RLock()
-		if i >= int64(len(s.segs)) {
-			s.segmx.RUnlock()
-			return false, io.EOF
+		seg, err := s.get(i)
+		if err != nil {
+			return false, err
 		}
-		s.segmx.RUnlock()
 
 		var done int64
 		req := end - start
-		seg := s.segs[i]
 
 		for done < req {
 			c, err := seg.ReadAt(toread[:req-done], start+done)
@@ -167,6 +371,10 @@ func (s *Store) ReadAt(p []byte, off int64) (n int, err error) {
 
 // WriteAt implements the io.WriterAt interface
 func (s *Store) WriteAt(p []byte, off int64) (n int, err error) {
+	if s.readonly {
+		return 0, segments.ErrReadOnly
+	}
+
 	sz := int64(len(p))
 	towrite := p[:]
 
@@ -175,9 +383,13 @@ func (s *Store) WriteAt(p []byte, off int64) (n int, err error) {
 			return false, err
 		}
 
+		seg, err := s.get(i)
+		if err != nil {
+			return false, err
+		}
+
 		var done int64
 		req := end - start
-		seg := s.segs[i]
 
 		for done < req {
 			c, err := seg.WriteAt(towrite[:req-done], start+done)
@@ -217,6 +429,10 @@ func (s *Store) SliceAt(sz, off int64) (p []byte, err error) {
 // Ensure makes sure that data upto given offset exists and are valid.
 // This will check from current segment length upto given position.
 func (s *Store) Ensure(off int64) (err error) {
+	if s.readonly {
+		return segments.ErrReadOnly
+	}
+
 	n := off / s.size
 	if off%s.size != 0 {
 		n++
@@ -227,60 +443,410 @@ func (s *Store) Ensure(off int64) (err error) {
 
 // Sync implements the fs.Syncer interface
 func (s *Store) Sync() (err error) {
-	s.segmx.RLock()
-	for _, seg := range s.segs {
+	for i, seg := range s.loaded() {
+		if !atomic.CompareAndSwapUint32(&seg.dirty, 1, 0) {
+			continue
+		}
+
+		if err := s.syncSeg(int64(i), seg); err != nil {
+			return err
+		}
+	}
+
+	return s.syncSums()
+}
+
+// SyncRange implements the fs.SyncRanger interface
+// Segments are synced completely if they are in the range.
+func (s *Store) SyncRange(sz, off int64) (err error) {
+	fn := func(i, start, end int64) (stop bool, err error) {
+		segs := s.loaded()
+		if i >= int64(len(segs)) {
+			return true, nil
+		}
+		seg := segs[i]
+
 		if !atomic.CompareAndSwapUint32(&seg.dirty, 1, 0) {
+			return false, nil
+		}
+
+		if err := s.syncSeg(i, seg); err != nil {
+			return false, err
+		}
+
+		return false, nil
+	}
+
+	if err := segments.Bounds(s.size, off, off+sz, fn); err != nil {
+		return err
+	}
+
+	return s.syncSums()
+}
+
+// Verify compares segments with their checksums and returns indexes of
+// segments which do not match. Segments which were changed but not synced
+// before the process crashed are also reported. Segments without checksums
+// (never synced) are not checked. ErrNoChecksums is returned if checksums
+// are not enabled.
+func (s *Store) Verify() (bad []int64, err error) {
+	if s.sums == nil {
+		return nil, segments.ErrNoChecksums
+	}
+
+	for i, seg := range s.loaded() {
+		if atomic.LoadUint32(&seg.removed) == 1 {
+			continue
+		}
+
+		sum, err := s.sums.Get(int64(i))
+		if err != nil {
+			return nil, err
+		}
+
+		if sum == 0 {
+			continue
+		}
+
+		actual, err := segments.ChecksumReader(io.NewSectionReader(seg, 0, s.size))
+		if err != nil {
+			return nil, err
+		}
+
+		if sum != actual {
+			bad = append(bad, int64(i))
+		}
+	}
+
+	return bad, nil
+}
+
+// Corrupted returns indexes of segments which did not match their checksums
+// when the store was opened. Syncing a segment updates its checksum.
+func (s *Store) Corrupted() (bad []int64) {
+	return s.corrupt
+}
+
+// Flush implements the fs.Flusher interface
+// Data is not buffered by the store so there's nothing to flush.
+func (s *Store) Flush() (err error) {
+	return nil
+}
+
+// Size implements the fs.Sizer interface
+// This includes the space allocated for all segments.
+func (s *Store) Size() (sz int64, err error) {
+	sz = int64(len(s.loaded())) * s.size
+	return sz, nil
+}
+
+// Truncate implements the fs.Truncator interface. Segment files cannot be
+// partially truncated so the size is rounded up to a segment boundary.
+// Segment files which are not required to store sz bytes are removed.
+func (s *Store) Truncate(sz int64) (err error) {
+	if s.readonly {
+		return segments.ErrReadOnly
+	}
+
+	n := int(sz / s.size)
+	if sz%s.size != 0 {
+		n++
+	}
+
+	s.segmx.Lock()
+	defer s.segmx.Unlock()
+
+	segs := s.loaded()
+	for i := len(segs) - 1; i >= n; i-- {
+		if atomic.LoadUint32(&segs[i].removed) == 0 {
+			if err := segs[i].Close(); err != nil {
+				return err
+			}
+		}
+
+		segs = segs[:i]
+		s.segs.Store(segs)
+
+		path := s.base + strconv.Itoa(i)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if s.sums != nil {
+		return s.sums.Truncate(int64(len(segs)))
+	}
+
+	return nil
+}
+
+// Remove removes segments which only have data before given offset. This
+// can be used to drop old data (ex. time series) without changing offsets
+// of remaining data. Removed data cannot be used and ErrRemoved is returned
+// when it's accessed. The last segment is never removed. Removed segments
+// must not be used while they're being removed.
+func (s *Store) Remove(before int64) (err error) {
+	if s.readonly {
+		return segments.ErrReadOnly
+	}
+
+	n := before / s.size
+
+	s.segmx.Lock()
+	defer s.segmx.Unlock()
+
+	segs := s.loaded()
+	if max := int64(len(segs)) - 1; n > max {
+		n = max
+	}
+
+	for i := int64(0); i < n; i++ {
+		seg := segs[i]
+		if atomic.LoadUint32(&seg.removed) == 1 {
 			continue
 		}
 
-		if err := seg.Sync(); err != nil {
-			s.segmx.RUnlock()
+		atomic.StoreUint32(&seg.removed, 1)
+		atomic.StoreUint32(&seg.dirty, 0)
+
+		if err := seg.Close(); err != nil {
+			return err
+		}
+
+		path := s.base + strconv.FormatInt(i, 10)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Export writes all segments to a single stream which can be used to copy
+// the store to another location (see segments.Export).
+func (s *Store) Export(w io.Writer) (err error) {
+	return segments.Export(s, s.size, w)
+}
+
+// Import writes segments from a stream created with Export to the store
+// (see segments.Import). Use it with an empty store with the same segment
+// size. Call Sync to write imported data to the disk.
+func (s *Store) Import(r io.Reader) (err error) {
+	return segments.Import(s, s.size, r)
+}
+
+// Compact removes segment files at the end of the store which only have
+// zeroes and frees disk space used by zero filled blocks in other segments
+// (see segments.Sparsify). One empty segment is kept after the last segment
+// with data for preallocation. The store should not be written while it's
+// being compacted.
+func (s *Store) Compact() (err error) {
+	if s.readonly {
+		return segments.ErrReadOnly
+	}
+
+	segs := s.loaded()
+	last := -1
+	for i := len(segs) - 1; i >= 0; i-- {
+		if atomic.LoadUint32(&segs[i].removed) == 1 {
+			break
+		}
+
+		zero, err := isZero(segs[i].File, s.size)
+		if err != nil {
+			return err
+		}
+
+		if !zero {
+			last = i
+			break
+		}
+	}
+
+	if err := s.Truncate(int64(last+2) * s.size); err != nil {
+		return err
+	}
+
+	for i := 0; i <= last; i++ {
+		err := segments.Sparsify(s.base + strconv.Itoa(i))
+		if err != nil && !os.IsNotExist(err) {
 			return err
 		}
 	}
-	s.segmx.RUnlock()
 
 	return nil
 }
 
 // Close implements the io.Closer interface
+// Segments which are being preallocated are created before closing.
+// The store lock is released after closing all segments.
 func (s *Store) Close() (err error) {
-	s.segmx.RLock()
-	for _, seg := range s.segs {
+	s.stopAlloc()
+
+	if s.sums != nil {
+		if err := s.Sync(); err != nil {
+			return err
+		}
+
+		if err := s.sums.Close(); err != nil {
+			return err
+		}
+	}
+
+	for _, seg := range s.loaded() {
+		if atomic.LoadUint32(&seg.removed) == 1 {
+			continue
+		}
+
 		if err := seg.Close(); err != nil {
-			s.segmx.RUnlock()
 			return err
 		}
 	}
-	s.segmx.RUnlock()
 
-	return nil
+	// the lock is released after closing segments
+	return s.lock.Close()
+}
+
+// loaded returns loaded segments without locking
+// The returned slice must not be modified.
+func (s *Store) loaded() (segs []*Segment) {
+	return s.segs.Load().([]*Segment)
+}
+
+// get returns a segment. io.EOF is returned if it does not exist
+// and segments.ErrRemoved is returned if it was removed.
+func (s *Store) get(i int64) (seg *Segment, err error) {
+	segs := s.loaded()
+	if i >= int64(len(segs)) {
+		return nil, io.EOF
+	}
+
+	seg = segs[i]
+	if atomic.LoadUint32(&seg.removed) == 1 {
+		return nil, segments.ErrRemoved
+	}
+
+	return seg, nil
+}
+
+// syncSeg syncs a segment and updates its checksum
+func (s *Store) syncSeg(i int64, seg *Segment) (err error) {
+	if err := seg.Sync(); err != nil {
+		return err
+	}
+
+	if s.sums == nil {
+		return nil
+	}
+
+	sum, err := segments.ChecksumReader(io.NewSectionReader(seg, 0, s.size))
+	if err != nil {
+		return err
+	}
+
+	return s.sums.Set(i, sum)
+}
+
+// syncSums writes segment checksums to the disk
+func (s *Store) syncSums() (err error) {
+	if s.sums == nil {
+		return nil
+	}
+
+	return s.sums.Sync()
 }
 
 // ensure makes sure that segments upto given index exists and are valid.
 // This will check from current segment length upto given position.
 // This will also pre allocate an additional segment file/mmap.
 func (s *Store) ensure(n int64) (err error) {
-	// +1 preallocate
-	num := int(n) + 1
+	if err := s.create(n); err != nil {
+		return err
+	}
+
+	s.prealloc(n + 1)
+	return nil
+}
 
+// create creates segments upto given index
+func (s *Store) create(n int64) (err error) {
 	// fast path
-	s.segmx.RLock()
-	if num < len(s.segs) {
-		s.segmx.RUnlock()
+	if n < int64(len(s.loaded())) {
 		return nil
 	}
-	s.segmx.RUnlock()
 
 	// slow path
 	s.segmx.Lock()
 	defer s.segmx.Unlock()
 
-	available := len(s.segs)
+	return s.extend(n)
+}
+
+// prealloc creates segments upto given index in the background
+// It's skipped if the worker already has too many segments to create.
+func (s *Store) prealloc(n int64) {
+	// fast path
+	if n < int64(len(s.loaded())) {
+		return
+	}
+
+	s.allocmx.RLock()
+	defer s.allocmx.RUnlock()
+
+	if s.alloc == nil {
+		return
+	}
+
+	select {
+	case s.alloc <- n:
+	default:
+	}
+}
+
+// allocate creates segments sent by prealloc until the channel is closed
+// Errors are ignored here, they're returned when writers create segments.
+func (s *Store) allocate(alloc chan int64) {
+	defer s.allocwg.Done()
+
+	for n := range alloc {
+		// only the segment after the last one is preallocated
+		// segments may have been truncated after sending this
+		s.segmx.Lock()
+		if n == int64(len(s.loaded())) {
+			s.extend(n)
+		}
+		s.segmx.Unlock()
+	}
+}
+
+// stopAlloc stops the preallocation worker after creating queued segments
+func (s *Store) stopAlloc() {
+	s.allocmx.Lock()
+	if s.alloc != nil {
+		close(s.alloc)
+		s.alloc = nil
+	}
+	s.allocmx.Unlock()
+
+	s.allocwg.Wait()
+}
+
+// extend creates segments after loaded segments upto given index
+// This should be called while holding the segment mutex.
+func (s *Store) extend(n int64) (err error) {
+	num := int(n)
+
+	loaded := s.loaded()
+	available := len(loaded)
 	if num < available {
 		return nil
 	}
 
+	// readers may be using the loaded slice so a new one is used
+	// new segments are added after the end of loaded segments
+	segs := make([]*Segment, available, num+1)
+	copy(segs, loaded)
+
 	for i := available; i <= num; i++ {
 		path := s.base + strconv.Itoa(i)
 		seg, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
@@ -301,14 +867,37 @@ func (s *Store) ensure(n int64) (err error) {
 			}
 
 			// If the file size if zero, it should be a new
-			// segment file. Truncate it to required size.
-			if err := seg.Truncate(s.size); err != nil {
+			// segment file. Allocate disk space for it.
+			if err := fsutils.FallocateFile(seg, s.size); err != nil {
 				return err
 			}
 		}
 
-		s.segs = append(s.segs, &Segment{seg, 0})
+		segs = append(segs, &Segment{File: seg})
+		s.segs.Store(segs)
 	}
 
 	return nil
 }
+
+// isZero checks whether the first sz bytes of the file are zeroes
+func isZero(file *os.File, sz int64) (zero bool, err error) {
+	buf := make([]byte, 64*1024)
+
+	for off := int64(0); off < sz; off += int64(len(buf)) {
+		n, err := file.ReadAt(buf, off)
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+
+		if !segments.IsZero(buf[:n]) {
+			return false, nil
+		}
+
+		if n < len(buf) {
+			break
+		}
+	}
+
+	return true, nil
+}
@@ -2,9 +2,14 @@ package segfile
 
 import (
 	"bytes"
+	"io"
 	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/kadirahq/go-tools/fsutils"
 	"github.com/kadirahq/go-tools/segments"
 )
 
@@ -60,7 +65,7 @@ func TestNew(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		if len(s.segs) != 1+1 {
+		if len(s.loaded()) != 1+1 {
 			t.Fatal("wrong length")
 		}
 
@@ -78,7 +83,7 @@ func TestOpen(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if len(s.segs) != 1+1 {
+	if len(s.loaded()) != 1+1 {
 		t.Fatal("wrong length")
 	}
 
@@ -95,7 +100,7 @@ func TestOpen(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if len(s.segs) != 6+1 {
+	if len(s.loaded()) != 6+1 {
 		t.Fatal("wrong length")
 	}
 
@@ -325,7 +330,554 @@ func TestSlicerAt(t *testing.T) {
 	}
 }
 
+func TestSyncRange(t *testing.T) {
+	defer setup(t)()
+
+	s, err := New(tmpfile, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fill(s, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SyncRange(5, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if atomic.LoadUint32(&s.loaded()[0].dirty) != 0 || atomic.LoadUint32(&s.loaded()[1].dirty) != 0 {
+		t.Fatal("should be synced")
+	}
+
+	if atomic.LoadUint32(&s.loaded()[3].dirty) != 1 {
+		t.Fatal("should not be synced")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	defer setup(t)()
+
+	s, err := Open(tmpfile, &Options{SegmentSize: 3, DisablePrealloc: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Ensure(10); err != nil {
+		t.Fatal(err)
+	}
+
+	if sz, err := s.Size(); err != nil {
+		t.Fatal(err)
+	} else if sz != 15 {
+		t.Fatal("wrong size")
+	}
+
+	if err := s.Truncate(5); err != nil {
+		t.Fatal(err)
+	}
+
+	if sz, err := s.Size(); err != nil {
+		t.Fatal(err)
+	} else if sz != 6 {
+		t.Fatal("wrong size")
+	}
+
+	if _, err := os.Stat(tmpfile + "2"); !os.IsNotExist(err) {
+		t.Fatal("segment file should be removed")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestImpl(t *testing.T) {
 	// throws error if it doesn't
 	var _ segments.Store = &Store{}
 }
+
+func TestReaderFromWriterTo(t *testing.T) {
+	defer setup(t)()
+
+	s, err := Open(tmpfile, &Options{SegmentSize: 3, DisablePrealloc: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	data := []byte("abcdefgh")
+	if n, err := s.ReadFrom(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	} else if n != int64(len(data)) {
+		t.Fatal("wrong n")
+	}
+
+	if _, err := s.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	n, err := io.Copy(buf, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sz, err := s.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != sz || !bytes.Equal(buf.Bytes()[:len(data)], data) {
+		t.Fatal("wrong data")
+	}
+
+	if off, err := s.Seek(0, 1); err != nil {
+		t.Fatal(err)
+	} else if off != sz {
+		t.Fatal("wrong offset")
+	}
+}
+
+func TestCompact(t *testing.T) {
+	defer setup(t)()
+
+	s, err := New(tmpfile, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	if _, err := s.WriteAt([]byte("hello"), 12); err != nil {
+		t.Fatal(err)
+	}
+
+	// segments 2..5 are created but only have zeroes
+	if err := s.Ensure(50); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	if sz, err := s.Size(); err != nil {
+		t.Fatal(err)
+	} else if sz != 30 {
+		t.Fatal("wrong size", sz)
+	}
+
+	if _, err := os.Stat(tmpfile + "3"); !os.IsNotExist(err) {
+		t.Fatal("segment file should be removed")
+	}
+
+	p := make([]byte, 5)
+	if _, err := s.ReadAt(p, 12); err != nil {
+		t.Fatal(err)
+	} else if string(p) != "hello" {
+		t.Fatal("wrong data")
+	}
+}
+
+func TestChecksums(t *testing.T) {
+	defer setup(t)()
+
+	s, err := New(tmpfile, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Verify(); err != segments.ErrNoChecksums {
+		t.Fatal("expected ErrNoChecksums")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &Options{SegmentSize: 10, Checksums: true}
+	s, err = Open(tmpfile, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.WriteAt([]byte("hello world"), 5); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate a torn write
+	file, err := os.OpenFile(tmpfile+"1", os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := file.WriteAt([]byte("W"), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = Open(tmpfile, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bad := s.Corrupted(); len(bad) != 1 || bad[0] != 1 {
+		t.Fatal("wrong corrupted segments", bad)
+	}
+
+	if _, err := s.WriteAt([]byte("w"), 11); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if bad, err := s.Verify(); err != nil {
+		t.Fatal(err)
+	} else if len(bad) != 0 {
+		t.Fatal("syncing should update checksums")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	defer setup(t)()
+
+	s, err := New(tmpfile, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.WriteAt([]byte("hello world!"), 20); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Remove(25); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(tmpfile + "1"); !os.IsNotExist(err) {
+		t.Fatal("segment file should be removed")
+	}
+
+	p := make([]byte, 5)
+	if _, err := s.ReadAt(p, 5); err != segments.ErrRemoved {
+		t.Fatal("expected ErrRemoved")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = New(tmpfile, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	p = make([]byte, 12)
+	if _, err := s.ReadAt(p, 20); err != nil {
+		t.Fatal(err)
+	} else if string(p) != "hello world!" {
+		t.Fatal("offsets should not change")
+	}
+
+	if _, err := s.WriteAt(p, 0); err != segments.ErrRemoved {
+		t.Fatal("expected ErrRemoved")
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	defer setup(t)()
+
+	s, err := Open(tmpfile, &Options{SegmentSize: 10, DisablePrealloc: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	if _, err := s.WriteAt([]byte("hello world!"), 15); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Remove(10); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := s.Export(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := New(tmpdir+"copy_", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer d.Close()
+
+	if err := d.Import(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+
+	if sz, err := d.Size(); err != nil {
+		t.Fatal(err)
+	} else if ssz, _ := s.Size(); sz < ssz {
+		t.Fatal("wrong size")
+	}
+
+	p := make([]byte, 12)
+	if _, err := d.ReadAt(p, 15); err != nil {
+		t.Fatal(err)
+	} else if string(p) != "hello world!" {
+		t.Fatal("wrong data")
+	}
+
+	e, err := New(tmpdir+"other_", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer e.Close()
+
+	if err := e.Import(bytes.NewReader(buf.Bytes())); err != segments.ErrSegSize {
+		t.Fatal("expected ErrSegSize")
+	}
+
+	// truncated stream
+	trunc := buf.Bytes()[:buf.Len()-5]
+	if err := d.Import(bytes.NewReader(trunc)); err != io.ErrUnexpectedEOF {
+		t.Fatal("expected io.ErrUnexpectedEOF")
+	}
+}
+
+func benchmarkParallel(b *testing.B, write bool) {
+	if err := os.RemoveAll(tmpdir); err != nil {
+		b.Fatal(err)
+	}
+
+	if err := os.MkdirAll(tmpdir, 0777); err != nil {
+		b.Fatal(err)
+	}
+
+	defer os.RemoveAll(tmpdir)
+
+	s, err := New(tmpfile, 1024*1024)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defer s.Close()
+
+	if err := s.Ensure(8 * 1024 * 1024); err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetParallelism(16)
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		p := make([]byte, 64)
+		off := int64(0)
+
+		for pb.Next() {
+			off = (off + 4096*1024 + 64) % (8 * 1024 * 1024)
+			if write {
+				if _, err := s.WriteAt(p, off); err != nil {
+					b.Fatal(err)
+				}
+			} else {
+				if _, err := s.ReadAt(p, off); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkParallelReadAt(b *testing.B) {
+	benchmarkParallel(b, false)
+}
+
+func BenchmarkParallelWriteAt(b *testing.B) {
+	benchmarkParallel(b, true)
+}
+
+func TestPrealloc(t *testing.T) {
+	defer setup(t)()
+
+	s, err := Open(tmpfile, &Options{SegmentSize: 10, DisablePrealloc: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.WriteAt([]byte("hello world!"), 5); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(tmpfile + "2"); !os.IsNotExist(err) {
+		t.Fatal("segment should not be preallocated")
+	}
+
+	s, err = Open(tmpfile, &Options{SegmentSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// preallocated in the background
+	if _, err := s.WriteAt([]byte("hello world!"), 25); err != nil {
+		t.Fatal(err)
+	}
+
+	// closing waits for the worker
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(tmpfile + "4"); err != nil {
+		t.Fatal("segment should be preallocated")
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	defer setup(t)()
+
+	s, err := New(tmpfile, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.WriteAt([]byte("hello"), 12); err != nil {
+		t.Fatal(err)
+	}
+
+	// writers use exclusive locks
+	if _, err := New(tmpfile, 10); err != fsutils.ErrLocked {
+		t.Fatal("expected ErrLocked")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &Options{SegmentSize: 10, ReadOnly: true}
+	r1, err := Open(tmpfile, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer r1.Close()
+
+	// read-only stores can be shared
+	r2, err := Open(tmpfile, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer r2.Close()
+
+	p := make([]byte, 5)
+	if _, err := r2.ReadAt(p, 12); err != nil {
+		t.Fatal(err)
+	} else if string(p) != "hello" {
+		t.Fatal("wrong data")
+	}
+
+	if _, err := r1.WriteAt(p, 0); err != segments.ErrReadOnly {
+		t.Fatal("expected ErrReadOnly")
+	}
+
+	if err := r1.Truncate(0); err != segments.ErrReadOnly {
+		t.Fatal("expected ErrReadOnly")
+	}
+
+	if err := r1.Remove(20); err != segments.ErrReadOnly {
+		t.Fatal("expected ErrReadOnly")
+	}
+
+	_, err = Open(tmpfile, &Options{SegmentSize: 10, LockTimeout: 20 * time.Millisecond})
+	if err != fsutils.ErrLocked {
+		t.Fatal("expected ErrLocked")
+	}
+}
+
+func TestReadOnlyPerms(t *testing.T) {
+	defer setup(t)()
+
+	s, err := New(tmpfile, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.WriteAt([]byte("hello"), 12); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := filepath.Glob(tmpfile + "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// files and the directory without write permission
+	for _, name := range names {
+		if err := os.Chmod(name, 0444); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Chmod(tmpdir, 0555); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Chmod(tmpdir, 0777)
+
+	r, err := Open(tmpfile, &Options{SegmentSize: 10, ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := make([]byte, 5)
+	if _, err := r.ReadAt(p, 12); err != nil {
+		t.Fatal(err)
+	} else if string(p) != "hello" {
+		t.Fatal("wrong data")
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// read-only stores should not create files
+	if after, err := filepath.Glob(tmpfile + "*"); err != nil {
+		t.Fatal(err)
+	} else if len(after) != len(names) {
+		t.Fatal("files should not be created")
+	}
+}
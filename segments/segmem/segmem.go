@@ -0,0 +1,268 @@
+// Package segmem provides an in-memory segment store. Unlike fs/memstore,
+// it keeps data in fixed size segments and behaves like file based stores
+// at segment boundaries (slices stop at boundaries, sizes are rounded up to
+// segments). It can be used to test and benchmark code which uses segment
+// stores without touching the disk.
+package segmem
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/kadirahq/go-tools/segments"
+)
+
+var (
+	// ErrClosed is returned when the store is used after closing it.
+	ErrClosed = errors.New("store is closed")
+)
+
+// Store is an in-memory segment store which implements segments.Store
+type Store struct {
+	segs   [][]byte
+	size   int64
+	offs   int64
+	closed bool
+	mutex  sync.RWMutex
+}
+
+// New creates an in-memory segment store with given segment size.
+// The store starts with one empty segment like file based stores.
+func New(size int64) (s *Store) {
+	return &Store{
+		segs: [][]byte{make([]byte, size)},
+		size: size,
+	}
+}
+
+// Read implements the io.Reader interface
+func (s *Store) Read(p []byte) (n int, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, err = s.readAt(p, s.offs)
+	s.offs += int64(n)
+	return n, err
+}
+
+// Write implements the io.Writer interface
+func (s *Store) Write(p []byte) (n int, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, err = s.writeAt(p, s.offs)
+	s.offs += int64(n)
+	return n, err
+}
+
+// Slice implements the fs.Slicer interface
+func (s *Store) Slice(sz int64) (p []byte, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	p, err = s.sliceAt(sz, s.offs)
+	s.offs += int64(len(p))
+	return p, err
+}
+
+// Seek implements the io.Seeker interface
+func (s *Store) Seek(offset int64, whence int) (off int64, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch whence {
+	case 0:
+		// from start
+		s.offs = offset
+	case 1:
+		// from current
+		s.offs += offset
+	case 2:
+		// from end
+		s.offs = int64(len(s.segs))*s.size + offset
+	}
+
+	return s.offs, nil
+}
+
+// ReadAt implements the io.ReaderAt interface
+func (s *Store) ReadAt(p []byte, off int64) (n int, err error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.readAt(p, off)
+}
+
+// WriteAt implements the io.WriterAt interface
+// New segments are created if data is written after the last segment.
+func (s *Store) WriteAt(p []byte, off int64) (n int, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.writeAt(p, off)
+}
+
+// SliceAt implements the fs.SlicerAt interface
+// The slice stops at the end of the segment.
+func (s *Store) SliceAt(sz, off int64) (p []byte, err error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.sliceAt(sz, off)
+}
+
+// Ensure makes sure that data upto given offset exists and are valid.
+func (s *Store) Ensure(off int64) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	n := off / s.size
+	if off%s.size != 0 {
+		n++
+	}
+
+	s.ensure(n)
+	return nil
+}
+
+// Sync implements the fs.Syncer interface
+// Data is only in memory so there's nothing to sync.
+func (s *Store) Sync() (err error) {
+	return s.check()
+}
+
+// SyncRange implements the fs.SyncRanger interface
+// Data is only in memory so there's nothing to sync.
+func (s *Store) SyncRange(sz, off int64) (err error) {
+	return s.check()
+}
+
+// Flush implements the fs.Flusher interface
+// Data is not buffered by the store so there's nothing to flush.
+func (s *Store) Flush() (err error) {
+	return nil
+}
+
+// Size implements the fs.Sizer interface
+// This includes the space allocated for all segments.
+func (s *Store) Size() (sz int64, err error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return int64(len(s.segs)) * s.size, nil
+}
+
+// Truncate implements the fs.Truncator interface. Segments cannot be
+// partially truncated so the size is rounded up to a segment boundary.
+func (s *Store) Truncate(sz int64) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	n := sz / s.size
+	if sz%s.size != 0 {
+		n++
+	}
+
+	if n < int64(len(s.segs)) {
+		// let truncated segments be garbage collected
+		for i := n; i < int64(len(s.segs)); i++ {
+			s.segs[i] = nil
+		}
+
+		s.segs = s.segs[:n]
+		return nil
+	}
+
+	if n > 0 {
+		s.ensure(n - 1)
+	}
+
+	return nil
+}
+
+// Close implements the io.Closer interface
+func (s *Store) Close() (err error) {
+	s.mutex.Lock()
+	s.closed = true
+	s.mutex.Unlock()
+	return nil
+}
+
+// check returns ErrClosed if the store is closed
+func (s *Store) check() (err error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	return nil
+}
+
+func (s *Store) readAt(p []byte, off int64) (n int, err error) {
+	if s.closed {
+		return 0, ErrClosed
+	}
+
+	fn := func(i, start, end int64) (stop bool, err error) {
+		if i >= int64(len(s.segs)) {
+			return false, io.EOF
+		}
+
+		n += copy(p[n:], s.segs[i][start:end])
+		return false, nil
+	}
+
+	err = segments.Bounds(s.size, off, off+int64(len(p)), fn)
+	return n, err
+}
+
+func (s *Store) writeAt(p []byte, off int64) (n int, err error) {
+	if s.closed {
+		return 0, ErrClosed
+	}
+
+	fn := func(i, start, end int64) (stop bool, err error) {
+		s.ensure(i)
+		n += copy(s.segs[i][start:end], p[n:])
+		return false, nil
+	}
+
+	err = segments.Bounds(s.size, off, off+int64(len(p)), fn)
+	return n, err
+}
+
+func (s *Store) sliceAt(sz, off int64) (p []byte, err error) {
+	if s.closed {
+		return nil, ErrClosed
+	}
+
+	fn := func(i, start, end int64) (stop bool, err error) {
+		if i >= int64(len(s.segs)) {
+			return false, io.EOF
+		}
+
+		p = s.segs[i][start:end]
+		return true, nil
+	}
+
+	if err := segments.Bounds(s.size, off, off+sz, fn); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// ensure creates segments upto given index
+// This should be called while holding the write lock.
+func (s *Store) ensure(n int64) {
+	for i := int64(len(s.segs)); i <= n; i++ {
+		s.segs = append(s.segs, make([]byte, s.size))
+	}
+}
@@ -0,0 +1,134 @@
+package segmem
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/kadirahq/go-tools/segments"
+)
+
+func TestImpl(t *testing.T) {
+	// throws error if it doesn't
+	var _ segments.Store = &Store{}
+}
+
+func TestReadWrite(t *testing.T) {
+	s := New(3)
+
+	data := []byte("abcdefghij")
+	if n, err := s.Write(data); err != nil {
+		t.Fatal(err)
+	} else if n != len(data) {
+		t.Fatal("short write")
+	}
+
+	if sz, err := s.Size(); err != nil {
+		t.Fatal(err)
+	} else if sz != 12 {
+		t.Fatal("size should be rounded up to segments")
+	}
+
+	if _, err := s.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	p := make([]byte, len(data))
+	if _, err := io.ReadFull(s, p); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(p, data) {
+		t.Fatal("wrong data")
+	}
+
+	p = make([]byte, 5)
+	if n, err := s.ReadAt(p, 10); err != io.EOF || n != 2 {
+		t.Fatal("expected io.EOF")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.ReadAt(p, 0); err != ErrClosed {
+		t.Fatal("expected ErrClosed")
+	}
+}
+
+func TestSliceAt(t *testing.T) {
+	s := New(3)
+
+	if _, err := s.WriteAt([]byte("abcdefghi"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// slices stop at segment boundaries
+	if p, err := s.SliceAt(5, 1); err != nil {
+		t.Fatal(err)
+	} else if string(p) != "bc" {
+		t.Fatal("wrong slice")
+	}
+
+	if _, err := s.SliceAt(5, 20); err != io.EOF {
+		t.Fatal("expected io.EOF")
+	}
+
+	// sliced data is not copied
+	p, err := s.Slice(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p[0] = 'A'
+	if q, _ := s.SliceAt(1, 0); q[0] != 'A' {
+		t.Fatal("slices should use store memory")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	s := New(3)
+
+	if err := s.Ensure(10); err != nil {
+		t.Fatal(err)
+	}
+
+	if sz, err := s.Size(); err != nil {
+		t.Fatal(err)
+	} else if sz != 15 {
+		t.Fatal("wrong size")
+	}
+
+	if err := s.Truncate(5); err != nil {
+		t.Fatal(err)
+	}
+
+	if sz, err := s.Size(); err != nil {
+		t.Fatal(err)
+	} else if sz != 6 {
+		t.Fatal("wrong size")
+	}
+
+	if err := s.Truncate(7); err != nil {
+		t.Fatal(err)
+	}
+
+	if sz, err := s.Size(); err != nil {
+		t.Fatal(err)
+	} else if sz != 9 {
+		t.Fatal("wrong size")
+	}
+}
+
+func BenchmarkWriteAt(b *testing.B) {
+	s := New(1024 * 1024)
+	p := make([]byte, 512)
+
+	b.SetBytes(int64(len(p)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		off := int64(i*len(p)) % (64 * 1024 * 1024)
+		if _, err := s.WriteAt(p, off); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -3,6 +3,10 @@ package segments
 import (
 	"errors"
 	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/kadirahq/go-tools/fs"
 )
@@ -11,6 +15,10 @@ var (
 	// ErrSegSize is returned when the file size is different from the segment
 	// size. In a segmented store, all segments should have the same file size.
 	ErrSegSize = errors.New("wrong segment size")
+
+	// ErrRemoved is returned when the user attempts to use data in segments
+	// which were removed from the store (see FirstIndex).
+	ErrRemoved = errors.New("segment was removed")
 )
 
 // Store abstracts storing data in multiple segment files and provides it an
@@ -27,6 +35,10 @@ type Store interface {
 	fs.SlicerAt
 	fs.Ensurer
 	fs.Syncer
+	fs.SyncRanger
+	fs.Flusher
+	fs.Truncator
+	fs.Sizer
 	io.Closer
 }
 
@@ -74,3 +86,55 @@ func Bounds(size, start, end int64, fn BoundsFn) (err error) {
 
 	return nil
 }
+
+// CheckSegSize checks the size of the first segment file with given base
+// path. ErrSegSize is returned if it's not the same as the segment size.
+// It can be used with CheckHeader for stores which use a file per segment.
+func CheckSegSize(base string, size int64) (err error) {
+	first, err := FirstIndex(base)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(base + strconv.FormatInt(first, 10))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if info.Size() != size {
+		return ErrSegSize
+	}
+
+	return nil
+}
+
+// FirstIndex returns the index of the first segment file with given base path.
+// Stores can remove segments at the beginning to free space without changing
+// offsets of remaining data so the first segment file may not be at index 0.
+// Zero is returned if there are no segment files.
+func FirstIndex(base string) (i int64, err error) {
+	matches, err := filepath.Glob(base + "*")
+	if err != nil {
+		return 0, err
+	}
+
+	i = -1
+	for _, path := range matches {
+		n, err := strconv.ParseInt(strings.TrimPrefix(path, base), 10, 64)
+		if err != nil || n < 0 {
+			continue
+		}
+
+		if i < 0 || n < i {
+			i = n
+		}
+	}
+
+	if i < 0 {
+		return 0, nil
+	}
+
+	return i, nil
+}
@@ -1 +1,63 @@
 package segments
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestFirstIndex(t *testing.T) {
+	dir := "/tmp/test-segments-first/"
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	if i, err := FirstIndex(dir + "seg_"); err != nil || i != 0 {
+		t.Fatal("expected 0")
+	}
+
+	for _, name := range []string{"seg_12", "seg_3", "seg_7", "seg_header", "seg_sums"} {
+		if err := ioutil.WriteFile(dir+name, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if i, err := FirstIndex(dir + "seg_"); err != nil || i != 3 {
+		t.Fatal("expected 3")
+	}
+}
+
+func TestCheckSegSize(t *testing.T) {
+	dir := "/tmp/test-segments-segsize/"
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	if err := CheckSegSize(dir+"seg_", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(dir+"seg_2", make([]byte, 10), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckSegSize(dir+"seg_", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckSegSize(dir+"seg_", 20); err != ErrSegSize {
+		t.Fatal("expected ErrSegSize")
+	}
+}
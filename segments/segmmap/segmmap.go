@@ -7,25 +7,101 @@ import (
 	"strconv"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/kadirahq/go-tools/fsutils"
+	"github.com/kadirahq/go-tools/function"
 	"github.com/kadirahq/go-tools/memmap"
 	"github.com/kadirahq/go-tools/segments"
 )
 
 var (
 	errstop = errors.New("not an error! used to stop")
+
+	// ErrClosed is returned when the store is used after closing it.
+	ErrClosed = errors.New("store is closed")
+)
+
+const (
+	// number of segments which can wait to be preallocated
+	allocQueue = 16
 )
 
+// Options for segment stores
+type Options struct {
+	// Size of each segment file in bytes.
+	SegmentSize int64
+
+	// Lock segment memory maps in physical memory (see memmap.LockPolicy).
+	Lock memmap.LockPolicy
+
+	// Request transparent huge pages for segment memory maps. This is
+	// useful with large segments (see memmap.Options).
+	HugePages bool
+
+	// Checksums enables keeping a checksum for each segment in a sidecar
+	// file (base + "sums"). Checksums are updated when segments are synced
+	// and they're verified when the store is opened (see Store.Verify).
+	Checksums bool
+
+	// FlushInterval enables syncing dirty segments in the background with
+	// given interval. This limits how much data can be lost if the process
+	// crashes without calling Sync on the hot path. Use 0 to disable it.
+	FlushInterval time.Duration
+
+	// FlushThreshold starts syncing in the background without waiting for
+	// the next interval when this number of segments are dirty. It's only
+	// used when FlushInterval is set. Use 0 to only sync periodically.
+	FlushThreshold int
+
+	// MaxOpenSegments limits the number of segments which are mapped at the
+	// same time. Least recently used segments are synced and unmapped when
+	// the limit is exceeded and they're mapped again when they're accessed.
+	// Slices returned by SliceAt/SliceVAt must not be used after their
+	// segments are unmapped so only use them for a short time with this
+	// option. Use 0 to keep all segments mapped.
+	MaxOpenSegments int
+
+	// DisablePrealloc disables creating the next segment in the background
+	// before it's used. Segments are created when data is written to them.
+	DisablePrealloc bool
+
+	// ReadOnly opens the store with a shared lock so other read-only users
+	// can use it at the same time. Changing the store fails with
+	// segments.ErrReadOnly (slices must not be changed either).
+	// Otherwise, an exclusive lock is used.
+	ReadOnly bool
+
+	// LockTimeout is the time to wait for other processes to release the
+	// store lock before returning fsutils.ErrLocked (see segments.LockStore).
+	LockTimeout time.Duration
+}
+
 // LoadSegs laods all existing segment files available
 // matching provided base path. The base path should contain
 // the path to the segment file and the segment file prefix.
 // example: "/path/to/segment/files/prefix_"
 func LoadSegs(base string, size int64, lock bool) (segs []*Segment, err error) {
+	return loadSegs(base, &Options{SegmentSize: size, Lock: lockPolicy(lock)})
+}
+
+// loadSegs loads existing segment files using options
+func loadSegs(base string, opts *Options) (segs []*Segment, err error) {
 	segs = []*Segment{}
 
-	for i := 0; true; i++ {
+	first, err := segments.FirstIndex(base)
+	if err != nil {
+		return nil, err
+	}
+
+	// segments before the first one were removed
+	for i := int64(0); i < first; i++ {
+		segs = append(segs, &Segment{removed: 1})
+	}
+
+	for i := int(first); true; i++ {
 		path := base + strconv.Itoa(i)
-		file, err := os.OpenFile(path, os.O_RDWR, 0644)
+		file, err := openSegment(path, opts)
 		if err != nil {
 			break
 		}
@@ -33,19 +109,30 @@ func LoadSegs(base string, size int64, lock bool) (segs []*Segment, err error) {
 		// don't need this
 		defer file.Close()
 
-		seg, err := memmap.MapFile(file, size)
-		if err != nil {
-			return nil, err
-		}
-
-		if lock {
-			if err := seg.Lock(); err != nil {
-				go seg.Close()
+		// segments after the limit are mapped when they're used
+		if opts.MaxOpenSegments > 0 && i >= opts.MaxOpenSegments {
+			info, err := file.Stat()
+			if err != nil {
+				closeSegs(segs)
 				return nil, err
 			}
+
+			if info.Size() != opts.SegmentSize {
+				closeSegs(segs)
+				return nil, memmap.ErrBadSz
+			}
+
+			segs = append(segs, &Segment{})
+			continue
 		}
 
-		segs = append(segs, &Segment{seg, 0})
+		seg, err := mapSegment(file, opts)
+		if err != nil {
+			closeSegs(segs)
+			return nil, err
+		}
+
+		segs = append(segs, &Segment{Map: seg, open: 1})
 	}
 
 	return segs, nil
@@ -55,37 +142,143 @@ func LoadSegs(base string, size int64, lock bool) (segs []*Segment, err error) {
 type Segment struct {
 	*memmap.Map
 	dirty uint32
+
+	// the mutex protects the memory map from being unmapped while it's used
+	// the rest is used when segments can be unmapped (see MaxOpenSegments)
+	mutex sync.RWMutex
+	open  uint32
+	used  int64
+
+	// set when the segment was removed (see Store.Remove)
+	removed uint32
 }
 
 // Store is a collection of segment files. Using a set of segment files can
 // be faster than using a single growing file. Also, it allocates faster.
 type Store struct {
-	segs  []*Segment
-	segmx *sync.RWMutex
+	opts  *Options
 	base  string
 	size  int64
 	offs  int64
 	offmx *sync.Mutex
+
+	// lock file used to prevent other processes from changing the store
+	lock *os.File
+
+	// loaded segments ([]*Segment) are replaced when segments are created
+	// or removed so they can be used without locking. The slice should not
+	// be modified after storing it. The mutex is used when changing it.
+	segs  atomic.Value
+	segmx *sync.Mutex
+
+	// segment checksums and segments which were
+	// corrupted when the store was opened
+	sums    *segments.SumFile
+	corrupt []int64
+
+	// background flusher and the number of dirty segments
+	tick   *function.Ticker
+	ndirty int32
+
+	// number of mapped segments and a counter used to find
+	// least recently used segments (see Options.MaxOpenSegments)
+	nopen   int32
+	clock   int64
+	evictmx sync.Mutex
+
+	// segment indexes to create in the background (see Options.DisablePrealloc)
+	// the mutex protects the channel from being closed while sending to it
+	alloc   chan int64
+	allocmx sync.RWMutex
+	allocwg sync.WaitGroup
+
+	// set when the store is closed so segments are not mapped again
+	closed uint32
 }
 
 // New creates a collection of segment files on given path
 func New(base string, size int64, lock bool) (s *Store, err error) {
-	segs, err := LoadSegs(base, size, lock)
+	return Open(base, &Options{SegmentSize: size, Lock: lockPolicy(lock)})
+}
+
+// Open creates a collection of segment files on given path with options
+// The segment size is recorded in a header file (base + "header") when the
+// store is created and opening it with a different size fails. The store is
+// locked while it's open so other processes cannot change it.
+func Open(base string, opts *Options) (s *Store, err error) {
+	lock, err := segments.LockStore(base, !opts.ReadOnly, opts.LockTimeout)
 	if err != nil {
 		return nil, err
 	}
 
+	check := func() error { return segments.CheckSegSize(base, opts.SegmentSize) }
+	if err := segments.CheckHeader(base+"header", opts.SegmentSize, opts.ReadOnly, check); err != nil {
+		lock.Close()
+		return nil, err
+	}
+
+	segs, err := loadSegs(base, opts)
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+
 	s = &Store{
-		segs:  segs,
-		segmx: &sync.RWMutex{},
+		opts:  opts,
 		base:  base,
-		size:  size,
+		size:  opts.SegmentSize,
 		offmx: &sync.Mutex{},
+		lock:  lock,
+		segmx: &sync.Mutex{},
 	}
 
-	if err := s.ensure(0); err != nil {
-		// TODO
-		_ = err
+	s.segs.Store(segs)
+
+	for _, seg := range segs {
+		if seg.Map != nil {
+			s.nopen++
+		}
+	}
+
+	if opts.Checksums {
+		s.sums, err = segments.OpenSumFile(base + "sums")
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+
+		s.corrupt, err = s.Verify()
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+
+	// read-only stores only use existing segments
+	if opts.ReadOnly {
+		return s, nil
+	}
+
+	// the first segment is preallocated while opening the store
+	first := int64(1)
+	if opts.DisablePrealloc {
+		first = 0
+	}
+
+	if err := s.ensure(first); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	if !opts.DisablePrealloc {
+		s.alloc = make(chan int64, allocQueue)
+		s.allocwg.Add(1)
+		go s.allocate(s.alloc)
+	}
+
+	if opts.FlushInterval > 0 {
+		s.tick = function.NewTicker(func() { s.Sync() }, opts.FlushInterval)
+		s.tick.Start()
 	}
 
 	return s, nil
@@ -109,6 +302,80 @@ func (s *Store) Write(p []byte) (n int, err error) {
 	return n, err
 }
 
+// ReadFrom implements the io.ReaderFrom interface
+// Data is read directly into segment memory maps starting from the current
+// offset until the reader returns io.EOF. New segments are created if needed.
+func (s *Store) ReadFrom(r io.Reader) (n int64, err error) {
+	if s.opts.ReadOnly {
+		return 0, segments.ErrReadOnly
+	}
+
+	s.offmx.Lock()
+	defer s.offmx.Unlock()
+
+	for {
+		i, start := s.offs/s.size, s.offs%s.size
+		if err := s.ensure(i); err != nil {
+			return n, err
+		}
+
+		seg, err := s.acquire(i)
+		if err != nil {
+			return n, err
+		}
+
+		nr, err := r.Read(seg.Data[start:])
+		if nr > 0 {
+			// mark the segment as changed
+			s.markDirty(seg)
+		}
+
+		s.release(seg)
+
+		n += int64(nr)
+		s.offs += int64(nr)
+
+		if err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+	}
+}
+
+// WriteTo implements the io.WriterTo interface
+// Data is written directly from segment memory maps starting from the
+// current offset until the end of the last segment.
+func (s *Store) WriteTo(w io.Writer) (n int64, err error) {
+	s.offmx.Lock()
+	defer s.offmx.Unlock()
+
+	end := int64(len(s.loaded())) * s.size
+	if s.offs >= end {
+		return 0, nil
+	}
+
+	fn := func(i, start, end int64) (stop bool, err error) {
+		seg, err := s.acquire(i)
+		if err == io.EOF {
+			return true, nil
+		} else if err != nil {
+			return false, err
+		}
+
+		c, err := w.Write(seg.Data[start:end])
+		s.release(seg)
+
+		n += int64(c)
+		s.offs += int64(c)
+
+		return false, err
+	}
+
+	err = segments.Bounds(s.size, s.offs, end, fn)
+	return n, err
+}
+
 // Slice implements the fs.Slicer interface
 func (s *Store) Slice(sz int64) (p []byte, err error) {
 	s.offmx.Lock()
@@ -130,10 +397,8 @@ func (s *Store) Seek(offset int64, whence int) (off int64, err error) {
 		s.offs += offset
 	case 2:
 		// from file end
-		s.segmx.RLock()
-		end := int64(len(s.segs)) * s.size
+		end := int64(len(s.loaded())) * s.size
 		s.offs = end + offset
-		s.segmx.RUnlock()
 	}
 	off = s.offs
 	s.offmx.Unlock()
@@ -147,15 +412,13 @@ func (s *Store) ReadAt(p []byte, off int64) (n int, err error) {
 	toread := p[:]
 
 	fn := func(i, start, end int64) (stop bool, err error) {
-		s.segmx.RLock()
-		if i >= int64(len(s.segs)) {
-			s.segmx.RUnlock()
-			return false, io.EOF
+		seg, err := s.acquire(i)
+		if err != nil {
+			return false, err
 		}
-		s.segmx.RUnlock()
 
-		seg := s.segs[i]
 		c := copy(toread, seg.Data[start:end])
+		s.release(seg)
 
 		n += c
 		toread = toread[c:]
@@ -169,24 +432,31 @@ func (s *Store) ReadAt(p []byte, off int64) (n int, err error) {
 
 // WriteAt implements the io.WriterAt interface
 func (s *Store) WriteAt(p []byte, off int64) (n int, err error) {
+	if s.opts.ReadOnly {
+		return 0, segments.ErrReadOnly
+	}
+
 	sz := int64(len(p))
 	towrite := p[:]
-	var toalloc int64
 
 	fn := func(i, start, end int64) (stop bool, err error) {
 		if err := s.ensure(i); err != nil {
 			return false, err
 		}
 
-		seg := s.segs[i]
+		seg, err := s.acquire(i)
+		if err != nil {
+			return false, err
+		}
+
 		c := copy(seg.Data[start:end], towrite)
 
 		// mark the segment as changed
-		atomic.StoreUint32(&seg.dirty, 1)
+		s.markDirty(seg)
+		s.release(seg)
 
 		n += c
 		towrite = towrite[c:]
-		toalloc = i + 1
 
 		return false, nil
 	}
@@ -201,20 +471,18 @@ func (s *Store) WriteAt(p []byte, off int64) (n int, err error) {
 // SliceAt implements the fs.SlicerAt interface
 func (s *Store) SliceAt(sz, off int64) (p []byte, err error) {
 	fn := func(i, start, end int64) (stop bool, err error) {
-		s.segmx.RLock()
-		if i >= int64(len(s.segs)) {
-			s.segmx.RUnlock()
-			return false, io.EOF
+		seg, err := s.acquire(i)
+		if err != nil {
+			return false, err
 		}
-		s.segmx.RUnlock()
 
-		seg := s.segs[i]
 		p = seg.Data[start:end]
+		defer s.release(seg)
 
 		// mark that the mmap may have changed (sliced data can be changed)
 		// TODO We're setting this as changed but the change is going to
 		// happen later (if it happens at all). Try to fix this problem.
-		atomic.StoreUint32(&seg.dirty, 1)
+		s.markDirty(seg)
 
 		return true, nil
 	}
@@ -226,9 +494,38 @@ func (s *Store) SliceAt(sz, off int64) (p []byte, err error) {
 	return p, nil
 }
 
+// SliceVAt implements the fs.SlicerV interface
+// Returns one byte slice for each segment in the range.
+func (s *Store) SliceVAt(sz, off int64) (ps [][]byte, err error) {
+	fn := func(i, start, end int64) (stop bool, err error) {
+		seg, err := s.acquire(i)
+		if err != nil {
+			return false, err
+		}
+
+		ps = append(ps, seg.Data[start:end])
+		defer s.release(seg)
+
+		// mark that the mmap may have changed (sliced data can be changed)
+		s.markDirty(seg)
+
+		return false, nil
+	}
+
+	if err := segments.Bounds(s.size, off, off+sz, fn); err != nil {
+		return nil, err
+	}
+
+	return ps, nil
+}
+
 // Ensure makes sure that data upto given offset exists and are valid.
 // This will check from current segment length upto given position.
 func (s *Store) Ensure(off int64) (err error) {
+	if s.opts.ReadOnly {
+		return segments.ErrReadOnly
+	}
+
 	n := off / s.size
 	if off%s.size != 0 {
 		n++
@@ -239,60 +536,323 @@ func (s *Store) Ensure(off int64) (err error) {
 
 // Sync implements the fs.Syncer interface
 func (s *Store) Sync() (err error) {
-	s.segmx.RLock()
-	for _, seg := range s.segs {
-		if !atomic.CompareAndSwapUint32(&seg.dirty, 1, 0) {
+	for i, seg := range s.loaded() {
+		if err := s.syncDirty(int64(i), seg); err != nil {
+			return err
+		}
+	}
+
+	return s.syncSums()
+}
+
+// SyncRange implements the fs.SyncRanger interface
+// Segments are synced completely if they are in the range.
+func (s *Store) SyncRange(sz, off int64) (err error) {
+	fn := func(i, start, end int64) (stop bool, err error) {
+		segs := s.loaded()
+		if i >= int64(len(segs)) {
+			return true, nil
+		}
+		seg := segs[i]
+
+		return false, s.syncDirty(i, seg)
+	}
+
+	if err := segments.Bounds(s.size, off, off+sz, fn); err != nil {
+		return err
+	}
+
+	return s.syncSums()
+}
+
+// Verify compares segments with their checksums and returns indexes of
+// segments which do not match. Segments which were changed but not synced
+// before the process crashed are also reported. Segments without checksums
+// (never synced) are not checked. ErrNoChecksums is returned if checksums
+// are not enabled.
+func (s *Store) Verify() (bad []int64, err error) {
+	if s.sums == nil {
+		return nil, segments.ErrNoChecksums
+	}
+
+	n := len(s.loaded())
+
+	for i := int64(0); i < int64(n); i++ {
+		sum, err := s.sums.Get(i)
+		if err != nil {
+			return nil, err
+		}
+
+		if sum == 0 {
 			continue
 		}
 
-		if err := seg.Sync(); err != nil {
-			s.segmx.RUnlock()
+		seg, err := s.acquire(i)
+		if err == segments.ErrRemoved {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if sum != segments.Checksum(seg.Data) {
+			bad = append(bad, i)
+		}
+
+		s.release(seg)
+	}
+
+	return bad, nil
+}
+
+// Corrupted returns indexes of segments which did not match their checksums
+// when the store was opened. Syncing a segment updates its checksum.
+func (s *Store) Corrupted() (bad []int64) {
+	return s.corrupt
+}
+
+// Flush implements the fs.Flusher interface
+// Data is not buffered by the store so there's nothing to flush.
+func (s *Store) Flush() (err error) {
+	return nil
+}
+
+// Size implements the fs.Sizer interface
+// This includes the space allocated for all segments.
+func (s *Store) Size() (sz int64, err error) {
+	sz = int64(len(s.loaded())) * s.size
+	return sz, nil
+}
+
+// Truncate implements the fs.Truncator interface. Segment files cannot be
+// partially truncated so the size is rounded up to a segment boundary.
+// Segment files which are not required to store sz bytes are removed.
+// Reads and writes running at the same time finish before their segments
+// are unmapped but slices returned by SliceAt/SliceVAt must not be used
+// after their segments are truncated.
+func (s *Store) Truncate(sz int64) (err error) {
+	if s.opts.ReadOnly {
+		return segments.ErrReadOnly
+	}
+
+	n := int(sz / s.size)
+	if sz%s.size != 0 {
+		n++
+	}
+
+	s.segmx.Lock()
+	defer s.segmx.Unlock()
+
+	segs := s.loaded()
+	for i := len(segs) - 1; i >= n; i-- {
+		// the segment is removed from loaded segments
+		// first so that it's not mapped again after this
+		seg := segs[i]
+		segs = segs[:i]
+		s.segs.Store(segs)
+
+		// the segment is synced when it's closed
+		if err := s.unmap(seg); err != nil {
+			return err
+		}
+
+		path := s.base + strconv.Itoa(i)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if s.sums != nil {
+		return s.sums.Truncate(int64(len(segs)))
+	}
+
+	return nil
+}
+
+// Remove removes segments which only have data before given offset. This
+// can be used to drop old data (ex. time series) without changing offsets
+// of remaining data. Removed data cannot be used and ErrRemoved is returned
+// when it's accessed. The last segment is never removed. Removed segments
+// must not be used while they're being removed.
+func (s *Store) Remove(before int64) (err error) {
+	if s.opts.ReadOnly {
+		return segments.ErrReadOnly
+	}
+
+	n := before / s.size
+
+	s.segmx.Lock()
+	defer s.segmx.Unlock()
+
+	segs := s.loaded()
+	if max := int64(len(segs)) - 1; n > max {
+		n = max
+	}
+
+	for i := int64(0); i < n; i++ {
+		seg := segs[i]
+		if atomic.LoadUint32(&seg.removed) == 1 {
+			continue
+		}
+
+		seg.mutex.Lock()
+		atomic.StoreUint32(&seg.removed, 1)
+		seg.mutex.Unlock()
+
+		if err := s.unmap(seg); err != nil {
+			return err
+		}
+
+		path := s.base + strconv.FormatInt(i, 10)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Export writes all segments to a single stream which can be used to copy
+// the store to another location (see segments.Export).
+func (s *Store) Export(w io.Writer) (err error) {
+	return segments.Export(s, s.size, w)
+}
+
+// Import writes segments from a stream created with Export to the store
+// (see segments.Import). Use it with an empty store with the same segment
+// size. Call Sync to write imported data to the disk.
+func (s *Store) Import(r io.Reader) (err error) {
+	return segments.Import(s, s.size, r)
+}
+
+// Compact removes segment files at the end of the store which only have
+// zeroes and frees disk space used by zero filled blocks in other segments
+// (see segments.Sparsify). One empty segment is kept after the last segment
+// with data for preallocation. The store should not be written while it's
+// being compacted.
+func (s *Store) Compact() (err error) {
+	if s.opts.ReadOnly {
+		return segments.ErrReadOnly
+	}
+
+	n := len(s.loaded())
+
+	last := -1
+	for i := n - 1; i >= 0; i-- {
+		seg, err := s.acquire(int64(i))
+		if err == segments.ErrRemoved {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		zero := segments.IsZero(seg.Data)
+		s.release(seg)
+
+		if !zero {
+			last = i
+			break
+		}
+	}
+
+	if err := s.Truncate(int64(last+2) * s.size); err != nil {
+		return err
+	}
+
+	for i := 0; i <= last; i++ {
+		err := segments.Sparsify(s.base + strconv.Itoa(i))
+		if err != nil && !os.IsNotExist(err) {
 			return err
 		}
 	}
-	s.segmx.RUnlock()
 
 	return nil
 }
 
 // Close implements the io.Closer interface
+// Segments which are being preallocated are created before closing.
+// The store lock is released after closing all segments. Reads and writes
+// running at the same time finish before segments are unmapped and later
+// ones fail with ErrClosed. Slices returned by SliceAt/SliceVAt must not
+// be used after closing the store.
 func (s *Store) Close() (err error) {
-	s.segmx.RLock()
-	for _, seg := range s.segs {
-		if err := seg.Close(); err != nil {
-			s.segmx.RUnlock()
+	if s.tick != nil {
+		s.tick.Stop()
+	}
+
+	s.stopAlloc()
+
+	if s.sums != nil {
+		if err := s.Sync(); err != nil {
+			return err
+		}
+
+		if err := s.sums.Close(); err != nil {
 			return err
 		}
 	}
-	s.segmx.RUnlock()
 
-	return nil
+	atomic.StoreUint32(&s.closed, 1)
+
+	for _, seg := range s.loaded() {
+		if err := s.unmap(seg); err != nil {
+			return err
+		}
+	}
+
+	// the lock is released after closing segments
+	return s.lock.Close()
 }
 
 // ensure makes sure that segments upto given index exists and are valid.
 // This will check from current segment length upto given position.
 // This will also pre allocate an additional segment file/mmap.
 func (s *Store) ensure(n int64) (err error) {
-	// +1 preallocate
-	num := int(n) + 1
+	if err := s.create(n); err != nil {
+		return err
+	}
+
+	s.prealloc(n + 1)
+
+	if s.opts.MaxOpenSegments > 0 {
+		return s.evict(n)
+	}
+
+	return nil
+}
+
+// create creates segments upto given index
+func (s *Store) create(n int64) (err error) {
+	num := int(n)
 
 	// fast path
-	s.segmx.RLock()
-	if num < len(s.segs) {
-		s.segmx.RUnlock()
+	if num < len(s.loaded()) {
 		return nil
 	}
-	s.segmx.RUnlock()
 
 	// slow path
 	s.segmx.Lock()
 	defer s.segmx.Unlock()
 
-	available := len(s.segs)
+	return s.extend(n, false)
+}
+
+// extend creates segments after loaded segments upto given index. Lazy
+// segments are not mapped until they're used (see Options.MaxOpenSegments).
+// This should be called while holding the segment mutex.
+func (s *Store) extend(n int64, lazy bool) (err error) {
+	num := int(n)
+
+	loaded := s.loaded()
+	available := len(loaded)
 	if num < available {
 		return nil
 	}
 
+	// readers may be using the loaded slice so a new one is used
+	// new segments are added after the end of loaded segments
+	segs := make([]*Segment, available, num+1)
+	copy(segs, loaded)
+
 	for i := available; i <= num; i++ {
 		path := s.base + strconv.Itoa(i)
 		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
@@ -303,18 +863,345 @@ func (s *Store) ensure(n int64) (err error) {
 		// don't need this
 		defer file.Close()
 
-		seg, err := memmap.MapFile(file, s.size)
+		if err := allocSegment(file, s.size); err != nil {
+			return err
+		}
+
+		if lazy {
+			segs = append(segs, &Segment{})
+			s.segs.Store(segs)
+			continue
+		}
+
+		seg, err := mapSegment(file, s.opts)
 		if err != nil {
 			return err
 		}
 
-		if err := seg.Lock(); err != nil {
-			go seg.Close()
+		segs = append(segs, &Segment{Map: seg, open: 1})
+		s.segs.Store(segs)
+		atomic.AddInt32(&s.nopen, 1)
+	}
+
+	return nil
+}
+
+// loaded returns loaded segments without locking
+// The returned slice must not be modified.
+func (s *Store) loaded() (segs []*Segment) {
+	return s.segs.Load().([]*Segment)
+}
+
+// prealloc creates segments upto given index in the background
+// It's skipped if the worker already has too many segments to create.
+func (s *Store) prealloc(n int64) {
+	// fast path
+	if n < int64(len(s.loaded())) {
+		return
+	}
+
+	s.allocmx.RLock()
+	defer s.allocmx.RUnlock()
+
+	if s.alloc == nil {
+		return
+	}
+
+	select {
+	case s.alloc <- n:
+	default:
+	}
+}
+
+// allocate creates segments sent by prealloc until the channel is closed
+// Errors are ignored here, they're returned when writers create segments.
+func (s *Store) allocate(alloc chan int64) {
+	defer s.allocwg.Done()
+
+	for n := range alloc {
+		// only the segment after the last one is preallocated
+		// segments may have been truncated after sending this
+		// preallocated segments are not mapped if mapped segments are limited
+		s.segmx.Lock()
+		if n == int64(len(s.loaded())) {
+			s.extend(n, s.opts.MaxOpenSegments > 0)
+		}
+		s.segmx.Unlock()
+	}
+}
+
+// stopAlloc stops the preallocation worker after creating queued segments
+func (s *Store) stopAlloc() {
+	s.allocmx.Lock()
+	if s.alloc != nil {
+		close(s.alloc)
+		s.alloc = nil
+	}
+	s.allocmx.Unlock()
+
+	s.allocwg.Wait()
+}
+
+// acquire returns a segment making sure that it's mapped. The segment is not
+// unmapped until it's released (ex. by Truncate or Close). io.EOF is returned
+// if it does not exist or if it was truncated.
+func (s *Store) acquire(i int64) (seg *Segment, err error) {
+	segs := s.loaded()
+	if i >= int64(len(segs)) {
+		return nil, io.EOF
+	}
+	seg = segs[i]
+
+	for {
+		seg.mutex.RLock()
+		if atomic.LoadUint32(&s.closed) == 1 {
+			seg.mutex.RUnlock()
+			return nil, ErrClosed
+		}
+
+		if atomic.LoadUint32(&seg.removed) == 1 {
+			seg.mutex.RUnlock()
+			return nil, segments.ErrRemoved
+		}
+
+		if seg.Map != nil {
+			if s.opts.MaxOpenSegments > 0 {
+				atomic.StoreInt64(&seg.used, atomic.AddInt64(&s.clock, 1))
+			}
+
+			return seg, nil
+		}
+		seg.mutex.RUnlock()
+
+		// segments are only unmapped by Truncate if they're not limited
+		if s.opts.MaxOpenSegments == 0 {
+			return nil, io.EOF
+		}
+
+		if err := s.remap(i, seg); err != nil {
+			return nil, err
+		}
+
+		// other segments are unmapped without holding segment locks
+		// the segment may be unmapped again before it's locked
+		if err := s.evict(i); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// release allows unmapping a segment returned by acquire
+func (s *Store) release(seg *Segment) {
+	seg.mutex.RUnlock()
+}
+
+// remap maps a segment which was unmapped
+func (s *Store) remap(i int64, seg *Segment) (err error) {
+	seg.mutex.Lock()
+	defer seg.mutex.Unlock()
+
+	if seg.Map != nil || atomic.LoadUint32(&seg.removed) == 1 {
+		return nil
+	}
+
+	if atomic.LoadUint32(&s.closed) == 1 {
+		return ErrClosed
+	}
+
+	// truncated segments are not mapped again
+	if segs := s.loaded(); i >= int64(len(segs)) || segs[i] != seg {
+		return io.EOF
+	}
+
+	file, err := openSegment(s.base+strconv.FormatInt(i, 10), s.opts)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	m, err := mapSegment(file, s.opts)
+	if err != nil {
+		return err
+	}
+
+	seg.Map = m
+	atomic.StoreUint32(&seg.open, 1)
+	atomic.StoreInt64(&seg.used, atomic.AddInt64(&s.clock, 1))
+	atomic.AddInt32(&s.nopen, 1)
+	return nil
+}
+
+// evict unmaps least recently used segments until the number of mapped
+// segments is within the limit. The segment with index keep is not unmapped.
+// This must not be called while holding segment locks.
+func (s *Store) evict(keep int64) (err error) {
+	s.evictmx.Lock()
+	defer s.evictmx.Unlock()
+
+	for int(atomic.LoadInt32(&s.nopen)) > s.opts.MaxOpenSegments {
+		var victim *Segment
+		var vi int64
+
+		for i, seg := range s.loaded() {
+			if int64(i) == keep || atomic.LoadUint32(&seg.open) == 0 {
+				continue
+			}
+
+			if victim == nil || atomic.LoadInt64(&seg.used) < atomic.LoadInt64(&victim.used) {
+				victim, vi = seg, int64(i)
+			}
+		}
+
+		if victim == nil {
+			return nil
+		}
+
+		if err := s.syncDirty(vi, victim); err != nil {
+			return err
+		}
+
+		if err := s.unmap(victim); err != nil {
 			return err
 		}
+	}
+
+	return nil
+}
+
+// unmap syncs and unmaps a segment if it's mapped
+func (s *Store) unmap(seg *Segment) (err error) {
+	seg.mutex.Lock()
+	defer seg.mutex.Unlock()
+
+	if seg.Map == nil {
+		return nil
+	}
 
-		s.segs = append(s.segs, &Segment{seg, 0})
+	// the segment is synced when it's closed
+	s.markClean(seg)
+
+	if err := seg.Close(); err != nil {
+		return err
 	}
 
+	seg.Map = nil
+	atomic.StoreUint32(&seg.open, 0)
+	atomic.AddInt32(&s.nopen, -1)
 	return nil
 }
+
+// syncDirty syncs a segment if it's dirty
+func (s *Store) syncDirty(i int64, seg *Segment) (err error) {
+	seg.mutex.RLock()
+	defer seg.mutex.RUnlock()
+
+	// unmapped segments are synced before unmapping
+	if seg.Map == nil || !s.markClean(seg) {
+		return nil
+	}
+
+	return s.syncSeg(i, seg)
+}
+
+// markDirty marks a segment as changed and starts syncing dirty segments
+// in the background if there are too many of them
+func (s *Store) markDirty(seg *Segment) {
+	if !atomic.CompareAndSwapUint32(&seg.dirty, 0, 1) {
+		return
+	}
+
+	n := atomic.AddInt32(&s.ndirty, 1)
+	if s.tick != nil && s.opts.FlushThreshold > 0 && int(n) >= s.opts.FlushThreshold {
+		s.tick.TriggerNow()
+	}
+}
+
+// markClean clears the dirty flag of a segment
+// returns false if the segment was not dirty
+func (s *Store) markClean(seg *Segment) bool {
+	if !atomic.CompareAndSwapUint32(&seg.dirty, 1, 0) {
+		return false
+	}
+
+	atomic.AddInt32(&s.ndirty, -1)
+	return true
+}
+
+// syncSeg syncs a segment and updates its checksum
+func (s *Store) syncSeg(i int64, seg *Segment) (err error) {
+	if err := seg.Sync(); err != nil {
+		return err
+	}
+
+	if s.sums == nil {
+		return nil
+	}
+
+	return s.sums.Set(i, segments.Checksum(seg.Data))
+}
+
+// syncSums writes segment checksums to the disk
+func (s *Store) syncSums() (err error) {
+	if s.sums == nil {
+		return nil
+	}
+
+	return s.sums.Sync()
+}
+
+// openSegment opens an existing segment file
+// Read-only stores open it without write access.
+func openSegment(path string, opts *Options) (file *os.File, err error) {
+	if opts.ReadOnly {
+		return os.Open(path)
+	}
+
+	return os.OpenFile(path, os.O_RDWR, 0644)
+}
+
+// mapSegment maps a segment file to memory
+func mapSegment(file *os.File, opts *Options) (m *memmap.Map, err error) {
+	return memmap.OpenFile(file, opts.SegmentSize, &memmap.Options{
+		Lock:      opts.Lock,
+		HugePages: opts.HugePages,
+		ReadOnly:  opts.ReadOnly,
+	})
+}
+
+// allocSegment allocates disk space for a new segment file
+// Existing segment files should have the correct size.
+func allocSegment(file *os.File, size int64) (err error) {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	switch info.Size() {
+	case size:
+		return nil
+	case 0:
+		return fsutils.FallocateFile(file, size)
+	default:
+		// file already exists with different size
+		return memmap.ErrBadSz
+	}
+}
+
+// closeSegs closes segments after failing to load all of them
+func closeSegs(segs []*Segment) {
+	for _, seg := range segs {
+		if seg.Map != nil {
+			seg.Close()
+		}
+	}
+}
+
+// lockPolicy converts the lock flag used by New and LoadSegs
+func lockPolicy(lock bool) memmap.LockPolicy {
+	if lock {
+		return memmap.LockRequired
+	}
+
+	return memmap.LockDisabled
+}
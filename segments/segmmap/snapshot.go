@@ -0,0 +1,208 @@
+package segmmap
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+
+	"github.com/kadirahq/go-tools/segments"
+)
+
+var (
+	// ErrSnapshotExists is returned when the snapshot directory exists.
+	ErrSnapshotExists = errors.New("snapshot directory exists")
+)
+
+// Manifest describes files in a store snapshot
+type Manifest struct {
+	// Size of each segment file in bytes.
+	SegmentSize int64
+
+	// Segment files in the snapshot sorted by their index.
+	Segments []SnapshotFile
+}
+
+// SnapshotFile is a segment file in a store snapshot
+type SnapshotFile struct {
+	// Segment index in the store
+	Index int64
+
+	// File name in the snapshot directory
+	Name string
+
+	// Checksum of the segment file (see segments.Checksum)
+	Checksum uint64
+}
+
+// Snapshot syncs dirty segments and copies the store into a new directory.
+// Files are written to a temporary directory which is renamed when it's
+// complete so the snapshot directory either has all files or none of them.
+// Files use the same name prefix as the store so the snapshot can be opened
+// with filepath.Join(dir, prefix). Writers are not stopped but each segment
+// is copied from its memory map so data written during the snapshot may or
+// may not be included. The header file never changes so it's hard-linked
+// when possible but segment files are modified in place and always copied.
+func (s *Store) Snapshot(dir string) (m *Manifest, err error) {
+	if _, err := os.Stat(dir); err == nil {
+		return nil, ErrSnapshotExists
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if err := s.Sync(); err != nil {
+		return nil, err
+	}
+
+	tmp, err := ioutil.TempDir(filepath.Dir(dir), filepath.Base(dir)+".tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	// removed if the snapshot is not complete
+	defer func() {
+		if err != nil {
+			os.RemoveAll(tmp)
+		}
+	}()
+
+	prefix := filepath.Base(s.base)
+	if err := linkFile(s.base+"header", filepath.Join(tmp, prefix+"header")); err != nil {
+		return nil, err
+	}
+
+	n := int64(len(s.loaded()))
+
+	m = &Manifest{SegmentSize: s.size}
+	for i := int64(0); i < n; i++ {
+		name := prefix + strconv.FormatInt(i, 10)
+		sum, err := s.copySegment(i, filepath.Join(tmp, name))
+		if err == segments.ErrRemoved {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		m.Segments = append(m.Segments, SnapshotFile{
+			Index:    i,
+			Name:     name,
+			Checksum: sum,
+		})
+	}
+
+	if s.sums != nil {
+		if err := writeSums(filepath.Join(tmp, prefix+"sums"), m); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := syncDir(tmp); err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(tmp, dir); err != nil {
+		return nil, err
+	}
+
+	if err := syncDir(filepath.Dir(dir)); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// copySegment writes segment data to a new file and returns its checksum.
+// The checksum is calculated using the same data written to the file.
+func (s *Store) copySegment(i int64, path string) (sum uint64, err error) {
+	seg, err := s.acquire(i)
+	if err != nil {
+		return 0, err
+	}
+
+	defer s.release(seg)
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return 0, err
+	}
+
+	defer file.Close()
+
+	sum, err = segments.ChecksumReader(io.TeeReader(bytes.NewReader(seg.Data), file))
+	if err != nil {
+		return 0, err
+	}
+
+	if err := file.Sync(); err != nil {
+		return 0, err
+	}
+
+	return sum, nil
+}
+
+// writeSums creates a checksum file for segments in a snapshot
+func writeSums(path string, m *Manifest) (err error) {
+	sums, err := segments.OpenSumFile(path)
+	if err != nil {
+		return err
+	}
+
+	defer sums.Close()
+
+	for _, f := range m.Segments {
+		if err := sums.Set(f.Index, f.Checksum); err != nil {
+			return err
+		}
+	}
+
+	return sums.Sync()
+}
+
+// linkFile creates a hard link to a file or copies it if it's not possible
+// (ex. if the target is on a different filesystem)
+func linkFile(src, dst string) (err error) {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+
+	return out.Sync()
+}
+
+// syncDir syncs a directory to make sure that file entries are on the disk
+// Directories cannot be synced on windows so it's not done there.
+func syncDir(path string) (err error) {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer dir.Close()
+
+	return dir.Sync()
+}
@@ -0,0 +1,126 @@
+package segmmap
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kadirahq/go-tools/segments"
+)
+
+func TestSnapshot(t *testing.T) {
+	defer setup(t)()
+
+	s, err := Open(tmpfile, &Options{
+		SegmentSize:     10,
+		Checksums:       true,
+		DisablePrealloc: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	if _, err := s.WriteAt([]byte("hello world!"), 5); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(tmpdir, "snap")
+	m, err := s.Snapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.SegmentSize != 10 || len(m.Segments) != 2 {
+		t.Fatal("wrong manifest")
+	}
+
+	for _, f := range m.Segments {
+		data, err := ioutil.ReadFile(filepath.Join(dir, f.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if f.Checksum != segments.Checksum(data) {
+			t.Fatal("wrong checksum")
+		}
+	}
+
+	if _, err := s.Snapshot(dir); err != ErrSnapshotExists {
+		t.Fatal("expected ErrSnapshotExists")
+	}
+
+	// changes after the snapshot should not be visible
+	if _, err := s.WriteAt([]byte("HELLO"), 5); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := Open(filepath.Join(dir, "seg_"), &Options{SegmentSize: 10, Checksums: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer snap.Close()
+
+	if bad := snap.Corrupted(); len(bad) != 0 {
+		t.Fatal("snapshot should not be corrupted")
+	}
+
+	p := make([]byte, 12)
+	if _, err := snap.ReadAt(p, 5); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(p, []byte("hello world!")) {
+		t.Fatal("wrong data")
+	}
+
+	files, err := ioutil.ReadDir(tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range files {
+		if f.IsDir() && f.Name() != "snap" {
+			t.Fatal("temporary directory should be renamed")
+		}
+	}
+}
+
+func TestSnapshotRemoved(t *testing.T) {
+	defer setup(t)()
+
+	s, err := New(tmpfile, 10, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	if _, err := s.WriteAt([]byte("hello world!"), 20); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Remove(25); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(tmpdir, "snap")
+	m, err := s.Snapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.Segments) == 0 || m.Segments[0].Index != 2 {
+		t.Fatal("removed segments should not be in the snapshot")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "seg_0")); !os.IsNotExist(err) {
+		t.Fatal("removed segment file should not exist")
+	}
+}
@@ -0,0 +1,558 @@
+// Package segsingle stores all segments of a segment store in a single
+// preallocated file. Segments are placed in fixed size slots in the data
+// file and an extent index maps segment indexes to slots. This is useful on
+// file systems where creating many files is expensive (ex. inode limits).
+package segsingle
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kadirahq/go-tools/fsutils"
+	"github.com/kadirahq/go-tools/segments"
+)
+
+var (
+	// ErrIndex is returned when the extent index does not match the data
+	// file (ex. a segment is mapped to a slot outside the data file).
+	ErrIndex = errors.New("bad segment extent index")
+)
+
+const (
+	// size of an extent index entry in bytes
+	entrySize = 8
+
+	// size of chunks used to clear reused slots
+	zeroChunk = 1024 * 1024
+)
+
+// Options for segment stores
+type Options struct {
+	// Size of each segment in bytes.
+	SegmentSize int64
+
+	// GrowSlots is the number of segment slots added to the data file
+	// when it's full. Disk space for new slots is allocated when the file
+	// grows (see fsutils.FallocateFile).
+	GrowSlots int64
+
+	// ReadOnly opens the store with a shared lock so other read-only users
+	// can use it at the same time. Changing the store fails with
+	// segments.ErrReadOnly. Otherwise, an exclusive lock is used.
+	ReadOnly bool
+
+	// LockTimeout is the time to wait for other processes to release the
+	// store lock before returning fsutils.ErrLocked (see segments.LockStore).
+	LockTimeout time.Duration
+}
+
+// DefaultOptions is used when options are not given to Open.
+var DefaultOptions = &Options{
+	SegmentSize: 64 * 1024 * 1024,
+	GrowSlots:   4,
+}
+
+// Store is a collection of segments in a single data file (base + "data").
+// The extent index (base + "index") has the slot of each segment in the data
+// file. Slots of segments which are truncated are reused for new segments.
+type Store struct {
+	data  *os.File
+	index *os.File
+	size  int64
+	grow  int64
+	offs  int64
+	offmx *sync.Mutex
+	dirty uint32
+
+	// lock file used to prevent other processes from changing the store
+	lock     *os.File
+	readonly bool
+
+	// slots of loaded segments ([]int64) are replaced when segments are
+	// created or truncated so they can be used without locking. The mutex
+	// is used when changing slots, the free list or the data file size.
+	slots atomic.Value
+	segmx *sync.Mutex
+	free  []int64
+	ncap  int64
+}
+
+// New creates a single file segment store on given path
+func New(base string, size int64) (s *Store, err error) {
+	return Open(base, &Options{SegmentSize: size, GrowSlots: DefaultOptions.GrowSlots})
+}
+
+// Open creates a single file segment store on given path with options
+// The segment size is recorded in a header file (base + "header") when the
+// store is created and opening it with a different size fails. The store is
+// locked while it's open so other processes cannot change it.
+func Open(base string, opts *Options) (s *Store, err error) {
+	if opts == nil {
+		opts = DefaultOptions
+	}
+
+	lock, err := segments.LockStore(base, !opts.ReadOnly, opts.LockTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// the data file of a store without a header should have whole segments
+	check := func() error {
+		info, err := os.Stat(base + "data")
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if info.Size()%opts.SegmentSize != 0 {
+			return segments.ErrSegSize
+		}
+
+		return nil
+	}
+
+	if err := segments.CheckHeader(base+"header", opts.SegmentSize, opts.ReadOnly, check); err != nil {
+		lock.Close()
+		return nil, err
+	}
+
+	grow := opts.GrowSlots
+	if grow < 1 {
+		grow = 1
+	}
+
+	// read-only stores are opened without write access
+	mode := os.O_RDWR | os.O_CREATE
+	if opts.ReadOnly {
+		mode = os.O_RDONLY
+	}
+
+	data, err := os.OpenFile(base+"data", mode, 0644)
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+
+	index, err := os.OpenFile(base+"index", mode, 0644)
+	if err != nil {
+		data.Close()
+		lock.Close()
+		return nil, err
+	}
+
+	s = &Store{
+		data:     data,
+		index:    index,
+		size:     opts.SegmentSize,
+		grow:     grow,
+		offmx:    &sync.Mutex{},
+		lock:     lock,
+		readonly: opts.ReadOnly,
+		segmx:    &sync.Mutex{},
+	}
+
+	if err := s.load(); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	// read-only stores only use existing segments
+	if opts.ReadOnly {
+		return s, nil
+	}
+
+	if err := s.ensure(0); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Read implements the io.Reader interface
+func (s *Store) Read(p []byte) (n int, err error) {
+	s.offmx.Lock()
+	n, err = s.ReadAt(p, s.offs)
+	s.offs += int64(n)
+	s.offmx.Unlock()
+	return n, err
+}
+
+// Write implements the io.Writer interface
+func (s *Store) Write(p []byte) (n int, err error) {
+	s.offmx.Lock()
+	n, err = s.WriteAt(p, s.offs)
+	s.offs += int64(n)
+	s.offmx.Unlock()
+	return n, err
+}
+
+// Slice implements the fs.Slicer interface
+func (s *Store) Slice(sz int64) (p []byte, err error) {
+	s.offmx.Lock()
+	p, err = s.SliceAt(sz, s.offs)
+	s.offs += int64(len(p))
+	s.offmx.Unlock()
+	return p, err
+}
+
+// Seek implements the io.Seeker interface
+func (s *Store) Seek(offset int64, whence int) (off int64, err error) {
+	s.offmx.Lock()
+	switch whence {
+	case 0:
+		// from file start
+		s.offs = offset
+	case 1:
+		// from current
+		s.offs += offset
+	case 2:
+		// from file end
+		end := int64(len(s.loaded())) * s.size
+		s.offs = end + offset
+	}
+	off = s.offs
+	s.offmx.Unlock()
+
+	return off, nil
+}
+
+// ReadAt implements the io.ReaderAt interface
+func (s *Store) ReadAt(p []byte, off int64) (n int, err error) {
+	sz := int64(len(p))
+	toread := p[:]
+
+	fn := func(i, start, end int64) (stop bool, err error) {
+		slot, err := s.slot(i)
+		if err != nil {
+			return false, err
+		}
+
+		c, err := s.data.ReadAt(toread[:end-start], slot*s.size+start)
+		n += c
+		toread = toread[c:]
+
+		return false, err
+	}
+
+	err = segments.Bounds(s.size, off, off+sz, fn)
+	return n, err
+}
+
+// WriteAt implements the io.WriterAt interface
+func (s *Store) WriteAt(p []byte, off int64) (n int, err error) {
+	if s.readonly {
+		return 0, segments.ErrReadOnly
+	}
+
+	sz := int64(len(p))
+	towrite := p[:]
+
+	fn := func(i, start, end int64) (stop bool, err error) {
+		if err := s.ensure(i); err != nil {
+			return false, err
+		}
+
+		slot, err := s.slot(i)
+		if err != nil {
+			return false, err
+		}
+
+		c, err := s.data.WriteAt(towrite[:end-start], slot*s.size+start)
+		n += c
+		towrite = towrite[c:]
+
+		// mark the store as changed
+		atomic.StoreUint32(&s.dirty, 1)
+
+		return false, err
+	}
+
+	if err := segments.Bounds(s.size, off, off+sz, fn); err != nil {
+		return n, err
+	}
+
+	return n, nil
+}
+
+// SliceAt implements the fs.SlicerAt interface
+func (s *Store) SliceAt(sz, off int64) (p []byte, err error) {
+	p = make([]byte, sz)
+	n, err := s.ReadAt(p, off)
+	if err != nil {
+		return nil, err
+	}
+
+	return p[:n], nil
+}
+
+// Ensure makes sure that data upto given offset exists and are valid.
+// This will check from current segment length upto given position.
+func (s *Store) Ensure(off int64) (err error) {
+	if s.readonly {
+		return segments.ErrReadOnly
+	}
+
+	n := off / s.size
+	if off%s.size != 0 {
+		n++
+	}
+
+	return s.ensure(n)
+}
+
+// Sync implements the fs.Syncer interface
+// Both the data file and the extent index are synced.
+func (s *Store) Sync() (err error) {
+	if !atomic.CompareAndSwapUint32(&s.dirty, 1, 0) {
+		return nil
+	}
+
+	if err := s.data.Sync(); err != nil {
+		atomic.StoreUint32(&s.dirty, 1)
+		return err
+	}
+
+	if err := s.index.Sync(); err != nil {
+		atomic.StoreUint32(&s.dirty, 1)
+		return err
+	}
+
+	return nil
+}
+
+// SyncRange implements the fs.SyncRanger interface
+// All segments are in the same file so the whole store is synced.
+func (s *Store) SyncRange(sz, off int64) (err error) {
+	return s.Sync()
+}
+
+// Flush implements the fs.Flusher interface
+// Data is not buffered by the store so there's nothing to flush.
+func (s *Store) Flush() (err error) {
+	return nil
+}
+
+// Size implements the fs.Sizer interface
+// This includes the space allocated for all segments.
+func (s *Store) Size() (sz int64, err error) {
+	sz = int64(len(s.loaded())) * s.size
+	return sz, nil
+}
+
+// Truncate implements the fs.Truncator interface. Segments cannot be
+// partially truncated so the size is rounded up to a segment boundary.
+// Slots used by truncated segments are reused when segments are created.
+// The data file does not shrink.
+func (s *Store) Truncate(sz int64) (err error) {
+	if s.readonly {
+		return segments.ErrReadOnly
+	}
+
+	n := sz / s.size
+	if sz%s.size != 0 {
+		n++
+	}
+
+	s.segmx.Lock()
+	defer s.segmx.Unlock()
+
+	slots := s.loaded()
+	if n >= int64(len(slots)) {
+		return nil
+	}
+
+	if err := s.index.Truncate(n * entrySize); err != nil {
+		return err
+	}
+
+	s.free = append(s.free, slots[n:]...)
+	sort.Sort(int64s(s.free))
+
+	s.slots.Store(slots[:n])
+	atomic.StoreUint32(&s.dirty, 1)
+	return nil
+}
+
+// Close implements the io.Closer interface
+// The store lock is released after closing all files.
+func (s *Store) Close() (err error) {
+	if s.slots.Load() != nil {
+		if err := s.Sync(); err != nil {
+			return err
+		}
+	}
+
+	if err := s.index.Close(); err != nil {
+		return err
+	}
+
+	if err := s.data.Close(); err != nil {
+		return err
+	}
+
+	return s.lock.Close()
+}
+
+// load reads the extent index and finds free slots in the data file
+func (s *Store) load() (err error) {
+	info, err := s.data.Stat()
+	if err != nil {
+		return err
+	}
+
+	s.ncap = info.Size() / s.size
+
+	buf, err := ioutil.ReadAll(s.index)
+	if err != nil {
+		return err
+	}
+
+	used := make(map[int64]bool, len(buf)/entrySize)
+	slots := make([]int64, 0, len(buf)/entrySize)
+
+	for i := 0; i+entrySize <= len(buf); i += entrySize {
+		// slots are stored as slot + 1 so zero is never valid
+		slot := int64(binary.LittleEndian.Uint64(buf[i:])) - 1
+		if slot < 0 || slot >= s.ncap || used[slot] {
+			return ErrIndex
+		}
+
+		used[slot] = true
+		slots = append(slots, slot)
+	}
+
+	for slot := int64(0); slot < s.ncap; slot++ {
+		if !used[slot] {
+			s.free = append(s.free, slot)
+		}
+	}
+
+	s.slots.Store(slots)
+	return nil
+}
+
+// loaded returns slots of loaded segments without locking
+// The returned slice must not be modified.
+func (s *Store) loaded() (slots []int64) {
+	return s.slots.Load().([]int64)
+}
+
+// slot returns the slot of a segment. io.EOF is returned if it does not exist.
+func (s *Store) slot(i int64) (slot int64, err error) {
+	slots := s.loaded()
+	if i >= int64(len(slots)) {
+		return 0, io.EOF
+	}
+
+	return slots[i], nil
+}
+
+// ensure makes sure that segments upto given index exists and are valid.
+// This will check from current segment length upto given position.
+func (s *Store) ensure(n int64) (err error) {
+	// fast path
+	if n < int64(len(s.loaded())) {
+		return nil
+	}
+
+	// slow path
+	s.segmx.Lock()
+	defer s.segmx.Unlock()
+
+	loaded := s.loaded()
+	available := int64(len(loaded))
+	if n < available {
+		return nil
+	}
+
+	// readers may be using the loaded slice so a new one is used
+	// new segments are added after the end of loaded segments
+	slots := make([]int64, available, n+1)
+	copy(slots, loaded)
+
+	for i := available; i <= n; i++ {
+		slot, err := s.allocSlot()
+		if err != nil {
+			return err
+		}
+
+		buf := make([]byte, entrySize)
+		binary.LittleEndian.PutUint64(buf, uint64(slot+1))
+		if _, err := s.index.WriteAt(buf, i*entrySize); err != nil {
+			s.free = append([]int64{slot}, s.free...)
+			return err
+		}
+
+		slots = append(slots, slot)
+		s.slots.Store(slots)
+		atomic.StoreUint32(&s.dirty, 1)
+	}
+
+	return nil
+}
+
+// allocSlot returns a free slot for a new segment. The data file grows when
+// there are no free slots. Reused slots are cleared before using them.
+// This should be called while holding the segment mutex.
+func (s *Store) allocSlot() (slot int64, err error) {
+	if len(s.free) > 0 {
+		slot = s.free[0]
+		if err := s.clearSlot(slot); err != nil {
+			return 0, err
+		}
+
+		s.free = s.free[1:]
+		return slot, nil
+	}
+
+	ncap := s.ncap + s.grow
+	if err := fsutils.FallocateFile(s.data, ncap*s.size); err != nil {
+		return 0, err
+	}
+
+	for i := s.ncap + 1; i < ncap; i++ {
+		s.free = append(s.free, i)
+	}
+
+	slot = s.ncap
+	s.ncap = ncap
+	return slot, nil
+}
+
+// clearSlot fills a slot with zeroes
+func (s *Store) clearSlot(slot int64) (err error) {
+	buf := make([]byte, zeroChunk)
+	start := slot * s.size
+
+	for off := int64(0); off < s.size; {
+		p := buf
+		if rem := s.size - off; rem < int64(len(p)) {
+			p = p[:rem]
+		}
+
+		n, err := s.data.WriteAt(p, start+off)
+		if err != nil {
+			return err
+		}
+
+		off += int64(n)
+	}
+
+	return nil
+}
+
+// int64s implements sort.Interface
+type int64s []int64
+
+func (a int64s) Len() int           { return len(a) }
+func (a int64s) Less(i, j int) bool { return a[i] < a[j] }
+func (a int64s) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
@@ -0,0 +1,335 @@
+package segsingle
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kadirahq/go-tools/fs"
+	"github.com/kadirahq/go-tools/fsutils"
+	"github.com/kadirahq/go-tools/segments"
+)
+
+var (
+	tmpdir  = "/tmp/test-segsingle/"
+	tmpfile = tmpdir + "seg_"
+)
+
+func setup(t *testing.T) func() {
+	if err := os.RemoveAll(tmpdir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(tmpdir, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if err := os.RemoveAll(tmpdir); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestImpl(t *testing.T) {
+	// throws error if it doesn't
+	var _ segments.Store = &Store{}
+	var _ fs.SlicerAt = &Store{}
+}
+
+func TestOpen(t *testing.T) {
+	defer setup(t)()
+
+	s, err := Open(tmpfile, &Options{SegmentSize: 10, GrowSlots: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sz, err := s.Size(); err != nil {
+		t.Fatal(err)
+	} else if sz != 10 {
+		t.Fatal("wrong size")
+	}
+
+	if _, err := s.WriteAt([]byte("hello world!"), 25); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(tmpfile, &Options{SegmentSize: 20}); err != segments.ErrSegSize {
+		t.Fatal("expected ErrSegSize")
+	}
+
+	// all segments are in one data file
+	info, err := os.Stat(tmpfile + "data")
+	if err != nil {
+		t.Fatal(err)
+	} else if info.Size() != 40 {
+		t.Fatal("wrong data file size")
+	}
+
+	s, err = Open(tmpfile, &Options{SegmentSize: 10, GrowSlots: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	if sz, err := s.Size(); err != nil {
+		t.Fatal(err)
+	} else if sz != 40 {
+		t.Fatal("wrong size")
+	}
+
+	p := make([]byte, 12)
+	if _, err := s.ReadAt(p, 25); err != nil {
+		t.Fatal(err)
+	} else if string(p) != "hello world!" {
+		t.Fatal("wrong data")
+	}
+
+	if _, err := s.ReadAt(p, 35); err != io.EOF {
+		t.Fatal("expected io.EOF")
+	}
+
+	if _, err := Open(tmpfile, &Options{SegmentSize: 10}); err != fsutils.ErrLocked {
+		t.Fatal("expected ErrLocked")
+	}
+}
+
+func TestReaderWriter(t *testing.T) {
+	defer setup(t)()
+
+	s, err := New(tmpfile, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	data := []byte("abcdefghij")
+	if n, err := s.Write(data); err != nil {
+		t.Fatal(err)
+	} else if n != len(data) {
+		t.Fatal("wrong n")
+	}
+
+	if _, err := s.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	p := make([]byte, len(data))
+	if _, err := io.ReadFull(s, p); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(p, data) {
+		t.Fatal("wrong data")
+	}
+
+	if off, err := s.Seek(0, 2); err != nil {
+		t.Fatal(err)
+	} else if off != 12 {
+		t.Fatal("wrong offset")
+	}
+
+	if p, err := s.SliceAt(4, 2); err != nil {
+		t.Fatal(err)
+	} else if string(p) != "cdef" {
+		t.Fatal("wrong slice")
+	}
+
+	if err := s.Sync(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	defer setup(t)()
+
+	s, err := Open(tmpfile, &Options{SegmentSize: 10, GrowSlots: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	data := bytes.Repeat([]byte{1}, 40)
+	if _, err := s.WriteAt(data, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Truncate(15); err != nil {
+		t.Fatal(err)
+	}
+
+	if sz, err := s.Size(); err != nil {
+		t.Fatal(err)
+	} else if sz != 20 {
+		t.Fatal("wrong size")
+	}
+
+	// truncated slots are reused and cleared
+	if err := s.Ensure(25); err != nil {
+		t.Fatal(err)
+	}
+
+	p := make([]byte, 20)
+	if _, err := s.ReadAt(p, 20); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(p, make([]byte, 20)) {
+		t.Fatal("reused slots should be cleared")
+	}
+
+	info, err := os.Stat(tmpfile + "data")
+	if err != nil {
+		t.Fatal(err)
+	} else if info.Size() != 40 {
+		t.Fatal("data file should not grow")
+	}
+}
+
+func TestBadIndex(t *testing.T) {
+	defer setup(t)()
+
+	s, err := New(tmpfile, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the index points to a slot after the end of the data file
+	index := []byte{10, 0, 0, 0, 0, 0, 0, 0}
+	file, err := os.OpenFile(tmpfile+"index", os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := file.WriteAt(index, 8); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(tmpfile, 10); err != ErrIndex {
+		t.Fatal("expected ErrIndex")
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	defer setup(t)()
+
+	s, err := New(tmpfile, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.WriteAt([]byte("hello"), 12); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &Options{SegmentSize: 10, ReadOnly: true}
+	r1, err := Open(tmpfile, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer r1.Close()
+
+	// read-only stores can be shared
+	r2, err := Open(tmpfile, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer r2.Close()
+
+	p := make([]byte, 5)
+	if _, err := r2.ReadAt(p, 12); err != nil {
+		t.Fatal(err)
+	} else if string(p) != "hello" {
+		t.Fatal("wrong data")
+	}
+
+	if _, err := r1.WriteAt(p, 0); err != segments.ErrReadOnly {
+		t.Fatal("expected ErrReadOnly")
+	}
+
+	if err := r1.Truncate(0); err != segments.ErrReadOnly {
+		t.Fatal("expected ErrReadOnly")
+	}
+
+	if _, err := New(tmpfile, 10); err != fsutils.ErrLocked {
+		t.Fatal("expected ErrLocked")
+	}
+}
+
+func TestReadOnlyPerms(t *testing.T) {
+	defer setup(t)()
+
+	s, err := New(tmpfile, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.WriteAt([]byte("hello"), 12); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := filepath.Glob(tmpfile + "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// files and the directory without write permission
+	for _, name := range names {
+		if err := os.Chmod(name, 0444); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Chmod(tmpdir, 0555); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Chmod(tmpdir, 0777)
+
+	r, err := Open(tmpfile, &Options{SegmentSize: 10, ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := make([]byte, 5)
+	if _, err := r.ReadAt(p, 12); err != nil {
+		t.Fatal(err)
+	} else if string(p) != "hello" {
+		t.Fatal("wrong data")
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// read-only stores should not create files
+	if after, err := filepath.Glob(tmpfile + "*"); err != nil {
+		t.Fatal(err)
+	} else if len(after) != len(names) {
+		t.Fatal("files should not be created")
+	}
+}
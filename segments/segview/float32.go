@@ -0,0 +1,16 @@
+package segview
+
+import (
+	"github.com/kadirahq/go-tools/fs"
+	"github.com/kadirahq/go-tools/hybrid"
+)
+
+// SliceAtFloat32 returns n float32 values at given offset without copying them.
+func SliceAtFloat32(s fs.SlicerAt, n, off int64) (v []float32, err error) {
+	p, err := slice(s, n*hybrid.SzFloat32, off, hybrid.SzFloat32)
+	if err != nil {
+		return nil, err
+	}
+
+	return hybrid.MapFloat32s(p), nil
+}
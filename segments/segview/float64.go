@@ -0,0 +1,16 @@
+package segview
+
+import (
+	"github.com/kadirahq/go-tools/fs"
+	"github.com/kadirahq/go-tools/hybrid"
+)
+
+// SliceAtFloat64 returns n float64 values at given offset without copying them.
+func SliceAtFloat64(s fs.SlicerAt, n, off int64) (v []float64, err error) {
+	p, err := slice(s, n*hybrid.SzFloat64, off, hybrid.SzFloat64)
+	if err != nil {
+		return nil, err
+	}
+
+	return hybrid.MapFloat64s(p), nil
+}
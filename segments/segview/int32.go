@@ -0,0 +1,16 @@
+package segview
+
+import (
+	"github.com/kadirahq/go-tools/fs"
+	"github.com/kadirahq/go-tools/hybrid"
+)
+
+// SliceAtInt32 returns n int32 values at given offset without copying them.
+func SliceAtInt32(s fs.SlicerAt, n, off int64) (v []int32, err error) {
+	p, err := slice(s, n*hybrid.SzInt32, off, hybrid.SzInt32)
+	if err != nil {
+		return nil, err
+	}
+
+	return hybrid.MapInt32s(p), nil
+}
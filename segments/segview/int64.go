@@ -0,0 +1,16 @@
+package segview
+
+import (
+	"github.com/kadirahq/go-tools/fs"
+	"github.com/kadirahq/go-tools/hybrid"
+)
+
+// SliceAtInt64 returns n int64 values at given offset without copying them.
+func SliceAtInt64(s fs.SlicerAt, n, off int64) (v []int64, err error) {
+	p, err := slice(s, n*hybrid.SzInt64, off, hybrid.SzInt64)
+	if err != nil {
+		return nil, err
+	}
+
+	return hybrid.MapInt64s(p), nil
+}
@@ -0,0 +1,16 @@
+package segview
+
+import (
+	"github.com/kadirahq/go-tools/fs"
+	"github.com/kadirahq/go-tools/hybrid"
+)
+
+// SliceAt{{BG}} returns n {{SM}} values at given offset without copying them.
+func SliceAt{{BG}}(s fs.SlicerAt, n, off int64) (v []{{SM}}, err error) {
+	p, err := slice(s, n*hybrid.Sz{{BG}}, off, hybrid.Sz{{BG}})
+	if err != nil {
+		return nil, err
+	}
+
+	return hybrid.Map{{BG}}s(p), nil
+}
@@ -0,0 +1,30 @@
+package segview
+
+import (
+	"testing"
+
+	"github.com/kadirahq/go-tools/hybrid"
+	"github.com/kadirahq/go-tools/segments/segmem"
+)
+
+func TestSliceAt{{BG}}(t *testing.T) {
+	s := segmem.New(4 * hybrid.Sz{{BG}})
+
+	v, err := SliceAt{{BG}}(s, 3, hybrid.Sz{{BG}})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(v) != 3 {
+		t.Fatal("wrong length")
+	}
+
+	v[2] = 5
+	if w, err := SliceAt{{BG}}(s, 1, 3*hybrid.Sz{{BG}}); err != nil {
+		t.Fatal(err)
+	} else if w[0] != 5 {
+		t.Fatal("views should use store memory")
+	}
+
+	if _, err := SliceAt{{BG}}(s, 2, 3*hybrid.Sz{{BG}}); err != ErrBounds {
+		t.Fatal("expected ErrBounds")
+	}
+}
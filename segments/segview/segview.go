@@ -0,0 +1,45 @@
+// Package segview provides typed views of data in segment stores and other
+// stores which can slice their data without copying (ex. memory maps). Views
+// use the same memory as the store so any changes done to them are written
+// to the store. Types with multiple bytes use the byte order of the machine.
+//
+// Views are generated from templates in the scripts directory (see Makefile).
+package segview
+
+import (
+	"errors"
+
+	"github.com/kadirahq/go-tools/fs"
+)
+
+var (
+	// ErrAlign is returned when the offset is not a multiple of the value size.
+	ErrAlign = errors.New("offset is not aligned")
+
+	// ErrBounds is returned when values span multiple segments or
+	// when there are not enough bytes in the store for all values.
+	ErrBounds = errors.New("values are out of segment bounds")
+)
+
+// slice slices n bytes at given offset and makes sure the
+// offset is aligned and all requested bytes are available.
+func slice(s fs.SlicerAt, n, off, sz int64) (p []byte, err error) {
+	if off%sz != 0 {
+		return nil, ErrAlign
+	}
+
+	if n == 0 {
+		return nil, nil
+	}
+
+	p, err = s.SliceAt(n, off)
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(p)) != n {
+		return nil, ErrBounds
+	}
+
+	return p, nil
+}
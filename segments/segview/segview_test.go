@@ -0,0 +1,26 @@
+package segview
+
+import (
+	"testing"
+
+	"github.com/kadirahq/go-tools/segments/segmem"
+)
+
+func TestSlice(t *testing.T) {
+	s := segmem.New(16)
+
+	if _, err := SliceAtUint64(s, 1, 4); err != ErrAlign {
+		t.Fatal("expected ErrAlign")
+	}
+
+	if v, err := SliceAtUint64(s, 0, 8); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 0 {
+		t.Fatal("wrong length")
+	}
+
+	// values cannot span segments
+	if _, err := SliceAtUint32(s, 2, 12); err != ErrBounds {
+		t.Fatal("expected ErrBounds")
+	}
+}
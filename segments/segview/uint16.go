@@ -0,0 +1,16 @@
+package segview
+
+import (
+	"github.com/kadirahq/go-tools/fs"
+	"github.com/kadirahq/go-tools/hybrid"
+)
+
+// SliceAtUint16 returns n uint16 values at given offset without copying them.
+func SliceAtUint16(s fs.SlicerAt, n, off int64) (v []uint16, err error) {
+	p, err := slice(s, n*hybrid.SzUint16, off, hybrid.SzUint16)
+	if err != nil {
+		return nil, err
+	}
+
+	return hybrid.MapUint16s(p), nil
+}
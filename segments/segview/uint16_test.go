@@ -0,0 +1,30 @@
+package segview
+
+import (
+	"testing"
+
+	"github.com/kadirahq/go-tools/hybrid"
+	"github.com/kadirahq/go-tools/segments/segmem"
+)
+
+func TestSliceAtUint16(t *testing.T) {
+	s := segmem.New(4 * hybrid.SzUint16)
+
+	v, err := SliceAtUint16(s, 3, hybrid.SzUint16)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(v) != 3 {
+		t.Fatal("wrong length")
+	}
+
+	v[2] = 5
+	if w, err := SliceAtUint16(s, 1, 3*hybrid.SzUint16); err != nil {
+		t.Fatal(err)
+	} else if w[0] != 5 {
+		t.Fatal("views should use store memory")
+	}
+
+	if _, err := SliceAtUint16(s, 2, 3*hybrid.SzUint16); err != ErrBounds {
+		t.Fatal("expected ErrBounds")
+	}
+}
@@ -0,0 +1,16 @@
+package segview
+
+import (
+	"github.com/kadirahq/go-tools/fs"
+	"github.com/kadirahq/go-tools/hybrid"
+)
+
+// SliceAtUint32 returns n uint32 values at given offset without copying them.
+func SliceAtUint32(s fs.SlicerAt, n, off int64) (v []uint32, err error) {
+	p, err := slice(s, n*hybrid.SzUint32, off, hybrid.SzUint32)
+	if err != nil {
+		return nil, err
+	}
+
+	return hybrid.MapUint32s(p), nil
+}
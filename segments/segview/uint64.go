@@ -0,0 +1,16 @@
+package segview
+
+import (
+	"github.com/kadirahq/go-tools/fs"
+	"github.com/kadirahq/go-tools/hybrid"
+)
+
+// SliceAtUint64 returns n uint64 values at given offset without copying them.
+func SliceAtUint64(s fs.SlicerAt, n, off int64) (v []uint64, err error) {
+	p, err := slice(s, n*hybrid.SzUint64, off, hybrid.SzUint64)
+	if err != nil {
+		return nil, err
+	}
+
+	return hybrid.MapUint64s(p), nil
+}
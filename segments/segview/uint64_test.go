@@ -0,0 +1,30 @@
+package segview
+
+import (
+	"testing"
+
+	"github.com/kadirahq/go-tools/hybrid"
+	"github.com/kadirahq/go-tools/segments/segmem"
+)
+
+func TestSliceAtUint64(t *testing.T) {
+	s := segmem.New(4 * hybrid.SzUint64)
+
+	v, err := SliceAtUint64(s, 3, hybrid.SzUint64)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(v) != 3 {
+		t.Fatal("wrong length")
+	}
+
+	v[2] = 5
+	if w, err := SliceAtUint64(s, 1, 3*hybrid.SzUint64); err != nil {
+		t.Fatal(err)
+	} else if w[0] != 5 {
+		t.Fatal("views should use store memory")
+	}
+
+	if _, err := SliceAtUint64(s, 2, 3*hybrid.SzUint64); err != ErrBounds {
+		t.Fatal("expected ErrBounds")
+	}
+}
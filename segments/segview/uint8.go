@@ -0,0 +1,16 @@
+package segview
+
+import (
+	"github.com/kadirahq/go-tools/fs"
+	"github.com/kadirahq/go-tools/hybrid"
+)
+
+// SliceAtUint8 returns n uint8 values at given offset without copying them.
+func SliceAtUint8(s fs.SlicerAt, n, off int64) (v []uint8, err error) {
+	p, err := slice(s, n*hybrid.SzUint8, off, hybrid.SzUint8)
+	if err != nil {
+		return nil, err
+	}
+
+	return hybrid.MapUint8s(p), nil
+}
@@ -0,0 +1,93 @@
+package segments
+
+import (
+	"io"
+	"os"
+
+	"github.com/kadirahq/go-tools/fsutils"
+)
+
+// blockSize is the size of blocks checked by Sparsify
+const blockSize = 4096
+
+// IsZero returns true if all bytes in p are zeroes.
+func IsZero(p []byte) bool {
+	for _, b := range p {
+		if b != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Sparsify frees disk space used by blocks of a file which only have zeroes.
+// The file size does not change and freed blocks are read as zeroes. Ranges
+// which are already holes are not read. It does nothing if the platform or
+// the file system does not support punching holes in files.
+func Sparsify(path string) (err error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	extents, err := fsutils.DataExtents(file)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, blockSize)
+	for _, e := range extents {
+		err := sparsify(file, buf, e.Offset, e.Offset+e.Length)
+		if err == fsutils.ErrUnsupported {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sparsify punches holes in zero blocks between start and end offsets
+func sparsify(file *os.File, buf []byte, start, end int64) (err error) {
+	// start of the current range of zero blocks (-1 if not in a range)
+	zero := int64(-1)
+
+	for off := start; off < end; off += blockSize {
+		p := buf
+		if rem := end - off; rem < int64(len(p)) {
+			p = p[:rem]
+		}
+
+		n, err := file.ReadAt(p, off)
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		if n > 0 && IsZero(p[:n]) {
+			if zero < 0 {
+				zero = off
+			}
+		} else if zero >= 0 {
+			if err := fsutils.PunchHole(file, zero, off-zero); err != nil {
+				return err
+			}
+
+			zero = -1
+		}
+
+		if n < len(p) {
+			end = off + int64(n)
+			break
+		}
+	}
+
+	if zero >= 0 {
+		return fsutils.PunchHole(file, zero, end-zero)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package segments
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestIsZero(t *testing.T) {
+	if !IsZero(nil) || !IsZero(make([]byte, 10)) {
+		t.Fatal("expected true")
+	}
+
+	if IsZero([]byte{0, 0, 1}) {
+		t.Fatal("expected false")
+	}
+}
+
+func TestSparsify(t *testing.T) {
+	path := "/tmp/test-segments-sparse"
+	defer os.RemoveAll(path)
+
+	data := make([]byte, 5*blockSize+10)
+	copy(data[blockSize:], "hello")
+	copy(data[4*blockSize:], "world")
+
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Sparsify(path); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(after, data) {
+		t.Fatal("data should not change")
+	}
+}
+
+func TestSparsifyHoles(t *testing.T) {
+	path := "/tmp/test-segments-sparse"
+	defer os.RemoveAll(path)
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	// data blocks after a hole
+	if _, err := file.WriteAt([]byte("hello"), 8*blockSize); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := file.Truncate(10 * blockSize); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Sparsify(path); err != nil {
+		t.Fatal(err)
+	}
+
+	p := make([]byte, 5)
+	if _, err := file.ReadAt(p, 8*blockSize); err != nil {
+		t.Fatal(err)
+	} else if string(p) != "hello" {
+		t.Fatal("data should not change")
+	}
+}
@@ -0,0 +1,153 @@
+package segments
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	// frame header size (segment index, data length and checksum)
+	frameSize = 24
+
+	// segment index used to mark the end of a stream
+	endIndex = -1
+)
+
+var (
+	// ErrStream is returned when a segment stream has invalid frames.
+	ErrStream = errors.New("bad segment stream")
+
+	// ErrChecksum is returned when segment data in a stream does not match
+	// its checksum.
+	ErrChecksum = errors.New("segment checksum mismatch")
+)
+
+// Export writes all segments of a store to a single stream. The stream
+// starts with a header (same as the store header file) followed by a frame
+// for each segment and an end frame. A frame has the segment index, data
+// length and data checksum (8 bytes each, little endian) followed by data.
+// Segments which were removed from the store are not exported.
+func Export(s Store, size int64, w io.Writer) (err error) {
+	if _, err := w.Write(encodeHeader(size)); err != nil {
+		return err
+	}
+
+	total, err := s.Size()
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, size)
+	for i := int64(0); i*size < total; i++ {
+		if _, err := s.ReadAt(buf, i*size); err == ErrRemoved {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if err := writeFrame(w, i, buf); err != nil {
+			return err
+		}
+	}
+
+	return writeFrame(w, endIndex, nil)
+}
+
+// Import writes segments from a stream created with Export to a store at
+// the same offsets. Segment checksums are verified before writing data to
+// the store. ErrSegSize is returned if segment sizes are different and
+// io.ErrUnexpectedEOF is returned if the stream ends before the end frame.
+// The store is not synced after importing data.
+func Import(s Store, size int64, r io.Reader) (err error) {
+	head := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return unexpected(err)
+	}
+
+	if sz, err := decodeHeader(head); err != nil {
+		return err
+	} else if sz != size {
+		return ErrSegSize
+	}
+
+	buf := make([]byte, size)
+	for {
+		i, n, err := readFrame(r, buf)
+		if err != nil {
+			return err
+		}
+
+		if i == endIndex {
+			return nil
+		}
+
+		if _, err := s.WriteAt(buf[:n], i*size); err != nil {
+			return err
+		}
+	}
+}
+
+// writeFrame writes a frame with segment data
+func writeFrame(w io.Writer, i int64, p []byte) (err error) {
+	head := make([]byte, frameSize)
+	binary.LittleEndian.PutUint64(head[0:], uint64(i))
+	binary.LittleEndian.PutUint64(head[8:], uint64(len(p)))
+
+	if i != endIndex {
+		binary.LittleEndian.PutUint64(head[16:], Checksum(p))
+	}
+
+	if _, err := w.Write(head); err != nil {
+		return err
+	}
+
+	_, err = w.Write(p)
+	return err
+}
+
+// readFrame reads a frame into p and verifies its checksum
+// Returns the segment index and the length of segment data.
+func readFrame(r io.Reader, p []byte) (i int64, n int, err error) {
+	head := make([]byte, frameSize)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return 0, 0, unexpected(err)
+	}
+
+	i = int64(binary.LittleEndian.Uint64(head[0:]))
+	sz := binary.LittleEndian.Uint64(head[8:])
+	sum := binary.LittleEndian.Uint64(head[16:])
+
+	if i == endIndex {
+		if sz != 0 {
+			return 0, 0, ErrStream
+		}
+
+		return i, 0, nil
+	}
+
+	if i < 0 || sz > uint64(len(p)) {
+		return 0, 0, ErrStream
+	}
+
+	n = int(sz)
+	if _, err := io.ReadFull(r, p[:n]); err != nil {
+		return 0, 0, unexpected(err)
+	}
+
+	if Checksum(p[:n]) != sum {
+		return 0, 0, ErrChecksum
+	}
+
+	return i, n, nil
+}
+
+// unexpected converts io.EOF to io.ErrUnexpectedEOF
+// the stream should always end with an end frame
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
@@ -0,0 +1,72 @@
+package segments
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestFrames(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := writeFrame(buf, 3, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFrame(buf, endIndex, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	p := make([]byte, 10)
+
+	r := bytes.NewReader(data)
+	if i, n, err := readFrame(r, p); err != nil {
+		t.Fatal(err)
+	} else if i != 3 || string(p[:n]) != "hello" {
+		t.Fatal("wrong frame")
+	}
+
+	if i, _, err := readFrame(r, p); err != nil {
+		t.Fatal(err)
+	} else if i != endIndex {
+		t.Fatal("expected end frame")
+	}
+
+	if _, _, err := readFrame(r, p); err != io.ErrUnexpectedEOF {
+		t.Fatal("expected io.ErrUnexpectedEOF")
+	}
+
+	// frames larger than segments
+	if _, _, err := readFrame(bytes.NewReader(data), p[:4]); err != ErrStream {
+		t.Fatal("expected ErrStream")
+	}
+
+	// corrupt segment data
+	bad := append([]byte{}, data...)
+	bad[frameSize] = 'H'
+	if _, _, err := readFrame(bytes.NewReader(bad), p); err != ErrChecksum {
+		t.Fatal("expected ErrChecksum")
+	}
+
+	// truncated segment data
+	if _, _, err := readFrame(bytes.NewReader(data[:frameSize+2]), p); err != io.ErrUnexpectedEOF {
+		t.Fatal("expected io.ErrUnexpectedEOF")
+	}
+}
+
+func TestImportHeader(t *testing.T) {
+	r := bytes.NewReader(encodeHeader(10))
+	if err := Import(nil, 20, r); err != ErrSegSize {
+		t.Fatal("expected ErrSegSize")
+	}
+
+	r = bytes.NewReader([]byte("not a segment stream"))
+	if err := Import(nil, 10, r); err != ErrHeader {
+		t.Fatal("expected ErrHeader")
+	}
+
+	r = bytes.NewReader(encodeHeader(10))
+	if err := Import(nil, 10, r); err != io.ErrUnexpectedEOF {
+		t.Fatal("expected io.ErrUnexpectedEOF")
+	}
+}
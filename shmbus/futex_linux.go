@@ -0,0 +1,27 @@
+package shmbus
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	// futex operations (not private because the memory is shared)
+	futexWaitOp = 0
+	futexWakeOp = 1
+)
+
+// futexWait sleeps until the value at addr is changed and woken up,
+// the timeout is reached or the value is already different from val.
+func futexWait(addr *uint32, val uint32, timeout time.Duration) {
+	ts := syscall.NsecToTimespec(int64(timeout))
+	syscall.Syscall6(syscall.SYS_FUTEX, uintptr(unsafe.Pointer(addr)), futexWaitOp,
+		uintptr(val), uintptr(unsafe.Pointer(&ts)), 0, 0)
+}
+
+// futexWake wakes up all processes waiting on addr
+func futexWake(addr *uint32) {
+	syscall.Syscall6(syscall.SYS_FUTEX, uintptr(unsafe.Pointer(addr)), futexWakeOp,
+		uintptr(1<<31-1), 0, 0, 0)
+}
@@ -0,0 +1,27 @@
+//go:build !linux
+// +build !linux
+
+package shmbus
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// pollInterval is used to check for changes without futex support
+const pollInterval = 100 * time.Microsecond
+
+// futexWait polls the value at addr until it's different from val or the
+// timeout is reached. Futexes are only available on linux.
+func futexWait(addr *uint32, val uint32, timeout time.Duration) {
+	for start := time.Now(); time.Since(start) < timeout; {
+		if atomic.LoadUint32(addr) != val {
+			return
+		}
+
+		time.Sleep(pollInterval)
+	}
+}
+
+// futexWake does nothing because waiters are polling
+func futexWake(addr *uint32) {}
@@ -0,0 +1,264 @@
+// Package shmbus publishes messages from one producer to many subscribers
+// using a ring buffer in a memory mapped file. Subscribers can be in other
+// processes and each subscriber has its own cursor. The producer never waits
+// for subscribers, old messages are overwritten and subscribers which fall
+// behind get ErrOverrun. Use a file in a memory backed file system (ex.
+// /dev/shm) to avoid writing messages to the disk.
+//
+// Waiting subscribers are woken up with futexes on linux and poll for new
+// messages on other platforms.
+package shmbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/kadirahq/go-tools/hybrid"
+	"github.com/kadirahq/go-tools/memmap"
+)
+
+const (
+	// magic number used to check whether the file is initialized
+	magic = 0x3153554248534b47
+
+	// header has the magic number, capacity, head and reserved positions,
+	// a sequence number used with futexes and the number of waiters
+	headSize = 192
+
+	// message header has the payload size (padded to 8 bytes)
+	msgHead = 8
+
+	// size value used to mark padding at the end of the ring
+	padding = 0xffffffff
+
+	// number of checks before waiting with a futex
+	spins = 100
+
+	// maximum time to wait with a futex before checking the context
+	waitTimeout = 10 * time.Millisecond
+)
+
+var (
+	// ErrEmpty is returned when there are no new messages.
+	ErrEmpty = errors.New("no new messages")
+
+	// ErrOverrun is returned when messages were overwritten before the
+	// subscriber could read them. The subscriber skips to the newest message.
+	ErrOverrun = errors.New("subscriber fell behind the producer")
+
+	// ErrSize is returned when the message is too large for the ring.
+	ErrSize = errors.New("message is too large")
+
+	// ErrCap is returned when the capacity is not a power of two.
+	ErrCap = errors.New("capacity should be a power of two")
+
+	// ErrLayout is returned when the file has a different capacity.
+	ErrLayout = errors.New("bus file has a different layout")
+)
+
+// Bus is a ring buffer of messages in a memory map. Only one goroutine in
+// one process should publish messages to a bus file.
+type Bus struct {
+	mmap *memmap.Map
+	ring []byte
+	cap  uint64
+	head *uint64
+	resv *uint64
+	seq  *uint32
+	wait *uint32
+}
+
+// Open opens or creates a bus file with a ring of given capacity in bytes.
+// The capacity should be a power of two. Messages can be at most a quarter
+// of the capacity.
+func Open(path string, capacity int64) (b *Bus, err error) {
+	if capacity < 64 || capacity&(capacity-1) != 0 {
+		return nil, ErrCap
+	}
+
+	mmap, err := memmap.New(path, headSize+capacity)
+	if err == memmap.ErrBadSz {
+		return nil, ErrLayout
+	} else if err != nil {
+		return nil, err
+	}
+
+	data := mmap.Data
+	b = &Bus{
+		mmap: mmap,
+		ring: data[headSize:],
+		cap:  uint64(capacity),
+		head: hybrid.NewUint64(data[64:]).Value,
+		resv: hybrid.NewUint64(data[72:]).Value,
+		seq:  hybrid.NewUint32(data[128:]).Value,
+		wait: hybrid.NewUint32(data[132:]).Value,
+	}
+
+	mg := hybrid.NewUint64(data[0:]).Value
+	cp := hybrid.NewUint64(data[8:]).Value
+
+	if atomic.LoadUint64(mg) != magic {
+		*cp = uint64(capacity)
+		atomic.StoreUint64(mg, magic)
+	} else if *cp != uint64(capacity) {
+		mmap.Close()
+		return nil, ErrLayout
+	}
+
+	return b, nil
+}
+
+// Publish adds a message to the ring and wakes up waiting subscribers.
+func (b *Bus) Publish(p []byte) (err error) {
+	if uint64(len(p)) > b.cap/4 {
+		return ErrSize
+	}
+
+	n := msgHead + (uint64(len(p))+7)/8*8
+	head := atomic.LoadUint64(b.head)
+	start := head
+	off := head & (b.cap - 1)
+
+	// messages are not split at the end of the ring
+	if off+n > b.cap {
+		start += b.cap - off
+	}
+
+	// subscribers check this to find whether a message was overwritten
+	atomic.StoreUint64(b.resv, start+n)
+
+	if start != head {
+		binary.LittleEndian.PutUint32(b.ring[off:], padding)
+		off = 0
+	}
+
+	binary.LittleEndian.PutUint32(b.ring[off:], uint32(len(p)))
+	copy(b.ring[off+msgHead:], p)
+
+	atomic.StoreUint64(b.head, start+n)
+	atomic.AddUint32(b.seq, 1)
+
+	if atomic.LoadUint32(b.wait) > 0 {
+		futexWake(b.seq)
+	}
+
+	return nil
+}
+
+// Subscribe creates a subscriber which receives messages published after
+// this call.
+func (b *Bus) Subscribe() (s *Subscriber) {
+	return &Subscriber{bus: b, pos: atomic.LoadUint64(b.head)}
+}
+
+// SubscribeAt creates a subscriber which starts reading from given position.
+// Use the Position of a subscriber to continue from where it stopped.
+func (b *Bus) SubscribeAt(pos uint64) (s *Subscriber) {
+	return &Subscriber{bus: b, pos: pos}
+}
+
+// Close unmaps the bus file.
+func (b *Bus) Close() (err error) {
+	return b.mmap.Close()
+}
+
+// Subscriber reads messages from a bus using its own cursor.
+// A subscriber should only be used by one goroutine.
+type Subscriber struct {
+	bus *Bus
+	pos uint64
+	buf []byte
+}
+
+// Position returns the position of the next message to read.
+func (s *Subscriber) Position() (pos uint64) {
+	return s.pos
+}
+
+// TryNext returns the next message without waiting. The message is valid
+// until the next call. Returns ErrEmpty if there are no new messages.
+func (s *Subscriber) TryNext() (p []byte, err error) {
+	b := s.bus
+
+	for {
+		head := atomic.LoadUint64(b.head)
+		if s.pos == head {
+			return nil, ErrEmpty
+		}
+
+		if head-s.pos > b.cap {
+			s.pos = head
+			return nil, ErrOverrun
+		}
+
+		off := s.pos & (b.cap - 1)
+		sz := binary.LittleEndian.Uint32(b.ring[off:])
+
+		if sz == padding {
+			if !s.valid() {
+				return nil, ErrOverrun
+			}
+
+			s.pos += b.cap - off
+			continue
+		}
+
+		if uint64(sz) > b.cap/4 || off+msgHead+uint64(sz) > b.cap {
+			// the size was overwritten while reading it
+			// messages never wrap around the end of the ring
+			s.pos = atomic.LoadUint64(b.head)
+			return nil, ErrOverrun
+		}
+
+		s.buf = append(s.buf[:0], b.ring[off+msgHead:off+msgHead+uint64(sz)]...)
+		if !s.valid() {
+			return nil, ErrOverrun
+		}
+
+		s.pos += msgHead + (uint64(sz)+7)/8*8
+		return s.buf, nil
+	}
+}
+
+// Next returns the next message. If there are no new messages, it waits
+// until a message is published or the context is done.
+func (s *Subscriber) Next(ctx context.Context) (p []byte, err error) {
+	b := s.bus
+
+	for i := 0; ; i++ {
+		p, err := s.TryNext()
+		if err != ErrEmpty {
+			return p, err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if i < spins {
+			continue
+		}
+
+		atomic.AddUint32(b.wait, 1)
+		seq := atomic.LoadUint32(b.seq)
+		if atomic.LoadUint64(b.head) == s.pos {
+			futexWait(b.seq, seq, waitTimeout)
+		}
+		atomic.AddUint32(b.wait, ^uint32(0))
+	}
+}
+
+// valid checks whether the message at the current position may have been
+// overwritten by the producer. If so, the position is moved to the head.
+func (s *Subscriber) valid() bool {
+	b := s.bus
+	if atomic.LoadUint64(b.resv)-s.pos > b.cap {
+		s.pos = atomic.LoadUint64(b.head)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,168 @@
+package shmbus
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"testing"
+	"time"
+)
+
+var (
+	tmpfile = "/tmp/test-shmbus"
+)
+
+func setup(t *testing.T) func() {
+	if err := os.RemoveAll(tmpfile); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if err := os.RemoveAll(tmpfile); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func msg(i int) []byte {
+	p := make([]byte, 4+i%13)
+	binary.LittleEndian.PutUint32(p, uint32(i))
+	return p
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	defer setup(t)()
+
+	b, err := Open(tmpfile, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer b.Close()
+
+	if err := b.Publish(make([]byte, 65)); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	// a second view of the same file (ex. another process)
+	b2, err := Open(tmpfile, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer b2.Close()
+
+	s := b2.Subscribe()
+	if _, err := s.TryNext(); err != ErrEmpty {
+		t.Fatal("expected ErrEmpty")
+	}
+
+	// the ring wraps around many times
+	for i := 0; i < 100; i++ {
+		if err := b.Publish(msg(i)); err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := s.TryNext()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(p) != len(msg(i)) || binary.LittleEndian.Uint32(p) != uint32(i) {
+			t.Fatal("wrong message", i, p)
+		}
+	}
+
+	// the subscriber falls behind
+	for i := 0; i < 100; i++ {
+		b.Publish(msg(i))
+	}
+
+	if _, err := s.TryNext(); err != ErrOverrun {
+		t.Fatal("expected ErrOverrun", err)
+	}
+
+	if _, err := s.TryNext(); err != ErrEmpty {
+		t.Fatal("expected ErrEmpty after skipping", err)
+	}
+
+	if _, err := Open(tmpfile, 512); err != ErrLayout {
+		t.Fatal("expected ErrLayout")
+	}
+}
+
+func TestTornSize(t *testing.T) {
+	defer setup(t)()
+
+	b, err := Open(tmpfile, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer b.Close()
+
+	// a size which is overwritten while reading it can point past the
+	// end of the ring even if it's smaller than the maximum message size
+	s := b.SubscribeAt(240)
+	binary.LittleEndian.PutUint32(b.ring[240:], 60)
+	*b.head = 320
+	*b.resv = 320
+
+	if _, err := s.TryNext(); err != ErrOverrun {
+		t.Fatal("expected ErrOverrun", err)
+	}
+
+	if s.Position() != 320 {
+		t.Fatal("subscriber should skip to the head")
+	}
+}
+
+func TestWait(t *testing.T) {
+	defer setup(t)()
+
+	b, err := Open(tmpfile, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer b.Close()
+
+	subs := []*Subscriber{b.Subscribe(), b.Subscribe()}
+	done := make(chan error)
+
+	for _, s := range subs {
+		go func(s *Subscriber) {
+			for i := 0; i < 10; i++ {
+				p, err := s.Next(context.Background())
+				if err != nil {
+					done <- err
+					return
+				}
+
+				if binary.LittleEndian.Uint32(p) != uint32(i) {
+					t.Error("wrong message", i)
+				}
+			}
+
+			done <- nil
+		}(s)
+	}
+
+	for i := 0; i < 10; i++ {
+		time.Sleep(time.Millisecond)
+		b.Publish(msg(i))
+	}
+
+	for range subs {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := subs[0].Next(ctx); err != context.DeadlineExceeded {
+		t.Fatal("expected context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,381 @@
+// Package snapshot copies segment stores and their metadata files into a
+// directory described by a manifest file. The manifest has the size and the
+// checksum of each file which are verified when the snapshot is restored.
+// Snapshot directories can also be written to and read from tar streams.
+package snapshot
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/kadirahq/go-tools/checksum"
+	"github.com/kadirahq/go-tools/segments"
+)
+
+const (
+	// ManifestFile is the name of the manifest file in the snapshot directory.
+	ManifestFile = "MANIFEST"
+
+	// Version is the manifest version written by this package.
+	Version = 1
+
+	// size of buffers used when copying data
+	bufSize = 1024 * 1024
+)
+
+var (
+	// ErrChecksum is returned when a file does not match the manifest.
+	ErrChecksum = errors.New("snapshot file checksum mismatch")
+
+	// ErrVersion is returned when the manifest version is not supported.
+	ErrVersion = errors.New("unsupported snapshot version")
+
+	// ErrNotFound is returned when the snapshot does not have the entry.
+	ErrNotFound = errors.New("snapshot entry not found")
+
+	// ErrName is returned when entry names are empty or repeated.
+	ErrName = errors.New("invalid snapshot entry name")
+)
+
+// Source is a store to include in a snapshot. If Meta is set, the metadata
+// file on that path is also copied. Metadata should be synced before calling
+// Create (ex. using its Sync method) so that the file has the latest values.
+type Source struct {
+	Name  string
+	Store segments.Store
+	Meta  string
+}
+
+// File has the size and the checksum of a file in the snapshot.
+type File struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	CRC  uint32 `json:"crc"`
+}
+
+// Manifest describes the contents of a snapshot directory.
+type Manifest struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	Files   []File    `json:"files"`
+}
+
+// Snapshot is a snapshot directory with its manifest.
+type Snapshot struct {
+	Dir      string
+	Manifest *Manifest
+}
+
+// Create syncs all stores and copies them into given directory. Stores should
+// not be written to while the snapshot is being created to get a consistent
+// copy. Store data is saved as "<name>.data" and metadata as "<name>.meta".
+func Create(dir string, srcs []Source) (s *Snapshot, err error) {
+	names := map[string]bool{}
+	for _, src := range srcs {
+		if src.Name == "" || names[src.Name] {
+			return nil, ErrName
+		}
+
+		names[src.Name] = true
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{Version: Version, Created: time.Now()}
+
+	for _, src := range srcs {
+		if err := src.Store.Sync(); err != nil {
+			return nil, err
+		}
+
+		sz, err := src.Store.Size()
+		if err != nil {
+			return nil, err
+		}
+
+		r := io.NewSectionReader(src.Store, 0, sz)
+		f, err := write(path.Join(dir, src.Name+".data"), r)
+		if err != nil {
+			return nil, err
+		}
+
+		m.Files = append(m.Files, *f)
+
+		if src.Meta == "" {
+			continue
+		}
+
+		mfile, err := os.Open(src.Meta)
+		if err != nil {
+			return nil, err
+		}
+
+		f, err = write(path.Join(dir, src.Name+".meta"), mfile)
+		mfile.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		m.Files = append(m.Files, *f)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	// the manifest is written last to mark the snapshot as complete
+	if _, err := write(path.Join(dir, ManifestFile), bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{Dir: dir, Manifest: m}, nil
+}
+
+// Open reads the manifest of a snapshot directory.
+func Open(dir string) (s *Snapshot, err error) {
+	file, err := os.Open(path.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	m := &Manifest{}
+	if err := json.NewDecoder(file).Decode(m); err != nil {
+		return nil, err
+	}
+
+	if m.Version != Version {
+		return nil, ErrVersion
+	}
+
+	return &Snapshot{Dir: dir, Manifest: m}, nil
+}
+
+// Verify checks sizes and checksums of all files in the snapshot.
+func (s *Snapshot) Verify() (err error) {
+	for _, f := range s.Manifest.Files {
+		if err := s.copy(f, ioutil.Discard); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Restore verifies the entry and copies its data into the store. The store is
+// truncated to the size of the data. The metadata file (if any) is copied to
+// given path. Metadata should be opened again after restoring it.
+func (s *Snapshot) Restore(name string, store segments.Store, meta string) (err error) {
+	data, ok := s.file(name + ".data")
+	if !ok {
+		return ErrNotFound
+	}
+
+	if err := s.copy(data, ioutil.Discard); err != nil {
+		return err
+	}
+
+	mdata, hasMeta := s.file(name + ".meta")
+	if hasMeta && meta != "" {
+		if err := s.copy(mdata, ioutil.Discard); err != nil {
+			return err
+		}
+	}
+
+	if err := store.Truncate(data.Size); err != nil {
+		return err
+	}
+
+	w := &offsetWriter{w: store}
+	if err := s.copy(data, w); err != nil {
+		return err
+	}
+
+	if err := store.Sync(); err != nil {
+		return err
+	}
+
+	if !hasMeta || meta == "" {
+		return nil
+	}
+
+	tmp := meta + ".tmp"
+	mfile, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if err := s.copy(mdata, mfile); err != nil {
+		mfile.Close()
+		return err
+	}
+
+	if err := mfile.Sync(); err != nil {
+		mfile.Close()
+		return err
+	}
+
+	if err := mfile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, meta)
+}
+
+// WriteTar writes all snapshot files and the manifest to a tar stream.
+func (s *Snapshot) WriteTar(w io.Writer) (err error) {
+	tw := tar.NewWriter(w)
+
+	files := append([]File{}, s.Manifest.Files...)
+	files = append(files, File{Name: ManifestFile})
+	for _, f := range files {
+		file, err := os.Open(path.Join(s.Dir, f.Name))
+		if err != nil {
+			return err
+		}
+
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return err
+		}
+
+		hdr := &tar.Header{
+			Name:    f.Name,
+			Mode:    0644,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			file.Close()
+			return err
+		}
+
+		_, err = io.Copy(tw, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return tw.Close()
+}
+
+// ReadTar extracts a snapshot from a tar stream into given directory and
+// verifies all files with the manifest.
+func ReadTar(r io.Reader, dir string) (s *Snapshot, err error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		// do not allow writing files outside the directory
+		name := path.Base(path.Clean("/" + hdr.Name))
+		if name == "/" || name == "." {
+			return nil, ErrName
+		}
+
+		if _, err := write(path.Join(dir, name), tr); err != nil {
+			return nil, err
+		}
+	}
+
+	s, err = Open(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Verify(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// file finds a file in the manifest by its name
+func (s *Snapshot) file(name string) (f File, ok bool) {
+	for _, f := range s.Manifest.Files {
+		if f.Name == name {
+			return f, true
+		}
+	}
+
+	return File{}, false
+}
+
+// copy copies a snapshot file to the writer and checks its size and checksum
+func (s *Snapshot) copy(f File, w io.Writer) (err error) {
+	file, err := os.Open(path.Join(s.Dir, f.Name))
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	h := checksum.NewCRC32C()
+	n, err := io.CopyBuffer(io.MultiWriter(w, h), file, make([]byte, bufSize))
+	if err != nil {
+		return err
+	}
+
+	if n != f.Size || h.Sum32() != f.CRC {
+		return ErrChecksum
+	}
+
+	return nil
+}
+
+// write writes data from the reader to a file and syncs it
+func write(fpath string, r io.Reader) (f *File, err error) {
+	file, err := os.OpenFile(fpath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	h := checksum.NewCRC32C()
+	n, err := io.CopyBuffer(io.MultiWriter(file, h), r, make([]byte, bufSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := file.Sync(); err != nil {
+		return nil, err
+	}
+
+	f = &File{Name: path.Base(fpath), Size: n, CRC: h.Sum32()}
+	return f, nil
+}
+
+// offsetWriter writes sequentially to an io.WriterAt
+type offsetWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+func (o *offsetWriter) Write(p []byte) (n int, err error) {
+	n, err = o.w.WriteAt(p, o.off)
+	o.off += int64(n)
+	return n, err
+}
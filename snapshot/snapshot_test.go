@@ -0,0 +1,155 @@
+package snapshot
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/kadirahq/go-tools/segments/segfile"
+	"github.com/kadirahq/go-tools/segments/segmmap"
+)
+
+var (
+	tmpdir = "/tmp/test-snapshot/"
+)
+
+func setup(t *testing.T) func() {
+	if err := os.RemoveAll(tmpdir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(tmpdir, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if err := os.RemoveAll(tmpdir); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCreateRestore(t *testing.T) {
+	defer setup(t)()
+
+	s, err := segmmap.New(tmpdir+"seg_", 16, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	m, err := segfile.NewMetadata(tmpdir+"meta", 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("hello snapshot!!!")
+	if _, err := s.WriteAt(data, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	m.SetInt("key", 42)
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	size, err := s.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := Create(tmpdir+"snap", []Source{{Name: "a", Store: s, Meta: tmpdir + "meta"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(snap.Manifest.Files) != 2 || snap.Manifest.Files[0].Size != size {
+		t.Fatal("wrong manifest", snap.Manifest)
+	}
+
+	// change the store and metadata after creating the snapshot
+	if _, err := s.WriteAt(make([]byte, size+40), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	m.SetInt("key", 7)
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err = Open(tmpdir + "snap")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := snap.Restore("a", s, tmpdir+"meta"); err != nil {
+		t.Fatal(err)
+	}
+
+	if sz, _ := s.Size(); sz < size {
+		t.Fatal("wrong size", sz)
+	}
+
+	p := make([]byte, len(data))
+	if _, err := s.ReadAt(p, 0); err != nil || !bytes.Equal(p, data) {
+		t.Fatal("wrong data", string(p), err)
+	}
+
+	m, err = segfile.ReadMetadata(tmpdir + "meta")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ := m.Int("key"); v != 42 {
+		t.Fatal("wrong metadata", v)
+	}
+
+	if err := snap.Restore("b", s, ""); err != ErrNotFound {
+		t.Fatal("expected ErrNotFound")
+	}
+}
+
+func TestTarChecksum(t *testing.T) {
+	defer setup(t)()
+
+	s, err := segmmap.New(tmpdir+"seg_", 16, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	if _, err := s.WriteAt([]byte("data"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := Create(tmpdir+"snap", []Source{{Name: "a", Store: s}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := snap.WriteTar(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := buf.Bytes()
+	if _, err := ReadTar(bytes.NewReader(tarball), tmpdir+"copy"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(tmpdir+"copy/a.data", []byte("bad!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	copy, err := Open(tmpdir + "copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := copy.Verify(); err != ErrChecksum {
+		t.Fatal("expected ErrChecksum", err)
+	}
+}
@@ -0,0 +1,311 @@
+// Package tsblocks stores fixed-size points in time partitioned segment stores.
+// Each epoch (ex. one day) has its own segment store in a separate directory.
+// Inside an epoch, each point has a fixed position calculated from its time.
+// Old epochs can be expired by removing their directories completely.
+package tsblocks
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kadirahq/go-tools/segments/segmmap"
+)
+
+var (
+	// ErrSize is returned when the point size is wrong.
+	ErrSize = errors.New("wrong point size")
+
+	// ErrOptions is returned when options are not valid.
+	ErrOptions = errors.New("invalid options")
+)
+
+// Options for the block store
+type Options struct {
+	// Duration of each epoch.
+	Epoch time.Duration
+
+	// Duration represented by a single point.
+	// The epoch should be a multiple of the resolution.
+	Resolution time.Duration
+
+	// Size of a single point in bytes.
+	PointSize int64
+
+	// Size of each segment file in bytes.
+	SegmentSize int64
+}
+
+// Store is a collection of segment stores, one for each epoch.
+type Store struct {
+	dir    string
+	opts   *Options
+	epochs map[int64]*epoch
+	mutex  sync.Mutex
+}
+
+// epoch is the segment store of an epoch. Readers and writers hold the read
+// lock while using the store so it's not closed while memory is in use.
+type epoch struct {
+	store *segmmap.Store
+	mutex sync.RWMutex
+}
+
+// close waits until the epoch is not used and closes its store
+func (e *epoch) close() (err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.store.Close()
+}
+
+// Open opens or creates a block store in given directory.
+func Open(dir string, opts *Options) (s *Store, err error) {
+	if opts.Epoch <= 0 || opts.Resolution <= 0 || opts.PointSize <= 0 ||
+		opts.SegmentSize <= 0 || opts.Epoch%opts.Resolution != 0 {
+		return nil, ErrOptions
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s = &Store{
+		dir:    dir,
+		opts:   opts,
+		epochs: map[int64]*epoch{},
+	}
+
+	return s, nil
+}
+
+// Write writes a point to the epoch and position for given time.
+// Writing to the same position again replaces the point.
+func (s *Store) Write(ts time.Time, p []byte) (err error) {
+	if int64(len(p)) != s.opts.PointSize {
+		return ErrSize
+	}
+
+	start, off := s.locate(ts)
+	e, err := s.epoch(start, true)
+	if err != nil {
+		return err
+	}
+
+	defer e.mutex.RUnlock()
+
+	_, err = e.store.WriteAt(p, off)
+	return err
+}
+
+// Query calls the function for each point position from start time until the
+// end time (excluding end). Positions without data have zeroed points and
+// epochs which do not exist are skipped. Stops if the function returns false.
+// Points are not copied so they should not be used after the function returns.
+// Expire and Close wait for running queries so the function should not call
+// them (or Write to a new epoch) while they may be running.
+func (s *Store) Query(start, end time.Time, fn func(ts time.Time, p []byte) bool) (err error) {
+	res := s.opts.Resolution
+
+	for ts := start.Truncate(res); ts.Before(end); {
+		first, _ := s.locate(ts)
+		next := first.Add(s.opts.Epoch)
+		if next.After(end) {
+			next = end
+		}
+
+		e, err := s.epoch(first, false)
+		if err != nil {
+			return err
+		}
+
+		if e == nil {
+			ts = next
+			continue
+		}
+
+		// the epoch is released before using the next epoch
+		ok, err := s.query(e, ts, next, fn)
+		if err != nil || !ok {
+			return err
+		}
+
+		ts = next
+	}
+
+	return nil
+}
+
+// query calls the function for points of an epoch from start until end.
+// The read lock of the epoch should be held and it's released here.
+func (s *Store) query(e *epoch, start, end time.Time, fn func(ts time.Time, p []byte) bool) (ok bool, err error) {
+	defer e.mutex.RUnlock()
+
+	res := s.opts.Resolution
+	psz := s.opts.PointSize
+	zero := make([]byte, psz)
+	_, off := s.locate(start)
+
+	for ts := start; ts.Before(end); ts, off = ts.Add(res), off+psz {
+		p, err := e.store.SliceAt(psz, off)
+		if err == io.EOF {
+			// nothing has been written to this part of the epoch yet
+			p = zero
+		} else if err != nil {
+			return false, err
+		} else if int64(len(p)) != psz {
+			// the point spans two segments
+			p = make([]byte, psz)
+			if _, err := e.store.ReadAt(p, off); err != nil && err != io.EOF {
+				return false, err
+			}
+		}
+
+		if !fn(ts, p) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// Epochs returns start times of all existing epochs in order.
+func (s *Store) Epochs() (epochs []time.Time, err error) {
+	infos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	nsecs := []int64{}
+	for _, info := range infos {
+		if n, err := strconv.ParseInt(info.Name(), 10, 64); err == nil && info.IsDir() {
+			nsecs = append(nsecs, n)
+		}
+	}
+
+	sort.Slice(nsecs, func(i, j int) bool { return nsecs[i] < nsecs[j] })
+
+	for _, n := range nsecs {
+		epochs = append(epochs, time.Unix(0, n))
+	}
+
+	return epochs, nil
+}
+
+// Expire removes all epochs which end before or at given time.
+// It waits until epochs are not used by running writes and queries.
+func (s *Store) Expire(before time.Time) (err error) {
+	epochs, err := s.Epochs()
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, start := range epochs {
+		if start.Add(s.opts.Epoch).After(before) {
+			break
+		}
+
+		key := start.UnixNano()
+		if e, ok := s.epochs[key]; ok {
+			if err := e.close(); err != nil {
+				return err
+			}
+
+			delete(s.epochs, key)
+		}
+
+		if err := os.RemoveAll(s.path(key)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Sync syncs all open epochs to the disk.
+func (s *Store) Sync() (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, e := range s.epochs {
+		if err := e.store.Sync(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close closes all open epochs.
+// It waits until epochs are not used by running writes and queries.
+func (s *Store) Close() (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for key, e := range s.epochs {
+		if err := e.close(); err != nil {
+			return err
+		}
+
+		delete(s.epochs, key)
+	}
+
+	return nil
+}
+
+// locate returns the epoch start time and the point offset for given time
+func (s *Store) locate(ts time.Time) (start time.Time, off int64) {
+	start = ts.Truncate(s.opts.Epoch)
+	off = int64(ts.Sub(start)/s.opts.Resolution) * s.opts.PointSize
+	return start, off
+}
+
+// epoch returns the epoch which starts at given time with its read lock.
+// The caller should release the read lock after using the epoch store.
+// If the epoch does not exist, it's created only if create is true.
+func (s *Store) epoch(start time.Time, create bool) (e *epoch, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := start.UnixNano()
+	if e, ok := s.epochs[key]; ok {
+		e.mutex.RLock()
+		return e, nil
+	}
+
+	dir := s.path(key)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if !create {
+			return nil, nil
+		}
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	store, err := segmmap.New(path.Join(dir, "seg_"), s.opts.SegmentSize, false)
+	if err != nil {
+		return nil, err
+	}
+
+	e = &epoch{store: store}
+	e.mutex.RLock()
+	s.epochs[key] = e
+	return e, nil
+}
+
+// path returns the directory path for the epoch
+func (s *Store) path(key int64) string {
+	return path.Join(s.dir, strconv.FormatInt(key, 10))
+}
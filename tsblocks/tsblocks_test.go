@@ -0,0 +1,172 @@
+package tsblocks
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+var (
+	tmpdir = "/tmp/test-tsblocks"
+)
+
+func setup(t *testing.T) func() {
+	if err := os.RemoveAll(tmpdir); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if err := os.RemoveAll(tmpdir); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func open(t *testing.T) *Store {
+	s, err := Open(tmpdir, &Options{
+		Epoch:       time.Hour,
+		Resolution:  time.Minute,
+		PointSize:   3,
+		SegmentSize: 16,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestWriteQuery(t *testing.T) {
+	defer setup(t)()
+	s := open(t)
+
+	base := time.Unix(0, 0)
+	times := []time.Time{
+		base.Add(5 * time.Minute),
+		base.Add(59 * time.Minute),
+		base.Add(3*time.Hour + 2*time.Minute),
+	}
+
+	for i, ts := range times {
+		if err := s.Write(ts, []byte{1, 2, byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.Write(base, []byte{1}); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s = open(t)
+	defer s.Close()
+
+	found := map[int64]byte{}
+	count := 0
+	err := s.Query(base, base.Add(4*time.Hour), func(ts time.Time, p []byte) bool {
+		count++
+		if p[0] == 1 {
+			found[ts.UnixNano()] = p[2]
+		}
+
+		return true
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// epochs 1 and 2 do not exist
+	if count != 120 {
+		t.Fatal("wrong count", count)
+	}
+
+	if len(found) != len(times) {
+		t.Fatal("wrong points", found)
+	}
+
+	for i, ts := range times {
+		if v, ok := found[ts.UnixNano()]; !ok || v != byte(i) {
+			t.Fatal("wrong point", i)
+		}
+	}
+}
+
+func TestExpire(t *testing.T) {
+	defer setup(t)()
+	s := open(t)
+	defer s.Close()
+
+	base := time.Unix(0, 0)
+	for i := 0; i < 3; i++ {
+		ts := base.Add(time.Duration(i) * time.Hour)
+		if err := s.Write(ts, []byte{1, 2, 3}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.Expire(base.Add(90 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	epochs, err := s.Epochs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(epochs) != 2 || !epochs[0].Equal(base.Add(time.Hour)) {
+		t.Fatal("wrong epochs", epochs)
+	}
+
+	n := 0
+	s.Query(base, base.Add(time.Hour), func(ts time.Time, p []byte) bool {
+		n++
+		return true
+	})
+
+	if n != 0 {
+		t.Fatal("expired epoch was queried")
+	}
+}
+
+func TestExpireWhileQuerying(t *testing.T) {
+	defer setup(t)()
+	s := open(t)
+	defer s.Close()
+
+	base := time.Unix(0, 0)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			s.Write(base.Add(time.Duration(i)*time.Minute), []byte{1, 2, 3})
+			s.Expire(base.Add(time.Hour))
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		// points are read from memory which is unmapped if the epoch is
+		// expired while the query is running
+		err := s.Query(base, base.Add(time.Hour), func(ts time.Time, p []byte) bool {
+			if p[0] != 0 && p[0] != 1 {
+				t.Fatal("wrong point", p)
+			}
+			return true
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
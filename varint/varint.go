@@ -0,0 +1,128 @@
+// Package varint encodes integers with a variable number of bytes. Small
+// values use fewer bytes which makes it useful for sizes and deltas in
+// length prefixed record formats. Signed values are zigzag encoded so
+// small negative values are small too. The encoding is compatible with
+// Uvarint and Varint functions in encoding/binary.
+//
+// Functions work with byte slices (ex. sliced from a store) or directly
+// with stores (io.ReaderAt, io.WriterAt) and return the number of bytes
+// used so the next value can be found without an intermediate buffer.
+package varint
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	// MaxLen is the maximum number of bytes used by a 64 bit value.
+	MaxLen = binary.MaxVarintLen64
+)
+
+var (
+	// ErrSize is returned when the slice is too small for the value.
+	ErrSize = errors.New("slice is too small for the value")
+
+	// ErrOverflow is returned when the encoded value is larger than 64 bits.
+	ErrOverflow = errors.New("varint overflows 64 bits")
+)
+
+// Size returns the number of bytes used to encode the unsigned value.
+func Size(v uint64) (n int) {
+	for n = 1; v >= 0x80; n++ {
+		v >>= 7
+	}
+
+	return n
+}
+
+// Zigzag maps signed values to unsigned values so that values
+// with small absolute values have small encoded sizes.
+func Zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+// Unzigzag reverses Zigzag.
+func Unzigzag(u uint64) int64 {
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+// EncodeUvarint writes the value to the start of the
+// slice and returns the number of bytes written.
+func EncodeUvarint(d []byte, v uint64) (n int, err error) {
+	if len(d) < Size(v) {
+		return 0, ErrSize
+	}
+
+	return binary.PutUvarint(d, v), nil
+}
+
+// DecodeUvarint reads a value from the start of the
+// slice and returns the number of bytes read.
+func DecodeUvarint(d []byte) (v uint64, n int, err error) {
+	v, n = binary.Uvarint(d)
+	if n == 0 {
+		return 0, 0, ErrSize
+	} else if n < 0 {
+		return 0, 0, ErrOverflow
+	}
+
+	return v, n, nil
+}
+
+// EncodeVarint writes the zigzag encoded value to the
+// start of the slice and returns the number of bytes written.
+func EncodeVarint(d []byte, v int64) (n int, err error) {
+	return EncodeUvarint(d, Zigzag(v))
+}
+
+// DecodeVarint reads a zigzag encoded value from the
+// start of the slice and returns the number of bytes read.
+func DecodeVarint(d []byte) (v int64, n int, err error) {
+	u, n, err := DecodeUvarint(d)
+	return Unzigzag(u), n, err
+}
+
+// WriteUvarint writes the value at given offset
+// and returns the number of bytes written.
+func WriteUvarint(w io.WriterAt, v uint64, off int64) (n int, err error) {
+	var b [MaxLen]byte
+	n = binary.PutUvarint(b[:], v)
+	return w.WriteAt(b[:n], off)
+}
+
+// ReadUvarint reads a value at given offset and returns the number of
+// bytes read. Returns io.EOF if there are no bytes at the offset and
+// io.ErrUnexpectedEOF if the value is not complete.
+func ReadUvarint(r io.ReaderAt, off int64) (v uint64, n int, err error) {
+	var b [MaxLen]byte
+	c, err := r.ReadAt(b[:], off)
+	if err != nil && err != io.EOF {
+		return 0, 0, err
+	}
+
+	if c == 0 {
+		return 0, 0, io.EOF
+	}
+
+	v, n, err = DecodeUvarint(b[:c])
+	if err == ErrSize {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+
+	return v, n, err
+}
+
+// WriteVarint writes the zigzag encoded value at given
+// offset and returns the number of bytes written.
+func WriteVarint(w io.WriterAt, v int64, off int64) (n int, err error) {
+	return WriteUvarint(w, Zigzag(v), off)
+}
+
+// ReadVarint reads a zigzag encoded value at given
+// offset and returns the number of bytes read.
+func ReadVarint(r io.ReaderAt, off int64) (v int64, n int, err error) {
+	u, n, err := ReadUvarint(r, off)
+	return Unzigzag(u), n, err
+}
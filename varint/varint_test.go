@@ -0,0 +1,137 @@
+package varint
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/kadirahq/go-tools/segments/segmem"
+)
+
+func TestUvarint(t *testing.T) {
+	vals := []uint64{0, 1, 127, 128, 300, 1 << 35, math.MaxUint64}
+	d := make([]byte, MaxLen)
+
+	for _, v := range vals {
+		n, err := EncodeUvarint(d, v)
+		if err != nil {
+			t.Fatal(err)
+		} else if n != Size(v) {
+			t.Fatal("wrong size", v, n)
+		}
+
+		// compatible with encoding/binary
+		if u, m := binary.Uvarint(d); u != v || m != n {
+			t.Fatal("wrong encoding", v)
+		}
+
+		u, m, err := DecodeUvarint(d)
+		if err != nil {
+			t.Fatal(err)
+		} else if u != v || m != n {
+			t.Fatal("wrong value", v, u)
+		}
+	}
+
+	if _, err := EncodeUvarint(d[:1], 128); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	EncodeUvarint(d, 300)
+	if _, _, err := DecodeUvarint(d[:1]); err != ErrSize {
+		t.Fatal("expected ErrSize")
+	}
+
+	over := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}
+	if _, _, err := DecodeUvarint(over); err != ErrOverflow {
+		t.Fatal("expected ErrOverflow")
+	}
+}
+
+func TestVarint(t *testing.T) {
+	vals := []int64{0, -1, 1, -64, 64, math.MinInt64, math.MaxInt64}
+	d := make([]byte, MaxLen)
+
+	for _, v := range vals {
+		n, err := EncodeVarint(d, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if u, m := binary.Varint(d); u != v || m != n {
+			t.Fatal("wrong encoding", v)
+		}
+
+		if u, m, err := DecodeVarint(d); err != nil {
+			t.Fatal(err)
+		} else if u != v || m != n {
+			t.Fatal("wrong value", v, u)
+		}
+	}
+
+	if Size(Zigzag(-1)) != 1 || Size(Zigzag(-64)) != 1 {
+		t.Fatal("small negative values should be small")
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := segmem.New(8)
+
+	// values can span segments
+	var off int64 = 6
+	vals := []int64{-300, 1 << 40, 5}
+	for _, v := range vals {
+		n, err := WriteVarint(s, v, off)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		off += int64(n)
+	}
+
+	end := off
+	off = 6
+	for _, v := range vals {
+		u, n, err := ReadVarint(s, off)
+		if err != nil {
+			t.Fatal(err)
+		} else if u != v {
+			t.Fatal("wrong value", u)
+		}
+
+		off += int64(n)
+	}
+
+	if off != end {
+		t.Fatal("wrong offset")
+	}
+
+	// the last byte is at the end of the store
+	sz, _ := s.Size()
+	if _, err := WriteUvarint(s, 1, sz-1); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, n, err := ReadUvarint(s, sz-1); err != nil || v != 1 || n != 1 {
+		t.Fatal("wrong value", err)
+	}
+
+	if _, _, err := ReadUvarint(s, sz); err != io.EOF {
+		t.Fatal("expected io.EOF")
+	}
+
+	if _, err := WriteUvarint(s, 300, sz-1); err != nil {
+		t.Fatal(err)
+	}
+
+	// the store grows when writing after the end, truncate it to
+	// check a value which is cut at the end of the store
+	if err := s.Truncate(sz); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := ReadUvarint(s, sz-1); err != io.ErrUnexpectedEOF {
+		t.Fatal("expected io.ErrUnexpectedEOF", err)
+	}
+}
@@ -0,0 +1,288 @@
+// Package wal implements a write-ahead log over memory mapped segment files.
+// Records are framed with their size and a checksum (see segments.Appender)
+// so that partially written records at the end of the log are detected and
+// discarded after a crash.
+// Each record is identified by its sequence number which is the offset of the
+// record in the log so sequence numbers increase but they're not contiguous.
+// Sync calls are grouped together.
+package wal
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/kadirahq/go-tools/function"
+	"github.com/kadirahq/go-tools/segments"
+	"github.com/kadirahq/go-tools/segments/segfile"
+	"github.com/kadirahq/go-tools/segments/segmmap"
+)
+
+const (
+	// record header has the payload size and the checksum
+	headSize = segments.RecordHeaderSize
+
+	// metadata key used to store the first valid sequence number
+	headKey = "head"
+
+	// size of the metadata file
+	metaSize = 4096
+)
+
+var (
+	// ErrSeq is returned when the sequence number is not in the valid range
+	// of the log.
+	ErrSeq = errors.New("invalid log sequence number")
+
+	// ErrCorrupt is returned when a record in the log has a bad checksum.
+	ErrCorrupt = errors.New("corrupted log record")
+
+	// ErrTooLarge is returned when the record is larger than MaxRecordSize.
+	ErrTooLarge = errors.New("record is too large")
+
+	// ErrOptions is returned when an option has a negative value.
+	ErrOptions = errors.New("invalid log options")
+)
+
+// Options for the write-ahead log. Zero sizes are replaced with values
+// from DefaultOptions when opening the log.
+type Options struct {
+	// Size of each segment file in bytes.
+	SegmentSize int64
+
+	// Pending Sync calls are synced together at this interval.
+	// If zero, sync calls are only completed by calling Flush.
+	SyncInterval time.Duration
+
+	// Maximum size of a single record in bytes.
+	MaxRecordSize int64
+}
+
+// DefaultOptions is used when options are not given to Open.
+var DefaultOptions = &Options{
+	SegmentSize:   64 * 1024 * 1024,
+	SyncInterval:  10 * time.Millisecond,
+	MaxRecordSize: 16 * 1024 * 1024,
+}
+
+// Log is a write-ahead log. All methods are safe to use concurrently.
+type Log struct {
+	opts  *Options
+	store *segmmap.Store
+	app   *segments.Appender
+	meta  *segfile.Metadata
+	group *function.AutoGroup
+	head  int64
+	tail  int64
+	mutex sync.RWMutex
+}
+
+// Open opens or creates a write-ahead log in given directory. Records are
+// scanned from the head of the log to find where the log ends. A record
+// with a bad checksum is treated as the end of the log and it's cleared.
+func Open(dir string, opts *Options) (l *Log, err error) {
+	if opts == nil {
+		opts = DefaultOptions
+	}
+
+	if opts.SegmentSize < 0 || opts.SyncInterval < 0 || opts.MaxRecordSize < 0 {
+		return nil, ErrOptions
+	}
+
+	// copy options to fill missing values
+	o := *opts
+	opts = &o
+
+	if opts.SegmentSize == 0 {
+		opts.SegmentSize = DefaultOptions.SegmentSize
+	}
+
+	if opts.MaxRecordSize == 0 {
+		opts.MaxRecordSize = DefaultOptions.MaxRecordSize
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	meta, err := segfile.NewMetadata(path.Join(dir, "meta"), metaSize)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := segmmap.New(path.Join(dir, "seg_"), opts.SegmentSize, false)
+	if err != nil {
+		meta.Close()
+		return nil, err
+	}
+
+	l = &Log{opts: opts, store: store, meta: meta}
+	l.head, _ = meta.Int(headKey)
+
+	l.app, err = segments.NewAppender(store, l.head)
+	if err != nil {
+		store.Close()
+		meta.Close()
+		return nil, err
+	}
+
+	l.tail = l.app.Tail()
+
+	l.group = function.NewAutoGroup(l.sync, opts.SyncInterval, 0)
+	return l, nil
+}
+
+// Head returns the sequence number of the first record in the log.
+func (l *Log) Head() (seq uint64) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return uint64(l.head)
+}
+
+// Tail returns the sequence number which will be used for the next record.
+func (l *Log) Tail() (seq uint64) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return uint64(l.tail)
+}
+
+// Append writes a record at the end of the log and returns its sequence
+// number. The record may not be on the disk until the Sync method returns.
+func (l *Log) Append(entry []byte) (seq uint64, err error) {
+	if int64(len(entry)) > l.opts.MaxRecordSize {
+		return 0, ErrTooLarge
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	off, err := l.app.Append(entry)
+	if err != nil {
+		return 0, err
+	}
+
+	l.tail = l.app.Tail()
+	return uint64(off), nil
+}
+
+// Sync blocks until all records appended before calling it are on the disk.
+// Sync calls made by multiple goroutines are grouped into a single sync.
+func (l *Log) Sync() (err error) {
+	return l.group.Run()
+}
+
+// Flush syncs all records to the disk and releases pending Sync calls.
+func (l *Log) Flush() (err error) {
+	if err := l.group.Flush(); err != nil {
+		return err
+	}
+
+	return l.sync()
+}
+
+// Truncate discards all records before given sequence number. It should be
+// the sequence number of a record (ex. a checkpoint) or the tail of the log.
+// Segment files which only have discarded records are removed.
+func (l *Log) Truncate(seq uint64) (err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if seq < uint64(l.head) || seq > uint64(l.tail) {
+		return ErrSeq
+	}
+
+	off := int64(seq)
+	if off < l.tail {
+		if _, _, err := l.read(off); err != nil {
+			return ErrSeq
+		}
+	}
+
+	l.head = off
+	l.meta.SetInt(headKey, off)
+	if err := l.meta.Sync(); err != nil {
+		return err
+	}
+
+	// segments are removed after the new head is on the disk
+	return l.store.Remove(off)
+}
+
+// Iter returns an iterator which reads records starting from given sequence
+// number.
+func (l *Log) Iter(seq uint64) (it *Iterator) {
+	return &Iterator{log: l, next: seq}
+}
+
+// Close syncs all records to the disk and closes the log.
+func (l *Log) Close() (err error) {
+	if err := l.group.Stop(); err != nil {
+		return err
+	}
+
+	if err := l.sync(); err != nil {
+		return err
+	}
+
+	if err := l.store.Close(); err != nil {
+		return err
+	}
+
+	return l.meta.Close()
+}
+
+// sync syncs all segment files to the disk
+func (l *Log) sync() (err error) {
+	return l.store.Sync()
+}
+
+// read reads the record at given offset and returns the offset of the next
+// record. This does not check whether the offset is within the valid range.
+func (l *Log) read(off int64) (p []byte, next int64, err error) {
+	p, next, err = segments.ReadRecord(l.store, off)
+	if err == segments.ErrRecord {
+		return nil, 0, ErrCorrupt
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	if int64(len(p)) > l.opts.MaxRecordSize {
+		return nil, 0, ErrCorrupt
+	}
+
+	return p, next, nil
+}
+
+// Iterator reads records from the log in order.
+type Iterator struct {
+	log  *Log
+	next uint64
+}
+
+// Next returns the next record and its sequence number.
+// Returns io.EOF at the end of log.
+func (it *Iterator) Next() (seq uint64, p []byte, err error) {
+	l := it.log
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if it.next < uint64(l.head) || it.next > uint64(l.tail) {
+		return 0, nil, ErrSeq
+	}
+
+	if it.next == uint64(l.tail) {
+		return 0, nil, io.EOF
+	}
+
+	seq = it.next
+	p, next, err := l.read(int64(seq))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	it.next = uint64(next)
+	return seq, p, nil
+}
@@ -0,0 +1,237 @@
+package wal
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var (
+	tmpdir = "/tmp/test-wal/"
+	opts   = &Options{
+		SegmentSize:   64,
+		SyncInterval:  time.Millisecond,
+		MaxRecordSize: 1024,
+	}
+)
+
+func setup(t *testing.T) func() {
+	if err := os.RemoveAll(tmpdir); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if err := os.RemoveAll(tmpdir); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func record(i int) []byte {
+	return []byte("record-" + strconv.Itoa(i))
+}
+
+func TestAppend(t *testing.T) {
+	defer setup(t)()
+
+	l, err := Open(tmpdir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seqs := []uint64{}
+	for i := 0; i < 20; i++ {
+		seq, err := l.Append(record(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		seqs = append(seqs, seq)
+	}
+
+	if err := l.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = Open(tmpdir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+
+	it := l.Iter(seqs[5])
+	for i := 5; i < 20; i++ {
+		seq, p, err := it.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if seq != seqs[i] || !bytes.Equal(p, record(i)) {
+			t.Fatal("wrong record")
+		}
+	}
+
+	if _, _, err := it.Next(); err != io.EOF {
+		t.Fatal("wrong error")
+	}
+
+	if _, err := l.Append(record(20)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, p, err := it.Next(); err != nil || !bytes.Equal(p, record(20)) {
+		t.Fatal("should read new records")
+	}
+}
+
+func TestTornWrite(t *testing.T) {
+	defer setup(t)()
+
+	l, err := Open(tmpdir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := l.Append(record(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	seq, err := l.Append(record(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// corrupt the last record
+	if _, err := l.store.WriteAt([]byte{0}, int64(seq)+headSize); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = Open(tmpdir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+
+	if l.Tail() != seq {
+		t.Fatal("should discard corrupted record")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	defer setup(t)()
+
+	l, err := Open(tmpdir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := l.Append(record(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	seq, err := l.Append(record(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Truncate(seq + 1); err != ErrSeq {
+		t.Fatal("wrong error")
+	}
+
+	if err := l.Truncate(seq); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := l.Iter(0).Next(); err != ErrSeq {
+		t.Fatal("wrong error")
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = Open(tmpdir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+
+	if l.Head() != seq {
+		t.Fatal("wrong head")
+	}
+
+	if _, p, err := l.Iter(seq).Next(); err != nil || !bytes.Equal(p, record(1)) {
+		t.Fatal("wrong record")
+	}
+}
+
+func TestTruncateSegments(t *testing.T) {
+	defer setup(t)()
+
+	l, err := Open(tmpdir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+
+	var seq uint64
+	for i := 0; i < 20; i++ {
+		if seq, err = l.Append(record(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := l.Truncate(seq); err != nil {
+		t.Fatal(err)
+	}
+
+	// segments before the segment with the head are removed
+	seg := strconv.FormatInt(int64(seq)/opts.SegmentSize, 10)
+	if _, err := os.Stat(tmpdir + "seg_0"); !os.IsNotExist(err) {
+		t.Fatal("segment should be removed")
+	} else if _, err := os.Stat(tmpdir + "seg_" + seg); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, p, err := l.Iter(seq).Next(); err != nil || !bytes.Equal(p, record(19)) {
+		t.Fatal("wrong record")
+	}
+}
+
+func TestPartialOptions(t *testing.T) {
+	defer setup(t)()
+
+	if _, err := Open(tmpdir, &Options{SegmentSize: -1}); err != ErrOptions {
+		t.Fatal("expected ErrOptions")
+	}
+
+	l, err := Open(tmpdir, &Options{SyncInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+
+	if _, err := l.Append(record(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Sync(); err != nil {
+		t.Fatal(err)
+	}
+}